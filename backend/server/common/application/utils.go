@@ -7,6 +7,32 @@ func IsProd() bool {
 	return isSet
 }
 
+// Allows using an S3-compatible server like MinIO for staging data during local development
+func GetS3Endpoint() *string {
+	endpoint, isSet := os.LookupEnv("S3_ENDPOINT")
+	if !isSet {
+		return nil
+	}
+
+	return &endpoint
+}
+
+// Allows using DynamoDB Local during development and tests
+func GetDynamoDbEndpoint() *string {
+	endpoint, isSet := os.LookupEnv("DYNAMODB_ENDPOINT")
+	if !isSet {
+		return nil
+	}
+
+	return &endpoint
+}
+
+// Stops sending the deprecated webhook signature header once receivers have moved to the new one
+func IsLegacyWebhookSignatureDisabled() bool {
+	_, isSet := os.LookupEnv("DISABLE_LEGACY_WEBHOOK_SIGNATURE")
+	return isSet
+}
+
 func IsCloudBuild() bool {
 	_, isSet := os.LookupEnv("IS_CLOUD_BUILD")
 	return isSet
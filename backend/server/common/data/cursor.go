@@ -0,0 +1,225 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.fabra.io/server/common/errors"
+)
+
+const CURSOR_STATE_VERSION = 1
+
+const (
+	CURSOR_TIMESTAMP_TZ_FORMAT  = time.RFC3339Nano
+	CURSOR_TIMESTAMP_NTZ_FORMAT = "2006-01-02T15:04:05.999999999"
+	CURSOR_DATE_FORMAT          = "2006-01-02"
+)
+
+// formats that cursor values may be read in from sources or from cursors stored before they were typed
+var cursorTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// CursorState is the position of a sync's cursor, stored as the type of the cursor field and a canonical string
+// encoding of the value so it can be decoded without losing precision or timezone information.
+type CursorState struct {
+	Version   int       `json:"version"`
+	FieldType FieldType `json:"field_type"`
+	Value     string    `json:"value"`
+
+	// The primary key of the last row read, used to order rows that share the same cursor value
+	TieBreakerFieldType FieldType `json:"tie_breaker_field_type,omitempty"`
+	TieBreakerValue     *string   `json:"tie_breaker_value,omitempty"`
+
+	// The cursor value of each primary key already read within the lookback window, so re-read rows can be skipped
+	SeenKeys map[string]string `json:"seen_keys,omitempty"`
+}
+
+// Creates a cursor state from a value read from a source, encoding it canonically for the field type
+func NewCursorState(fieldType FieldType, value any) (*CursorState, error) {
+	encodedValue, err := encodeCursorValue(fieldType, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.NewCursorState)")
+	}
+
+	return &CursorState{
+		Version:   CURSOR_STATE_VERSION,
+		FieldType: fieldType,
+		Value:     encodedValue,
+	}, nil
+}
+
+// Parses a stored cursor state. Cursors stored before they were typed are plain strings, which may be quoted SQL
+// literals, so those are returned without a field type for the caller to fill in.
+func ParseCursorState(encoded string) CursorState {
+	var cursorState CursorState
+	if err := json.Unmarshal([]byte(encoded), &cursorState); err == nil && cursorState.Version > 0 {
+		return cursorState
+	}
+
+	if len(encoded) >= 2 && strings.HasPrefix(encoded, "'") && strings.HasSuffix(encoded, "'") {
+		encoded = strings.ReplaceAll(encoded[1:len(encoded)-1], "''", "'")
+	}
+
+	return CursorState{Value: encoded}
+}
+
+func (c CursorState) Encode() (string, error) {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "(data.CursorState.Encode)")
+	}
+
+	return string(encoded), nil
+}
+
+// Decodes the cursor value into a Go value of the cursor field type: int64 for integers, float64 for numbers,
+// time.Time for dates and timestamps, and string for everything else
+func (c CursorState) TypedValue() (any, error) {
+	value, err := decodeCursorValue(c.FieldType, c.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.CursorState.TypedValue)")
+	}
+
+	return value, nil
+}
+
+func (c *CursorState) SetTieBreaker(fieldType FieldType, value any) error {
+	encodedValue, err := encodeCursorValue(fieldType, value)
+	if err != nil {
+		return errors.Wrap(err, "(data.CursorState.SetTieBreaker)")
+	}
+
+	c.TieBreakerFieldType = fieldType
+	c.TieBreakerValue = &encodedValue
+	return nil
+}
+
+// Decodes the tie-breaker value the same way as TypedValue. Returns nil if the cursor has no tie-breaker.
+func (c CursorState) TypedTieBreakerValue() (any, error) {
+	if c.TieBreakerValue == nil {
+		return nil, nil
+	}
+
+	value, err := decodeCursorValue(c.TieBreakerFieldType, *c.TieBreakerValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.CursorState.TypedTieBreakerValue)")
+	}
+
+	return value, nil
+}
+
+// Returns the canonical encoding of a cursor value so values read from a source can be compared to stored ones
+func EncodeCursorValue(fieldType FieldType, value any) (string, error) {
+	encodedValue, err := encodeCursorValue(fieldType, value)
+	if err != nil {
+		return "", errors.Wrap(err, "(data.EncodeCursorValue)")
+	}
+
+	return encodedValue, nil
+}
+
+func decodeCursorValue(fieldType FieldType, value string) (any, error) {
+	switch fieldType {
+	case FieldTypeInteger:
+		decoded, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing integer cursor")
+		}
+		return decoded, nil
+	case FieldTypeNumber:
+		decoded, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing number cursor")
+		}
+		return decoded, nil
+	case FieldTypeDate, FieldTypeDateTimeTz, FieldTypeDateTimeNtz, FieldTypeTimestamp:
+		return parseCursorTime(value)
+	default:
+		return value, nil
+	}
+}
+
+func encodeCursorValue(fieldType FieldType, value any) (string, error) {
+	if value == nil {
+		return "", errors.New("cursor value is null")
+	}
+
+	switch fieldType {
+	case FieldTypeInteger:
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return fmt.Sprintf("%d", v), nil
+		case float64:
+			if v != math.Trunc(v) {
+				return "", errors.Newf("integer cursor has a fractional value: %v", v)
+			}
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		default:
+			encoded := strings.TrimSpace(fmt.Sprintf("%v", v))
+			if _, err := strconv.ParseInt(encoded, 10, 64); err != nil {
+				return "", errors.Wrap(err, "parsing integer cursor")
+			}
+			return encoded, nil
+		}
+	case FieldTypeNumber:
+		switch v := value.(type) {
+		case float32:
+			return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return fmt.Sprintf("%d", v), nil
+		default:
+			// keep decimal strings as is so numbers with more precision than a float64 are not rounded
+			encoded := strings.TrimSpace(fmt.Sprintf("%v", v))
+			if _, err := strconv.ParseFloat(encoded, 64); err != nil {
+				return "", errors.Wrap(err, "parsing number cursor")
+			}
+			return encoded, nil
+		}
+	case FieldTypeDate, FieldTypeDateTimeTz, FieldTypeDateTimeNtz, FieldTypeTimestamp:
+		var timeValue time.Time
+		switch v := value.(type) {
+		case time.Time:
+			timeValue = v
+		default:
+			var err error
+			timeValue, err = parseCursorTime(fmt.Sprintf("%v", v))
+			if err != nil {
+				return "", err
+			}
+		}
+
+		switch fieldType {
+		case FieldTypeDate:
+			return timeValue.Format(CURSOR_DATE_FORMAT), nil
+		case FieldTypeDateTimeNtz:
+			return timeValue.Format(CURSOR_TIMESTAMP_NTZ_FORMAT), nil
+		default:
+			return timeValue.Format(CURSOR_TIMESTAMP_TZ_FORMAT), nil
+		}
+	default:
+		return fmt.Sprintf("%v", value), nil
+	}
+}
+
+func parseCursorTime(value string) (time.Time, error) {
+	for _, layout := range cursorTimeLayouts {
+		timeValue, err := time.Parse(layout, value)
+		if err == nil {
+			return timeValue, nil
+		}
+	}
+
+	return time.Time{}, errors.Newf("unrecognized time cursor: %s", value)
+}
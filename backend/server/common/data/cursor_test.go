@@ -0,0 +1,68 @@
+package data_test
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/data"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CursorState", func() {
+	It("keeps the precision and timezone of timestamps", func() {
+		timestamp := time.Date(2023, 1, 2, 3, 4, 5, 123456789, time.FixedZone("", 5*60*60))
+		cursorState, err := data.NewCursorState(data.FieldTypeDateTimeTz, timestamp)
+		Expect(err).To(BeNil())
+		Expect(cursorState.Value).To(Equal("2023-01-02T03:04:05.123456789+05:00"))
+
+		cursorValue, err := cursorState.TypedValue()
+		Expect(err).To(BeNil())
+		Expect(cursorValue.(time.Time).Equal(timestamp)).To(BeTrue())
+	})
+
+	It("encodes values read as formatted strings canonically", func() {
+		cursorState, err := data.NewCursorState(data.FieldTypeDateTimeNtz, "2023-01-02 03:04:05.120")
+		Expect(err).To(BeNil())
+		Expect(cursorState.Value).To(Equal("2023-01-02T03:04:05.12"))
+
+		cursorState, err = data.NewCursorState(data.FieldTypeDate, "2023-01-02")
+		Expect(err).To(BeNil())
+		Expect(cursorState.Value).To(Equal("2023-01-02"))
+
+		cursorState, err = data.NewCursorState(data.FieldTypeInteger, int32(42))
+		Expect(err).To(BeNil())
+		Expect(cursorState.Value).To(Equal("42"))
+
+		cursorState, err = data.NewCursorState(data.FieldTypeNumber, "12345678901234567890.123")
+		Expect(err).To(BeNil())
+		Expect(cursorState.Value).To(Equal("12345678901234567890.123"))
+	})
+
+	It("rejects values that do not match the field type", func() {
+		_, err := data.NewCursorState(data.FieldTypeInteger, "abc")
+		Expect(err).ToNot(BeNil())
+
+		_, err = data.NewCursorState(data.FieldTypeDateTimeTz, nil)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("round trips through its encoding", func() {
+		cursorState, err := data.NewCursorState(data.FieldTypeInteger, int64(9007199254740993))
+		Expect(err).To(BeNil())
+
+		encoded, err := cursorState.Encode()
+		Expect(err).To(BeNil())
+		Expect(encoded).To(Equal(`{"version":1,"field_type":"INTEGER","value":"9007199254740993"}`))
+		Expect(data.ParseCursorState(encoded)).To(Equal(*cursorState))
+
+		cursorValue, err := cursorState.TypedValue()
+		Expect(err).To(BeNil())
+		Expect(cursorValue).To(Equal(int64(9007199254740993)))
+	})
+
+	It("parses legacy quoted cursors without a field type", func() {
+		Expect(data.ParseCursorState("'it''s'")).To(Equal(data.CursorState{Value: "it's"}))
+		Expect(data.ParseCursorState("42")).To(Equal(data.CursorState{Value: "42"}))
+	})
+})
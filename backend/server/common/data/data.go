@@ -24,6 +24,10 @@ const (
 	FieldTypeJson        FieldType = "JSON"
 )
 
+// Formats that sources return dates and times in, and that destinations load them from
+const TIMESTAMP_TZ_FORMAT = "2006-01-02 15:04:05.000-07:00"
+const TIMESTAMP_NTZ_FORMAT = "2006-01-02 15:04:05.000"
+
 type Field struct {
 	Name string    `json:"name"`
 	Type FieldType `json:"type"`
@@ -31,6 +35,48 @@ type Field struct {
 
 type Row []any
 
+// What a row sent to a destination does. Deleted rows only have their primary key set.
+type RowOperation string
+
+const (
+	RowOperationUpsert RowOperation = "upsert"
+	RowOperationDelete RowOperation = "delete"
+)
+
+// Approximate size of the row in memory, used to bound how much data is buffered in a batch
+func (r Row) EstimatedSize() int64 {
+	var size int64
+	for _, value := range r {
+		size += estimateValueSize(value)
+	}
+
+	return size
+}
+
+func estimateValueSize(value any) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case Row:
+		return v.EstimatedSize()
+	case []any:
+		return Row(v).EstimatedSize()
+	case map[string]any:
+		var size int64
+		for key, element := range v {
+			size += int64(len(key)) + estimateValueSize(element)
+		}
+		return size
+	default:
+		// numbers, booleans, and times
+		return 8
+	}
+}
+
 var ErrDone = errors.New("no more items in fabra iterator")
 
 type RowIterator interface {
@@ -0,0 +1,17 @@
+package data_test
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/data"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Row", func() {
+	It("estimates the size of nested values", func() {
+		row := data.Row{"abc", int64(1), nil, time.Now(), map[string]any{"key": "value", "list": []any{"a", true}}}
+		Expect(row.EstimatedSize()).To(Equal(int64(3 + 8 + 0 + 8 + (3 + 5) + (4 + 1 + 8))))
+	})
+})
@@ -0,0 +1,201 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.fabra.io/server/common/errors"
+)
+
+type FilterOperator string
+
+const (
+	FilterOperatorEqual              FilterOperator = "equal"
+	FilterOperatorNotEqual           FilterOperator = "not_equal"
+	FilterOperatorGreaterThan        FilterOperator = "greater_than"
+	FilterOperatorGreaterThanOrEqual FilterOperator = "greater_than_or_equal"
+	FilterOperatorLessThan           FilterOperator = "less_than"
+	FilterOperatorLessThanOrEqual    FilterOperator = "less_than_or_equal"
+	FilterOperatorIn                 FilterOperator = "in"
+	FilterOperatorNotIn              FilterOperator = "not_in"
+	FilterOperatorIsNull             FilterOperator = "is_null"
+	FilterOperatorIsNotNull          FilterOperator = "is_not_null"
+)
+
+// Limits on the size of a filter so a single sync can't build an unbounded query
+const MAX_FILTER_DEPTH = 5
+const MAX_FILTER_CONDITIONS = 100
+
+// Filter limits which source rows are read. A filter is either a condition comparing a single field to a value, or a
+// combination of nested filters where all (And) or any (Or) of them must match.
+type Filter struct {
+	Field    string         `json:"field,omitempty"`
+	Operator FilterOperator `json:"operator,omitempty"`
+	Value    any            `json:"value,omitempty"` // a list for in and not_in, and unset for is_null and is_not_null
+
+	// The type of the source field, recorded when the filter is validated so values can be bound with the right type
+	FieldType FieldType `json:"field_type,omitempty"`
+
+	And []Filter `json:"and,omitempty"`
+	Or  []Filter `json:"or,omitempty"`
+}
+
+func ParseFilter(encoded string) (*Filter, error) {
+	var filter Filter
+	err := json.Unmarshal([]byte(encoded), &filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.ParseFilter)")
+	}
+
+	return &filter, nil
+}
+
+func (f Filter) Encode() (string, error) {
+	encoded, err := json.Marshal(f)
+	if err != nil {
+		return "", errors.Wrap(err, "(data.Filter.Encode)")
+	}
+
+	return string(encoded), nil
+}
+
+func (f Filter) IsCondition() bool {
+	return len(f.And) == 0 && len(f.Or) == 0
+}
+
+// Checks the filter against the types of the source fields, recording the type of each field the filter uses
+func (f *Filter) Validate(fieldTypes map[string]FieldType) error {
+	numConditions := 0
+	return f.validate(fieldTypes, 1, &numConditions)
+}
+
+func (f *Filter) validate(fieldTypes map[string]FieldType, depth int, numConditions *int) error {
+	if depth > MAX_FILTER_DEPTH {
+		return errors.NewBadRequestf("filters can be nested at most %d levels deep", MAX_FILTER_DEPTH)
+	}
+
+	if !f.IsCondition() {
+		if len(f.And) > 0 && len(f.Or) > 0 {
+			return errors.NewBadRequest("a filter must use either and or or, not both")
+		}
+		if f.Field != "" || f.Operator != "" || f.Value != nil {
+			return errors.NewBadRequest("a filter must either be a condition or combine other filters, not both")
+		}
+
+		for i := range f.And {
+			if err := f.And[i].validate(fieldTypes, depth+1, numConditions); err != nil {
+				return err
+			}
+		}
+		for i := range f.Or {
+			if err := f.Or[i].validate(fieldTypes, depth+1, numConditions); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	*numConditions++
+	if *numConditions > MAX_FILTER_CONDITIONS {
+		return errors.NewBadRequestf("filters can have at most %d conditions", MAX_FILTER_CONDITIONS)
+	}
+
+	fieldType, ok := fieldTypes[f.Field]
+	if !ok {
+		return errors.NewBadRequestf("filter field %s is not in the source", f.Field)
+	}
+	f.FieldType = fieldType
+
+	switch f.Operator {
+	case FilterOperatorIsNull, FilterOperatorIsNotNull:
+		if f.Value != nil {
+			return errors.NewBadRequestf("%s filters do not take a value", f.Operator)
+		}
+		return nil
+	}
+
+	// nested values can only be checked for null, since sources compare them in different ways
+	if fieldType == FieldTypeJson || fieldType == FieldTypeArray {
+		return errors.NewBadRequestf("filter field %s can only be checked for null", f.Field)
+	}
+
+	switch f.Operator {
+	case FilterOperatorIn, FilterOperatorNotIn:
+		values, ok := f.Value.([]any)
+		if !ok || len(values) == 0 {
+			return errors.NewBadRequestf("%s filters must have a list of values", f.Operator)
+		}
+	case FilterOperatorEqual, FilterOperatorNotEqual:
+	case FilterOperatorGreaterThan, FilterOperatorGreaterThanOrEqual, FilterOperatorLessThan, FilterOperatorLessThanOrEqual:
+		if fieldType == FieldTypeBoolean {
+			return errors.NewBadRequestf("filter field %s can't be compared with %s", f.Field, f.Operator)
+		}
+	default:
+		return errors.NewBadRequestf("unknown filter operator: %s", f.Operator)
+	}
+
+	if _, err := f.TypedValue(); err != nil {
+		return errors.NewBadRequestf("invalid value for filter field %s: %v", f.Field, f.Value)
+	}
+
+	return nil
+}
+
+// Decodes the filter value into a Go value of the field type the same way as cursor values, with a list of values for
+// in and not_in
+func (f Filter) TypedValue() (any, error) {
+	switch f.Operator {
+	case FilterOperatorIsNull, FilterOperatorIsNotNull:
+		return nil, nil
+	case FilterOperatorIn, FilterOperatorNotIn:
+		values, ok := f.Value.([]any)
+		if !ok {
+			return nil, errors.Newf("(data.Filter.TypedValue) %s filter value is not a list", f.Operator)
+		}
+
+		typedValues := make([]any, len(values))
+		for i, value := range values {
+			typedValue, err := typedFilterValue(f.FieldType, value)
+			if err != nil {
+				return nil, errors.Wrap(err, "(data.Filter.TypedValue)")
+			}
+			typedValues[i] = typedValue
+		}
+
+		return typedValues, nil
+	default:
+		typedValue, err := typedFilterValue(f.FieldType, f.Value)
+		if err != nil {
+			return nil, errors.Wrap(err, "(data.Filter.TypedValue)")
+		}
+
+		return typedValue, nil
+	}
+}
+
+func typedFilterValue(fieldType FieldType, value any) (any, error) {
+	if value == nil {
+		return nil, errors.New("filter value is null")
+	}
+
+	switch fieldType {
+	case FieldTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		default:
+			return strconv.ParseBool(fmt.Sprintf("%v", v))
+		}
+	case FieldTypeString:
+		return fmt.Sprintf("%v", value), nil
+	default:
+		encoded, err := encodeCursorValue(fieldType, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeCursorValue(fieldType, encoded)
+	}
+}
@@ -0,0 +1,61 @@
+package data_test
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/data"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+	fieldTypes := map[string]data.FieldType{
+		"status":     data.FieldTypeString,
+		"seats":      data.FieldTypeInteger,
+		"active":     data.FieldTypeBoolean,
+		"created_at": data.FieldTypeTimestamp,
+	}
+
+	It("records field types and decodes values", func() {
+		filter, err := data.ParseFilter(`{"and":[
+			{"field":"status","operator":"in","value":["active","trialing"]},
+			{"or":[{"field":"seats","operator":"greater_than","value":"10"},{"field":"active","operator":"equal","value":true}]},
+			{"field":"created_at","operator":"greater_than_or_equal","value":"2023-01-01T00:00:00Z"}
+		]}`)
+		Expect(err).To(BeNil())
+		Expect(filter.Validate(fieldTypes)).To(Succeed())
+
+		Expect(filter.And[0].FieldType).To(Equal(data.FieldTypeString))
+		Expect(filter.And[0].TypedValue()).To(Equal([]any{"active", "trialing"}))
+		Expect(filter.And[1].Or[0].TypedValue()).To(Equal(int64(10)))
+		Expect(filter.And[1].Or[1].TypedValue()).To(Equal(true))
+		Expect(filter.And[2].TypedValue()).To(Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+		encoded, err := filter.Encode()
+		Expect(err).To(BeNil())
+		decoded, err := data.ParseFilter(encoded)
+		Expect(err).To(BeNil())
+		Expect(decoded.And[1].Or[0].FieldType).To(Equal(data.FieldTypeInteger))
+	})
+
+	It("rejects invalid filters", func() {
+		invalid := []string{
+			`{"field":"missing","operator":"equal","value":"a"}`,
+			`{"field":"seats","operator":"equal","value":"many"}`,
+			`{"field":"status","operator":"like","value":"a"}`,
+			`{"field":"status","operator":"in","value":"a"}`,
+			`{"field":"status","operator":"is_null","value":"a"}`,
+			`{"field":"active","operator":"greater_than","value":true}`,
+			`{"field":"status","operator":"equal"}`,
+			`{"and":[{"field":"status","operator":"is_null"}],"or":[{"field":"status","operator":"is_null"}]}`,
+			`{"and":[{"and":[{"and":[{"and":[{"and":[{"field":"status","operator":"is_null"}]}]}]}]}]}`,
+		}
+
+		for _, encoded := range invalid {
+			filter, err := data.ParseFilter(encoded)
+			Expect(err).To(BeNil())
+			Expect(filter.Validate(fieldTypes)).ToNot(Succeed(), encoded)
+		}
+	})
+})
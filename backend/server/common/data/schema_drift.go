@@ -0,0 +1,123 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.fabra.io/server/common/errors"
+)
+
+func ParseSchema(encoded string) (Schema, error) {
+	var schema Schema
+	err := json.Unmarshal([]byte(encoded), &schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.ParseSchema)")
+	}
+
+	return schema, nil
+}
+
+func EncodeSchema(schema Schema) (string, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return "", errors.Wrap(err, "(data.EncodeSchema)")
+	}
+
+	return string(encoded), nil
+}
+
+type SchemaChangeType string
+
+const (
+	SchemaChangeTypeFieldAdded   SchemaChangeType = "field_added"   // a source field that wasn't there when the sync last read
+	SchemaChangeTypeFieldRemoved SchemaChangeType = "field_removed" // a mapped field that is no longer in the source
+	SchemaChangeTypeTypeChanged  SchemaChangeType = "type_changed"  // a mapped field whose source type changed
+)
+
+type SchemaChangeResolution string
+
+const (
+	SchemaChangeResolutionIgnored SchemaChangeResolution = "ignored"
+	SchemaChangeResolutionCast    SchemaChangeResolution = "cast" // values are cast back to the mapped type as they are read
+	SchemaChangeResolutionFailed  SchemaChangeResolution = "failed"
+)
+
+// A difference between the live schema of a source and the schema recorded by the field mappings of a sync
+type SchemaChange struct {
+	Type       SchemaChangeType       `json:"type"`
+	FieldName  string                 `json:"field_name"`
+	MappedType FieldType              `json:"mapped_type,omitempty"` // unset for added fields
+	SourceType FieldType              `json:"source_type,omitempty"` // unset for removed fields
+	Resolution SchemaChangeResolution `json:"resolution"`
+}
+
+// The schema changes found before a run read from its source, and how each of them was handled
+type SchemaDriftReport struct {
+	Changes []SchemaChange `json:"changes"`
+}
+
+func ParseSchemaDriftReport(encoded string) (*SchemaDriftReport, error) {
+	var report SchemaDriftReport
+	err := json.Unmarshal([]byte(encoded), &report)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.ParseSchemaDriftReport)")
+	}
+
+	return &report, nil
+}
+
+func (r SchemaDriftReport) Encode() (string, error) {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return "", errors.Wrap(err, "(data.SchemaDriftReport.Encode)")
+	}
+
+	return string(encoded), nil
+}
+
+func (r SchemaDriftReport) Failed() bool {
+	for _, change := range r.Changes {
+		if change.Resolution == SchemaChangeResolutionFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Describes each change that failed the run, to show as the error of the run
+func (r SchemaDriftReport) FailureMessage() string {
+	var descriptions []string
+	for _, change := range r.Changes {
+		if change.Resolution != SchemaChangeResolutionFailed {
+			continue
+		}
+
+		switch change.Type {
+		case SchemaChangeTypeFieldAdded:
+			descriptions = append(descriptions, fmt.Sprintf("field %s was added", change.FieldName))
+		case SchemaChangeTypeFieldRemoved:
+			descriptions = append(descriptions, fmt.Sprintf("field %s was removed", change.FieldName))
+		case SchemaChangeTypeTypeChanged:
+			descriptions = append(descriptions, fmt.Sprintf("field %s changed from %s to %s", change.FieldName, change.MappedType, change.SourceType))
+		}
+	}
+
+	return fmt.Sprintf("the source schema no longer matches the field mappings: %s", strings.Join(descriptions, ", "))
+}
+
+// Whether every value of the from type can be cast to the to type without losing information. Integers aren't cast to
+// numbers since numbers are read as floats, which can't hold every integer.
+func IsSafeCast(from FieldType, to FieldType) bool {
+	switch to {
+	case FieldTypeString:
+		return from == FieldTypeInteger || from == FieldTypeNumber || from == FieldTypeBoolean
+	case FieldTypeJson:
+		return from == FieldTypeInteger || from == FieldTypeNumber || from == FieldTypeBoolean || from == FieldTypeArray
+	case FieldTypeDateTimeNtz:
+		return from == FieldTypeDate
+	default:
+		return false
+	}
+}
@@ -0,0 +1,36 @@
+package data_test
+
+import (
+	"go.fabra.io/server/common/data"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SchemaDriftReport", func() {
+	It("describes only the changes that failed the run", func() {
+		report := data.SchemaDriftReport{Changes: []data.SchemaChange{
+			{Type: data.SchemaChangeTypeFieldRemoved, FieldName: "name", MappedType: data.FieldTypeString, Resolution: data.SchemaChangeResolutionFailed},
+			{Type: data.SchemaChangeTypeTypeChanged, FieldName: "seats", MappedType: data.FieldTypeInteger, SourceType: data.FieldTypeString, Resolution: data.SchemaChangeResolutionFailed},
+			{Type: data.SchemaChangeTypeFieldAdded, FieldName: "notes", SourceType: data.FieldTypeString, Resolution: data.SchemaChangeResolutionIgnored},
+		}}
+
+		Expect(report.Failed()).To(BeTrue())
+		Expect(report.FailureMessage()).To(Equal("the source schema no longer matches the field mappings: field name was removed, field seats changed from INTEGER to STRING"))
+
+		encoded, err := report.Encode()
+		Expect(err).To(BeNil())
+		decoded, err := data.ParseSchemaDriftReport(encoded)
+		Expect(err).To(BeNil())
+		Expect(*decoded).To(Equal(report))
+	})
+
+	It("only casts types when no values can be lost", func() {
+		Expect(data.IsSafeCast(data.FieldTypeInteger, data.FieldTypeString)).To(BeTrue())
+		Expect(data.IsSafeCast(data.FieldTypeBoolean, data.FieldTypeJson)).To(BeTrue())
+		Expect(data.IsSafeCast(data.FieldTypeDate, data.FieldTypeDateTimeNtz)).To(BeTrue())
+		Expect(data.IsSafeCast(data.FieldTypeString, data.FieldTypeInteger)).To(BeFalse())
+		Expect(data.IsSafeCast(data.FieldTypeNumber, data.FieldTypeInteger)).To(BeFalse())
+		Expect(data.IsSafeCast(data.FieldTypeInteger, data.FieldTypeNumber)).To(BeFalse())
+	})
+})
@@ -0,0 +1,315 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.fabra.io/server/common/errors"
+)
+
+type TransformType string
+
+const (
+	TransformTypeCast         TransformType = "cast"
+	TransformTypeTrim         TransformType = "trim"
+	TransformTypeLowercase    TransformType = "lowercase"
+	TransformTypeUppercase    TransformType = "uppercase"
+	TransformTypeRegexExtract TransformType = "regex_extract"
+	TransformTypeDefault      TransformType = "default"
+	TransformTypeValueMap     TransformType = "value_map"
+	TransformTypeTimezone     TransformType = "timezone"
+	TransformTypeHash         TransformType = "hash"
+)
+
+// Transform changes a source value before it is written to the destination. Each field mapping can apply several
+// transforms in order, each taking the output of the previous one.
+type Transform struct {
+	Type TransformType `json:"type"`
+
+	// cast converts to this type, and value_map converts mapped values to this type if it is set
+	ToType FieldType `json:"to_type,omitempty"`
+	// regex_extract keeps the first capture group, or the whole match if the pattern has no groups
+	Pattern string `json:"pattern,omitempty"`
+	// default replaces nulls with this value
+	Value any `json:"value,omitempty"`
+	// value_map replaces values by their string form, keeping values that aren't in the map
+	Values map[string]any `json:"values,omitempty"`
+	// timezone converts times to this IANA timezone
+	Timezone string `json:"timezone,omitempty"`
+	// hash prefixes values with this salt before hashing them with SHA-256
+	Salt string `json:"salt,omitempty"`
+}
+
+func ParseTransforms(encoded string) ([]Transform, error) {
+	var transforms []Transform
+	err := json.Unmarshal([]byte(encoded), &transforms)
+	if err != nil {
+		return nil, errors.Wrap(err, "(data.ParseTransforms)")
+	}
+
+	return transforms, nil
+}
+
+func EncodeTransforms(transforms []Transform) (string, error) {
+	encoded, err := json.Marshal(transforms)
+	if err != nil {
+		return "", errors.Wrap(err, "(data.EncodeTransforms)")
+	}
+
+	return string(encoded), nil
+}
+
+// FieldTransformer applies the transforms of a single field mapping to each value read for that field
+type FieldTransformer struct {
+	InputType  FieldType
+	OutputType FieldType
+	steps      []transformStep
+}
+
+type transformStep struct {
+	transform  Transform
+	inputType  FieldType
+	outputType FieldType
+	regex      *regexp.Regexp
+	location   *time.Location
+	values     map[string]any
+}
+
+// Checks the transforms can be applied in order to values of the input type, and prepares them to be applied
+func NewFieldTransformer(inputType FieldType, transforms []Transform) (*FieldTransformer, error) {
+	transformer := FieldTransformer{InputType: inputType, OutputType: inputType}
+	for _, transform := range transforms {
+		step, err := newTransformStep(transform, transformer.OutputType)
+		if err != nil {
+			return nil, err
+		}
+
+		transformer.steps = append(transformer.steps, *step)
+		transformer.OutputType = step.outputType
+	}
+
+	return &transformer, nil
+}
+
+func newTransformStep(transform Transform, inputType FieldType) (*transformStep, error) {
+	step := transformStep{transform: transform, inputType: inputType, outputType: inputType}
+	switch transform.Type {
+	case TransformTypeCast:
+		switch transform.ToType {
+		case FieldTypeString, FieldTypeInteger, FieldTypeNumber, FieldTypeBoolean, FieldTypeDate, FieldTypeDateTimeTz,
+			FieldTypeDateTimeNtz, FieldTypeTimestamp, FieldTypeJson, FieldTypeArray:
+			step.outputType = transform.ToType
+		default:
+			return nil, errors.NewBadRequestf("cannot cast to %s", transform.ToType)
+		}
+	case TransformTypeTrim, TransformTypeLowercase, TransformTypeUppercase:
+		if inputType != FieldTypeString {
+			return nil, errors.NewBadRequestf("%s can only be applied to strings, not %s", transform.Type, inputType)
+		}
+	case TransformTypeRegexExtract:
+		if inputType != FieldTypeString {
+			return nil, errors.NewBadRequestf("%s can only be applied to strings, not %s", transform.Type, inputType)
+		}
+
+		regex, err := regexp.Compile(transform.Pattern)
+		if err != nil {
+			return nil, errors.NewBadRequestf("invalid pattern %s: %v", transform.Pattern, err)
+		}
+		step.regex = regex
+	case TransformTypeDefault:
+		if transform.Value == nil {
+			return nil, errors.NewBadRequest("default transforms must have a value")
+		}
+
+		value, err := castValue(transform.Value, inputType)
+		if err != nil {
+			return nil, errors.NewBadRequestf("default value %v is not a valid %s", transform.Value, inputType)
+		}
+		step.values = map[string]any{"": value}
+	case TransformTypeValueMap:
+		if transform.ToType != "" {
+			step.outputType = transform.ToType
+		}
+
+		step.values = map[string]any{}
+		for key, value := range transform.Values {
+			mappedValue, err := castValue(value, step.outputType)
+			if err != nil {
+				return nil, errors.NewBadRequestf("mapped value %v is not a valid %s", value, step.outputType)
+			}
+			step.values[key] = mappedValue
+		}
+	case TransformTypeTimezone:
+		switch inputType {
+		case FieldTypeDateTimeTz, FieldTypeTimestamp:
+		default:
+			return nil, errors.NewBadRequestf("%s can only be applied to times with a timezone, not %s", transform.Type, inputType)
+		}
+
+		location, err := time.LoadLocation(transform.Timezone)
+		if err != nil || transform.Timezone == "" {
+			return nil, errors.NewBadRequestf("unknown timezone: %s", transform.Timezone)
+		}
+		step.location = location
+	case TransformTypeHash:
+		switch inputType {
+		case FieldTypeJson, FieldTypeArray:
+			return nil, errors.NewBadRequestf("%s can't be applied to %s", transform.Type, inputType)
+		}
+		step.outputType = FieldTypeString
+	default:
+		return nil, errors.NewBadRequestf("unknown transform: %s", transform.Type)
+	}
+
+	return &step, nil
+}
+
+// Applies each transform in order. Times are returned in the same string formats that sources are read in.
+func (t FieldTransformer) Apply(value any) (any, error) {
+	var err error
+	for _, step := range t.steps {
+		value, err = step.apply(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return formatTransformedValue(value, t.OutputType), nil
+}
+
+func (s transformStep) apply(value any) (any, error) {
+	if value == nil {
+		if s.transform.Type == TransformTypeDefault {
+			return s.values[""], nil
+		}
+		return nil, nil
+	}
+
+	switch s.transform.Type {
+	case TransformTypeCast:
+		return castValue(value, s.outputType)
+	case TransformTypeTrim:
+		return strings.TrimSpace(fmt.Sprintf("%v", value)), nil
+	case TransformTypeLowercase:
+		return strings.ToLower(fmt.Sprintf("%v", value)), nil
+	case TransformTypeUppercase:
+		return strings.ToUpper(fmt.Sprintf("%v", value)), nil
+	case TransformTypeRegexExtract:
+		match := s.regex.FindStringSubmatch(fmt.Sprintf("%v", value))
+		if match == nil {
+			return nil, nil
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	case TransformTypeDefault:
+		return value, nil
+	case TransformTypeValueMap:
+		if mapped, ok := s.values[stringValue(value, s.inputType)]; ok {
+			return mapped, nil
+		}
+		return castValue(value, s.outputType)
+	case TransformTypeTimezone:
+		timeValue, err := castValue(value, s.inputType)
+		if err != nil {
+			return nil, err
+		}
+		return timeValue.(time.Time).In(s.location), nil
+	case TransformTypeHash:
+		hash := sha256.Sum256([]byte(s.transform.Salt + stringValue(value, s.inputType)))
+		return hex.EncodeToString(hash[:]), nil
+	default:
+		return nil, errors.Newf("unknown transform: %s", s.transform.Type)
+	}
+}
+
+// Converts the value to the Go type used for the field type: strings, int64, float64, bool, time.Time for dates and
+// times, and decoded JSON for JSON and arrays
+func castValue(value any, fieldType FieldType) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case FieldTypeString:
+		return stringValue(value, ""), nil
+	case FieldTypeBoolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+
+		converted, err := strconv.ParseBool(strings.TrimSpace(fmt.Sprintf("%v", value)))
+		if err != nil {
+			return nil, errors.Newf("%v is not a valid %s", value, fieldType)
+		}
+		return converted, nil
+	case FieldTypeJson, FieldTypeArray:
+		converted := value
+		if v, ok := value.(string); ok {
+			if err := json.Unmarshal([]byte(v), &converted); err != nil {
+				return nil, errors.Newf("%v is not a valid %s", value, fieldType)
+			}
+		}
+
+		if _, ok := converted.([]any); fieldType == FieldTypeArray && !ok {
+			return nil, errors.Newf("%v is not a valid %s", value, fieldType)
+		}
+		return converted, nil
+	case FieldTypeInteger, FieldTypeNumber, FieldTypeDate, FieldTypeDateTimeTz, FieldTypeDateTimeNtz, FieldTypeTimestamp:
+		if _, ok := value.(bool); ok {
+			return nil, errors.Newf("%v is not a valid %s", value, fieldType)
+		}
+
+		encoded, err := encodeCursorValue(fieldType, value)
+		if err != nil {
+			return nil, errors.Newf("%v is not a valid %s", value, fieldType)
+		}
+
+		converted, err := decodeCursorValue(fieldType, encoded)
+		if err != nil {
+			return nil, errors.Newf("%v is not a valid %s", value, fieldType)
+		}
+		return converted, nil
+	default:
+		return value, nil
+	}
+}
+
+func stringValue(value any, fieldType FieldType) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return formatTransformedValue(v, fieldType).(string)
+	case map[string]any, []any:
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			return string(encoded)
+		}
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func formatTransformedValue(value any, fieldType FieldType) any {
+	timeValue, ok := value.(time.Time)
+	if !ok {
+		return value
+	}
+
+	switch fieldType {
+	case FieldTypeDate:
+		return timeValue.Format(CURSOR_DATE_FORMAT)
+	case FieldTypeDateTimeNtz:
+		return timeValue.Format(TIMESTAMP_NTZ_FORMAT)
+	default:
+		return timeValue.Format(TIMESTAMP_TZ_FORMAT)
+	}
+}
@@ -0,0 +1,81 @@
+package data_test
+
+import (
+	"go.fabra.io/server/common/data"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FieldTransformer", func() {
+	apply := func(inputType data.FieldType, transforms []data.Transform, value any) (any, error) {
+		transformer, err := data.NewFieldTransformer(inputType, transforms)
+		Expect(err).To(BeNil())
+		return transformer.Apply(value)
+	}
+
+	It("applies transforms in order", func() {
+		transformer, err := data.NewFieldTransformer(data.FieldTypeString, []data.Transform{
+			{Type: data.TransformTypeTrim},
+			{Type: data.TransformTypeRegexExtract, Pattern: `^(\d+)-`},
+			{Type: data.TransformTypeCast, ToType: data.FieldTypeInteger},
+			{Type: data.TransformTypeDefault, Value: 0},
+		})
+		Expect(err).To(BeNil())
+		Expect(transformer.OutputType).To(Equal(data.FieldTypeInteger))
+		Expect(transformer.Apply("  42-abc ")).To(Equal(int64(42)))
+		Expect(transformer.Apply("abc")).To(Equal(int64(0)))
+		Expect(transformer.Apply(nil)).To(Equal(int64(0)))
+	})
+
+	It("changes the case of strings", func() {
+		Expect(apply(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeLowercase}}, "Active")).To(Equal("active"))
+		Expect(apply(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeUppercase}}, "Active")).To(Equal("ACTIVE"))
+	})
+
+	It("maps enum values and keeps unmapped values", func() {
+		transforms := []data.Transform{{Type: data.TransformTypeValueMap, ToType: data.FieldTypeString, Values: map[string]any{"1": "active", "2": "churned"}}}
+		Expect(apply(data.FieldTypeInteger, transforms, int64(2))).To(Equal("churned"))
+		Expect(apply(data.FieldTypeInteger, transforms, int64(3))).To(Equal("3"))
+	})
+
+	It("normalizes timezones", func() {
+		transforms := []data.Transform{{Type: data.TransformTypeTimezone, Timezone: "America/New_York"}}
+		Expect(apply(data.FieldTypeDateTimeTz, transforms, "2023-01-01 12:00:00.000+00:00")).To(Equal("2023-01-01 07:00:00.000-05:00"))
+	})
+
+	It("hashes values with the salt", func() {
+		transforms := []data.Transform{{Type: data.TransformTypeHash, Salt: "salt"}}
+		Expect(apply(data.FieldTypeString, transforms, "a@b.com")).To(Equal("d3bdaa92b6373f6067a450fb11488f88965636df6452f34eff6ffaf7803b1db0"))
+		Expect(apply(data.FieldTypeString, transforms, nil)).To(BeNil())
+	})
+
+	It("casts between types", func() {
+		Expect(apply(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeBoolean}}, "true")).To(Equal(true))
+		Expect(apply(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeDate}}, "2023-01-02T03:04:05Z")).To(Equal("2023-01-02"))
+		Expect(apply(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeJson}}, `{"a":1}`)).To(Equal(map[string]any{"a": float64(1)}))
+		Expect(apply(data.FieldTypeInteger, []data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeString}}, int64(7))).To(Equal("7"))
+
+		_, err := apply(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeInteger}}, "seven")
+		Expect(err).To(MatchError("seven is not a valid INTEGER"))
+	})
+
+	It("rejects transforms that don't apply to the field", func() {
+		invalid := []data.Transform{
+			{Type: data.TransformTypeTrim},
+			{Type: data.TransformTypeTimezone, Timezone: "Nowhere/Special"},
+			{Type: data.TransformTypeCast, ToType: data.FieldTypeTimeTz},
+			{Type: data.TransformTypeDefault},
+			{Type: data.TransformTypeDefault, Value: "not a number"},
+			{Type: "reverse"},
+		}
+
+		for _, transform := range invalid {
+			_, err := data.NewFieldTransformer(data.FieldTypeInteger, []data.Transform{transform})
+			Expect(err).ToNot(BeNil(), string(transform.Type))
+		}
+
+		_, err := data.NewFieldTransformer(data.FieldTypeString, []data.Transform{{Type: data.TransformTypeRegexExtract, Pattern: "("}})
+		Expect(err).ToNot(BeNil())
+	})
+})
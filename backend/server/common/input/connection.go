@@ -16,6 +16,13 @@ type RedshiftConfig struct {
 	Password     string `json:"password,omitempty"`
 	DatabaseName string `json:"database_name,omitempty"`
 	Endpoint     string `json:"endpoint,omitempty"`
+
+	// Only needed for Redshift destinations, which stage data in S3 with the staging keys before loading it with COPY
+	// under the IAM role
+	StagingAccessKey *string `json:"staging_access_key,omitempty"`
+	StagingSecretKey *string `json:"staging_secret_key,omitempty"`
+	StagingRegion    *string `json:"staging_region,omitempty"`
+	IamRole          *string `json:"iam_role,omitempty"`
 }
 
 type PostgresConfig struct {
@@ -47,8 +54,18 @@ type MongoDbConfig struct {
 }
 
 type WebhookConfig struct {
-	URL     string   `json:"url,omitempty"`
-	Headers []Header `json:"headers,omitempty"`
+	URL             string                  `json:"url,omitempty"`
+	Headers         []Header                `json:"headers,omitempty"`
+	DeliveryOptions *WebhookDeliveryOptions `json:"delivery_options,omitempty"`
+}
+
+// Controls how fast and in what size batches rows are sent to a webhook. Unset values use the defaults.
+type WebhookDeliveryOptions struct {
+	RequestsPerSecond *float64 `json:"requests_per_second,omitempty"`
+	Burst             *int     `json:"burst,omitempty"`
+	BatchSize         *int     `json:"batch_size,omitempty"`
+	MaxPayloadBytes   *int     `json:"max_payload_bytes,omitempty"`
+	MaxDeadLetters    *int     `json:"max_dead_letters,omitempty"`
 }
 
 type DynamoDbConfig struct {
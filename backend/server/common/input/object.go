@@ -13,6 +13,10 @@ type PartialUpdateObjectInput struct {
 	Recurring      *bool                  `json:"recurring,omitempty"`
 	Frequency      *int64                 `json:"frequency,omitempty"`
 	FrequencyUnits *models.FrequencyUnits `json:"frequency_units,omitempty"`
+	// Takes effect from the next run of each sync of the object
+	SchemaDriftPolicy *models.SchemaDriftPolicy `json:"schema_drift_policy,omitempty"`
+	// Replaces all existing webhook delivery overrides when set
+	WebhookDeliveryOptions *WebhookDeliveryOptions `json:"webhook_delivery_options,omitempty"`
 }
 
 type PartialUpdateObjectField struct {
@@ -52,6 +52,21 @@ func (mr *MockQueryServiceMockRecorder) GetClient(ctx, connection interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClient", reflect.TypeOf((*MockQueryService)(nil).GetClient), ctx, connection)
 }
 
+// GetDatabaseClient mocks base method.
+func (m *MockQueryService) GetDatabaseClient(ctx context.Context, connection *models.Connection) (query.DatabaseClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDatabaseClient", ctx, connection)
+	ret0, _ := ret[0].(query.DatabaseClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDatabaseClient indicates an expected call of GetDatabaseClient.
+func (mr *MockQueryServiceMockRecorder) GetDatabaseClient(ctx, connection interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDatabaseClient", reflect.TypeOf((*MockQueryService)(nil).GetDatabaseClient), ctx, connection)
+}
+
 // GetFieldValues mocks base method.
 func (m *MockQueryService) GetFieldValues(ctx context.Context, connection *models.Connection, namespace, tableName, fieldName string) ([]any, error) {
 	m.ctrl.T.Helper()
@@ -83,18 +98,23 @@ func (mr *MockQueryServiceMockRecorder) GetNamespaces(ctx, connection interface{
 }
 
 // GetQueryIterator mocks base method.
-func (m *MockQueryService) GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string) (data.RowIterator, error) {
+func (m *MockQueryService) GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string, args ...any) (data.RowIterator, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetQueryIterator", ctx, connection, queryString)
+	varargs := []interface{}{ctx, connection, queryString}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetQueryIterator", varargs...)
 	ret0, _ := ret[0].(data.RowIterator)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetQueryIterator indicates an expected call of GetQueryIterator.
-func (mr *MockQueryServiceMockRecorder) GetQueryIterator(ctx, connection, queryString interface{}) *gomock.Call {
+func (mr *MockQueryServiceMockRecorder) GetQueryIterator(ctx, connection, queryString interface{}, args ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockQueryService)(nil).GetQueryIterator), ctx, connection, queryString)
+	varargs := append([]interface{}{ctx, connection, queryString}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockQueryService)(nil).GetQueryIterator), varargs...)
 }
 
 // GetSchema mocks base method.
@@ -211,18 +231,23 @@ func (mr *MockConnectorClientMockRecorder) GetNamespaces(ctx interface{}) *gomoc
 }
 
 // GetQueryIterator mocks base method.
-func (m *MockConnectorClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (m *MockConnectorClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetQueryIterator", ctx, queryString)
+	varargs := []interface{}{ctx, queryString}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetQueryIterator", varargs...)
 	ret0, _ := ret[0].(data.RowIterator)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetQueryIterator indicates an expected call of GetQueryIterator.
-func (mr *MockConnectorClientMockRecorder) GetQueryIterator(ctx, queryString interface{}) *gomock.Call {
+func (mr *MockConnectorClientMockRecorder) GetQueryIterator(ctx, queryString interface{}, args ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockConnectorClient)(nil).GetQueryIterator), ctx, queryString)
+	varargs := append([]interface{}{ctx, queryString}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockConnectorClient)(nil).GetQueryIterator), varargs...)
 }
 
 // GetSchema mocks base method.
@@ -312,6 +337,25 @@ func (mr *MockWarehouseClientMockRecorder) CleanUpStagingData(ctx, stagingOption
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanUpStagingData", reflect.TypeOf((*MockWarehouseClient)(nil).CleanUpStagingData), ctx, stagingOptions)
 }
 
+// ExecuteInTransaction mocks base method.
+func (m *MockWarehouseClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range statements {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecuteInTransaction", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecuteInTransaction indicates an expected call of ExecuteInTransaction.
+func (mr *MockWarehouseClientMockRecorder) ExecuteInTransaction(ctx interface{}, statements ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, statements...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteInTransaction", reflect.TypeOf((*MockWarehouseClient)(nil).ExecuteInTransaction), varargs...)
+}
+
 // GetFieldValues mocks base method.
 func (m *MockWarehouseClient) GetFieldValues(ctx context.Context, namespace, tableName, fieldName string) ([]any, error) {
 	m.ctrl.T.Helper()
@@ -343,18 +387,23 @@ func (mr *MockWarehouseClientMockRecorder) GetNamespaces(ctx interface{}) *gomoc
 }
 
 // GetQueryIterator mocks base method.
-func (m *MockWarehouseClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (m *MockWarehouseClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetQueryIterator", ctx, queryString)
+	varargs := []interface{}{ctx, queryString}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetQueryIterator", varargs...)
 	ret0, _ := ret[0].(data.RowIterator)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetQueryIterator indicates an expected call of GetQueryIterator.
-func (mr *MockWarehouseClientMockRecorder) GetQueryIterator(ctx, queryString interface{}) *gomock.Call {
+func (mr *MockWarehouseClientMockRecorder) GetQueryIterator(ctx, queryString interface{}, args ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockWarehouseClient)(nil).GetQueryIterator), ctx, queryString)
+	varargs := append([]interface{}{ctx, queryString}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockWarehouseClient)(nil).GetQueryIterator), varargs...)
 }
 
 // GetSchema mocks base method.
@@ -422,15 +471,171 @@ func (mr *MockWarehouseClientMockRecorder) RunQuery(ctx, queryString interface{}
 }
 
 // StageData mocks base method.
-func (m *MockWarehouseClient) StageData(ctx context.Context, csvData string, stagingOptions query.StagingOptions) error {
+func (m *MockWarehouseClient) StageData(ctx context.Context, writeData query.StagingDataWriter, stagingOptions query.StagingOptions) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StageData", ctx, csvData, stagingOptions)
+	ret := m.ctrl.Call(m, "StageData", ctx, writeData, stagingOptions)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // StageData indicates an expected call of StageData.
-func (mr *MockWarehouseClientMockRecorder) StageData(ctx, csvData, stagingOptions interface{}) *gomock.Call {
+func (mr *MockWarehouseClientMockRecorder) StageData(ctx, writeData, stagingOptions interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StageData", reflect.TypeOf((*MockWarehouseClient)(nil).StageData), ctx, writeData, stagingOptions)
+}
+
+// MockDatabaseClient is a mock of DatabaseClient interface.
+type MockDatabaseClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockDatabaseClientMockRecorder
+}
+
+// MockDatabaseClientMockRecorder is the mock recorder for MockDatabaseClient.
+type MockDatabaseClientMockRecorder struct {
+	mock *MockDatabaseClient
+}
+
+// NewMockDatabaseClient creates a new mock instance.
+func NewMockDatabaseClient(ctrl *gomock.Controller) *MockDatabaseClient {
+	mock := &MockDatabaseClient{ctrl: ctrl}
+	mock.recorder = &MockDatabaseClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDatabaseClient) EXPECT() *MockDatabaseClientMockRecorder {
+	return m.recorder
+}
+
+// ExecuteInTransaction mocks base method.
+func (m *MockDatabaseClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range statements {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecuteInTransaction", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecuteInTransaction indicates an expected call of ExecuteInTransaction.
+func (mr *MockDatabaseClientMockRecorder) ExecuteInTransaction(ctx interface{}, statements ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, statements...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteInTransaction", reflect.TypeOf((*MockDatabaseClient)(nil).ExecuteInTransaction), varargs...)
+}
+
+// GetFieldValues mocks base method.
+func (m *MockDatabaseClient) GetFieldValues(ctx context.Context, namespace, tableName, fieldName string) ([]any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFieldValues", ctx, namespace, tableName, fieldName)
+	ret0, _ := ret[0].([]any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFieldValues indicates an expected call of GetFieldValues.
+func (mr *MockDatabaseClientMockRecorder) GetFieldValues(ctx, namespace, tableName, fieldName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFieldValues", reflect.TypeOf((*MockDatabaseClient)(nil).GetFieldValues), ctx, namespace, tableName, fieldName)
+}
+
+// GetNamespaces mocks base method.
+func (m *MockDatabaseClient) GetNamespaces(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNamespaces", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNamespaces indicates an expected call of GetNamespaces.
+func (mr *MockDatabaseClientMockRecorder) GetNamespaces(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNamespaces", reflect.TypeOf((*MockDatabaseClient)(nil).GetNamespaces), ctx)
+}
+
+// GetQueryIterator mocks base method.
+func (m *MockDatabaseClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, queryString}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetQueryIterator", varargs...)
+	ret0, _ := ret[0].(data.RowIterator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQueryIterator indicates an expected call of GetQueryIterator.
+func (mr *MockDatabaseClientMockRecorder) GetQueryIterator(ctx, queryString interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, queryString}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueryIterator", reflect.TypeOf((*MockDatabaseClient)(nil).GetQueryIterator), varargs...)
+}
+
+// GetSchema mocks base method.
+func (m *MockDatabaseClient) GetSchema(ctx context.Context, namespace, tableName string) (data.Schema, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchema", ctx, namespace, tableName)
+	ret0, _ := ret[0].(data.Schema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchema indicates an expected call of GetSchema.
+func (mr *MockDatabaseClientMockRecorder) GetSchema(ctx, namespace, tableName interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StageData", reflect.TypeOf((*MockWarehouseClient)(nil).StageData), ctx, csvData, stagingOptions)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchema", reflect.TypeOf((*MockDatabaseClient)(nil).GetSchema), ctx, namespace, tableName)
+}
+
+// GetTables mocks base method.
+func (m *MockDatabaseClient) GetTables(ctx context.Context, namespace string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTables", ctx, namespace)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTables indicates an expected call of GetTables.
+func (mr *MockDatabaseClientMockRecorder) GetTables(ctx, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTables", reflect.TypeOf((*MockDatabaseClient)(nil).GetTables), ctx, namespace)
+}
+
+// LoadData mocks base method.
+func (m *MockDatabaseClient) LoadData(ctx context.Context, namespace, tableName string, columns []string, rows []data.Row) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadData", ctx, namespace, tableName, columns, rows)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LoadData indicates an expected call of LoadData.
+func (mr *MockDatabaseClientMockRecorder) LoadData(ctx, namespace, tableName, columns, rows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadData", reflect.TypeOf((*MockDatabaseClient)(nil).LoadData), ctx, namespace, tableName, columns, rows)
+}
+
+// RunQuery mocks base method.
+func (m *MockDatabaseClient) RunQuery(ctx context.Context, queryString string, args ...any) (*data.QueryResults, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, queryString}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RunQuery", varargs...)
+	ret0, _ := ret[0].(*data.QueryResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RunQuery indicates an expected call of RunQuery.
+func (mr *MockDatabaseClientMockRecorder) RunQuery(ctx, queryString interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, queryString}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunQuery", reflect.TypeOf((*MockDatabaseClient)(nil).RunQuery), varargs...)
 }
@@ -1,6 +1,10 @@
 package models
 
-import "go.fabra.io/server/common/database"
+import (
+	"time"
+
+	"go.fabra.io/server/common/database"
+)
 
 type ConnectionType string
 
@@ -29,6 +33,20 @@ type BigQueryCredentials struct {
 	ClientX509CertUrl       string `json:"client_x509_cert_url"`
 }
 
+// Used by Redshift to stage data in S3 and to authorize the COPY from S3
+type AwsStagingCredentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	Region          string `json:"region"`
+}
+
+// A webhook signing key replaced by a rotation, which payloads are still signed with until it expires so receivers
+// can switch over
+type PreviousSigningKey struct {
+	EncryptedSigningKey string    `json:"encrypted_signing_key"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
 type Connection struct {
 	OrganizationID    int64
 	ConnectionType    ConnectionType      `json:"connection_type"`
@@ -43,5 +61,11 @@ type Connection struct {
 	Port              database.NullString
 	ConnectionOptions database.NullString
 
+	// Limits how many queries a worker runs against a source at once when reading partitions concurrently
+	MaxReadConcurrency *int
+
+	// JSON list of the webhook signing keys replaced by rotations that haven't expired yet, oldest first
+	PreviousSigningKeys database.NullString `json:"-"`
+
 	BaseModel
 }
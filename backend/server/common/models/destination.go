@@ -8,6 +8,13 @@ type Destination struct {
 	ConnectionID   int64               `json:"connection_id"`
 	StagingBucket  database.NullString `json:"staging_bucket"`
 
+	// Webhook delivery settings. Unset values fall back to the defaults in the webhook connector.
+	WebhookRequestsPerSecond *float64 `json:"webhook_requests_per_second"`
+	WebhookBurst             *int     `json:"webhook_burst"`
+	WebhookBatchSize         *int     `json:"webhook_batch_size"`
+	WebhookMaxPayloadBytes   *int     `json:"webhook_max_payload_bytes"`
+	WebhookMaxDeadLetters    *int     `json:"webhook_max_dead_letters"`
+
 	BaseModel
 }
 
@@ -2,14 +2,16 @@ package models
 
 import (
 	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/database"
 )
 
 type FieldMapping struct {
 	SyncID             int64
-	SourceFieldName    string         `json:"source_field_name"`
-	SourceFieldType    data.FieldType `json:"source_field_type"`
-	DestinationFieldId int64          `json:"destination_field_id"`
-	IsJsonField        bool           `json:"is_json_field"`
+	SourceFieldName    string              `json:"source_field_name"`
+	SourceFieldType    data.FieldType      `json:"source_field_type"`
+	DestinationFieldId int64               `json:"destination_field_id"`
+	IsJsonField        bool                `json:"is_json_field"`
+	Transforms         database.NullString `json:"transforms"` // JSON encoded []data.Transform applied to each value before it is written
 
 	BaseModel
 }
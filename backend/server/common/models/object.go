@@ -10,6 +10,16 @@ const (
 	TargetTypeWebhook          TargetType = "webhook"
 )
 
+// How runs handle changes to the source schema since the field mappings of a sync were created. Fields removed from
+// the source always fail the run, since they can't be read.
+type SchemaDriftPolicy string
+
+const (
+	SchemaDriftPolicyFail             SchemaDriftPolicy = "fail"               // fails the run on any change
+	SchemaDriftPolicyIgnoreNewColumns SchemaDriftPolicy = "ignore_new_columns" // ignores added fields, and fails on type changes
+	SchemaDriftPolicyAutoCast         SchemaDriftPolicy = "auto_cast"          // also casts changed types back to the mapped type if no values can be lost
+)
+
 type Object struct {
 	OrganizationID     int64               `json:"organization_id"`
 	DisplayName        string              `json:"display_name"`
@@ -24,6 +34,14 @@ type Object struct {
 	Recurring          bool                `json:"recurring"`
 	Frequency          *int64              `json:"frequency"`
 	FrequencyUnits     *FrequencyUnits     `json:"frequency_units"`
+	SchemaDriftPolicy  SchemaDriftPolicy   `json:"schema_drift_policy"`
+
+	// Overrides the webhook delivery settings of the destination
+	WebhookRequestsPerSecond *float64 `json:"webhook_requests_per_second"`
+	WebhookBurst             *int     `json:"webhook_burst"`
+	WebhookBatchSize         *int     `json:"webhook_batch_size"`
+	WebhookMaxPayloadBytes   *int     `json:"webhook_max_payload_bytes"`
+	WebhookMaxDeadLetters    *int     `json:"webhook_max_dead_letters"`
 
 	BaseModel
 }
@@ -11,10 +11,16 @@ const (
 	SyncModeFullAppend        SyncMode = "full_append" // for testing only: do not expose to customers in UI
 	SyncModeIncrementalAppend SyncMode = "incremental_append"
 	SyncModeIncrementalUpdate SyncMode = "incremental_update"
+	SyncModeChangeDataCapture SyncMode = "change_data_capture" // reads the source's change log instead of querying it
 )
 
 func (sm SyncMode) UsesCursor() bool {
-	return sm == SyncModeIncrementalAppend || sm == SyncModeIncrementalUpdate
+	return sm == SyncModeIncrementalAppend || sm == SyncModeIncrementalUpdate || sm == SyncModeChangeDataCapture
+}
+
+// Whether rows written to the destination replace existing rows with the same primary key
+func (sm SyncMode) UpdatesByPrimaryKey() bool {
+	return sm == SyncModeIncrementalUpdate || sm == SyncModeChangeDataCapture
 }
 
 type FrequencyUnits string
@@ -52,7 +58,25 @@ type Sync struct {
 	FrequencyUnits    *FrequencyUnits     `json:"frequency_units,omitempty"`
 	SourceCursorField database.NullString `json:"source_cursor_field,omitempty"`
 	SourcePrimaryKey  database.NullString `json:"source_primary_key,omitempty"`
-	CursorPosition    database.NullString `json:"cursor_position"` // current value of the cursor to determine where to start a sync from
+	CursorPosition    database.NullString `json:"cursor_position"` // JSON encoded data.CursorState to determine where to start a sync from
+
+	// Incremental syncs can order rows with the same cursor value by the primary key, and re-read rows from the lookback
+	// window before the cursor to pick up rows that were committed late. Re-read rows are skipped by primary key.
+	CursorTieBreaker      bool   `json:"cursor_tie_breaker"`
+	CursorLookbackSeconds *int64 `json:"cursor_lookback_seconds,omitempty"`
+
+	// Full syncs can split the source table into ranges of the partition field and read them concurrently
+	PartitionField database.NullString `json:"partition_field,omitempty"`
+	PartitionCount *int                `json:"partition_count,omitempty"`
+
+	Filter database.NullString `json:"filter,omitempty"` // JSON encoded data.Filter limiting which source rows are read
+
+	// Incremental update syncs without change data capture can diff the source's primary keys against the keys already
+	// synced this often, deleting rows whose keys are no longer in the source
+	DeleteDetectionIntervalSeconds *int64 `json:"delete_detection_interval_seconds,omitempty"`
+
+	// JSON encoded data.Schema of the source table when the sync last read it, to find fields added since then
+	SourceSchema database.NullString `json:"source_schema,omitempty"`
 
 	BaseModel
 }
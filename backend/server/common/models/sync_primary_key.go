@@ -0,0 +1,16 @@
+package models
+
+// The primary key of a row in the destination of a sync that detects deletes, as of the sync's last successful run
+type SyncPrimaryKey struct {
+	SyncID     int64
+	PrimaryKey string
+}
+
+// A primary key the current run of a sync wrote, deleted, or read from the source. Deleted is nil for keys the run
+// only read from the source.
+type SyncStagedPrimaryKey struct {
+	SyncID     int64
+	PrimaryKey string
+	Deleted    *bool
+	InSource   bool
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"go.fabra.io/server/common/database"
+)
+
+// When the primary keys stored for a sync that detects deletes were last diffed against the keys in the source. The
+// keys themselves are stored as SyncPrimaryKeys.
+type SyncPrimaryKeySnapshot struct {
+	OrganizationID int64
+	SyncID         int64             `json:"sync_id"`
+	DiffedAt       database.NullTime `json:"diffed_at"`
+
+	BaseModel
+}
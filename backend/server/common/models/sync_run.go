@@ -14,15 +14,32 @@ const (
 	SyncRunStatusCompleted SyncRunStatus = "completed"
 )
 
+type SyncRunPhase string
+
+const (
+	SyncRunPhaseConnecting  SyncRunPhase = "connecting"  // waiting for the first rows from the source
+	SyncRunPhaseReplicating SyncRunPhase = "replicating" // reading rows and writing them to the destination
+	SyncRunPhaseLoading     SyncRunPhase = "loading"     // every row has been read and the destination is finishing the write
+)
+
 type SyncRun struct {
-	OrganizationID int64
-	SyncID         int64               `json:"sync_id"`
-	WorkflowID     string              `json:"workflow_id"`
-	Status         SyncRunStatus       `json:"status"`
-	Error          database.NullString `json:"error"`
-	RowsWritten    int                 `json:"rows_written"`
-	StartedAt      time.Time           `json:"started_at"`
-	CompletedAt    time.Time           `json:"completed_at"`
+	OrganizationID   int64
+	SyncID           int64               `json:"sync_id"`
+	WorkflowID       string              `json:"workflow_id"`
+	Status           SyncRunStatus       `json:"status"`
+	Error            database.NullString `json:"error"`
+	RowsWritten      int                 `json:"rows_written"`
+	RowsDeadLettered int                 `json:"rows_dead_lettered"`
+	StartedAt        time.Time           `json:"started_at"`
+	CompletedAt      time.Time           `json:"completed_at"`
+
+	// Progress of a running sync, updated as rows are read and written
+	RowsRead          int           `json:"rows_read"`
+	BytesRead         int64         `json:"bytes_read"`
+	Phase             *SyncRunPhase `json:"phase,omitempty"`
+	ProgressUpdatedAt *time.Time    `json:"progress_updated_at,omitempty"`
+
+	SchemaDrift database.NullString `json:"schema_drift"` // JSON encoded data.SchemaDriftReport, set if the source schema changed
 
 	BaseModel
 }
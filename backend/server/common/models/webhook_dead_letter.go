@@ -0,0 +1,20 @@
+package models
+
+import (
+	"go.fabra.io/server/common/database"
+)
+
+// A batch of records that could not be delivered to a webhook destination, stored so it can be inspected and replayed
+type WebhookDeadLetter struct {
+	OrganizationID int64
+	SyncID         int64              `json:"sync_id"`
+	ObjectID       int64              `json:"object_id"`
+	EndCustomerID  string             `json:"end_customer_id"`
+	Data           string             `json:"data"`
+	Error          string             `json:"error"`
+	StatusCode     database.NullInt64 `json:"status_code"`
+	Attempts       int                `json:"attempts"`
+	ReplayedAt     database.NullTime  `json:"replayed_at"`
+
+	BaseModel
+}
@@ -162,7 +162,7 @@ func (ac BigQueryApiClient) RunQuery(ctx context.Context, queryString string, ar
 	defer client.Close()
 
 	q := client.Query(queryString)
-	for arg := range args {
+	for _, arg := range args {
 		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Value: arg})
 	}
 
@@ -209,7 +209,7 @@ func (ac BigQueryApiClient) RunQuery(ctx context.Context, queryString string, ar
 	}, nil
 }
 
-func (ac BigQueryApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (ac BigQueryApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := ac.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.BigQueryApiClient.GetQueryIterator) opening connection")
@@ -217,6 +217,9 @@ func (ac BigQueryApiClient) GetQueryIterator(ctx context.Context, queryString st
 	defer client.Close()
 
 	q := client.Query(queryString)
+	for _, arg := range args {
+		q.Parameters = append(q.Parameters, bigquery.QueryParameter{Value: arg})
+	}
 
 	// Location must match that of the dataset(s) referenced in the query.
 	q.Location = *ac.Location
@@ -243,7 +246,7 @@ func (ac BigQueryApiClient) GetQueryIterator(ctx context.Context, queryString st
 	}, nil
 }
 
-func (ac BigQueryApiClient) StageData(ctx context.Context, csvData string, stagingOptions StagingOptions) error {
+func (ac BigQueryApiClient) StageData(ctx context.Context, writeData StagingDataWriter, stagingOptions StagingOptions) error {
 	var credentialOption option.ClientOption
 	if ac.Credentials != nil {
 		credentialOption = option.WithCredentialsJSON([]byte(*ac.Credentials))
@@ -255,10 +258,14 @@ func (ac BigQueryApiClient) StageData(ctx context.Context, csvData string, stagi
 	}
 	defer gcsClient.Close()
 
+	// cancelling the context aborts the upload, so a partial object is never created if writing the data fails
+	writeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	w := gcsClient.Bucket(stagingOptions.Bucket).Object(stagingOptions.Object).Retryer(
 		storage.WithPolicy(storage.RetryAlways),
-	).NewWriter(ctx)
-	if _, err := fmt.Fprint(w, csvData); err != nil {
+	).NewWriter(writeCtx)
+	if err := writeData(w); err != nil {
 		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.BigQueryApiClient.StageData) writing data")
 	}
 
@@ -293,7 +300,7 @@ func (ac BigQueryApiClient) LoadFromStaging(ctx context.Context, namespace strin
 	}
 
 	if status.Err() != nil {
-		errors.Wrap(errors.WrapCustomerVisibleError(status.Err()), "(query.BigQueryApiClient.LoadFromStaging) status error")
+		return errors.Wrap(errors.WrapCustomerVisibleError(status.Err()), "(query.BigQueryApiClient.LoadFromStaging) status error")
 	}
 
 	return nil
@@ -323,6 +330,17 @@ func (ac BigQueryApiClient) CleanUpStagingData(ctx context.Context, stagingOptio
 	return nil
 }
 
+// BigQuery runs multi-statement queries as a script, so the statements are wrapped in a transaction within a single query
+func (ac BigQueryApiClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	script := fmt.Sprintf("BEGIN TRANSACTION;\n%s;\nCOMMIT TRANSACTION;", strings.Join(statements, ";\n"))
+	_, err := ac.RunQuery(ctx, script)
+	if err != nil {
+		return errors.Wrap(err, "(query.BigQueryApiClient.ExecuteInTransaction)")
+	}
+
+	return nil
+}
+
 func convertBigQueryRow(bigQueryRow []bigquery.Value, schema bigquery.Schema) data.Row {
 	row := make(data.Row, len(bigQueryRow))
 	for i, value := range bigQueryRow {
@@ -2,35 +2,83 @@ package query
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 )
 
+// number of items scanned to infer the schema of a table, since DynamoDB tables have no fixed schema
+const DYNAMODB_SCHEMA_SAMPLE_SIZE = 100
+
+// BatchWriteItem accepts at most 25 items per request
+const DYNAMODB_BATCH_WRITE_SIZE = 25
+
+const DYNAMODB_MAX_WRITE_RETRIES = 8
+
 type DynamoDbClient struct {
 	KeyID     string
 	AccessKey string
 	Location  string
+	Endpoint  *string
 }
 
-// TODO
-// type dynamodbIterator struct {
-// 	queryResult sql.Rows
-// 	schema      data.Schema
-// }
+// DynamoDbQuery describes a Scan over a single table. It is serialized to a string so it can be passed through GetQueryIterator.
+type DynamoDbQuery struct {
+	TableName                 string            `json:"table_name"`
+	Attributes                []string          `json:"attributes"`
+	FilterExpression          *string           `json:"filter_expression,omitempty"`
+	ExpressionAttributeNames  map[string]string `json:"expression_attribute_names,omitempty"`
+	ExpressionAttributeValues map[string]any    `json:"expression_attribute_values,omitempty"`
+}
+
+type dynamoDbIterator struct {
+	client *dynamodb.Client
+	input  *dynamodb.ScanInput
+	schema data.Schema
+	items  []map[string]types.AttributeValue
+	index  int
+	done   bool
+}
 
-// func (it *dynamodbIterator) Next(_ context.Context) (data.Row, error) {
-// 	// TODO
-// 	return nil, errors.New("not implemented")
-// }
+func (it *dynamoDbIterator) Next(ctx context.Context) (data.Row, error) {
+	// filtered scans can return empty pages, so keep fetching until there are items or the table is exhausted
+	for it.index >= len(it.items) {
+		if it.done {
+			return nil, data.ErrDone
+		}
 
-// // TODO: this must be in order
-// func (it *dynamodbIterator) Schema() data.Schema {
-// 	return it.schema
-// }
+		page, err := it.client.Scan(ctx, it.input)
+		if err != nil {
+			return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.dynamoDbIterator.Next) scanning table")
+		}
+
+		it.items = page.Items
+		it.index = 0
+		it.input.ExclusiveStartKey = page.LastEvaluatedKey
+		it.done = len(page.LastEvaluatedKey) == 0
+	}
+
+	row := convertDynamoDbItem(it.items[it.index], it.schema)
+	it.index++
+	return row, nil
+}
+
+func (it *dynamoDbIterator) Schema() data.Schema {
+	return it.schema
+}
 
 func (dc DynamoDbClient) openConnection(ctx context.Context) (*dynamodb.Client, error) {
 	awsConfig, err := config.LoadDefaultConfig(context.TODO(),
@@ -42,7 +90,11 @@ func (dc DynamoDbClient) openConnection(ctx context.Context) (*dynamodb.Client,
 		return nil, errors.Wrap(err, "(query.DynamoDbClient.openConnection) loading AWS config")
 	}
 
-	return dynamodb.NewFromConfig(awsConfig), nil
+	return dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		if dc.Endpoint != nil {
+			o.EndpointResolver = dynamodb.EndpointResolverFromURL(*dc.Endpoint)
+		}
+	}), nil
 }
 
 func (dc DynamoDbClient) GetTables(ctx context.Context, _namespace string) ([]string, error) {
@@ -59,31 +111,579 @@ func (dc DynamoDbClient) GetTables(ctx context.Context, _namespace string) ([]st
 	return tables.TableNames, nil
 }
 
-func (dc DynamoDbClient) LoadData(ctx context.Context, namespace string, tableName string, rows []data.Row) error {
-	// TODO
-	return errors.New("not implemented")
+func (dc DynamoDbClient) LoadData(ctx context.Context, _namespace string, tableName string, columns []string, rows []data.Row) error {
+	client, err := dc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.LoadData) opening connection")
+	}
+
+	for start := 0; start < len(rows); start += DYNAMODB_BATCH_WRITE_SIZE {
+		end := start + DYNAMODB_BATCH_WRITE_SIZE
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		writeRequests := []types.WriteRequest{}
+		for _, row := range rows[start:end] {
+			item, err := convertToDynamoDbItem(columns, row)
+			if err != nil {
+				return errors.Wrap(err, "(query.DynamoDbClient.LoadData) converting row")
+			}
+
+			writeRequests = append(writeRequests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		err = dc.batchWriteWithRetries(ctx, client, map[string][]types.WriteRequest{tableName: writeRequests})
+		if err != nil {
+			return errors.Wrap(err, "(query.DynamoDbClient.LoadData) writing batch")
+		}
+	}
+
+	return nil
 }
 
-func (dc DynamoDbClient) GetSchema(ctx context.Context, namespace string, tableName string) (data.Schema, error) {
-	// TODO
-	return nil, errors.New("not implemented")
+// BatchWriteItem returns any items that could not be written due to throttling as unprocessed, so these are retried with
+// exponential backoff along with requests that were throttled outright
+func (dc DynamoDbClient) batchWriteWithRetries(ctx context.Context, client *dynamodb.Client, requestItems map[string][]types.WriteRequest) error {
+	for attempt := 0; ; attempt++ {
+		output, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: requestItems})
+		if err != nil {
+			if !isDynamoDbThrottlingError(err) {
+				return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.batchWriteWithRetries)")
+			}
+		} else {
+			if len(output.UnprocessedItems) == 0 {
+				return nil
+			}
+
+			requestItems = output.UnprocessedItems
+		}
+
+		if attempt == DYNAMODB_MAX_WRITE_RETRIES {
+			return errors.NewCustomerVisibleError("DynamoDB write capacity exceeded, retries exhausted")
+		}
+
+		// full jitter, capped at ~10 seconds
+		maxBackoff := time.Duration(50*(1<<attempt)) * time.Millisecond
+		if maxBackoff > 10*time.Second {
+			maxBackoff = 10 * time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "(query.DynamoDbClient.batchWriteWithRetries)")
+		case <-time.After(time.Duration(rand.Int63n(int64(maxBackoff)))):
+		}
+	}
 }
 
-func (dc DynamoDbClient) GetFieldValues(ctx context.Context, namespace string, tableName string, fieldName string) ([]any, error) {
-	// TODO
-	return nil, errors.New("not implemented")
+func isDynamoDbThrottlingError(err error) bool {
+	var provisionedThroughputExceeded *types.ProvisionedThroughputExceededException
+	var requestLimitExceeded *types.RequestLimitExceeded
+	if errors.As(err, &provisionedThroughputExceeded) || errors.As(err, &requestLimitExceeded) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ThrottlingException"
+}
+
+// Statements are PartiQL, since DynamoDB transactions do not support SQL
+func (dc DynamoDbClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	client, err := dc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.ExecuteInTransaction) opening connection")
+	}
+
+	transactStatements := []types.ParameterizedStatement{}
+	for _, statement := range statements {
+		transactStatements = append(transactStatements, types.ParameterizedStatement{Statement: aws.String(statement)})
+	}
+
+	_, err = client.ExecuteTransaction(ctx, &dynamodb.ExecuteTransactionInput{TransactStatements: transactStatements})
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.ExecuteInTransaction) executing transaction")
+	}
+
+	return nil
+}
+
+// DynamoDB has no fixed schema other than the keys, so the schema is inferred from a sample of items in the table
+func (dc DynamoDbClient) GetSchema(ctx context.Context, _namespace string, tableName string) (data.Schema, error) {
+	client, err := dc.openConnection(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetSchema) opening connection")
+	}
+
+	table, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetSchema) describing table")
+	}
+
+	fieldTypes := map[string]data.FieldType{}
+	input := &dynamodb.ScanInput{TableName: aws.String(tableName), Limit: aws.Int32(DYNAMODB_SCHEMA_SAMPLE_SIZE)}
+	numSampled := 0
+	for numSampled < DYNAMODB_SCHEMA_SAMPLE_SIZE {
+		page, err := client.Scan(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetSchema) scanning table")
+		}
+
+		for _, item := range page.Items {
+			for name, value := range item {
+				fieldType, ok := getDynamoDbFieldType(value)
+				if !ok {
+					continue
+				}
+
+				existingType, exists := fieldTypes[name]
+				fieldTypes[name] = mergeDynamoDbFieldTypes(existingType, exists, fieldType)
+			}
+		}
+
+		numSampled += len(page.Items)
+		if len(page.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+
+	// key attributes always exist, so they come first even if no items were sampled
+	schema := data.Schema{}
+	keyNames := map[string]bool{}
+	for _, keyElement := range table.Table.KeySchema {
+		name := *keyElement.AttributeName
+		keyNames[name] = true
+
+		fieldType, ok := fieldTypes[name]
+		if !ok {
+			fieldType = getDynamoDbKeyFieldType(name, table.Table.AttributeDefinitions)
+		}
+		schema = append(schema, data.Field{Name: name, Type: fieldType})
+	}
+
+	otherNames := []string{}
+	for name := range fieldTypes {
+		if !keyNames[name] {
+			otherNames = append(otherNames, name)
+		}
+	}
+	sort.Strings(otherNames)
+
+	for _, name := range otherNames {
+		schema = append(schema, data.Field{Name: name, Type: fieldTypes[name]})
+	}
+
+	return schema, nil
+}
+
+func (dc DynamoDbClient) GetFieldValues(ctx context.Context, _namespace string, tableName string, fieldName string) ([]any, error) {
+	client, err := dc.openConnection(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetFieldValues) opening connection")
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(tableName),
+		ProjectionExpression:     aws.String("#field"),
+		ExpressionAttributeNames: map[string]string{"#field": fieldName},
+	}
+
+	seen := map[string]bool{}
+	values := []any{}
+	for len(values) < 100 {
+		page, err := client.Scan(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetFieldValues) scanning table")
+		}
+
+		for _, item := range page.Items {
+			value, ok := item[fieldName]
+			if !ok {
+				continue
+			}
+
+			converted := convertDynamoDbValue(value)
+			key := fmt.Sprintf("%v", converted)
+			if !seen[key] && len(values) < 100 {
+				seen[key] = true
+				values = append(values, converted)
+			}
+		}
+
+		if len(page.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+
+	return values, nil
 }
 
 func (dc DynamoDbClient) GetNamespaces(ctx context.Context) ([]string, error) {
 	return nil, errors.New("DynamoDB does not support namespaces")
 }
 
+// Queries are PartiQL statements, with any args passed as statement parameters
 func (dc DynamoDbClient) RunQuery(ctx context.Context, queryString string, args ...any) (*data.QueryResults, error) {
-	// TODO
-	return nil, errors.New("not implemented")
+	client, err := dc.openConnection(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.RunQuery) opening connection")
+	}
+
+	parameters := []types.AttributeValue{}
+	for _, arg := range args {
+		parameter, err := convertToDynamoDbValue(arg)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.DynamoDbClient.RunQuery) converting parameter")
+		}
+		parameters = append(parameters, parameter)
+	}
+
+	input := &dynamodb.ExecuteStatementInput{Statement: aws.String(queryString)}
+	if len(parameters) > 0 {
+		input.Parameters = parameters
+	}
+
+	items := []map[string]types.AttributeValue{}
+	for {
+		page, err := client.ExecuteStatement(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.RunQuery) executing statement")
+		}
+
+		items = append(items, page.Items...)
+		if page.NextToken == nil {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+
+	schema := inferDynamoDbSchema(items)
+	rows := []data.Row{}
+	for _, item := range items {
+		rows = append(rows, convertDynamoDbItem(item, schema))
+	}
+
+	return &data.QueryResults{
+		Schema: schema,
+		Data:   rows,
+	}, nil
+}
+
+func (dc DynamoDbClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
+	client, err := dc.openConnection(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetQueryIterator) opening connection")
+	}
+
+	var dynamoDbQuery DynamoDbQuery
+	err = json.Unmarshal([]byte(queryString), &dynamoDbQuery)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.DynamoDbClient.GetQueryIterator) unmarshalling query")
+	}
+
+	input, err := dynamoDbQuery.toScanInput()
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.DynamoDbClient.GetQueryIterator) creating scan")
+	}
+
+	// rows are returned in the order of the requested attributes
+	var schema data.Schema
+	if len(dynamoDbQuery.Attributes) > 0 {
+		tableSchema, err := dc.GetSchema(ctx, "", dynamoDbQuery.TableName)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.DynamoDbClient.GetQueryIterator) getting schema")
+		}
+
+		fieldTypes := map[string]data.FieldType{}
+		for _, field := range tableSchema {
+			fieldTypes[field.Name] = field.Type
+		}
+
+		for _, attribute := range dynamoDbQuery.Attributes {
+			fieldType, ok := fieldTypes[attribute]
+			if !ok {
+				// the attribute was not present in any sampled item
+				fieldType = data.FieldTypeString
+			}
+			schema = append(schema, data.Field{Name: attribute, Type: fieldType})
+		}
+	} else {
+		schema, err = dc.GetSchema(ctx, "", dynamoDbQuery.TableName)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.DynamoDbClient.GetQueryIterator) getting schema")
+		}
+	}
+
+	return &dynamoDbIterator{
+		client: client,
+		input:  input,
+		schema: schema,
+	}, nil
+}
+
+func (q DynamoDbQuery) toScanInput() (*dynamodb.ScanInput, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(q.TableName),
+		FilterExpression: q.FilterExpression,
+	}
+
+	attributeNames := map[string]string{}
+	for placeholder, name := range q.ExpressionAttributeNames {
+		attributeNames[placeholder] = name
+	}
+
+	// attribute names are always passed as placeholders since many common names are reserved words in DynamoDB
+	if len(q.Attributes) > 0 {
+		projection := ""
+		for i, attribute := range q.Attributes {
+			placeholder := fmt.Sprintf("#attr%d", i)
+			attributeNames[placeholder] = attribute
+			if i > 0 {
+				projection += ", "
+			}
+			projection += placeholder
+		}
+		input.ProjectionExpression = aws.String(projection)
+	}
+
+	if len(attributeNames) > 0 {
+		input.ExpressionAttributeNames = attributeNames
+	}
+
+	if len(q.ExpressionAttributeValues) > 0 {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{}
+		for placeholder, value := range q.ExpressionAttributeValues {
+			attributeValue, err := convertToDynamoDbValue(value)
+			if err != nil {
+				return nil, errors.Wrap(err, "(query.DynamoDbQuery.toScanInput)")
+			}
+			input.ExpressionAttributeValues[placeholder] = attributeValue
+		}
+	}
+
+	return input, nil
+}
+
+func CreateDynamoDbQueryString(dynamoDbQuery DynamoDbQuery) string {
+	dynamoDbQueryBytes, err := json.Marshal(dynamoDbQuery)
+	if err != nil {
+		// this should never happen anyway so just panic
+		panic(err)
+	}
+
+	return string(dynamoDbQueryBytes)
+}
+
+func inferDynamoDbSchema(items []map[string]types.AttributeValue) data.Schema {
+	fieldTypes := map[string]data.FieldType{}
+	for _, item := range items {
+		for name, value := range item {
+			fieldType, ok := getDynamoDbFieldType(value)
+			if !ok {
+				if _, exists := fieldTypes[name]; !exists {
+					fieldTypes[name] = data.FieldTypeString
+				}
+				continue
+			}
+
+			existingType, exists := fieldTypes[name]
+			fieldTypes[name] = mergeDynamoDbFieldTypes(existingType, exists, fieldType)
+		}
+	}
+
+	names := []string{}
+	for name := range fieldTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schema := data.Schema{}
+	for _, name := range names {
+		schema = append(schema, data.Field{Name: name, Type: fieldTypes[name]})
+	}
+
+	return schema
+}
+
+// returns false for NULL values since they carry no type information
+func getDynamoDbFieldType(value types.AttributeValue) (data.FieldType, bool) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberB:
+		return data.FieldTypeString, true
+	case *types.AttributeValueMemberN:
+		if _, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return data.FieldTypeInteger, true
+		}
+		return data.FieldTypeNumber, true
+	case *types.AttributeValueMemberBOOL:
+		return data.FieldTypeBoolean, true
+	case *types.AttributeValueMemberNULL:
+		return "", false
+	default:
+		// maps, lists and sets
+		return data.FieldTypeJson, true
+	}
+}
+
+func mergeDynamoDbFieldTypes(existingType data.FieldType, exists bool, fieldType data.FieldType) data.FieldType {
+	if !exists || existingType == fieldType {
+		return fieldType
+	}
+
+	// a mix of integers and decimals is still numeric
+	if (existingType == data.FieldTypeInteger || existingType == data.FieldTypeNumber) &&
+		(fieldType == data.FieldTypeInteger || fieldType == data.FieldTypeNumber) {
+		return data.FieldTypeNumber
+	}
+
+	// everything else can always be treated as a string
+	return data.FieldTypeString
+}
+
+func getDynamoDbKeyFieldType(name string, attributeDefinitions []types.AttributeDefinition) data.FieldType {
+	for _, attributeDefinition := range attributeDefinitions {
+		if *attributeDefinition.AttributeName == name && attributeDefinition.AttributeType == types.ScalarAttributeTypeN {
+			return data.FieldTypeNumber
+		}
+	}
+
+	return data.FieldTypeString
 }
 
-func (dc DynamoDbClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
-	// TODO
-	return nil, errors.New("not implemented")
+func convertDynamoDbItem(item map[string]types.AttributeValue, schema data.Schema) data.Row {
+	row := make(data.Row, len(schema))
+	for i, field := range schema {
+		value, ok := item[field.Name]
+		if !ok {
+			row[i] = nil
+			continue
+		}
+
+		row[i] = convertDynamoDbValue(value)
+	}
+
+	return row
+}
+
+func convertDynamoDbValue(value types.AttributeValue) any {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return convertDynamoDbNumber(v.Value)
+	case *types.AttributeValueMemberBOOL:
+		return v.Value
+	case *types.AttributeValueMemberNULL:
+		return nil
+	case *types.AttributeValueMemberB:
+		return base64.StdEncoding.EncodeToString(v.Value)
+	case *types.AttributeValueMemberM:
+		converted := map[string]any{}
+		for key, nested := range v.Value {
+			converted[key] = convertDynamoDbValue(nested)
+		}
+		return converted
+	case *types.AttributeValueMemberL:
+		converted := []any{}
+		for _, nested := range v.Value {
+			converted = append(converted, convertDynamoDbValue(nested))
+		}
+		return converted
+	case *types.AttributeValueMemberSS:
+		converted := []any{}
+		for _, nested := range v.Value {
+			converted = append(converted, nested)
+		}
+		return converted
+	case *types.AttributeValueMemberNS:
+		converted := []any{}
+		for _, nested := range v.Value {
+			converted = append(converted, convertDynamoDbNumber(nested))
+		}
+		return converted
+	case *types.AttributeValueMemberBS:
+		converted := []any{}
+		for _, nested := range v.Value {
+			converted = append(converted, base64.StdEncoding.EncodeToString(nested))
+		}
+		return converted
+	default:
+		return nil
+	}
+}
+
+func convertDynamoDbNumber(value string) any {
+	if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return intValue
+	}
+
+	if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+		return floatValue
+	}
+
+	// numbers can have up to 38 digits of precision, so fall back to the string representation
+	return value
+}
+
+func convertToDynamoDbItem(columns []string, row data.Row) (map[string]types.AttributeValue, error) {
+	item := map[string]types.AttributeValue{}
+	for i, column := range columns {
+		// omit missing values rather than storing NULL, which is more idiomatic for DynamoDB
+		if row[i] == nil {
+			continue
+		}
+
+		value, err := convertToDynamoDbValue(row[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "(query.convertToDynamoDbItem) converting %s", column)
+		}
+		item[column] = value
+	}
+
+	return item, nil
+}
+
+func convertToDynamoDbValue(value any) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case string:
+		return &types.AttributeValueMemberS{Value: v}, nil
+	case bool:
+		return &types.AttributeValueMemberBOOL{Value: v}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", v)}, nil
+	case float32:
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(float64(v), 'f', -1, 32)}, nil
+	case float64:
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case json.Number:
+		return &types.AttributeValueMemberN{Value: v.String()}, nil
+	case time.Time:
+		return &types.AttributeValueMemberS{Value: v.Format(FABRA_TIMESTAMP_TZ_FORMAT)}, nil
+	case map[string]any:
+		converted := map[string]types.AttributeValue{}
+		for key, nested := range v {
+			nestedValue, err := convertToDynamoDbValue(nested)
+			if err != nil {
+				return nil, errors.Wrap(err, "(query.convertToDynamoDbValue)")
+			}
+			converted[key] = nestedValue
+		}
+		return &types.AttributeValueMemberM{Value: converted}, nil
+	case []any:
+		converted := []types.AttributeValue{}
+		for _, nested := range v {
+			nestedValue, err := convertToDynamoDbValue(nested)
+			if err != nil {
+				return nil, errors.Wrap(err, "(query.convertToDynamoDbValue)")
+			}
+			converted = append(converted, nestedValue)
+		}
+		return &types.AttributeValueMemberL{Value: converted}, nil
+	default:
+		return nil, errors.Newf("(query.convertToDynamoDbValue) unsupported value type %T", value)
+	}
 }
@@ -41,7 +41,7 @@ func (it *mongoDbIterator) Next(ctx context.Context) (data.Row, error) {
 			return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.mongoDbIterator.Next) decoding row")
 		}
 
-		return convertMongoDbRow(row, it.schema), nil
+		return ConvertMongoDbRow(row, it.schema), nil
 	}
 
 	defer it.cursor.Close(ctx)
@@ -188,7 +188,7 @@ func (mc MongoDbApiClient) RunQuery(ctx context.Context, queryString string, arg
 	}, nil
 }
 
-func (mc MongoDbApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (mc MongoDbApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := mc.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MongoDbApiClient.GetQueryIterator) opening connection")
@@ -338,13 +338,14 @@ func getFieldTypes(collection *mongo.Collection, fields []string) (map[string]st
 func convertMongoDbRows(mongoDbRows bson.A, schema data.Schema) []data.Row {
 	var rows []data.Row
 	for _, mongoDbRow := range mongoDbRows {
-		rows = append(rows, convertMongoDbRow(mongoDbRow.(bson.D), schema))
+		rows = append(rows, ConvertMongoDbRow(mongoDbRow.(bson.D), schema))
 	}
 
 	return rows
 }
 
-func convertMongoDbRow(mongoDbRow bson.D, schema data.Schema) data.Row {
+// Converts the document into a row with the values of the schema's fields, in order
+func ConvertMongoDbRow(mongoDbRow bson.D, schema data.Schema) data.Row {
 	// TODO: convert the values to the expected Fabra Golang types
 	valueMap := make(map[string]any)
 	for _, keyPair := range mongoDbRow {
@@ -0,0 +1,144 @@
+package query
+
+import (
+	"context"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDB sources stream their changes through change streams, which can be resumed after the token of any event
+// read from them. Tokens are only valid while their event is still in the oplog.
+
+// The server can't resume after the token because its event is no longer in the oplog
+var ErrChangeStreamHistoryLost = errors.New("change stream history lost")
+
+const (
+	mongoErrorChangeStreamFatal       = 280
+	mongoErrorChangeStreamHistoryLost = 286
+
+	// how long to wait for new changes before ending the stream
+	mongoChangeStreamMaxAwaitTime = time.Second
+)
+
+type ChangeStreamOptions struct {
+	Database   string
+	Collection string
+
+	// the stream starts with the changes after this token, or with new changes if it isn't set
+	ResumeToken *string
+}
+
+type ChangeEventType string
+
+const (
+	ChangeEventTypeInsert     ChangeEventType = "insert"
+	ChangeEventTypeUpdate     ChangeEventType = "update"
+	ChangeEventTypeReplace    ChangeEventType = "replace"
+	ChangeEventTypeDelete     ChangeEventType = "delete"
+	ChangeEventTypeInvalidate ChangeEventType = "invalidate"
+)
+
+type ChangeEvent struct {
+	Type        ChangeEventType
+	DocumentKey bson.D
+
+	// the whole document after the change. Updates have the document as of when the event is read, which is nil if
+	// the document was deleted since.
+	FullDocument bson.D
+	ResumeToken  string
+}
+
+type ChangeStreamClient interface {
+	OpenChangeStream(ctx context.Context, options ChangeStreamOptions) (ChangeStream, error)
+}
+
+type ChangeStream interface {
+	// Returns data.ErrDone once no more changes are available without waiting
+	Next(ctx context.Context) (*ChangeEvent, error)
+	// The token to resume after every change read so far, which may be after the last change read
+	ResumeToken() string
+	Close(ctx context.Context) error
+}
+
+func (mc MongoDbApiClient) OpenChangeStream(ctx context.Context, changeStreamOptions ChangeStreamOptions) (ChangeStream, error) {
+	client, err := mc.openConnection(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MongoDbApiClient.OpenChangeStream) opening connection")
+	}
+
+	watchOptions := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetMaxAwaitTime(mongoChangeStreamMaxAwaitTime)
+	if changeStreamOptions.ResumeToken != nil {
+		// unlike resuming after the token, starting after it also works after an invalidate event
+		watchOptions.SetStartAfter(bson.D{{Key: "_data", Value: *changeStreamOptions.ResumeToken}})
+	}
+
+	collection := client.Database(changeStreamOptions.Database).Collection(changeStreamOptions.Collection)
+	stream, err := collection.Watch(ctx, mongo.Pipeline{}, watchOptions)
+	if err != nil {
+		client.Disconnect(ctx)
+		return nil, errors.Wrap(convertChangeStreamError(err), "(query.MongoDbApiClient.OpenChangeStream) watching collection")
+	}
+
+	return &mongoChangeStream{client: client, stream: stream}, nil
+}
+
+type mongoChangeStream struct {
+	client *mongo.Client
+	stream *mongo.ChangeStream
+}
+
+type mongoChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   bson.D `bson:"documentKey"`
+	FullDocument  bson.D `bson:"fullDocument"`
+}
+
+func (s *mongoChangeStream) Next(ctx context.Context) (*ChangeEvent, error) {
+	if !s.stream.TryNext(ctx) {
+		err := s.stream.Err()
+		if err != nil {
+			return nil, errors.Wrap(convertChangeStreamError(err), "(query.mongoChangeStream.Next)")
+		}
+
+		return nil, data.ErrDone
+	}
+
+	var event mongoChangeEvent
+	err := s.stream.Decode(&event)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.mongoChangeStream.Next) decoding event")
+	}
+
+	return &ChangeEvent{
+		Type:         ChangeEventType(event.OperationType),
+		DocumentKey:  event.DocumentKey,
+		FullDocument: event.FullDocument,
+		ResumeToken:  s.ResumeToken(),
+	}, nil
+}
+
+func (s *mongoChangeStream) ResumeToken() string {
+	token, _ := s.stream.ResumeToken().Lookup("_data").StringValueOK()
+	return token
+}
+
+func (s *mongoChangeStream) Close(ctx context.Context) error {
+	defer s.client.Disconnect(ctx)
+	return s.stream.Close(ctx)
+}
+
+func convertChangeStreamError(err error) error {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && (commandErr.HasErrorCode(mongoErrorChangeStreamHistoryLost) || commandErr.HasErrorCode(mongoErrorChangeStreamFatal)) {
+		return errors.Wrap(ErrChangeStreamHistoryLost, commandErr.Message)
+	}
+
+	return errors.WrapCustomerVisibleError(err)
+}
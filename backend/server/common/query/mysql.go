@@ -139,7 +139,7 @@ func (mc MySqlApiClient) RunQuery(ctx context.Context, queryString string, args
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.RunQuery) running query")
 	}
@@ -173,14 +173,14 @@ func (mc MySqlApiClient) RunQuery(ctx context.Context, queryString string, args
 	}, nil
 }
 
-func (mc MySqlApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (mc MySqlApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := mc.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetQueryIterator) opening connection")
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetQueryIterator) running query")
 	}
@@ -196,6 +196,115 @@ func (mc MySqlApiClient) GetQueryIterator(ctx context.Context, queryString strin
 	}, nil
 }
 
+func (mc MySqlApiClient) LoadData(ctx context.Context, namespace string, tableName string, columns []string, rows []data.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	client, err := mc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.LoadData) opening connection")
+	}
+	defer client.Close()
+
+	txn, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.LoadData) starting transaction")
+	}
+	defer txn.Rollback()
+
+	for _, batch := range GetMySqlInsertBatches(rows, len(columns)) {
+		statement := GetMySqlInsertStatement(namespace, tableName, columns, len(batch))
+
+		args := []any{}
+		for _, row := range batch {
+			args = append(args, row...)
+		}
+
+		_, err = txn.ExecContext(ctx, statement, args...)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.LoadData) inserting rows")
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.LoadData) committing transaction")
+	}
+
+	return nil
+}
+
+func (mc MySqlApiClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	client, err := mc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.ExecuteInTransaction) opening connection")
+	}
+	defer client.Close()
+
+	txn, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.ExecuteInTransaction) starting transaction")
+	}
+	defer txn.Rollback()
+
+	for _, statement := range statements {
+		_, err = txn.ExecContext(ctx, statement)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.ExecuteInTransaction) executing statement")
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.ExecuteInTransaction) committing transaction")
+	}
+
+	return nil
+}
+
+// MySQL prepared statements can have at most 65,535 placeholders, so large inserts must be split up
+const MYSQL_MAX_PLACEHOLDERS = 65_535
+
+func GetMySqlInsertBatches(rows []data.Row, numColumns int) [][]data.Row {
+	batchSize := MYSQL_MAX_PLACEHOLDERS / numColumns
+
+	batches := [][]data.Row{}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batches = append(batches, rows[start:end])
+	}
+
+	return batches
+}
+
+func GetMySqlInsertStatement(namespace string, tableName string, columns []string, numRows int) string {
+	quotedColumns := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = QuoteMySqlIdentifier(column)
+		placeholders[i] = "?"
+	}
+
+	rowPlaceholder := fmt.Sprintf("(%s)", strings.Join(placeholders, ", "))
+	rowPlaceholders := make([]string, numRows)
+	for i := range rowPlaceholders {
+		rowPlaceholders[i] = rowPlaceholder
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s.%s (%s) VALUES %s",
+		QuoteMySqlIdentifier(namespace), QuoteMySqlIdentifier(tableName), strings.Join(quotedColumns, ", "), strings.Join(rowPlaceholders, ", "),
+	)
+}
+
+func QuoteMySqlIdentifier(identifier string) string {
+	return fmt.Sprintf("`%s`", strings.ReplaceAll(identifier, "`", "``"))
+}
+
 func getMySqlFieldType(mysqlType string) data.FieldType {
 	uppemcased := strings.ToUpper(mysqlType)
 	switch uppemcased {
@@ -0,0 +1,706 @@
+package query
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+)
+
+// MySQL sources stream their changes by connecting as a replica and reading the row-based binlog.
+
+// A place in the binlog. When GTIDSet is set, the binlog is read from the first transaction not in the set instead of
+// the file and position, so the position stays valid on other servers the source fails over to.
+type BinlogPosition struct {
+	File     string
+	Position uint32
+	GTIDSet  string
+}
+
+const binlogGtidPrefix = "gtid:"
+
+func (p BinlogPosition) String() string {
+	if p.GTIDSet != "" {
+		return binlogGtidPrefix + p.GTIDSet
+	}
+
+	return fmt.Sprintf("%s:%d", p.File, p.Position)
+}
+
+func ParseBinlogPosition(encoded string) (*BinlogPosition, error) {
+	if strings.HasPrefix(encoded, binlogGtidPrefix) {
+		gtids, err := parseGtidSet(strings.TrimPrefix(encoded, binlogGtidPrefix))
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.ParseBinlogPosition)")
+		}
+		if len(gtids.Sets) == 0 {
+			return nil, errors.Newf("(query.ParseBinlogPosition) invalid binlog position %s", encoded)
+		}
+
+		return &BinlogPosition{GTIDSet: gtids.String()}, nil
+	}
+
+	separator := strings.LastIndex(encoded, ":")
+	if separator < 1 {
+		return nil, errors.Newf("(query.ParseBinlogPosition) invalid binlog position %s", encoded)
+	}
+
+	position, err := strconv.ParseUint(encoded[separator+1:], 10, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.ParseBinlogPosition)")
+	}
+
+	return &BinlogPosition{File: encoded[:separator], Position: uint32(position)}, nil
+}
+
+// Returns whether every change before the other position is also before this one. GTID sets are compared when both
+// positions have one, since the same transaction can be at a different file and position on another server.
+func (p BinlogPosition) Includes(other BinlogPosition) bool {
+	if p.GTIDSet != "" && other.GTIDSet != "" {
+		gtids, err := parseGtidSet(p.GTIDSet)
+		if err != nil {
+			return false
+		}
+		otherGtids, err := parseGtidSet(other.GTIDSet)
+		if err != nil {
+			return false
+		}
+
+		return gtids.Contain(otherGtids)
+	}
+
+	if p.File == "" || other.File == "" {
+		return false
+	}
+	if p.File != other.File {
+		// binlog files are numbered in order, and the number only gets longer once it runs out of digits
+		if len(p.File) != len(other.File) {
+			return len(p.File) > len(other.File)
+		}
+		return p.File > other.File
+	}
+
+	return p.Position >= other.Position
+}
+
+// Parses a GTID set. Formatting the set again orders it and merges its intervals, so equal sets have the same text.
+func parseGtidSet(encoded string) (*mysql.MysqlGTIDSet, error) {
+	gtids, err := mysql.ParseMysqlGTIDSet(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.parseGtidSet)")
+	}
+
+	return gtids.(*mysql.MysqlGTIDSet), nil
+}
+
+type BinlogOptions struct {
+	ServerID uint32 // must be unique among the replicas connected to the source
+	Position BinlogPosition
+
+	// only changes to this table are decoded
+	Namespace string
+	TableName string
+}
+
+type BinlogRowsEventType string
+
+const (
+	BinlogRowsEventTypeInsert BinlogRowsEventType = "insert"
+	BinlogRowsEventTypeUpdate BinlogRowsEventType = "update"
+	BinlogRowsEventTypeDelete BinlogRowsEventType = "delete"
+)
+
+// Rows changed by a single statement, with the value of every column in table order. Updates have the rows before
+// the change in Rows and the rows after it in NewRows.
+type BinlogRowsEvent struct {
+	Type    BinlogRowsEventType
+	Columns []string
+	Rows    [][]any
+	NewRows [][]any
+}
+
+type BinlogEvent struct {
+	Position BinlogPosition // the position after this event
+	Commit   bool           // whether this event ends a transaction
+	Rows     *BinlogRowsEvent
+}
+
+type BinlogClient interface {
+	OpenBinlog(ctx context.Context, options BinlogOptions) (BinlogStream, error)
+	// Returns the position after every transaction committed so far
+	GetBinlogPosition(ctx context.Context) (*BinlogPosition, error)
+	// Runs the query in a consistent snapshot, and returns the position of the first change that isn't in it
+	GetSnapshotIterator(ctx context.Context, queryString string, args ...any) (*BinlogPosition, data.RowIterator, error)
+}
+
+type BinlogStream interface {
+	// Returns data.ErrDone once every event written before the stream was opened has been read
+	Next(ctx context.Context) (*BinlogEvent, error)
+	Close() error
+}
+
+// the source sends rows changed by a single statement as a partial JSON update when binlog_row_value_options is set,
+// which this version of the replication library can't decode
+const binlogEventPartialUpdateRows replication.EventType = 39
+
+// The source keeps the stream open to send changes as they happen, so the stream ends at the position the binlog was
+// at when it was opened. Reading from a GTID set skips the transactions in it, and the source finds the file to start
+// from itself.
+func (mc MySqlApiClient) OpenBinlog(ctx context.Context, options BinlogOptions) (BinlogStream, error) {
+	endPosition, err := mc.GetBinlogPosition(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.MySqlApiClient.OpenBinlog)")
+	}
+
+	host, port, err := splitMySqlHost(mc.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.MySqlApiClient.OpenBinlog)")
+	}
+
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID: options.ServerID,
+		Flavor:   mysql.MySQLFlavor,
+		Host:     host,
+		Port:     port,
+		User:     mc.Username,
+		Password: mc.Password,
+		// connect over TLS like the database driver does
+		TLSConfig: &tls.Config{ServerName: host},
+		// timestamps are logged in UTC
+		TimestampStringLocation: time.UTC,
+		// the next run opens the stream again from its cursor, so a broken connection fails the read instead
+		DisableRetrySync: true,
+	})
+
+	var events *replication.BinlogStreamer
+	if options.Position.GTIDSet != "" {
+		gtids, err := parseGtidSet(options.Position.GTIDSet)
+		if err != nil {
+			syncer.Close()
+			return nil, errors.Wrap(err, "(query.MySqlApiClient.OpenBinlog)")
+		}
+		events, err = syncer.StartSyncGTID(gtids)
+	} else {
+		events, err = syncer.StartSync(mysql.Position{Name: options.Position.File, Pos: options.Position.Position})
+	}
+	if err != nil {
+		syncer.Close()
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.OpenBinlog) starting sync")
+	}
+
+	stream := newMySqlBinlogStream(mc, events, options, *endPosition)
+	stream.syncer = syncer
+	return stream, nil
+}
+
+// The host may include the port, which defaults to 3306 like it does for the database driver
+func splitMySqlHost(address string) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, 3306, nil
+	}
+
+	parsedPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "(query.splitMySqlHost)")
+	}
+
+	return host, uint16(parsedPort), nil
+}
+
+func (mc MySqlApiClient) GetBinlogPosition(ctx context.Context) (*BinlogPosition, error) {
+	client, err := mc.openConnection(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetBinlogPosition) opening connection")
+	}
+	defer client.Close()
+
+	position, err := getBinlogPosition(ctx, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.MySqlApiClient.GetBinlogPosition)")
+	}
+
+	return position, nil
+}
+
+// The global read lock stops commits while the snapshot starts, so the position read under it is exactly where the
+// snapshot ends. Sources that don't allow the lock, like RDS, have the position read before the snapshot starts
+// instead, so changes committed in between are read again by the next run, which only rewrites the latest version of
+// those rows.
+func (mc MySqlApiClient) GetSnapshotIterator(ctx context.Context, queryString string, args ...any) (*BinlogPosition, data.RowIterator, error) {
+	client, err := mc.openConnection(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetSnapshotIterator) opening connection")
+	}
+	defer client.Close()
+
+	// the snapshot only lasts as long as the connection it was started on
+	conn, err := client.Conn(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetSnapshotIterator) opening connection")
+	}
+
+	var position *BinlogPosition
+	_, lockErr := conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK")
+	if lockErr != nil {
+		position, err = getBinlogPosition(ctx, conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, errors.Wrap(err, "(query.MySqlApiClient.GetSnapshotIterator)")
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT")
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetSnapshotIterator) starting snapshot")
+	}
+
+	if lockErr == nil {
+		position, err = getBinlogPosition(ctx, conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, errors.Wrap(err, "(query.MySqlApiClient.GetSnapshotIterator)")
+		}
+
+		_, err = conn.ExecContext(ctx, "UNLOCK TABLES")
+		if err != nil {
+			conn.Close()
+			return nil, nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetSnapshotIterator) releasing lock")
+		}
+	}
+
+	queryResult, err := conn.QueryContext(ctx, queryString, args...)
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetSnapshotIterator) running query")
+	}
+
+	columns, err := queryResult.ColumnTypes()
+	if err != nil {
+		queryResult.Close()
+		conn.Close()
+		return nil, nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.MySqlApiClient.GetSnapshotIterator) getting column types")
+	}
+
+	return position, &mysqlSnapshotIterator{
+		mysqlIterator: mysqlIterator{queryResult: queryResult, schema: convertMySqlSchema(columns)},
+		conn:          conn,
+	}, nil
+}
+
+// Ends the snapshot once every row is read
+type mysqlSnapshotIterator struct {
+	mysqlIterator
+	conn *sql.Conn
+}
+
+func (it *mysqlSnapshotIterator) Next(ctx context.Context) (data.Row, error) {
+	row, err := it.mysqlIterator.Next(ctx)
+	if err != nil {
+		it.conn.Close()
+	}
+
+	return row, err
+}
+
+type mysqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func getBinlogPosition(ctx context.Context, queryer mysqlQueryer) (*BinlogPosition, error) {
+	// MySQL 8.4 renamed the statement
+	status, err := queryMySqlStatus(ctx, queryer, "SHOW MASTER STATUS")
+	if err != nil {
+		status, err = queryMySqlStatus(ctx, queryer, "SHOW BINARY LOG STATUS")
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.getBinlogPosition)")
+		}
+	}
+	if status == nil {
+		return nil, errors.NewCustomerVisibleError("binary logging must be enabled on the source to use change data capture")
+	}
+
+	gtidMode, err := queryMySqlStatus(ctx, queryer, "SELECT @@global.gtid_mode")
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.getBinlogPosition) getting GTID mode")
+	}
+
+	position, err := parseBinlogStatus(status, gtidMode)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.getBinlogPosition)")
+	}
+
+	return position, nil
+}
+
+// Reads the first row of the results as text, or nil if there are none
+func queryMySqlStatus(ctx context.Context, queryer mysqlQueryer, statement string) ([]string, error) {
+	queryResult, err := queryer.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.queryMySqlStatus)")
+	}
+	defer queryResult.Close()
+
+	columns, err := queryResult.Columns()
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.queryMySqlStatus) getting columns")
+	}
+	if !queryResult.Next() {
+		return nil, queryResult.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	err = queryResult.Scan(valuePtrs...)
+	if err != nil {
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.queryMySqlStatus) scanning row")
+	}
+
+	status := make([]string, len(values))
+	for i, value := range values {
+		status[i] = value.String
+	}
+
+	return status, nil
+}
+
+// Reads the position from the binary log status, which has the file, the position and the executed GTID set in its
+// first and second and fifth columns. With GTIDs, the position still works after the source fails over to a replica
+// with different binlog files.
+func parseBinlogStatus(status []string, gtidMode []string) (*BinlogPosition, error) {
+	if len(status) < 2 {
+		return nil, errors.New("(query.parseBinlogStatus) missing binlog position")
+	}
+
+	position, err := strconv.ParseUint(status[1], 10, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.parseBinlogStatus)")
+	}
+	result := BinlogPosition{File: status[0], Position: uint32(position)}
+
+	if len(gtidMode) > 0 && strings.EqualFold(gtidMode[0], "ON") && len(status) > 4 && strings.TrimSpace(status[4]) != "" {
+		// parsing formats the set the same way the binlog stream does
+		gtids, err := parseGtidSet(status[4])
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.parseBinlogStatus)")
+		}
+		result.GTIDSet = gtids.String()
+	}
+
+	return &result, nil
+}
+
+type binlogEventReader interface {
+	GetEvent(ctx context.Context) (*replication.BinlogEvent, error)
+}
+
+func newMySqlBinlogStream(client MySqlApiClient, events binlogEventReader, options BinlogOptions, endPosition BinlogPosition) *mysqlBinlogStream {
+	return &mysqlBinlogStream{
+		client:      client,
+		events:      events,
+		options:     options,
+		position:    options.Position,
+		endPosition: endPosition,
+		tableIDs:    map[uint64]bool{},
+	}
+}
+
+type mysqlBinlogStream struct {
+	client      MySqlApiClient
+	syncer      *replication.BinlogSyncer
+	events      binlogEventReader
+	options     BinlogOptions
+	position    BinlogPosition
+	endPosition BinlogPosition
+
+	// the IDs the source gave the table in its table map events, and the table's columns
+	tableIDs     map[uint64]bool
+	tableColumns []binlogColumnInfo
+
+	// whether the transaction being read started with a GTID
+	inGtidTransaction bool
+}
+
+func (s *mysqlBinlogStream) Close() error {
+	if s.syncer != nil {
+		s.syncer.Close()
+	}
+
+	return nil
+}
+
+func (s *mysqlBinlogStream) Next(ctx context.Context) (*BinlogEvent, error) {
+	if s.position.Includes(s.endPosition) {
+		return nil, data.ErrDone
+	}
+
+	event, err := s.events.GetEvent(ctx)
+	if err != nil {
+		var mysqlErr *mysql.MyError
+		if errors.As(err, &mysqlErr) && mysqlErr.Code == mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG {
+			return nil, errors.NewCustomerVisibleError(fmt.Sprintf("could not read the binlog from %s, which may have been purged: %s", s.position, mysqlErr.Message))
+		}
+		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.mysqlBinlogStream.Next) reading event")
+	}
+
+	result := BinlogEvent{}
+	var gtids mysql.GTIDSet
+	switch e := event.Event.(type) {
+	case *replication.RotateEvent:
+		// rotations move to the start of the next file, and the source sends one first to name the current file
+		s.position.File = string(e.NextLogName)
+		s.position.Position = uint32(e.Position)
+		result.Position = s.position
+		return &result, nil
+	case *replication.GTIDEvent:
+		// the GTID of a transaction comes before it, and the transaction is only in the set once it commits
+		s.inGtidTransaction = true
+	case *replication.XIDEvent:
+		result.Commit = true
+		gtids = e.GSet
+	case *replication.QueryEvent:
+		// transactions on tables without transaction support end with a COMMIT statement instead of an XID. DDL
+		// statements are transactions of their own.
+		query := string(e.Query)
+		result.Commit = strings.EqualFold(query, "COMMIT") || (s.inGtidTransaction && !strings.EqualFold(query, "BEGIN"))
+		gtids = e.GSet
+	case *replication.TableMapEvent:
+		err = s.readTableMap(ctx, e)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.mysqlBinlogStream.Next)")
+		}
+	case *replication.RowsEvent:
+		result.Rows, err = s.readRows(event.Header.EventType, e)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.mysqlBinlogStream.Next)")
+		}
+	case *replication.GenericEvent:
+		// the event starts with the table ID, in the same 6 bytes as the other row events
+		if event.Header.EventType == binlogEventPartialUpdateRows && len(e.Data) >= 6 && s.tableIDs[peekBinlogTableID(e.Data)] {
+			return nil, errors.NewCustomerVisibleError("partial JSON updates can't be read. Set binlog_row_value_options to an empty value on the source.")
+		}
+	}
+
+	if event.Header.LogPos != 0 {
+		s.position.Position = event.Header.LogPos
+	}
+	if result.Commit {
+		// the set is only tracked when the stream was opened from one
+		if gtids != nil {
+			s.position.GTIDSet = gtids.String()
+		}
+		s.inGtidTransaction = false
+	}
+	result.Position = s.position
+
+	return &result, nil
+}
+
+func peekBinlogTableID(body []byte) uint64 {
+	var tableID uint64
+	for i := 5; i >= 0; i-- {
+		tableID = tableID<<8 | uint64(body[i])
+	}
+
+	return tableID
+}
+
+func (s *mysqlBinlogStream) readTableMap(ctx context.Context, tableMap *replication.TableMapEvent) error {
+	namespace, tableName := string(tableMap.Schema), string(tableMap.Table)
+	if namespace != s.options.Namespace || tableName != s.options.TableName {
+		return nil
+	}
+
+	// table maps only have column types, so the names and the details the types leave out come from the table's
+	// current definition
+	if s.tableColumns == nil {
+		columnInfo, err := s.client.getBinlogColumnInfo(ctx, namespace, tableName)
+		if err != nil {
+			return errors.Wrap(err, "(query.mysqlBinlogStream.readTableMap)")
+		}
+		s.tableColumns = columnInfo
+	}
+	if len(s.tableColumns) != int(tableMap.ColumnCount) {
+		return errors.NewCustomerVisibleError(fmt.Sprintf("the columns of table %s.%s changed since these changes were made", namespace, tableName))
+	}
+
+	s.tableIDs[tableMap.TableID] = true
+	return nil
+}
+
+// Updates have the row before each change followed by the row after it
+func (s *mysqlBinlogStream) readRows(eventType replication.EventType, rowsEvent *replication.RowsEvent) (*BinlogRowsEvent, error) {
+	if !s.tableIDs[rowsEvent.TableID] {
+		return nil, nil
+	}
+
+	event := BinlogRowsEvent{}
+	switch eventType {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		event.Type = BinlogRowsEventTypeInsert
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		event.Type = BinlogRowsEventTypeUpdate
+	default:
+		event.Type = BinlogRowsEventTypeDelete
+	}
+	for _, column := range s.tableColumns {
+		event.Columns = append(event.Columns, column.name)
+	}
+
+	for i, values := range rowsEvent.Rows {
+		if len(values) != len(s.tableColumns) {
+			return nil, errors.Newf("(query.mysqlBinlogStream.readRows) rows have %d columns but the table has %d", len(values), len(s.tableColumns))
+		}
+
+		row := make([]any, len(values))
+		for j, value := range values {
+			converted, err := convertBinlogValue(s.tableColumns[j], value)
+			if err != nil {
+				return nil, errors.Wrap(err, "(query.mysqlBinlogStream.readRows)")
+			}
+			row[j] = converted
+		}
+
+		if event.Type == BinlogRowsEventTypeUpdate && i%2 == 1 {
+			event.NewRows = append(event.NewRows, row)
+		} else {
+			event.Rows = append(event.Rows, row)
+		}
+	}
+
+	return &event, nil
+}
+
+type binlogColumnInfo struct {
+	name       string
+	columnType string // the full column type, like "int(10) unsigned" or "enum('a','b')"
+}
+
+func (mc MySqlApiClient) getBinlogColumnInfo(ctx context.Context, namespace string, tableName string) ([]binlogColumnInfo, error) {
+	results, err := mc.RunQuery(
+		ctx,
+		"SELECT column_name, column_type FROM INFORMATION_SCHEMA.COLUMNS WHERE table_schema = ? AND table_name = ? ORDER BY ordinal_position",
+		namespace, tableName,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "(query.MySqlApiClient.getBinlogColumnInfo)")
+	}
+
+	columns := []binlogColumnInfo{}
+	for _, row := range results.Data {
+		columns = append(columns, binlogColumnInfo{name: fmt.Sprintf("%s", row[0]), columnType: fmt.Sprintf("%s", row[1])})
+	}
+
+	return columns, nil
+}
+
+// Row events are decoded without knowing whether integer columns are unsigned, and with ENUM and SET columns as the
+// numbers MySQL stores them as. Values are converted to int64, uint64 for unsigned BIGINT and BIT columns, float64,
+// and strings for everything else, with JSON as its text and dates and times in the same format MySQL returns them in.
+func convertBinlogValue(column binlogColumnInfo, value any) (any, error) {
+	columnType := strings.ToLower(column.columnType)
+	unsigned := strings.Contains(columnType, "unsigned")
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case int8:
+		if unsigned {
+			return int64(uint8(v)), nil
+		}
+		return int64(v), nil
+	case int16:
+		if unsigned {
+			return int64(uint16(v)), nil
+		}
+		return int64(v), nil
+	case int32:
+		// MEDIUMINT values only have 3 bytes
+		if unsigned && strings.HasPrefix(columnType, "mediumint") {
+			return int64(uint32(v) & 0xffffff), nil
+		}
+		if unsigned {
+			return int64(uint32(v)), nil
+		}
+		return int64(v), nil
+	case int64:
+		switch {
+		case strings.HasPrefix(columnType, "enum("):
+			labels := parseMySqlTypeLabels(column.columnType)
+			// 0 is the empty string stored for invalid values
+			if v == 0 {
+				return "", nil
+			}
+			if v > int64(len(labels)) {
+				return nil, errors.Newf("(query.convertBinlogValue) enum value %d of column %s is out of range", v, column.name)
+			}
+			return labels[v-1], nil
+		case strings.HasPrefix(columnType, "set("):
+			var members []string
+			for i, label := range parseMySqlTypeLabels(column.columnType) {
+				if v&(1<<i) != 0 {
+					members = append(members, label)
+				}
+			}
+			return strings.Join(members, ","), nil
+		case unsigned, strings.HasPrefix(columnType, "bit"):
+			return uint64(v), nil
+		}
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	default:
+		return nil, errors.Newf("(query.convertBinlogValue) unexpected %T value in column %s", value, column.name)
+	}
+}
+
+// Gets the values of an ENUM or SET column type, like enum('a','b')
+func parseMySqlTypeLabels(columnType string) []string {
+	lowercased := strings.ToLower(columnType)
+	if !strings.HasPrefix(lowercased, "enum(") && !strings.HasPrefix(lowercased, "set(") {
+		return nil
+	}
+
+	labels := []string{}
+	var label strings.Builder
+	quoted := false
+	values := columnType[strings.Index(columnType, "(")+1:]
+	for i := 0; i < len(values); i++ {
+		switch {
+		case values[i] == '\'' && !quoted:
+			quoted = true
+			label.Reset()
+		case values[i] == '\'' && i+1 < len(values) && values[i+1] == '\'':
+			label.WriteByte('\'')
+			i++
+		case values[i] == '\'':
+			quoted = false
+			labels = append(labels, label.String())
+		case quoted:
+			label.WriteByte(values[i])
+		}
+	}
+
+	return labels
+}
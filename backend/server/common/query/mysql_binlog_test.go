@@ -0,0 +1,274 @@
+package query
+
+import (
+	"context"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const testTableID = 0x55
+
+var _ = Describe("convertBinlogValue", func() {
+	DescribeTable("converts values to the types the database driver reads",
+		func(columnType string, value any, expected any) {
+			converted, err := convertBinlogValue(binlogColumnInfo{name: "c", columnType: columnType}, value)
+			Expect(err).To(BeNil())
+			Expect(converted).To(Equal(expected))
+		},
+		Entry("TINYINT", "tinyint", int8(-1), int64(-1)),
+		Entry("TINYINT UNSIGNED", "tinyint unsigned", int8(-1), int64(255)),
+		Entry("SMALLINT UNSIGNED", "smallint unsigned", int16(-2), int64(65534)),
+		Entry("MEDIUMINT", "mediumint", int32(-3), int64(-3)),
+		Entry("MEDIUMINT UNSIGNED", "mediumint unsigned", int32(-1), int64(16777215)),
+		Entry("INT UNSIGNED", "int unsigned", int32(-1), int64(4294967295)),
+		Entry("BIGINT", "bigint", int64(-1), int64(-1)),
+		Entry("BIGINT UNSIGNED", "bigint unsigned", int64(-1), uint64(18446744073709551615)),
+		Entry("FLOAT", "float", float32(1.5), float64(1.5)),
+		Entry("DECIMAL", "decimal(10,2)", "-1234.56", "-1234.56"),
+		Entry("YEAR", "year", 2024, int64(2024)),
+		Entry("BIT", "bit(10)", int64(513), uint64(513)),
+		Entry("ENUM", "enum('a','b','c')", int64(2), "b"),
+		Entry("invalid ENUM", "enum('a','b','c')", int64(0), ""),
+		Entry("SET", "set('a','b','c')", int64(5), "a,c"),
+		Entry("BLOB", "blob", []byte("hello"), "hello"),
+		Entry("JSON", "json", `{"a":1}`, `{"a":1}`),
+	)
+
+	It("keeps NULL values", func() {
+		converted, err := convertBinlogValue(binlogColumnInfo{name: "c", columnType: "int"}, nil)
+		Expect(err).To(BeNil())
+		Expect(converted).To(BeNil())
+	})
+
+	It("reads labels with quotes", func() {
+		converted, err := convertBinlogValue(binlogColumnInfo{name: "c", columnType: "enum('it''s','b')"}, int64(1))
+		Expect(err).To(BeNil())
+		Expect(converted).To(Equal("it's"))
+	})
+})
+
+var _ = Describe("mysqlBinlogStream", func() {
+	It("reads both images of updated rows and deleted rows", func() {
+		stream := newTestBinlogStream(
+			binlogEvent(replication.TABLE_MAP_EVENT, 100, tableMapEvent("db", "t", 2)),
+			binlogEvent(replication.UPDATE_ROWS_EVENTv2, 200, rowsEvent([]any{int32(1), "abc"}, []any{int32(2), "def"})),
+			binlogEvent(replication.DELETE_ROWS_EVENTv1, 300, rowsEvent([]any{int32(2), "def"})),
+		)
+
+		_, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+
+		event, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Rows.Type).To(Equal(BinlogRowsEventTypeUpdate))
+		Expect(event.Rows.Columns).To(Equal([]string{"id", "name"}))
+		Expect(event.Rows.Rows).To(Equal([][]any{{int64(1), "abc"}}))
+		Expect(event.Rows.NewRows).To(Equal([][]any{{int64(2), "def"}}))
+		Expect(event.Position).To(Equal(BinlogPosition{File: "mysql-bin.000001", Position: 200}))
+
+		event, err = stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Rows.Type).To(Equal(BinlogRowsEventTypeDelete))
+		Expect(event.Rows.Rows).To(Equal([][]any{{int64(2), "def"}}))
+	})
+
+	It("skips rows of other tables", func() {
+		stream := newTestBinlogStream(
+			binlogEvent(replication.TABLE_MAP_EVENT, 100, tableMapEvent("db", "other", 1)),
+			binlogEvent(replication.WRITE_ROWS_EVENTv2, 200, rowsEvent([]any{int32(1)})),
+		)
+
+		_, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		event, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Rows).To(BeNil())
+		Expect(event.Position).To(Equal(BinlogPosition{File: "mysql-bin.000001", Position: 200}))
+	})
+
+	It("fails if the table has different columns than when the changes were made", func() {
+		stream := newTestBinlogStream(binlogEvent(replication.TABLE_MAP_EVENT, 100, tableMapEvent("db", "t", 3)))
+
+		_, err := stream.Next(context.TODO())
+		Expect(err.Error()).To(ContainSubstring("the columns of table db.t changed"))
+	})
+
+	It("follows rotations to the next file and ends where the binlog was when it was opened", func() {
+		stream := newTestBinlogStream(
+			binlogEvent(replication.ROTATE_EVENT, 0, &replication.RotateEvent{Position: 4, NextLogName: []byte("mysql-bin.000002")}),
+			binlogEvent(replication.QUERY_EVENT, 150, &replication.QueryEvent{Query: []byte("BEGIN")}),
+			binlogEvent(replication.XID_EVENT, 200, &replication.XIDEvent{}),
+		)
+		stream.endPosition = BinlogPosition{File: "mysql-bin.000002", Position: 200}
+
+		event, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Position).To(Equal(BinlogPosition{File: "mysql-bin.000002", Position: 4}))
+
+		event, err = stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Commit).To(BeFalse())
+
+		event, err = stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Commit).To(BeTrue())
+		Expect(event.Position).To(Equal(BinlogPosition{File: "mysql-bin.000002", Position: 200}))
+
+		_, err = stream.Next(context.TODO())
+		Expect(err).To(Equal(data.ErrDone))
+	})
+
+	It("reports binlogs the source purged", func() {
+		stream := newTestBinlogStream()
+		stream.events = &testBinlogEvents{err: &mysql.MyError{
+			Code:    mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG,
+			Message: "Could not find first log file name in binary log index file",
+		}}
+
+		_, err := stream.Next(context.TODO())
+		var customerVisibleError *errors.CustomerVisibleError
+		Expect(errors.As(err, &customerVisibleError)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("may have been purged"))
+	})
+
+	It("moves to the GTID set of each committed transaction", func() {
+		committed, _ := mysql.ParseMysqlGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6")
+		afterDdl, _ := mysql.ParseMysqlGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6,3e11fa47-71ca-11e1-9e33-c80aa9429563:1")
+		stream := newTestBinlogStream(
+			binlogEvent(replication.GTID_EVENT, 100, &replication.GTIDEvent{}),
+			binlogEvent(replication.QUERY_EVENT, 150, &replication.QueryEvent{Query: []byte("BEGIN"), GSet: committed}),
+			binlogEvent(replication.XID_EVENT, 200, &replication.XIDEvent{GSet: committed}),
+			binlogEvent(replication.GTID_EVENT, 250, &replication.GTIDEvent{}),
+			binlogEvent(replication.QUERY_EVENT, 300, &replication.QueryEvent{Query: []byte("ALTER TABLE t ADD COLUMN c INT"), GSet: afterDdl}),
+		)
+		stream.position.GTIDSet = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+		stream.endPosition = BinlogPosition{GTIDSet: afterDdl.String()}
+
+		_, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+
+		event, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Commit).To(BeFalse())
+		Expect(event.Position.String()).To(Equal("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"))
+
+		event, err = stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Commit).To(BeTrue())
+		Expect(event.Position.String()).To(Equal("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"))
+
+		_, err = stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		event, err = stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		Expect(event.Commit).To(BeTrue())
+		Expect(event.Position.String()).To(Equal("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6,3e11fa47-71ca-11e1-9e33-c80aa9429563:1"))
+
+		_, err = stream.Next(context.TODO())
+		Expect(err).To(Equal(data.ErrDone))
+	})
+
+	It("fails on partial JSON updates to the table", func() {
+		stream := newTestBinlogStream(
+			binlogEvent(replication.TABLE_MAP_EVENT, 100, tableMapEvent("db", "t", 2)),
+			binlogEvent(binlogEventPartialUpdateRows, 200, &replication.GenericEvent{Data: []byte{testTableID, 0, 0, 0, 0, 0, 0, 0}}),
+		)
+
+		_, err := stream.Next(context.TODO())
+		Expect(err).To(BeNil())
+		_, err = stream.Next(context.TODO())
+		Expect(err.Error()).To(ContainSubstring("partial JSON updates can't be read"))
+	})
+})
+
+var _ = Describe("BinlogPosition", func() {
+	It("parses file positions and GTID sets", func() {
+		position, err := ParseBinlogPosition("mysql-bin.000001:157")
+		Expect(err).To(BeNil())
+		Expect(*position).To(Equal(BinlogPosition{File: "mysql-bin.000001", Position: 157}))
+		Expect(position.String()).To(Equal("mysql-bin.000001:157"))
+
+		position, err = ParseBinlogPosition("gtid:3E11FA47-71CA-11E1-9E33-C80AA9429562:7-9:1-5:6,\n3e11fa47-71ca-11e1-9e33-c80aa9429561:3")
+		Expect(err).To(BeNil())
+		Expect(position.String()).To(Equal("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429561:3,3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9"))
+
+		_, err = ParseBinlogPosition("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:tag:1-5")
+		Expect(err).ToNot(BeNil())
+		_, err = ParseBinlogPosition("gtid:")
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("reads the position from the binary log status", func() {
+		status := []string{"mysql-bin.000003", "157", "", "", "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5,\n3e11fa47-71ca-11e1-9e33-c80aa9429563:1"}
+
+		position, err := parseBinlogStatus(status, []string{"ON"})
+		Expect(err).To(BeNil())
+		Expect(*position).To(Equal(BinlogPosition{File: "mysql-bin.000003", Position: 157, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,3e11fa47-71ca-11e1-9e33-c80aa9429563:1"}))
+
+		position, err = parseBinlogStatus(status, []string{"OFF"})
+		Expect(err).To(BeNil())
+		Expect(*position).To(Equal(BinlogPosition{File: "mysql-bin.000003", Position: 157}))
+	})
+
+	It("compares positions by GTID set when both have one, and by file and position otherwise", func() {
+		end := BinlogPosition{File: "mysql-bin.000009", Position: 157, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+
+		Expect(BinlogPosition{GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"}.Includes(end)).To(BeTrue())
+		Expect(BinlogPosition{GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-4:6"}.Includes(end)).To(BeFalse())
+		Expect(BinlogPosition{File: "mysql-bin.000009", Position: 157}.Includes(end)).To(BeTrue())
+		Expect(BinlogPosition{File: "mysql-bin.000009", Position: 4}.Includes(end)).To(BeFalse())
+		Expect(BinlogPosition{File: "mysql-bin.000008", Position: 900}.Includes(end)).To(BeFalse())
+		Expect(BinlogPosition{File: "mysql-bin.1000000", Position: 4}.Includes(end)).To(BeTrue())
+		Expect(BinlogPosition{}.Includes(end)).To(BeFalse())
+	})
+})
+
+type testBinlogEvents struct {
+	events []*replication.BinlogEvent
+	err    error
+}
+
+func (e *testBinlogEvents) GetEvent(ctx context.Context) (*replication.BinlogEvent, error) {
+	if len(e.events) == 0 {
+		if e.err != nil {
+			return nil, e.err
+		}
+		return nil, errors.New("no more events")
+	}
+
+	event := e.events[0]
+	e.events = e.events[1:]
+	return event, nil
+}
+
+// Reads the events as if the source sent them after the stream was opened at the start of mysql-bin.000001, for a
+// table with an id and a name column
+func newTestBinlogStream(events ...*replication.BinlogEvent) *mysqlBinlogStream {
+	options := BinlogOptions{Position: BinlogPosition{File: "mysql-bin.000001", Position: 4}, Namespace: "db", TableName: "t"}
+	endPosition := BinlogPosition{File: "mysql-bin.000009", Position: 4}
+	stream := newMySqlBinlogStream(MySqlApiClient{}, &testBinlogEvents{events: events}, options, endPosition)
+	stream.tableColumns = []binlogColumnInfo{{name: "id", columnType: "int"}, {name: "name", columnType: "varchar(10)"}}
+
+	return stream
+}
+
+func binlogEvent(eventType replication.EventType, nextPosition uint32, event replication.Event) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: eventType, LogPos: nextPosition},
+		Event:  event,
+	}
+}
+
+func tableMapEvent(namespace string, tableName string, numColumns int) *replication.TableMapEvent {
+	return &replication.TableMapEvent{TableID: testTableID, Schema: []byte(namespace), Table: []byte(tableName), ColumnCount: uint64(numColumns)}
+}
+
+func rowsEvent(rows ...[]any) *replication.RowsEvent {
+	return &replication.RowsEvent{TableID: testTableID, Rows: rows}
+}
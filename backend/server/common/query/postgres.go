@@ -10,7 +10,7 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 )
@@ -143,7 +143,7 @@ func (pc PostgresApiClient) RunQuery(ctx context.Context, queryString string, ar
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.Query(queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.RunQuery) running query")
 	}
@@ -177,14 +177,14 @@ func (pc PostgresApiClient) RunQuery(ctx context.Context, queryString string, ar
 	}, nil
 }
 
-func (pc PostgresApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (pc PostgresApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := pc.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.GetQueryIterator) opening connection")
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.GetQueryIterator) running query")
 	}
@@ -200,6 +200,79 @@ func (pc PostgresApiClient) GetQueryIterator(ctx context.Context, queryString st
 	}, nil
 }
 
+// Bulk loads the rows into the table using COPY
+func (pc PostgresApiClient) LoadData(ctx context.Context, namespace string, tableName string, columns []string, rows []data.Row) error {
+	client, err := pc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) opening connection")
+	}
+	defer client.Close()
+
+	txn, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) starting transaction")
+	}
+	defer txn.Rollback()
+
+	stmt, err := txn.PrepareContext(ctx, pq.CopyInSchema(namespace, tableName, columns...))
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) preparing copy")
+	}
+
+	for _, row := range rows {
+		_, err = stmt.ExecContext(ctx, row...)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) copying row")
+		}
+	}
+
+	// an empty exec flushes the buffered data
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) flushing copy")
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) closing copy")
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.LoadData) committing transaction")
+	}
+
+	return nil
+}
+
+func (pc PostgresApiClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	client, err := pc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.ExecuteInTransaction) opening connection")
+	}
+	defer client.Close()
+
+	txn, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.ExecuteInTransaction) starting transaction")
+	}
+	defer txn.Rollback()
+
+	for _, statement := range statements {
+		_, err = txn.ExecContext(ctx, statement)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.ExecuteInTransaction) executing statement")
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.PostgresApiClient.ExecuteInTransaction) committing transaction")
+	}
+
+	return nil
+}
+
 func getPostgresFieldType(postgresType string) data.FieldType {
 	uppepcased := strings.ToUpper(postgresType)
 	switch uppepcased {
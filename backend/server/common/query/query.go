@@ -3,26 +3,62 @@ package query
 import (
 	"context"
 	"encoding/json"
+	"io"
 
 	"cloud.google.com/go/bigquery"
+	"go.fabra.io/server/common/application"
 	"go.fabra.io/server/common/crypto"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/storage"
 )
 
-const FABRA_TIMESTAMP_TZ_FORMAT = "2006-01-02 15:04:05.000-07:00"
-const FABRA_TIMESTAMP_NTZ_FORMAT = "2006-01-02 15:04:05.000"
+const FABRA_TIMESTAMP_TZ_FORMAT = data.TIMESTAMP_TZ_FORMAT
+const FABRA_TIMESTAMP_NTZ_FORMAT = data.TIMESTAMP_NTZ_FORMAT
 
 type StagingOptions struct {
 	Bucket string
 	Object string
 }
 
+// Writes the data to stage. StageData streams it to staging storage as it is written, so it is never held in memory in full.
+type StagingDataWriter func(w io.Writer) error
+
+// Runs the writer into a pipe while the upload reads from the other end, returning the first error from either side
+func streamStagingData(writeData StagingDataWriter, upload func(r io.Reader) error) error {
+	pipeReader, pipeWriter := io.Pipe()
+	writeErrC := make(chan error, 1)
+	go func() {
+		err := writeData(pipeWriter)
+		pipeWriter.CloseWithError(err)
+		writeErrC <- err
+	}()
+
+	uploadErr := upload(pipeReader)
+	// unblock the writer if the upload stopped reading early
+	pipeReader.CloseWithError(io.ErrClosedPipe)
+	writeErr := <-writeErrC
+
+	// the writer only sees a closed pipe if the upload failed first, so report the upload error in that case
+	if writeErr != nil && (uploadErr == nil || !errors.Is(writeErr, io.ErrClosedPipe)) {
+		return writeErr
+	}
+
+	return uploadErr
+}
+
 type LoadOptions struct {
 	GcsReference   string
 	BigQuerySchema bigquery.Schema
 	WriteMode      bigquery.TableWriteDisposition
+
+	// Used by warehouses that load staged files with COPY
+	StagingReference string
+	Columns          []string
+
+	// Columns staged as JSON text, for warehouses that parse them into a JSON type while loading
+	JsonColumns []string
 }
 
 type QueryService interface {
@@ -31,9 +67,10 @@ type QueryService interface {
 	GetSchema(ctx context.Context, connection *models.Connection, namespace string, tableName string) ([]data.Field, error)
 	GetFieldValues(ctx context.Context, connection *models.Connection, namespace string, tableName string, fieldName string) ([]any, error)
 	RunQuery(ctx context.Context, connection *models.Connection, queryString string) (*data.QueryResults, error)
-	GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string) (data.RowIterator, error)
+	GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string, args ...any) (data.RowIterator, error)
 	GetClient(ctx context.Context, connection *models.Connection) (ConnectorClient, error)
 	GetWarehouseClient(ctx context.Context, connection *models.Connection) (WarehouseClient, error)
+	GetDatabaseClient(ctx context.Context, connection *models.Connection) (DatabaseClient, error)
 }
 
 type QueryServiceImpl struct {
@@ -52,19 +89,21 @@ type ConnectorClient interface {
 	GetNamespaces(ctx context.Context) ([]string, error)
 	GetFieldValues(ctx context.Context, namespace string, tableName string, fieldName string) ([]any, error)
 	RunQuery(ctx context.Context, queryString string, args ...any) (*data.QueryResults, error)
-	GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error)
+	GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error)
 }
 
 type WarehouseClient interface {
 	ConnectorClient
-	StageData(ctx context.Context, csvData string, stagingOptions StagingOptions) error
+	StageData(ctx context.Context, writeData StagingDataWriter, stagingOptions StagingOptions) error
 	LoadFromStaging(ctx context.Context, namespace string, tableName string, loadOptions LoadOptions) error
 	CleanUpStagingData(ctx context.Context, stagingOptions StagingOptions) error
+	ExecuteInTransaction(ctx context.Context, statements ...string) error
 }
 
 type DatabaseClient interface {
 	ConnectorClient
-	LoadData(ctx context.Context, namespace string, tableName string, rows []data.Row) error
+	LoadData(ctx context.Context, namespace string, tableName string, columns []string, rows []data.Row) error
+	ExecuteInTransaction(ctx context.Context, statements ...string) error
 }
 
 func (qs QueryServiceImpl) GetClient(ctx context.Context, connection *models.Connection) (ConnectorClient, error) {
@@ -100,6 +139,7 @@ func (qs QueryServiceImpl) GetClient(ctx context.Context, connection *models.Con
 			KeyID:     connection.Username.String,
 			AccessKey: *password,
 			Location:  connection.Location.String,
+			Endpoint:  application.GetDynamoDbEndpoint(),
 		}, nil
 
 	case models.ConnectionTypeSnowflake:
@@ -210,6 +250,59 @@ func (qs QueryServiceImpl) GetWarehouseClient(ctx context.Context, connection *m
 			Credentials: bigQueryCredentialsString,
 			Location:    &connection.Location.String,
 		}, nil
+	case models.ConnectionTypeRedshift:
+		redshiftPassword, err := qs.cryptoService.DecryptConnectionCredentials(connection.Password.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetWarehouseClient) decrypting Redshift password")
+		}
+
+		if !connection.Credentials.Valid {
+			return nil, errors.NewCustomerVisibleError("Redshift destination must have staging credentials defined")
+		}
+
+		stagingCredentialsString, err := qs.cryptoService.DecryptConnectionCredentials(connection.Credentials.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetWarehouseClient) decrypting Redshift staging credentials")
+		}
+
+		var stagingCredentials models.AwsStagingCredentials
+		err = json.Unmarshal([]byte(*stagingCredentialsString), &stagingCredentials)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetWarehouseClient) unmarshalling Redshift staging credentials")
+		}
+
+		redshiftClient := RedshiftApiClient{
+			Username:     connection.Username.String,
+			Password:     *redshiftPassword,
+			DatabaseName: connection.DatabaseName.String,
+			Host:         connection.Host.String,
+			StagingStorage: storage.S3Storage{
+				AccessKeyID:     stagingCredentials.AccessKeyID,
+				SecretAccessKey: stagingCredentials.SecretAccessKey,
+				Region:          stagingCredentials.Region,
+				Endpoint:        application.GetS3Endpoint(),
+			},
+			StagingRegion: stagingCredentials.Region,
+		}
+		if connection.Role.Valid {
+			redshiftClient.IamRole = &connection.Role.String
+		}
+
+		return redshiftClient, nil
+	case models.ConnectionTypeSnowflake:
+		snowflakePassword, err := qs.cryptoService.DecryptConnectionCredentials(connection.Password.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetWarehouseClient) decrypting Snowflake password")
+		}
+
+		return SnowflakeApiClient{
+			Username:      connection.Username.String,
+			Password:      *snowflakePassword,
+			WarehouseName: connection.WarehouseName.String,
+			DatabaseName:  connection.DatabaseName.String,
+			Role:          connection.Role.String,
+			Host:          connection.Host.String,
+		}, nil
 	default:
 		return nil, errors.Newf("(query.QueryServiceImpl.GetWarehouseClient) unrecognized warehouse type %v", connection.ConnectionType)
 	}
@@ -231,6 +324,31 @@ func (qs QueryServiceImpl) GetDatabaseClient(ctx context.Context, connection *mo
 			KeyID:     connection.Username.String,
 			AccessKey: *dynamoDbAccessKey,
 			Location:  connection.Location.String,
+			Endpoint:  application.GetDynamoDbEndpoint(),
+		}, nil
+	case models.ConnectionTypePostgres:
+		postgresPassword, err := qs.cryptoService.DecryptConnectionCredentials(connection.Password.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetDatabaseClient) decrypting Postgres password")
+		}
+
+		return PostgresApiClient{
+			Username:     connection.Username.String,
+			Password:     *postgresPassword,
+			DatabaseName: connection.DatabaseName.String,
+			Host:         connection.Host.String,
+		}, nil
+	case models.ConnectionTypeMySQL:
+		mysqlPassword, err := qs.cryptoService.DecryptConnectionCredentials(connection.Password.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetDatabaseClient) decrypting MySQL password")
+		}
+
+		return MySqlApiClient{
+			Username:     connection.Username.String,
+			Password:     *mysqlPassword,
+			DatabaseName: connection.DatabaseName.String,
+			Host:         connection.Host.String,
 		}, nil
 	default:
 		return nil, errors.Newf("(query.QueryServiceImpl.GetDatabaseClient) unrecognized database type %v", connection.ConnectionType)
@@ -246,13 +364,13 @@ func (qs QueryServiceImpl) RunQuery(ctx context.Context, connection *models.Conn
 	return client.RunQuery(ctx, queryString)
 }
 
-func (qs QueryServiceImpl) GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string) (data.RowIterator, error) {
+func (qs QueryServiceImpl) GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := qs.GetClient(ctx, connection)
 	if err != nil {
 		return nil, errors.Wrap(err, "(query.QueryServiceImpl.GetQueryIterator)")
 	}
 
-	return client.GetQueryIterator(ctx, queryString)
+	return client.GetQueryIterator(ctx, queryString, args...)
 }
 
 func (qs QueryServiceImpl) GetNamespaces(ctx context.Context, connection *models.Connection) ([]string, error) {
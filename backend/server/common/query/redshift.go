@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	_ "github.com/lib/pq"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/storage"
 )
 
 type RedshiftApiClient struct {
@@ -20,6 +22,12 @@ type RedshiftApiClient struct {
 	Password     string
 	DatabaseName string
 	Host         string
+
+	// Only needed when loading data into Redshift. The staging credentials only write to the bucket, and COPY reads it
+	// with the IAM role so no secret is ever sent in a query.
+	StagingStorage storage.ObjectStorage
+	StagingRegion  string
+	IamRole        *string
 }
 
 type redshiftIterator struct {
@@ -143,7 +151,7 @@ func (rc RedshiftApiClient) RunQuery(ctx context.Context, queryString string, ar
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.RunQuery) running query")
 	}
@@ -177,14 +185,106 @@ func (rc RedshiftApiClient) RunQuery(ctx context.Context, queryString string, ar
 	}, nil
 }
 
-func (rc RedshiftApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (rc RedshiftApiClient) StageData(ctx context.Context, writeData StagingDataWriter, stagingOptions StagingOptions) error {
+	if rc.StagingStorage == nil {
+		return errors.New("(query.RedshiftApiClient.StageData) missing staging storage")
+	}
+
+	err := streamStagingData(writeData, func(r io.Reader) error {
+		return rc.StagingStorage.PutObject(ctx, stagingOptions.Bucket, stagingOptions.Object, r)
+	})
+	if err != nil {
+		return errors.Wrap(err, "(query.RedshiftApiClient.StageData)")
+	}
+
+	return nil
+}
+
+// Loads every staged object matching the staging reference prefix with a single COPY
+func (rc RedshiftApiClient) LoadFromStaging(ctx context.Context, namespace string, tableName string, loadOptions LoadOptions) error {
+	copyQuery, err := rc.getCopyQuery(namespace, tableName, loadOptions)
+	if err != nil {
+		return errors.Wrap(err, "(query.RedshiftApiClient.LoadFromStaging)")
+	}
+
+	err = rc.ExecuteInTransaction(ctx, copyQuery)
+	if err != nil {
+		return errors.Wrap(err, "(query.RedshiftApiClient.LoadFromStaging)")
+	}
+
+	return nil
+}
+
+func (rc RedshiftApiClient) getCopyQuery(namespace string, tableName string, loadOptions LoadOptions) (string, error) {
+	if rc.IamRole == nil {
+		return "", errors.NewCustomerVisibleError("Redshift destination must have an IAM role to load staged data")
+	}
+
+	authorization := fmt.Sprintf("IAM_ROLE '%s'", *rc.IamRole)
+	if rc.StagingRegion != "" {
+		authorization += fmt.Sprintf(" REGION '%s'", rc.StagingRegion)
+	}
+
+	return fmt.Sprintf(
+		"COPY %s.%s (%s) FROM '%s' %s FORMAT AS CSV EMPTYASNULL TIMEFORMAT 'auto'",
+		namespace, tableName, strings.Join(loadOptions.Columns, ","), loadOptions.StagingReference, authorization,
+	), nil
+}
+
+func (rc RedshiftApiClient) CleanUpStagingData(ctx context.Context, stagingOptions StagingOptions) error {
+	if rc.StagingStorage == nil {
+		return errors.New("(query.RedshiftApiClient.CleanUpStagingData) missing staging storage")
+	}
+
+	err := rc.StagingStorage.DeleteObjects(ctx, stagingOptions.Bucket, stagingOptions.Object)
+	if err != nil {
+		return errors.Wrap(err, "(query.RedshiftApiClient.CleanUpStagingData)")
+	}
+
+	return nil
+}
+
+func (rc RedshiftApiClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	client, err := rc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.ExecuteInTransaction) opening connection")
+	}
+	defer client.Close()
+
+	txn, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.ExecuteInTransaction) starting transaction")
+	}
+	defer txn.Rollback()
+
+	for _, statement := range statements {
+		_, err = txn.ExecContext(ctx, statement)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.ExecuteInTransaction) executing statement")
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.ExecuteInTransaction) committing transaction")
+	}
+
+	return nil
+}
+
+// Returns the S3 reference that COPY reads the staged objects from
+func GetS3StagingReference(bucket string, object string) string {
+	return fmt.Sprintf("s3://%s/%s", bucket, object)
+}
+
+func (rc RedshiftApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := rc.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.GetQueryIterator) opening connection")
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.RedshiftApiClient.GetQueryIterator) running query")
 	}
@@ -0,0 +1,52 @@
+package query
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.fabra.io/server/common/storage"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redshift staging", func() {
+	It("stages data and cleans up every object under the prefix", func() {
+		rootDir := GinkgoT().TempDir()
+		client := RedshiftApiClient{StagingStorage: storage.LocalStorage{RootDir: rootDir}}
+
+		for _, object := range []string{"run/0.csv", "run/1.csv", "other/0.csv"} {
+			err := client.StageData(context.TODO(), func(w io.Writer) error {
+				_, err := io.WriteString(w, "1,first\n")
+				return err
+			}, StagingOptions{Bucket: "bucket", Object: object})
+			Expect(err).To(BeNil())
+		}
+
+		staged, err := os.ReadFile(filepath.Join(rootDir, "bucket", "run", "0.csv"))
+		Expect(err).To(BeNil())
+		Expect(string(staged)).To(Equal("1,first\n"))
+
+		err = client.CleanUpStagingData(context.TODO(), StagingOptions{Bucket: "bucket", Object: "run/"})
+		Expect(err).To(BeNil())
+
+		_, err = os.Stat(filepath.Join(rootDir, "bucket", "run", "0.csv"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(filepath.Join(rootDir, "bucket", "other", "0.csv"))
+		Expect(err).To(BeNil())
+	})
+
+	It("loads staged data with the IAM role instead of the staging credentials", func() {
+		role := "arn:aws:iam::123456789012:role/redshift"
+		client := RedshiftApiClient{StagingRegion: "us-east-1", IamRole: &role}
+
+		copyQuery, err := client.getCopyQuery("schema", "table", LoadOptions{StagingReference: "s3://bucket/run/", Columns: []string{"id", "name"}})
+		Expect(err).To(BeNil())
+		Expect(copyQuery).To(Equal("COPY schema.table (id,name) FROM 's3://bucket/run/' IAM_ROLE 'arn:aws:iam::123456789012:role/redshift' REGION 'us-east-1' FORMAT AS CSV EMPTYASNULL TIMEFORMAT 'auto'"))
+
+		_, err = RedshiftApiClient{}.getCopyQuery("schema", "table", LoadOptions{})
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -5,12 +5,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/snowflakedb/gosnowflake"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/sqlbuilder"
 )
 
 const SNOWFLAKE_TZ_FORMAT = "2006-01-02T15:04:05.000-07:00"
@@ -198,7 +201,7 @@ func (sc SnowflakeApiClient) RunQuery(ctx context.Context, queryString string, a
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.RunQuery) running query")
 	}
@@ -232,14 +235,14 @@ func (sc SnowflakeApiClient) RunQuery(ctx context.Context, queryString string, a
 	}, nil
 }
 
-func (sc SnowflakeApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (sc SnowflakeApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := sc.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.GetQueryIterator) opening connection")
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.GetQueryIterator) running query")
 	}
@@ -255,6 +258,136 @@ func (sc SnowflakeApiClient) GetQueryIterator(ctx context.Context, queryString s
 	}, nil
 }
 
+// Uploads the data to a Snowflake internal stage. The bucket is the name of the stage, and defaults to the user stage.
+func (sc SnowflakeApiClient) StageData(ctx context.Context, writeData StagingDataWriter, stagingOptions StagingOptions) error {
+	client, err := sc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.StageData) opening connection")
+	}
+	defer client.Close()
+
+	// PUT normally reads a local file, but the driver can stream the data instead. The file name is still used as the staged file name.
+	stageDirectory, fileName := path.Split(stagingOptions.Object)
+	putQuery := fmt.Sprintf(
+		"PUT 'file:///tmp/%s' '%s/%s' AUTO_COMPRESS=TRUE OVERWRITE=TRUE",
+		fileName, getSnowflakeStage(stagingOptions.Bucket), stageDirectory,
+	)
+
+	err = streamStagingData(writeData, func(r io.Reader) error {
+		_, err := client.ExecContext(gosnowflake.WithFileStream(ctx, r), putQuery)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.StageData) uploading data")
+	}
+
+	return nil
+}
+
+func (sc SnowflakeApiClient) LoadFromStaging(ctx context.Context, namespace string, tableName string, loadOptions LoadOptions) error {
+	client, err := sc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.LoadFromStaging) opening connection")
+	}
+	defer client.Close()
+
+	quotedColumns := make([]string, len(loadOptions.Columns))
+	for i, column := range loadOptions.Columns {
+		quotedColumns[i] = sqlbuilder.DialectSnowflake.QuoteIdentifier(column)
+	}
+
+	copyQuery := fmt.Sprintf(
+		"COPY INTO %s (%s) FROM %s FILE_FORMAT = (TYPE = CSV FIELD_OPTIONALLY_ENCLOSED_BY = '\"' TIMESTAMP_FORMAT = 'AUTO') ON_ERROR = ABORT_STATEMENT",
+		sqlbuilder.DialectSnowflake.QuoteTable(namespace, tableName), strings.Join(quotedColumns, ","), getSnowflakeCopySource(loadOptions),
+	)
+
+	_, err = client.ExecContext(ctx, copyQuery)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.LoadFromStaging) copying data")
+	}
+
+	return nil
+}
+
+// JSON is staged as text, which would be loaded into VARIANT columns as a string, so those columns are parsed with a
+// transform. Transforms select each column by its position in the staged files.
+func getSnowflakeCopySource(loadOptions LoadOptions) string {
+	if len(loadOptions.JsonColumns) == 0 {
+		return fmt.Sprintf("'%s'", loadOptions.StagingReference)
+	}
+
+	jsonColumns := map[string]bool{}
+	for _, column := range loadOptions.JsonColumns {
+		jsonColumns[column] = true
+	}
+
+	selectColumns := make([]string, len(loadOptions.Columns))
+	for i, column := range loadOptions.Columns {
+		selectColumns[i] = fmt.Sprintf("$%d", i+1)
+		if jsonColumns[column] {
+			selectColumns[i] = fmt.Sprintf("PARSE_JSON($%d)", i+1)
+		}
+	}
+
+	return fmt.Sprintf("(SELECT %s FROM %s)", strings.Join(selectColumns, ", "), loadOptions.StagingReference)
+}
+
+func (sc SnowflakeApiClient) CleanUpStagingData(ctx context.Context, stagingOptions StagingOptions) error {
+	client, err := sc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.CleanUpStagingData) opening connection")
+	}
+	defer client.Close()
+
+	_, err = client.ExecContext(ctx, fmt.Sprintf("REMOVE '%s/%s'", getSnowflakeStage(stagingOptions.Bucket), stagingOptions.Object))
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.CleanUpStagingData) removing staged data")
+	}
+
+	return nil
+}
+
+func (sc SnowflakeApiClient) ExecuteInTransaction(ctx context.Context, statements ...string) error {
+	client, err := sc.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.ExecuteInTransaction) opening connection")
+	}
+	defer client.Close()
+
+	txn, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.ExecuteInTransaction) starting transaction")
+	}
+	defer txn.Rollback()
+
+	for _, statement := range statements {
+		_, err = txn.ExecContext(ctx, statement)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.ExecuteInTransaction) executing statement")
+		}
+	}
+
+	err = txn.Commit()
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SnowflakeApiClient.ExecuteInTransaction) committing transaction")
+	}
+
+	return nil
+}
+
+// Returns the reference for the stage used to hold files before loading
+func GetSnowflakeStagingReference(stageName string, object string) string {
+	return fmt.Sprintf("%s/%s", getSnowflakeStage(stageName), object)
+}
+
+func getSnowflakeStage(stageName string) string {
+	if stageName == "" {
+		return "@~"
+	}
+
+	return fmt.Sprintf("@%s", stageName)
+}
+
 func convertSnowflakeRow(snowflakeRow []any, schema data.Schema) data.Row {
 	row := make(data.Row, len(snowflakeRow))
 	for i, value := range snowflakeRow {
@@ -0,0 +1,24 @@
+package query
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snowflake COPY source", func() {
+	It("reads staged files directly when there are no JSON columns", func() {
+		source := getSnowflakeCopySource(LoadOptions{StagingReference: "@~/prefix/", Columns: []string{"id", "name"}})
+
+		Expect(source).To(Equal("'@~/prefix/'"))
+	})
+
+	It("parses JSON columns by their position in the staged files", func() {
+		source := getSnowflakeCopySource(LoadOptions{
+			StagingReference: "@stage/prefix/",
+			Columns:          []string{"id", "json", "end_customer_id"},
+			JsonColumns:      []string{"json"},
+		})
+
+		Expect(source).To(Equal("(SELECT $1, PARSE_JSON($2), $3 FROM @stage/prefix/)"))
+	})
+})
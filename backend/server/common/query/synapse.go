@@ -148,7 +148,7 @@ func (sc SynapseApiClient) RunQuery(ctx context.Context, queryString string, arg
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SynapseApiClient.RunQuery) running query")
 	}
@@ -182,14 +182,14 @@ func (sc SynapseApiClient) RunQuery(ctx context.Context, queryString string, arg
 	}, nil
 }
 
-func (sc SynapseApiClient) GetQueryIterator(ctx context.Context, queryString string) (data.RowIterator, error) {
+func (sc SynapseApiClient) GetQueryIterator(ctx context.Context, queryString string, args ...any) (data.RowIterator, error) {
 	client, err := sc.openConnection(ctx)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SynapseApiClient.GetQueryIterator) opening connection")
 	}
 	defer client.Close()
 
-	queryResult, err := client.Query(queryString)
+	queryResult, err := client.QueryContext(ctx, queryString, args...)
 	if err != nil {
 		return nil, errors.Wrap(errors.WrapCustomerVisibleError(err), "(query.SynapseApiClient.GetQueryIterator) running query")
 	}
@@ -1,6 +1,9 @@
 package connections
 
 import (
+	"encoding/json"
+	"time"
+
 	"go.fabra.io/server/common/database"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/input"
@@ -104,6 +107,7 @@ func CreateRedshiftConnection(
 	organizationID int64,
 	redshiftConfig input.RedshiftConfig,
 	encryptedPassword string,
+	encryptedStagingCredentials *string,
 ) (*models.Connection, error) {
 	connection := models.Connection{
 		OrganizationID: organizationID,
@@ -112,6 +116,8 @@ func CreateRedshiftConnection(
 		Password:       database.NewNullString(encryptedPassword),
 		DatabaseName:   database.NewNullString(redshiftConfig.DatabaseName),
 		Host:           database.NewNullString(redshiftConfig.Endpoint), // we just use the host field to store the whole endpoint (including port)
+		Credentials:    database.NewNullStringFromPtr(encryptedStagingCredentials),
+		Role:           database.NewNullStringFromPtr(redshiftConfig.IamRole),
 	}
 
 	result := db.Create(&connection)
@@ -222,6 +228,7 @@ func CreateWebhookConnection(
 	organizationID int64,
 	webhookConfig input.WebhookConfig,
 	encryptedSigningKey string,
+	encryptedHeaders *string,
 ) (*models.Connection, error) {
 	connection := models.Connection{
 		OrganizationID: organizationID,
@@ -230,6 +237,11 @@ func CreateWebhookConnection(
 		Credentials:    database.NewNullString(encryptedSigningKey), // store signing key in the credentials column
 	}
 
+	// store custom headers in the password column since they often contain secrets
+	if encryptedHeaders != nil {
+		connection.Password = database.NewNullString(*encryptedHeaders)
+	}
+
 	result := db.Create(&connection)
 	if result.Error != nil {
 		return nil, errors.Wrap(result.Error, "(connections.CreateWebhookConnection)")
@@ -237,3 +249,85 @@ func CreateWebhookConnection(
 
 	return &connection, nil
 }
+
+func UpdateWebhookHeaders(db *gorm.DB, organizationID int64, connectionID int64, encryptedHeaders *string) (*models.Connection, error) {
+	connection, err := LoadConnectionByID(db, organizationID, connectionID)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connections.UpdateWebhookHeaders)")
+	}
+
+	if connection.ConnectionType != models.ConnectionTypeWebhook {
+		return nil, errors.NewBadRequest("headers can only be set on webhook destinations")
+	}
+
+	connection.Password = database.NewNullStringFromPtr(encryptedHeaders)
+	result := db.Save(connection)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(connections.UpdateWebhookHeaders)")
+	}
+
+	return connection, nil
+}
+
+// Replaces the signing key, keeping the current one valid until previousKeyExpiresAt along with any earlier keys that
+// haven't expired yet
+func RotateWebhookSigningKey(
+	db *gorm.DB,
+	organizationID int64,
+	connectionID int64,
+	encryptedSigningKey string,
+	previousKeyExpiresAt time.Time,
+) (*models.Connection, error) {
+	connection, err := LoadConnectionByID(db, organizationID, connectionID)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connections.RotateWebhookSigningKey)")
+	}
+
+	if connection.ConnectionType != models.ConnectionTypeWebhook {
+		return nil, errors.NewBadRequest("signing keys can only be rotated for webhook destinations")
+	}
+
+	var previousSigningKeys []models.PreviousSigningKey
+	if connection.PreviousSigningKeys.Valid {
+		err = json.Unmarshal([]byte(connection.PreviousSigningKeys.String), &previousSigningKeys)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connections.RotateWebhookSigningKey) unmarshalling previous signing keys")
+		}
+	}
+
+	now := time.Now()
+	unexpiredSigningKeys := []models.PreviousSigningKey{}
+	for _, previousSigningKey := range previousSigningKeys {
+		if now.Before(previousSigningKey.ExpiresAt) {
+			unexpiredSigningKeys = append(unexpiredSigningKeys, previousSigningKey)
+		}
+	}
+	unexpiredSigningKeys = append(unexpiredSigningKeys, models.PreviousSigningKey{
+		EncryptedSigningKey: connection.Credentials.String,
+		ExpiresAt:           previousKeyExpiresAt,
+	})
+
+	marshalled, err := json.Marshal(unexpiredSigningKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connections.RotateWebhookSigningKey) marshalling previous signing keys")
+	}
+
+	connection.PreviousSigningKeys = database.NewNullString(string(marshalled))
+	connection.Credentials = database.NewNullString(encryptedSigningKey)
+	result := db.Save(connection)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(connections.RotateWebhookSigningKey)")
+	}
+
+	return connection, nil
+}
+
+func UpdateMaxReadConcurrency(db *gorm.DB, connection *models.Connection, maxReadConcurrency *int) (*models.Connection, error) {
+	connection.MaxReadConcurrency = maxReadConcurrency
+	result := db.Save(connection)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(connections.UpdateMaxReadConcurrency)")
+	}
+
+	return connection, nil
+}
@@ -3,6 +3,7 @@ package destinations
 import (
 	"go.fabra.io/server/common/database"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/input"
 	"go.fabra.io/server/common/models"
 
 	"gorm.io/gorm"
@@ -14,6 +15,7 @@ func CreateDestination(
 	displayName string,
 	connectionID int64,
 	stagingBucket *string,
+	webhookDeliveryOptions *input.WebhookDeliveryOptions,
 ) (*models.Destination, error) {
 
 	destination := models.Destination{
@@ -26,6 +28,14 @@ func CreateDestination(
 		destination.StagingBucket = database.NewNullString(*stagingBucket)
 	}
 
+	if webhookDeliveryOptions != nil {
+		destination.WebhookRequestsPerSecond = webhookDeliveryOptions.RequestsPerSecond
+		destination.WebhookBurst = webhookDeliveryOptions.Burst
+		destination.WebhookBatchSize = webhookDeliveryOptions.BatchSize
+		destination.WebhookMaxPayloadBytes = webhookDeliveryOptions.MaxPayloadBytes
+		destination.WebhookMaxDeadLetters = webhookDeliveryOptions.MaxDeadLetters
+	}
+
 	result := db.Create(&destination)
 	if result.Error != nil {
 		return nil, errors.Wrap(result.Error, "(destinations.CreateDestination)")
@@ -34,6 +44,32 @@ func CreateDestination(
 	return &destination, nil
 }
 
+// Replaces all webhook delivery settings on the destination. Unset options fall back to the defaults.
+func UpdateWebhookDeliveryOptions(
+	db *gorm.DB,
+	organizationID int64,
+	destinationID int64,
+	webhookDeliveryOptions input.WebhookDeliveryOptions,
+) (*models.Destination, error) {
+	destination, err := LoadDestinationByID(db, organizationID, destinationID)
+	if err != nil {
+		return nil, errors.Wrap(err, "(destinations.UpdateWebhookDeliveryOptions)")
+	}
+
+	destination.WebhookRequestsPerSecond = webhookDeliveryOptions.RequestsPerSecond
+	destination.WebhookBurst = webhookDeliveryOptions.Burst
+	destination.WebhookBatchSize = webhookDeliveryOptions.BatchSize
+	destination.WebhookMaxPayloadBytes = webhookDeliveryOptions.MaxPayloadBytes
+	destination.WebhookMaxDeadLetters = webhookDeliveryOptions.MaxDeadLetters
+
+	result := db.Save(destination)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(destinations.UpdateWebhookDeliveryOptions)")
+	}
+
+	return destination, nil
+}
+
 // TODO: test that connection credentials are not exposed
 func LoadDestinationByID(db *gorm.DB, organizationID int64, destinationID int64) (*models.Destination, error) {
 	var destination models.Destination
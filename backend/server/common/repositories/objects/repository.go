@@ -24,6 +24,8 @@ func CreateObject(
 	recurring bool,
 	frequency *int64,
 	frequencyUnits *models.FrequencyUnits,
+	webhookDeliveryOptions *input.WebhookDeliveryOptions,
+	schemaDriftPolicy *models.SchemaDriftPolicy,
 ) (*models.Object, error) {
 
 	object := models.Object{
@@ -36,6 +38,11 @@ func CreateObject(
 		Recurring:          recurring,
 		Frequency:          frequency,
 		FrequencyUnits:     frequencyUnits,
+		SchemaDriftPolicy:  models.SchemaDriftPolicyIgnoreNewColumns,
+	}
+
+	if schemaDriftPolicy != nil {
+		object.SchemaDriftPolicy = *schemaDriftPolicy
 	}
 
 	if namespace != nil {
@@ -50,6 +57,14 @@ func CreateObject(
 		object.CursorField = database.NewNullString(*cursorField)
 	}
 
+	if webhookDeliveryOptions != nil {
+		object.WebhookRequestsPerSecond = webhookDeliveryOptions.RequestsPerSecond
+		object.WebhookBurst = webhookDeliveryOptions.Burst
+		object.WebhookBatchSize = webhookDeliveryOptions.BatchSize
+		object.WebhookMaxPayloadBytes = webhookDeliveryOptions.MaxPayloadBytes
+		object.WebhookMaxDeadLetters = webhookDeliveryOptions.MaxDeadLetters
+	}
+
 	if primaryKey != nil {
 		object.PrimaryKey = database.NewNullString(*primaryKey)
 	}
@@ -261,6 +276,18 @@ func PartialUpdateObject(
 		object.FrequencyUnits = nil
 	}
 
+	if objectUpdates.SchemaDriftPolicy != nil {
+		object.SchemaDriftPolicy = *objectUpdates.SchemaDriftPolicy
+	}
+
+	if objectUpdates.WebhookDeliveryOptions != nil {
+		object.WebhookRequestsPerSecond = objectUpdates.WebhookDeliveryOptions.RequestsPerSecond
+		object.WebhookBurst = objectUpdates.WebhookDeliveryOptions.Burst
+		object.WebhookBatchSize = objectUpdates.WebhookDeliveryOptions.BatchSize
+		object.WebhookMaxPayloadBytes = objectUpdates.WebhookDeliveryOptions.MaxPayloadBytes
+		object.WebhookMaxDeadLetters = objectUpdates.WebhookDeliveryOptions.MaxDeadLetters
+	}
+
 	// Explicitly do not allow updating the destination, sync mode, primary key, or cursor field
 	// since that may affect running syncs. TODO: do this safely
 	result = db.Save(&object)
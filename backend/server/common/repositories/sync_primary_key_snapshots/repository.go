@@ -0,0 +1,51 @@
+package sync_primary_key_snapshots
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/database"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+
+	"gorm.io/gorm"
+)
+
+func LoadSnapshotBySyncID(db *gorm.DB, syncID int64) (*models.SyncPrimaryKeySnapshot, error) {
+	var snapshot models.SyncPrimaryKeySnapshot
+	result := db.Table("sync_primary_key_snapshots").
+		Select("sync_primary_key_snapshots.*").
+		Where("sync_primary_key_snapshots.sync_id = ?", syncID).
+		Where("sync_primary_key_snapshots.deactivated_at IS NULL").
+		Take(&snapshot)
+
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(sync_primary_key_snapshots.LoadSnapshotBySyncID)")
+	}
+
+	return &snapshot, nil
+}
+
+// Creates the sync's snapshot if it doesn't have one yet, keeping the previous diff time unless diffedAt is set
+func SaveSnapshot(db *gorm.DB, organizationID int64, syncID int64, diffedAt *time.Time) (*models.SyncPrimaryKeySnapshot, error) {
+	snapshot, err := LoadSnapshotBySyncID(db, syncID)
+	if err != nil && !errors.IsRecordNotFound(err) {
+		return nil, errors.Wrap(err, "(sync_primary_key_snapshots.SaveSnapshot)")
+	}
+	if snapshot == nil {
+		snapshot = &models.SyncPrimaryKeySnapshot{
+			OrganizationID: organizationID,
+			SyncID:         syncID,
+		}
+	}
+
+	if diffedAt != nil {
+		snapshot.DiffedAt = database.NewNullTime(*diffedAt)
+	}
+
+	result := db.Save(snapshot)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(sync_primary_key_snapshots.SaveSnapshot)")
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,138 @@
+package sync_primary_keys
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/repositories/sync_primary_key_snapshots"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Keys are written in batches since a run can stage the key of every row in the source
+const STAGE_BATCH_SIZE = 1000
+
+func ClearStagedKeys(db *gorm.DB, syncID int64) error {
+	result := db.Exec("DELETE FROM sync_staged_primary_keys WHERE sync_id = ?", syncID)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "(sync_primary_keys.ClearStagedKeys)")
+	}
+
+	return nil
+}
+
+// Stages keys the run wrote or deleted. Later changes to the same key replace earlier ones.
+func StageKeys(db *gorm.DB, syncID int64, primaryKeys []string, deleted bool) error {
+	if len(primaryKeys) == 0 {
+		return nil
+	}
+
+	stagedKeys := make([]models.SyncStagedPrimaryKey, len(primaryKeys))
+	for i, primaryKey := range primaryKeys {
+		stagedKeys[i] = models.SyncStagedPrimaryKey{SyncID: syncID, PrimaryKey: primaryKey, Deleted: &deleted}
+	}
+
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sync_id"}, {Name: "primary_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"deleted"}),
+	}).CreateInBatches(stagedKeys, STAGE_BATCH_SIZE)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "(sync_primary_keys.StageKeys)")
+	}
+
+	return nil
+}
+
+// Stages keys read from the source, keeping whether the run wrote or deleted them
+func StageSourceKeys(db *gorm.DB, syncID int64, primaryKeys []string) error {
+	if len(primaryKeys) == 0 {
+		return nil
+	}
+
+	stagedKeys := make([]models.SyncStagedPrimaryKey, len(primaryKeys))
+	for i, primaryKey := range primaryKeys {
+		stagedKeys[i] = models.SyncStagedPrimaryKey{SyncID: syncID, PrimaryKey: primaryKey, InSource: true}
+	}
+
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sync_id"}, {Name: "primary_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"in_source"}),
+	}).CreateInBatches(stagedKeys, STAGE_BATCH_SIZE)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "(sync_primary_keys.StageSourceKeys)")
+	}
+
+	return nil
+}
+
+// Returns the keys in the destination that weren't read from the source, in order and starting after afterKey. These
+// are the stored keys and the keys written by the run, less the keys the run deleted.
+func LoadMissingKeys(db *gorm.DB, syncID int64, afterKey string, limit int) ([]string, error) {
+	var primaryKeys []string
+	result := db.Raw(`
+		SELECT destination_keys.primary_key FROM (
+			SELECT primary_key FROM sync_primary_keys WHERE sync_id = @sync_id
+			UNION
+			SELECT primary_key FROM sync_staged_primary_keys WHERE sync_id = @sync_id AND deleted = FALSE
+		) destination_keys
+		WHERE destination_keys.primary_key > @after_key
+		AND NOT EXISTS (
+			SELECT 1 FROM sync_staged_primary_keys
+			WHERE sync_staged_primary_keys.sync_id = @sync_id
+			AND sync_staged_primary_keys.primary_key = destination_keys.primary_key
+			AND (sync_staged_primary_keys.in_source OR COALESCE(sync_staged_primary_keys.deleted, FALSE))
+		)
+		ORDER BY destination_keys.primary_key
+		LIMIT @limit`,
+		map[string]any{"sync_id": syncID, "after_key": afterKey, "limit": limit},
+	).Scan(&primaryKeys)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(sync_primary_keys.LoadMissingKeys)")
+	}
+
+	return primaryKeys, nil
+}
+
+// Applies the staged keys to the stored keys and clears them. When the run diffed its keys against the source, the
+// keys read from the source replace the stored keys, since every other row was deleted from the destination.
+func CommitStagedKeys(db *gorm.DB, organizationID int64, syncID int64, diffedAt *time.Time) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var statements []string
+		if diffedAt != nil {
+			statements = []string{
+				"DELETE FROM sync_primary_keys WHERE sync_id = @sync_id",
+				`INSERT INTO sync_primary_keys (sync_id, primary_key)
+				SELECT sync_id, primary_key FROM sync_staged_primary_keys WHERE sync_id = @sync_id AND in_source`,
+			}
+		} else {
+			statements = []string{
+				`DELETE FROM sync_primary_keys USING sync_staged_primary_keys
+				WHERE sync_primary_keys.sync_id = @sync_id
+				AND sync_staged_primary_keys.sync_id = sync_primary_keys.sync_id
+				AND sync_staged_primary_keys.primary_key = sync_primary_keys.primary_key
+				AND sync_staged_primary_keys.deleted`,
+				`INSERT INTO sync_primary_keys (sync_id, primary_key)
+				SELECT sync_id, primary_key FROM sync_staged_primary_keys WHERE sync_id = @sync_id AND deleted = FALSE
+				ON CONFLICT DO NOTHING`,
+			}
+		}
+		statements = append(statements, "DELETE FROM sync_staged_primary_keys WHERE sync_id = @sync_id")
+
+		for _, statement := range statements {
+			result := tx.Exec(statement, map[string]any{"sync_id": syncID})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		_, err := sync_primary_key_snapshots.SaveSnapshot(tx, organizationID, syncID, diffedAt)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "(sync_primary_keys.CommitStagedKeys)")
+	}
+
+	return nil
+}
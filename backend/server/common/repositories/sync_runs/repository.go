@@ -3,6 +3,7 @@ package sync_runs
 import (
 	"time"
 
+	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/database"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
@@ -43,14 +44,14 @@ func CreateOrStartSyncRun(
 	if err != nil && !errors.IsRecordNotFound(err) {
 		return nil, errors.Wrap(err, "CreateOrStartSyncRun")
 	} else if err == nil {
-		return UpdateSyncRun(db, syncRun, models.SyncRunStatusRunning, nil, nil)
+		return UpdateSyncRun(db, syncRun, models.SyncRunStatusRunning, nil, nil, nil)
 	} else {
 		// Didn't find an active sync run, so create a new one
 		return createSyncRun(db, organizationID, syncID, workflowID)
 	}
 }
 
-func UpdateSyncRun(db *gorm.DB, syncRun *models.SyncRun, newStatus models.SyncRunStatus, syncError *string, rowsWritten *int) (*models.SyncRun, error) {
+func UpdateSyncRun(db *gorm.DB, syncRun *models.SyncRun, newStatus models.SyncRunStatus, syncError *string, rowsWritten *int, rowsDeadLettered *int) (*models.SyncRun, error) {
 	updates := models.SyncRun{
 		CompletedAt: time.Now(),
 		Status:      newStatus,
@@ -60,6 +61,10 @@ func UpdateSyncRun(db *gorm.DB, syncRun *models.SyncRun, newStatus models.SyncRu
 		updates.RowsWritten = *rowsWritten
 	}
 
+	if rowsDeadLettered != nil {
+		updates.RowsDeadLettered = *rowsDeadLettered
+	}
+
 	if syncError != nil {
 		updates.Error = database.NewNullString(*syncError)
 	}
@@ -72,6 +77,46 @@ func UpdateSyncRun(db *gorm.DB, syncRun *models.SyncRun, newStatus models.SyncRu
 	return syncRun, nil
 }
 
+// Only updates running sync runs, so late progress updates can't overwrite the final status of a run
+func UpdateSyncRunProgress(db *gorm.DB, syncRunID int64, phase models.SyncRunPhase, rowsRead int, rowsWritten int, rowsDeadLettered int, bytesRead int64) error {
+	now := time.Now()
+	updates := models.SyncRun{
+		Phase:             &phase,
+		RowsRead:          rowsRead,
+		RowsWritten:       rowsWritten,
+		RowsDeadLettered:  rowsDeadLettered,
+		BytesRead:         bytesRead,
+		ProgressUpdatedAt: &now,
+	}
+
+	result := db.Model(&models.SyncRun{}).
+		Where("sync_runs.id = ?", syncRunID).
+		Where("sync_runs.status = ?", string(models.SyncRunStatusRunning)).
+		Updates(updates)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "(sync_runs.UpdateSyncRunProgress)")
+	}
+
+	return nil
+}
+
+// Records the schema changes found before the run read from its source
+func UpdateSyncRunSchemaDrift(db *gorm.DB, syncRunID int64, schemaDrift data.SchemaDriftReport) error {
+	encodedSchemaDrift, err := schemaDrift.Encode()
+	if err != nil {
+		return errors.Wrap(err, "(sync_runs.UpdateSyncRunSchemaDrift)")
+	}
+
+	result := db.Model(&models.SyncRun{}).
+		Where("sync_runs.id = ?", syncRunID).
+		Updates(models.SyncRun{SchemaDrift: database.NewNullString(encodedSchemaDrift)})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "(sync_runs.UpdateSyncRunSchemaDrift)")
+	}
+
+	return nil
+}
+
 // Temporal guarantees that only one active workflow execution will have a given workflow ID, so we check the status
 // to be doubly sure we have the right sync run even though the workflow IDs should always be unique
 func LoadActiveByWorkflowID(db *gorm.DB, workflowID string) (*models.SyncRun, error) {
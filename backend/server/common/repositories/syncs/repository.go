@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/database"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/input"
@@ -28,19 +29,29 @@ func CreateSync(
 	recurring bool,
 	frequency *int64,
 	frequencyUnits *models.FrequencyUnits,
+	cursorTieBreaker bool,
+	cursorLookbackSeconds *int64,
+	partitionField *string,
+	partitionCount *int,
+	filter *data.Filter,
+	deleteDetectionIntervalSeconds *int64,
 ) (*models.Sync, error) {
 
 	sync := models.Sync{
-		OrganizationID: organizationID,
-		DisplayName:    displayName,
-		WorkflowID:     uuid.NewString(),
-		EndCustomerID:  endCustomerID,
-		SourceID:       sourceID,
-		ObjectID:       objectID,
-		SyncMode:       syncMode,
-		Frequency:      frequency,
-		FrequencyUnits: frequencyUnits,
-		Status:         models.SyncStatusActive,
+		OrganizationID:                 organizationID,
+		DisplayName:                    displayName,
+		WorkflowID:                     uuid.NewString(),
+		EndCustomerID:                  endCustomerID,
+		SourceID:                       sourceID,
+		ObjectID:                       objectID,
+		SyncMode:                       syncMode,
+		Frequency:                      frequency,
+		FrequencyUnits:                 frequencyUnits,
+		Status:                         models.SyncStatusActive,
+		CursorTieBreaker:               cursorTieBreaker,
+		CursorLookbackSeconds:          cursorLookbackSeconds,
+		PartitionCount:                 partitionCount,
+		DeleteDetectionIntervalSeconds: deleteDetectionIntervalSeconds,
 	}
 
 	if tableName != nil && namespace != nil {
@@ -60,6 +71,18 @@ func CreateSync(
 		sync.SourcePrimaryKey = database.NewNullString(*sourcePrimaryKey)
 	}
 
+	if partitionField != nil {
+		sync.PartitionField = database.NewNullString(*partitionField)
+	}
+
+	if filter != nil {
+		encodedFilter, err := filter.Encode()
+		if err != nil {
+			return nil, errors.Wrap(err, "(syncs.CreateSync)")
+		}
+		sync.Filter = database.NewNullString(encodedFilter)
+	}
+
 	result := db.Create(&sync)
 	if result.Error != nil {
 		return nil, errors.Wrap(result.Error, "(syncs.CreateSync)")
@@ -85,6 +108,14 @@ func CreateFieldMappings(
 			IsJsonField:        fieldMapping.IsJsonField,
 		}
 
+		if len(fieldMapping.Transforms) > 0 {
+			encodedTransforms, err := data.EncodeTransforms(fieldMapping.Transforms)
+			if err != nil {
+				return nil, errors.Wrap(err, "(syncs.CreateFieldMappings)")
+			}
+			fieldMappingModel.Transforms = database.NewNullString(encodedTransforms)
+		}
+
 		result := db.Create(&fieldMappingModel)
 		if result.Error != nil {
 			return nil, errors.Wrap(result.Error, "(syncs.CreateFieldMappings)")
@@ -239,10 +270,15 @@ func LoadFieldMappingsForSync(
 func UpdateCursor(
 	db *gorm.DB,
 	sync *models.Sync,
-	cursorPosition string,
+	cursorPosition data.CursorState,
 ) (*models.Sync, error) {
+	encodedCursorPosition, err := cursorPosition.Encode()
+	if err != nil {
+		return nil, errors.Wrap(err, "(syncs.UpdateCursor)")
+	}
+
 	updates := models.Sync{
-		CursorPosition: database.NewNullString(cursorPosition),
+		CursorPosition: database.NewNullString(encodedCursorPosition),
 	}
 
 	result := db.Model(sync).Updates(updates)
@@ -252,3 +288,25 @@ func UpdateCursor(
 
 	return sync, nil
 }
+
+func UpdateSourceSchema(
+	db *gorm.DB,
+	sync *models.Sync,
+	sourceSchema data.Schema,
+) (*models.Sync, error) {
+	encodedSourceSchema, err := data.EncodeSchema(sourceSchema)
+	if err != nil {
+		return nil, errors.Wrap(err, "(syncs.UpdateSourceSchema)")
+	}
+
+	updates := models.Sync{
+		SourceSchema: database.NewNullString(encodedSourceSchema),
+	}
+
+	result := db.Model(sync).Updates(updates)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(syncs.UpdateSourceSchema)")
+	}
+
+	return sync, nil
+}
@@ -0,0 +1,111 @@
+package webhook_dead_letters
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/database"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+
+	"gorm.io/gorm"
+)
+
+func CreateWebhookDeadLetter(
+	db *gorm.DB,
+	organizationID int64,
+	syncID int64,
+	objectID int64,
+	endCustomerID string,
+	data string,
+	deliveryError string,
+	statusCode *int,
+	attempts int,
+) (*models.WebhookDeadLetter, error) {
+	deadLetter := models.WebhookDeadLetter{
+		OrganizationID: organizationID,
+		SyncID:         syncID,
+		ObjectID:       objectID,
+		EndCustomerID:  endCustomerID,
+		Data:           data,
+		Error:          deliveryError,
+		Attempts:       attempts,
+	}
+
+	if statusCode != nil {
+		deadLetter.StatusCode = database.NewNullInt64(int64(*statusCode))
+	}
+
+	result := db.Create(&deadLetter)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(webhook_dead_letters.CreateWebhookDeadLetter)")
+	}
+
+	return &deadLetter, nil
+}
+
+func LoadWebhookDeadLetterByID(db *gorm.DB, organizationID int64, syncID int64, deadLetterID int64) (*models.WebhookDeadLetter, error) {
+	var deadLetter models.WebhookDeadLetter
+	result := db.Table("webhook_dead_letters").
+		Select("webhook_dead_letters.*").
+		Where("webhook_dead_letters.id = ?", deadLetterID).
+		Where("webhook_dead_letters.organization_id = ?", organizationID).
+		Where("webhook_dead_letters.sync_id = ?", syncID).
+		Where("webhook_dead_letters.deactivated_at IS NULL").
+		Take(&deadLetter)
+
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(webhook_dead_letters.LoadWebhookDeadLetterByID)")
+	}
+
+	return &deadLetter, nil
+}
+
+func LoadWebhookDeadLettersForSync(db *gorm.DB, organizationID int64, syncID int64, includeReplayed bool) ([]models.WebhookDeadLetter, error) {
+	var deadLetters []models.WebhookDeadLetter
+	query := db.Table("webhook_dead_letters").
+		Select("webhook_dead_letters.*").
+		Where("webhook_dead_letters.organization_id = ?", organizationID).
+		Where("webhook_dead_letters.sync_id = ?", syncID).
+		Where("webhook_dead_letters.deactivated_at IS NULL")
+
+	if !includeReplayed {
+		query = query.Where("webhook_dead_letters.replayed_at IS NULL")
+	}
+
+	result := query.Order("webhook_dead_letters.created_at DESC").Find(&deadLetters)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(webhook_dead_letters.LoadWebhookDeadLettersForSync)")
+	}
+
+	return deadLetters, nil
+}
+
+func MarkWebhookDeadLetterReplayed(db *gorm.DB, deadLetter *models.WebhookDeadLetter) (*models.WebhookDeadLetter, error) {
+	result := db.Model(deadLetter).Updates(models.WebhookDeadLetter{
+		ReplayedAt: database.NewNullTime(time.Now()),
+		Attempts:   deadLetter.Attempts + 1,
+	})
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(webhook_dead_letters.MarkWebhookDeadLetterReplayed)")
+	}
+
+	return deadLetter, nil
+}
+
+func RecordFailedReplay(db *gorm.DB, deadLetter *models.WebhookDeadLetter, deliveryError string, statusCode *int) (*models.WebhookDeadLetter, error) {
+	updates := models.WebhookDeadLetter{
+		Error:    deliveryError,
+		Attempts: deadLetter.Attempts + 1,
+	}
+
+	if statusCode != nil {
+		updates.StatusCode = database.NewNullInt64(int64(*statusCode))
+	}
+
+	result := db.Model(deadLetter).Updates(updates)
+	if result.Error != nil {
+		return nil, errors.Wrap(result.Error, "(webhook_dead_letters.RecordFailedReplay)")
+	}
+
+	return deadLetter, nil
+}
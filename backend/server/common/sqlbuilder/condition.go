@@ -0,0 +1,113 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A condition in a WHERE clause. Conditions bind their values to the builder they are added to, so placeholders are
+// numbered in the order they appear in the query.
+type Condition interface {
+	build(b *SelectBuilder) string
+}
+
+type compareCondition struct {
+	column   string
+	operator Operator
+	value    any
+}
+
+func Compare(column string, operator Operator, value any) Condition {
+	return compareCondition{column: column, operator: operator, value: value}
+}
+
+func (c compareCondition) build(b *SelectBuilder) string {
+	return fmt.Sprintf("%s %s %s", b.dialect.QuoteIdentifier(c.column), c.operator, b.bind(c.value))
+}
+
+type nullCondition struct {
+	column string
+	not    bool
+}
+
+func IsNull(column string) Condition {
+	return nullCondition{column: column}
+}
+
+func IsNotNull(column string) Condition {
+	return nullCondition{column: column, not: true}
+}
+
+func (c nullCondition) build(b *SelectBuilder) string {
+	if c.not {
+		return fmt.Sprintf("%s IS NOT NULL", b.dialect.QuoteIdentifier(c.column))
+	}
+
+	return fmt.Sprintf("%s IS NULL", b.dialect.QuoteIdentifier(c.column))
+}
+
+type inCondition struct {
+	column string
+	values []any
+	not    bool
+}
+
+func In(column string, values []any) Condition {
+	return inCondition{column: column, values: values}
+}
+
+func NotIn(column string, values []any) Condition {
+	return inCondition{column: column, values: values, not: true}
+}
+
+func (c inCondition) build(b *SelectBuilder) string {
+	// IN () is not valid SQL, so an empty list matches no rows, or every row when negated
+	if len(c.values) == 0 {
+		if c.not {
+			return "1 = 1"
+		}
+		return "1 = 0"
+	}
+
+	placeholders := []string{}
+	for _, value := range c.values {
+		placeholders = append(placeholders, b.bind(value))
+	}
+
+	operator := "IN"
+	if c.not {
+		operator = "NOT IN"
+	}
+
+	return fmt.Sprintf("%s %s (%s)", b.dialect.QuoteIdentifier(c.column), operator, strings.Join(placeholders, ","))
+}
+
+type groupCondition struct {
+	conditions []Condition
+	operator   string
+}
+
+func And(conditions ...Condition) Condition {
+	return groupCondition{conditions: conditions, operator: "AND"}
+}
+
+func Or(conditions ...Condition) Condition {
+	return groupCondition{conditions: conditions, operator: "OR"}
+}
+
+func (c groupCondition) build(b *SelectBuilder) string {
+	// an empty AND matches every row and an empty OR matches none
+	if len(c.conditions) == 0 {
+		if c.operator == "AND" {
+			return "1 = 1"
+		}
+		return "1 = 0"
+	}
+
+	built := []string{}
+	for _, condition := range c.conditions {
+		built = append(built, condition.build(b))
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(built, fmt.Sprintf(" %s ", c.operator)))
+}
@@ -0,0 +1,69 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+)
+
+type Dialect string
+
+const (
+	DialectPostgres  Dialect = "postgres"
+	DialectRedshift  Dialect = "redshift"
+	DialectMySql     Dialect = "mysql"
+	DialectSnowflake Dialect = "snowflake"
+	DialectSynapse   Dialect = "synapse"
+	DialectBigQuery  Dialect = "bigquery"
+)
+
+func GetDialect(connectionType models.ConnectionType) (Dialect, error) {
+	switch connectionType {
+	case models.ConnectionTypePostgres:
+		return DialectPostgres, nil
+	case models.ConnectionTypeRedshift:
+		return DialectRedshift, nil
+	case models.ConnectionTypeMySQL:
+		return DialectMySql, nil
+	case models.ConnectionTypeSnowflake:
+		return DialectSnowflake, nil
+	case models.ConnectionTypeSynapse:
+		return DialectSynapse, nil
+	case models.ConnectionTypeBigQuery:
+		return DialectBigQuery, nil
+	default:
+		return "", errors.Newf("(sqlbuilder.GetDialect) no SQL dialect for %s", connectionType)
+	}
+}
+
+// Quotes the identifier so reserved words, mixed case and special characters are used as is
+func (d Dialect) QuoteIdentifier(identifier string) string {
+	switch d {
+	case DialectMySql:
+		return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+	case DialectBigQuery:
+		return "`" + strings.ReplaceAll(identifier, "`", "\\`") + "`"
+	case DialectSynapse:
+		return "[" + strings.ReplaceAll(identifier, "]", "]]") + "]"
+	default:
+		return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+	}
+}
+
+func (d Dialect) QuoteTable(namespace string, tableName string) string {
+	return fmt.Sprintf("%s.%s", d.QuoteIdentifier(namespace), d.QuoteIdentifier(tableName))
+}
+
+// Placeholder for the n-th (1-indexed) bound parameter, in the syntax the driver for the dialect expects
+func (d Dialect) Placeholder(n int) string {
+	switch d {
+	case DialectPostgres, DialectRedshift:
+		return fmt.Sprintf("$%d", n)
+	case DialectSynapse:
+		return fmt.Sprintf("@p%d", n)
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,137 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OperatorEqual              Operator = "="
+	OperatorNotEqual           Operator = "<>"
+	OperatorGreaterThan        Operator = ">"
+	OperatorGreaterThanOrEqual Operator = ">="
+	OperatorLessThan           Operator = "<"
+	OperatorLessThanOrEqual    Operator = "<="
+)
+
+// Builds SELECT statements with quoted identifiers and bound parameters. Values are never written into the SQL.
+type SelectBuilder struct {
+	dialect     Dialect
+	columns     []string
+	expressions []string
+	from        string
+	conditions  []string
+	orderBy     []string
+	limit       *int
+	args        []any
+}
+
+func Select(dialect Dialect, columns ...string) *SelectBuilder {
+	return &SelectBuilder{
+		dialect: dialect,
+		columns: columns,
+	}
+}
+
+// Selects the smallest and largest values of the column, used to split a table into ranges of the column
+func SelectBounds(dialect Dialect, column string) *SelectBuilder {
+	quoted := dialect.QuoteIdentifier(column)
+	return &SelectBuilder{
+		dialect:     dialect,
+		expressions: []string{fmt.Sprintf("MIN(%s)", quoted), fmt.Sprintf("MAX(%s)", quoted)},
+	}
+}
+
+func (b *SelectBuilder) From(namespace string, tableName string) *SelectBuilder {
+	b.from = b.dialect.QuoteTable(namespace, tableName)
+	return b
+}
+
+// Selects from a query written by the customer, such as a custom join. It is used as a subquery so conditions can be
+// added without knowing how the query is structured.
+func (b *SelectBuilder) FromQuery(queryString string) *SelectBuilder {
+	queryString = strings.TrimSuffix(strings.TrimSpace(queryString), ";")
+	b.from = fmt.Sprintf("(%s) AS %s", queryString, b.dialect.QuoteIdentifier("fabra_source"))
+	return b
+}
+
+func (b *SelectBuilder) Where(column string, operator Operator, value any) *SelectBuilder {
+	return b.WhereCondition(Compare(column, operator, value))
+}
+
+func (b *SelectBuilder) WhereNull(column string) *SelectBuilder {
+	return b.WhereCondition(IsNull(column))
+}
+
+func (b *SelectBuilder) WhereCondition(condition Condition) *SelectBuilder {
+	b.conditions = append(b.conditions, condition.build(b))
+	return b
+}
+
+// Adds a condition for rows after the cursor value, using the tie-breaker column to order rows with the same cursor value
+func (b *SelectBuilder) WhereAfter(cursorColumn string, cursorValue any, tieBreakerColumn string, tieBreakerValue any) *SelectBuilder {
+	cursor := b.dialect.QuoteIdentifier(cursorColumn)
+	tieBreaker := b.dialect.QuoteIdentifier(tieBreakerColumn)
+	b.conditions = append(b.conditions, fmt.Sprintf(
+		"(%s > %s OR (%s = %s AND %s > %s))",
+		cursor, b.bind(cursorValue), cursor, b.bind(cursorValue), tieBreaker, b.bind(tieBreakerValue),
+	))
+	return b
+}
+
+func (b *SelectBuilder) OrderByAsc(column string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, fmt.Sprintf("%s ASC", b.dialect.QuoteIdentifier(column)))
+	return b
+}
+
+func (b *SelectBuilder) Limit(limit int) *SelectBuilder {
+	b.limit = &limit
+	return b
+}
+
+// Returns the query and the arguments to pass alongside it, in placeholder order
+func (b *SelectBuilder) Build() (string, []any) {
+	var columns string
+	if len(b.expressions) > 0 {
+		columns = strings.Join(b.expressions, ",")
+	} else if len(b.columns) == 0 {
+		columns = "*"
+	} else {
+		quoted := []string{}
+		for _, column := range b.columns {
+			quoted = append(quoted, b.dialect.QuoteIdentifier(column))
+		}
+		columns = strings.Join(quoted, ",")
+	}
+
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString("SELECT ")
+	// SQL Server has no LIMIT clause
+	if b.limit != nil && b.dialect == DialectSynapse {
+		queryBuilder.WriteString(fmt.Sprintf("TOP %d ", *b.limit))
+	}
+	queryBuilder.WriteString(fmt.Sprintf("%s FROM %s", columns, b.from))
+
+	if len(b.conditions) > 0 {
+		queryBuilder.WriteString(" WHERE ")
+		queryBuilder.WriteString(strings.Join(b.conditions, " AND "))
+	}
+
+	if len(b.orderBy) > 0 {
+		queryBuilder.WriteString(" ORDER BY ")
+		queryBuilder.WriteString(strings.Join(b.orderBy, ","))
+	}
+
+	if b.limit != nil && b.dialect != DialectSynapse {
+		queryBuilder.WriteString(fmt.Sprintf(" LIMIT %d", *b.limit))
+	}
+
+	return queryBuilder.String(), b.args
+}
+
+func (b *SelectBuilder) bind(value any) string {
+	b.args = append(b.args, value)
+	return b.dialect.Placeholder(len(b.args))
+}
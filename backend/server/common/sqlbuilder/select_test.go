@@ -0,0 +1,105 @@
+package sqlbuilder_test
+
+import (
+	"go.fabra.io/server/common/sqlbuilder"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelectBuilder", func() {
+	It("quotes identifiers for each dialect", func() {
+		expected := map[sqlbuilder.Dialect]string{
+			sqlbuilder.DialectPostgres:  `SELECT "id","Order" FROM "public"."My Table"`,
+			sqlbuilder.DialectRedshift:  `SELECT "id","Order" FROM "public"."My Table"`,
+			sqlbuilder.DialectSnowflake: `SELECT "id","Order" FROM "public"."My Table"`,
+			sqlbuilder.DialectMySql:     "SELECT `id`,`Order` FROM `public`.`My Table`",
+			sqlbuilder.DialectBigQuery:  "SELECT `id`,`Order` FROM `public`.`My Table`",
+			sqlbuilder.DialectSynapse:   "SELECT [id],[Order] FROM [public].[My Table]",
+		}
+
+		for dialect, expectedQuery := range expected {
+			queryString, args := sqlbuilder.Select(dialect, "id", "Order").From("public", "My Table").Build()
+			Expect(queryString).To(Equal(expectedQuery), string(dialect))
+			Expect(args).To(BeEmpty())
+		}
+	})
+
+	It("escapes quote characters inside identifiers", func() {
+		Expect(sqlbuilder.DialectPostgres.QuoteIdentifier(`a"b`)).To(Equal(`"a""b"`))
+		Expect(sqlbuilder.DialectMySql.QuoteIdentifier("a`b")).To(Equal("`a``b`"))
+		Expect(sqlbuilder.DialectSynapse.QuoteIdentifier("a]b")).To(Equal("[a]]b]"))
+	})
+
+	It("binds values as parameters with the dialect's placeholders", func() {
+		expected := map[sqlbuilder.Dialect]string{
+			sqlbuilder.DialectPostgres: `SELECT "id" FROM "ns"."t" WHERE "updated_at" > $1 AND "name" <> $2 ORDER BY "updated_at" ASC`,
+			sqlbuilder.DialectMySql:    "SELECT `id` FROM `ns`.`t` WHERE `updated_at` > ? AND `name` <> ? ORDER BY `updated_at` ASC",
+			sqlbuilder.DialectSynapse:  "SELECT [id] FROM [ns].[t] WHERE [updated_at] > @p1 AND [name] <> @p2 ORDER BY [updated_at] ASC",
+		}
+
+		for dialect, expectedQuery := range expected {
+			queryString, args := sqlbuilder.Select(dialect, "id").
+				From("ns", "t").
+				Where("updated_at", sqlbuilder.OperatorGreaterThan, int64(5)).
+				Where("name", sqlbuilder.OperatorNotEqual, "o'brien").
+				OrderByAsc("updated_at").
+				Build()
+			Expect(queryString).To(Equal(expectedQuery), string(dialect))
+			Expect(args).To(Equal([]any{int64(5), "o'brien"}))
+		}
+	})
+
+	It("wraps custom queries in a subquery", func() {
+		queryString, args := sqlbuilder.Select(sqlbuilder.DialectPostgres).
+			FromQuery("SELECT a.id, b.updated_at FROM a JOIN b ON a.id = b.a_id WHERE b.active;").
+			Where("updated_at", sqlbuilder.OperatorGreaterThan, "2023-01-01").
+			Build()
+		Expect(queryString).To(Equal(`SELECT * FROM (SELECT a.id, b.updated_at FROM a JOIN b ON a.id = b.a_id WHERE b.active) AS "fabra_source" WHERE "updated_at" > $1`))
+		Expect(args).To(Equal([]any{"2023-01-01"}))
+	})
+
+	It("orders rows with the same cursor value by the tie-breaker", func() {
+		queryString, args := sqlbuilder.Select(sqlbuilder.DialectPostgres, "id").
+			From("ns", "t").
+			WhereAfter("updated_at", "2023-01-01", "id", int64(7)).
+			OrderByAsc("updated_at").
+			OrderByAsc("id").
+			Build()
+		Expect(queryString).To(Equal(`SELECT "id" FROM "ns"."t" WHERE ("updated_at" > $1 OR ("updated_at" = $2 AND "id" > $3)) ORDER BY "updated_at" ASC,"id" ASC`))
+		Expect(args).To(Equal([]any{"2023-01-01", "2023-01-01", int64(7)}))
+	})
+
+	It("selects the bounds of a column and its null rows", func() {
+		queryString, args := sqlbuilder.SelectBounds(sqlbuilder.DialectMySql, "id").From("ns", "t").Build()
+		Expect(queryString).To(Equal("SELECT MIN(`id`),MAX(`id`) FROM `ns`.`t`"))
+		Expect(args).To(BeEmpty())
+
+		queryString, _ = sqlbuilder.Select(sqlbuilder.DialectPostgres, "id").From("ns", "t").WhereNull("created_at").Build()
+		Expect(queryString).To(Equal(`SELECT "id" FROM "ns"."t" WHERE "created_at" IS NULL`))
+	})
+
+	It("limits results", func() {
+		queryString, _ := sqlbuilder.Select(sqlbuilder.DialectSnowflake, "id").From("ns", "t").Limit(10).Build()
+		Expect(queryString).To(Equal(`SELECT "id" FROM "ns"."t" LIMIT 10`))
+
+		queryString, _ = sqlbuilder.Select(sqlbuilder.DialectSynapse, "id").From("ns", "t").Limit(10).Build()
+		Expect(queryString).To(Equal("SELECT TOP 10 [id] FROM [ns].[t]"))
+	})
+	It("builds nested conditions", func() {
+		queryString, args := sqlbuilder.Select(sqlbuilder.DialectPostgres, "id").
+			From("ns", "t").
+			Where("updated_at", sqlbuilder.OperatorGreaterThan, int64(5)).
+			WhereCondition(sqlbuilder.And(
+				sqlbuilder.Compare("status", sqlbuilder.OperatorEqual, "active"),
+				sqlbuilder.Or(
+					sqlbuilder.In("plan", []any{"pro", "enterprise"}),
+					sqlbuilder.IsNotNull("trial_ends_at"),
+				),
+				sqlbuilder.NotIn("region", []any{}),
+			)).
+			Build()
+		Expect(queryString).To(Equal(`SELECT "id" FROM "ns"."t" WHERE "updated_at" > $1 AND ("status" = $2 AND ("plan" IN ($3,$4) OR "trial_ends_at" IS NOT NULL) AND 1 = 1)`))
+		Expect(args).To(Equal([]any{int64(5), "active", "pro", "enterprise"}))
+	})
+})
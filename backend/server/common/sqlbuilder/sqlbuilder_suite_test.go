@@ -0,0 +1,13 @@
+package sqlbuilder_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSqlBuilder(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SQL Builder Suite")
+}
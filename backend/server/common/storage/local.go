@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.fabra.io/server/common/errors"
+)
+
+// LocalStorage stores objects on the local filesystem, with each bucket as a directory under the root
+type LocalStorage struct {
+	RootDir string
+}
+
+func (l LocalStorage) PutObject(ctx context.Context, bucket string, object string, data io.Reader) error {
+	objectPath := filepath.Join(l.RootDir, bucket, object)
+	err := os.MkdirAll(filepath.Dir(objectPath), 0755)
+	if err != nil {
+		return errors.Wrap(err, "(storage.LocalStorage.PutObject) creating directory")
+	}
+
+	file, err := os.Create(objectPath)
+	if err != nil {
+		return errors.Wrap(err, "(storage.LocalStorage.PutObject) creating file")
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, data)
+	if err != nil {
+		return errors.Wrap(err, "(storage.LocalStorage.PutObject) writing file")
+	}
+
+	return nil
+}
+
+func (l LocalStorage) DeleteObjects(ctx context.Context, bucket string, prefix string) error {
+	bucketPath := filepath.Join(l.RootDir, bucket)
+	return filepath.WalkDir(bucketPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return errors.Wrap(err, "(storage.LocalStorage.DeleteObjects) walking directory")
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(bucketPath, path)
+		if err != nil {
+			return errors.Wrap(err, "(storage.LocalStorage.DeleteObjects) getting object name")
+		}
+
+		if strings.HasPrefix(filepath.ToSlash(relativePath), prefix) {
+			err = os.Remove(path)
+			if err != nil {
+				return errors.Wrap(err, "(storage.LocalStorage.DeleteObjects) removing file")
+			}
+		}
+
+		return nil
+	})
+}
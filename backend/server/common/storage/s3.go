@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.fabra.io/server/common/errors"
+)
+
+type S3Storage struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	Endpoint        *string // only set when using an S3-compatible server like MinIO
+}
+
+func (s S3Storage) openConnection(ctx context.Context) (*s3.Client, error) {
+	awsConfig, err := config.LoadDefaultConfig(ctx,
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(s.AccessKeyID, s.SecretAccessKey, "")), // empty token is ok
+		config.WithRegion(s.Region),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "(storage.S3Storage.openConnection) loading AWS config")
+	}
+
+	return s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if s.Endpoint != nil {
+			o.EndpointResolver = s3.EndpointResolverFromURL(*s.Endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+func (s S3Storage) PutObject(ctx context.Context, bucket string, object string, data io.Reader) error {
+	client, err := s.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(storage.S3Storage.PutObject) opening connection")
+	}
+
+	// the upload manager sends the data in parts, so it can be streamed without knowing the size up front
+	uploader := manager.NewUploader(client)
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+		Body:   data,
+	})
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(storage.S3Storage.PutObject) uploading object")
+	}
+
+	return nil
+}
+
+func (s S3Storage) DeleteObjects(ctx context.Context, bucket string, prefix string) error {
+	client, err := s.openConnection(ctx)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(storage.S3Storage.DeleteObjects) opening connection")
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(storage.S3Storage.DeleteObjects) listing objects")
+		}
+
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		objectIds := make([]types.ObjectIdentifier, len(page.Contents))
+		for i, object := range page.Contents {
+			objectIds[i] = types.ObjectIdentifier{Key: object.Key}
+		}
+
+		_, err = client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: objectIds},
+		})
+		if err != nil {
+			return errors.Wrap(errors.WrapCustomerVisibleError(err), "(storage.S3Storage.DeleteObjects) deleting objects")
+		}
+	}
+
+	return nil
+}
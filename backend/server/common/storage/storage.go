@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// ObjectStorage is where data is staged before being bulk loaded into a warehouse. Warehouses read from S3
+// in production, but tests can use the local filesystem or an S3-compatible server like MinIO instead.
+type ObjectStorage interface {
+	PutObject(ctx context.Context, bucket string, object string, data io.Reader) error
+	DeleteObjects(ctx context.Context, bucket string, prefix string) error
+}
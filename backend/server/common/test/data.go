@@ -97,6 +97,7 @@ func CreateObject(db *gorm.DB, organizationID int64, destinationID int64, syncMo
 		TableName:          database.NewNullString("table"),
 		EndCustomerIDField: strings.GetPointer("end_customer_id"),
 		SyncMode:           syncMode,
+		SchemaDriftPolicy:  models.SchemaDriftPolicyIgnoreNewColumns,
 	}
 
 	result := db.Create(&object)
@@ -100,7 +100,7 @@ func (qs MockQueryService) RunQuery(ctx context.Context, connection *models.Conn
 	return nil, nil
 }
 
-func (qs MockQueryService) GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string) (data.RowIterator, error) {
+func (qs MockQueryService) GetQueryIterator(ctx context.Context, connection *models.Connection, queryString string, args ...any) (data.RowIterator, error) {
 	return nil, nil
 }
 
@@ -112,6 +112,10 @@ func (qs MockQueryService) GetWarehouseClient(ctx context.Context, connection *m
 	return mock_query.NewMockWarehouseClient(qs.ctrl), nil
 }
 
+func (qs MockQueryService) GetDatabaseClient(ctx context.Context, connection *models.Connection) (query.DatabaseClient, error) {
+	return mock_query.NewMockDatabaseClient(qs.ctrl), nil
+}
+
 type MockWarehouseClient struct {
 }
 
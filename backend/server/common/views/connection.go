@@ -12,6 +12,16 @@ type Destination struct {
 	Connection        Connection `json:"connection"`
 	StagingBucket     *string    `json:"staging_bucket,omitempty"`
 	WebhookSigningKey *string    `json:"webhook_signing_key,omitempty"`
+
+	WebhookDeliveryOptions *WebhookDeliveryOptions `json:"webhook_delivery_options,omitempty"`
+}
+
+type WebhookDeliveryOptions struct {
+	RequestsPerSecond *float64 `json:"requests_per_second,omitempty"`
+	Burst             *int     `json:"burst,omitempty"`
+	BatchSize         *int     `json:"batch_size,omitempty"`
+	MaxPayloadBytes   *int     `json:"max_payload_bytes,omitempty"`
+	MaxDeadLetters    *int     `json:"max_dead_letters,omitempty"`
 }
 
 type Source struct {
@@ -28,19 +38,22 @@ type Connection struct {
 
 // Don't return this to the client except in special situations
 type FullConnection struct {
-	ID                int64                 `json:"id"`
-	OrganizationID    int64                 `json:"organization_id"`
-	ConnectionType    models.ConnectionType `json:"connection_type"`
-	Credentials       string                `json:"credentials"`
-	Username          string                `json:"username"`
-	Password          string                `json:"password"`
-	Location          string                `json:"location"`
-	WarehouseName     string                `json:"warehouse_name"`
-	DatabaseName      string                `json:"database_name"`
-	Role              string                `json:"role"`
-	Host              string                `json:"host"`
-	Port              string                `json:"port"`
-	ConnectionOptions string                `json:"connection_options"`
+	ID                 int64                 `json:"id"`
+	OrganizationID     int64                 `json:"organization_id"`
+	ConnectionType     models.ConnectionType `json:"connection_type"`
+	Credentials        string                `json:"credentials"`
+	Username           string                `json:"username"`
+	Password           string                `json:"password"`
+	Location           string                `json:"location"`
+	WarehouseName      string                `json:"warehouse_name"`
+	DatabaseName       string                `json:"database_name"`
+	Role               string                `json:"role"`
+	Host               string                `json:"host"`
+	Port               string                `json:"port"`
+	ConnectionOptions  string                `json:"connection_options"`
+	MaxReadConcurrency *int                  `json:"max_read_concurrency,omitempty"`
+
+	PreviousSigningKeys string `json:"previous_signing_keys"`
 }
 
 type Object struct {
@@ -58,6 +71,9 @@ type Object struct {
 	Frequency          *int64                 `json:"frequency,omitempty"`
 	FrequencyUnits     *models.FrequencyUnits `json:"frequency_units,omitempty"`
 	ObjectFields       []ObjectField          `json:"object_fields"`
+
+	SchemaDriftPolicy      models.SchemaDriftPolicy `json:"schema_drift_policy"`
+	WebhookDeliveryOptions *WebhookDeliveryOptions  `json:"webhook_delivery_options,omitempty"`
 }
 
 type ObjectField struct {
@@ -96,6 +112,13 @@ func ConvertWebhook(destination models.Destination, connection models.Connection
 			ConnectionType: connection.ConnectionType,
 		},
 		WebhookSigningKey: webhookSigningKey,
+		WebhookDeliveryOptions: &WebhookDeliveryOptions{
+			RequestsPerSecond: destination.WebhookRequestsPerSecond,
+			Burst:             destination.WebhookBurst,
+			BatchSize:         destination.WebhookBatchSize,
+			MaxPayloadBytes:   destination.WebhookMaxPayloadBytes,
+			MaxDeadLetters:    destination.WebhookMaxDeadLetters,
+		},
 	}
 
 	if destination.StagingBucket.Valid {
@@ -191,6 +214,7 @@ func ConvertObject(object *models.Object, objectFields []models.ObjectField) Obj
 		Frequency:          object.Frequency,
 		FrequencyUnits:     object.FrequencyUnits,
 		ObjectFields:       viewObjectFields,
+		SchemaDriftPolicy:  object.SchemaDriftPolicy,
 	}
 
 	if object.Namespace.Valid {
@@ -209,6 +233,16 @@ func ConvertObject(object *models.Object, objectFields []models.ObjectField) Obj
 		viewObject.PrimaryKey = &object.PrimaryKey.String
 	}
 
+	if object.WebhookRequestsPerSecond != nil || object.WebhookBurst != nil || object.WebhookBatchSize != nil || object.WebhookMaxPayloadBytes != nil || object.WebhookMaxDeadLetters != nil {
+		viewObject.WebhookDeliveryOptions = &WebhookDeliveryOptions{
+			RequestsPerSecond: object.WebhookRequestsPerSecond,
+			Burst:             object.WebhookBurst,
+			BatchSize:         object.WebhookBatchSize,
+			MaxPayloadBytes:   object.WebhookMaxPayloadBytes,
+			MaxDeadLetters:    object.WebhookMaxDeadLetters,
+		}
+	}
+
 	return viewObject
 }
 
@@ -248,23 +282,28 @@ func ConvertFullConnection(connection *models.Connection) FullConnection {
 	if connection.ConnectionOptions.Valid {
 		fullConnection.ConnectionOptions = connection.ConnectionOptions.String
 	}
+	fullConnection.MaxReadConcurrency = connection.MaxReadConcurrency
+	if connection.PreviousSigningKeys.Valid {
+		fullConnection.PreviousSigningKeys = connection.PreviousSigningKeys.String
+	}
 
 	return fullConnection
 }
 
 func ConvertConnectionView(fullConnection FullConnection) *models.Connection {
 	return &models.Connection{
-		OrganizationID:    fullConnection.OrganizationID,
-		ConnectionType:    fullConnection.ConnectionType,
-		Credentials:       database.NewNullString(fullConnection.Credentials),
-		Username:          database.NewNullString(fullConnection.Username),
-		Password:          database.NewNullString(fullConnection.Password),
-		Location:          database.NewNullString(fullConnection.Location),
-		DatabaseName:      database.NewNullString(fullConnection.DatabaseName),
-		WarehouseName:     database.NewNullString(fullConnection.WarehouseName),
-		Role:              database.NewNullString(fullConnection.Role),
-		Host:              database.NewNullString(fullConnection.Host),
-		Port:              database.NewNullString(fullConnection.Port),
-		ConnectionOptions: database.NewNullString(fullConnection.ConnectionOptions),
+		OrganizationID:     fullConnection.OrganizationID,
+		ConnectionType:     fullConnection.ConnectionType,
+		Credentials:        database.NewNullString(fullConnection.Credentials),
+		Username:           database.NewNullString(fullConnection.Username),
+		Password:           database.NewNullString(fullConnection.Password),
+		Location:           database.NewNullString(fullConnection.Location),
+		DatabaseName:       database.NewNullString(fullConnection.DatabaseName),
+		WarehouseName:      database.NewNullString(fullConnection.WarehouseName),
+		Role:               database.NewNullString(fullConnection.Role),
+		Host:               database.NewNullString(fullConnection.Host),
+		Port:               database.NewNullString(fullConnection.Port),
+		ConnectionOptions:  database.NewNullString(fullConnection.ConnectionOptions),
+		MaxReadConcurrency: fullConnection.MaxReadConcurrency,
 	}
 }
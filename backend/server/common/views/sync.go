@@ -1,6 +1,7 @@
 package views
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.fabra.io/server/common/data"
@@ -12,54 +13,92 @@ import (
 const CUSTOMER_VISIBLE_TIME_FORMAT = "01/02/06 at 03:04 PM MST"
 
 type Sync struct {
-	ID                int64                  `json:"id"`
-	OrganizationID    int64                  `json:"organization_id"`
-	Status            models.SyncStatus      `json:"status"`
-	EndCustomerID     string                 `json:"end_customer_id"`
-	DisplayName       string                 `json:"display_name"`
-	SourceID          int64                  `json:"source_id"`
-	ObjectID          int64                  `json:"object_id"`
-	Namespace         *string                `json:"namespace,omitempty"`
-	TableName         *string                `json:"table_name,omitempty"`
-	CustomJoin        *string                `json:"custom_join,omitempty"`
-	CursorPosition    *string                `json:"cursor_position,omitempty"`
-	SourceCursorField *string                `json:"source_cursor_field,omitempty"`
-	SourcePrimaryKey  *string                `json:"source_primary_key,omitempty"`
-	SyncMode          models.SyncMode        `json:"sync_mode"`
-	Recurring         bool                   `json:"recurring"`
-	Frequency         *int64                 `json:"frequency,omitempty"`
-	FrequencyUnits    *models.FrequencyUnits `json:"frequency_units,omitempty"`
+	ID                             int64                  `json:"id"`
+	OrganizationID                 int64                  `json:"organization_id"`
+	Status                         models.SyncStatus      `json:"status"`
+	EndCustomerID                  string                 `json:"end_customer_id"`
+	DisplayName                    string                 `json:"display_name"`
+	SourceID                       int64                  `json:"source_id"`
+	ObjectID                       int64                  `json:"object_id"`
+	Namespace                      *string                `json:"namespace,omitempty"`
+	TableName                      *string                `json:"table_name,omitempty"`
+	CustomJoin                     *string                `json:"custom_join,omitempty"`
+	CursorPosition                 *data.CursorState      `json:"cursor_position,omitempty"`
+	SourceCursorField              *string                `json:"source_cursor_field,omitempty"`
+	SourcePrimaryKey               *string                `json:"source_primary_key,omitempty"`
+	CursorTieBreaker               bool                   `json:"cursor_tie_breaker"`
+	CursorLookbackSeconds          *int64                 `json:"cursor_lookback_seconds,omitempty"`
+	PartitionField                 *string                `json:"partition_field,omitempty"`
+	PartitionCount                 *int                   `json:"partition_count,omitempty"`
+	Filter                         *data.Filter           `json:"filter,omitempty"`
+	DeleteDetectionIntervalSeconds *int64                 `json:"delete_detection_interval_seconds,omitempty"`
+	SourceSchema                   data.Schema            `json:"source_schema,omitempty"`
+	SyncMode                       models.SyncMode        `json:"sync_mode"`
+	Recurring                      bool                   `json:"recurring"`
+	Frequency                      *int64                 `json:"frequency,omitempty"`
+	FrequencyUnits                 *models.FrequencyUnits `json:"frequency_units,omitempty"`
 }
 
 type SyncRun struct {
-	Status      models.SyncRunStatus `json:"status"`
-	StartedAt   string               `json:"started_at"`
-	CompletedAt string               `json:"completed_at"`
-	Duration    *string              `json:"duration,omitempty"`
-	Error       *string              `json:"error,omitempty"`
-	RowsWritten int                  `json:"rows_written"`
+	Status           models.SyncRunStatus `json:"status"`
+	StartedAt        string               `json:"started_at"`
+	CompletedAt      string               `json:"completed_at"`
+	Duration         *string              `json:"duration,omitempty"`
+	Error            *string              `json:"error,omitempty"`
+	RowsWritten      int                  `json:"rows_written"`
+	RowsDeadLettered int                  `json:"rows_dead_lettered"`
+	Progress         *SyncRunProgress     `json:"progress,omitempty"`
+
+	// Changes to the source schema found before the run read from the source
+	SchemaDrift *data.SchemaDriftReport `json:"schema_drift,omitempty"`
+}
+
+// Live progress of a running sync
+type SyncRunProgress struct {
+	Phase            models.SyncRunPhase `json:"phase"`
+	RowsRead         int                 `json:"rows_read"`
+	RowsWritten      int                 `json:"rows_written"`
+	RowsDeadLettered int                 `json:"rows_dead_lettered"`
+	BytesRead        int64               `json:"bytes_read"`
+	UpdatedAt        string              `json:"updated_at"`
+}
+
+type WebhookDeadLetter struct {
+	ID         int64           `json:"id"`
+	ObjectID   int64           `json:"object_id"`
+	Data       json.RawMessage `json:"data"`
+	Error      string          `json:"error"`
+	StatusCode *int64          `json:"status_code,omitempty"`
+	Attempts   int             `json:"attempts"`
+	CreatedAt  string          `json:"created_at"`
+	ReplayedAt *string         `json:"replayed_at,omitempty"`
 }
 
 type FieldMapping struct {
-	SourceFieldName      string         `json:"source_field_name"`
-	SourceFieldType      data.FieldType `json:"source_field_type"`
-	DestinationFieldId   int64          `json:"destination_field_id"`
-	DestinationFieldName string         `json:"destination_field_name"`
-	DestinationFieldType data.FieldType `json:"destination_field_type"`
-	IsJsonField          bool           `json:"is_json_field"`
+	SourceFieldName      string           `json:"source_field_name"`
+	SourceFieldType      data.FieldType   `json:"source_field_type"`
+	DestinationFieldId   int64            `json:"destination_field_id"`
+	DestinationFieldName string           `json:"destination_field_name"`
+	DestinationFieldType data.FieldType   `json:"destination_field_type"`
+	IsJsonField          bool             `json:"is_json_field"`
+	Transforms           []data.Transform `json:"transforms,omitempty"`
 }
 
 func ConvertSync(sync *models.Sync) Sync {
 	syncView := Sync{
-		ID:             sync.ID,
-		OrganizationID: sync.OrganizationID,
-		Status:         sync.Status,
-		EndCustomerID:  sync.EndCustomerID,
-		DisplayName:    sync.DisplayName,
-		SourceID:       sync.SourceID,
-		ObjectID:       sync.ObjectID,
-		SyncMode:       sync.SyncMode,
-		Frequency:      sync.Frequency,
+		ID:                             sync.ID,
+		OrganizationID:                 sync.OrganizationID,
+		Status:                         sync.Status,
+		EndCustomerID:                  sync.EndCustomerID,
+		DisplayName:                    sync.DisplayName,
+		SourceID:                       sync.SourceID,
+		ObjectID:                       sync.ObjectID,
+		SyncMode:                       sync.SyncMode,
+		Frequency:                      sync.Frequency,
+		CursorTieBreaker:               sync.CursorTieBreaker,
+		CursorLookbackSeconds:          sync.CursorLookbackSeconds,
+		PartitionCount:                 sync.PartitionCount,
+		DeleteDetectionIntervalSeconds: sync.DeleteDetectionIntervalSeconds,
 	}
 
 	if sync.Namespace.Valid {
@@ -71,8 +110,27 @@ func ConvertSync(sync *models.Sync) Sync {
 	if sync.CustomJoin.Valid {
 		syncView.CustomJoin = &sync.CustomJoin.String
 	}
+	if sync.PartitionField.Valid {
+		syncView.PartitionField = &sync.PartitionField.String
+	}
+	if sync.Filter.Valid {
+		filter, err := data.ParseFilter(sync.Filter.String)
+		if err != nil {
+			// an empty filter can't be turned into a query, so reads fail instead of reading every row
+			filter = &data.Filter{}
+		}
+		syncView.Filter = filter
+	}
+	if sync.SourceSchema.Valid {
+		sourceSchema, err := data.ParseSchema(sync.SourceSchema.String)
+		if err == nil {
+			// a schema that can't be parsed is replaced by the next run
+			syncView.SourceSchema = sourceSchema
+		}
+	}
 	if sync.CursorPosition.Valid {
-		syncView.CursorPosition = &sync.CursorPosition.String
+		cursorPosition := data.ParseCursorState(sync.CursorPosition.String)
+		syncView.CursorPosition = &cursorPosition
 	}
 	if sync.SourceCursorField.Valid {
 		syncView.SourceCursorField = &sync.SourceCursorField.String
@@ -94,14 +152,24 @@ func ConvertFieldMappings(fieldMappings []models.FieldMapping, objectFields []mo
 	var fieldMappingsView []FieldMapping
 	for _, fieldMapping := range fieldMappings {
 		destinationField := objectFieldsById[fieldMapping.DestinationFieldId]
-		fieldMappingsView = append(fieldMappingsView, FieldMapping{
+		fieldMappingView := FieldMapping{
 			SourceFieldName:      fieldMapping.SourceFieldName,
 			SourceFieldType:      fieldMapping.SourceFieldType,
 			DestinationFieldId:   fieldMapping.DestinationFieldId,
 			DestinationFieldName: destinationField.Name,
 			DestinationFieldType: destinationField.Type,
 			IsJsonField:          fieldMapping.IsJsonField,
-		})
+		}
+		if fieldMapping.Transforms.Valid {
+			transforms, err := data.ParseTransforms(fieldMapping.Transforms.String)
+			if err != nil {
+				// an unknown transform fails the sync, so values are never written without their transforms
+				transforms = []data.Transform{{}}
+			}
+			fieldMappingView.Transforms = transforms
+		}
+
+		fieldMappingsView = append(fieldMappingsView, fieldMappingView)
 	}
 
 	return fieldMappingsView
@@ -111,21 +179,31 @@ func ConvertSyncRuns(syncRuns []models.SyncRun, timezone *time.Location) ([]Sync
 	var syncRunsView []SyncRun
 	for _, syncRun := range syncRuns {
 		syncRunView := SyncRun{
-			Status:      syncRun.Status,
-			StartedAt:   syncRun.StartedAt.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT),
-			CompletedAt: syncRun.CompletedAt.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT),
-			RowsWritten: syncRun.RowsWritten,
+			Status:           syncRun.Status,
+			StartedAt:        syncRun.StartedAt.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT),
+			CompletedAt:      syncRun.CompletedAt.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT),
+			RowsWritten:      syncRun.RowsWritten,
+			RowsDeadLettered: syncRun.RowsDeadLettered,
 		}
 		if syncRun.Error.Valid {
 			syncError := syncRun.Error.String
 			syncRunView.Error = &syncError
 		}
+		if syncRun.SchemaDrift.Valid {
+			schemaDrift, err := data.ParseSchemaDriftReport(syncRun.SchemaDrift.String)
+			if err != nil {
+				return nil, errors.Wrap(err, "(views.ConvertSyncRuns) parsing schema drift")
+			}
+			syncRunView.SchemaDrift = schemaDrift
+		}
 		if syncRun.Status != models.SyncRunStatusRunning {
 			duration, err := timeutils.GetDurationString(syncRun.CompletedAt.Sub(syncRun.StartedAt))
 			if err != nil {
 				return nil, errors.Wrap(err, "(views.ConvertSyncRuns) getting duration string")
 			}
 			syncRunView.Duration = duration
+		} else {
+			syncRunView.Progress = ConvertSyncRunProgress(syncRun, timezone)
 		}
 
 		syncRunsView = append(syncRunsView, syncRunView)
@@ -133,3 +211,49 @@ func ConvertSyncRuns(syncRuns []models.SyncRun, timezone *time.Location) ([]Sync
 
 	return syncRunsView, nil
 }
+
+// Returns nil if the sync run has not reported any progress yet
+func ConvertSyncRunProgress(syncRun models.SyncRun, timezone *time.Location) *SyncRunProgress {
+	if syncRun.Phase == nil || syncRun.ProgressUpdatedAt == nil {
+		return nil
+	}
+
+	return &SyncRunProgress{
+		Phase:            *syncRun.Phase,
+		RowsRead:         syncRun.RowsRead,
+		RowsWritten:      syncRun.RowsWritten,
+		RowsDeadLettered: syncRun.RowsDeadLettered,
+		BytesRead:        syncRun.BytesRead,
+		UpdatedAt:        syncRun.ProgressUpdatedAt.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT),
+	}
+}
+
+func ConvertWebhookDeadLetters(deadLetters []models.WebhookDeadLetter, timezone *time.Location) []WebhookDeadLetter {
+	deadLettersView := []WebhookDeadLetter{}
+	for _, deadLetter := range deadLetters {
+		deadLettersView = append(deadLettersView, ConvertWebhookDeadLetter(deadLetter, timezone))
+	}
+
+	return deadLettersView
+}
+
+func ConvertWebhookDeadLetter(deadLetter models.WebhookDeadLetter, timezone *time.Location) WebhookDeadLetter {
+	deadLetterView := WebhookDeadLetter{
+		ID:        deadLetter.ID,
+		ObjectID:  deadLetter.ObjectID,
+		Data:      json.RawMessage(deadLetter.Data),
+		Error:     deadLetter.Error,
+		Attempts:  deadLetter.Attempts,
+		CreatedAt: deadLetter.CreatedAt.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT),
+	}
+	if deadLetter.StatusCode.Valid {
+		statusCode := deadLetter.StatusCode.Int64
+		deadLetterView.StatusCode = &statusCode
+	}
+	if deadLetter.ReplayedAt.Valid {
+		replayedAt := deadLetter.ReplayedAt.Time.In(timezone).Format(CUSTOMER_VISIBLE_TIME_FORMAT)
+		deadLetterView.ReplayedAt = &replayedAt
+	}
+
+	return deadLetterView
+}
@@ -105,6 +105,18 @@ func (s ApiService) AuthenticatedRoutes() []router.AuthenticatedRoute {
 			Pattern:     "/destination",
 			HandlerFunc: s.CreateDestination,
 		},
+		{
+			Name:        "Update destination",
+			Method:      router.PATCH,
+			Pattern:     "/destination/{destinationID}",
+			HandlerFunc: s.UpdateDestination,
+		},
+		{
+			Name:        "Rotate webhook signing key",
+			Method:      router.POST,
+			Pattern:     "/destination/{destinationID}/signing_key/rotate",
+			HandlerFunc: s.RotateWebhookSigningKey,
+		},
 		{
 			Name:        "Create source for sync",
 			Method:      router.POST,
@@ -165,6 +177,18 @@ func (s ApiService) AuthenticatedRoutes() []router.AuthenticatedRoute {
 			Pattern:     "/sync/{syncID}",
 			HandlerFunc: s.GetSync,
 		},
+		{
+			Name:        "Get webhook dead letters for sync",
+			Method:      router.GET,
+			Pattern:     "/sync/{syncID}/webhook_dead_letters",
+			HandlerFunc: s.GetWebhookDeadLetters,
+		},
+		{
+			Name:        "Replay webhook dead letter",
+			Method:      router.POST,
+			Pattern:     "/sync/{syncID}/webhook_dead_letters/{deadLetterID}/replay",
+			HandlerFunc: s.ReplayWebhookDeadLetter,
+		},
 		{
 			Name:        "Create link token",
 			Method:      router.POST,
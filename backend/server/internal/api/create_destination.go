@@ -22,6 +22,8 @@ type CreateDestinationRequest struct {
 	BigQueryConfig  *input.BigQueryConfig  `json:"bigquery_config,omitempty"`
 	SnowflakeConfig *input.SnowflakeConfig `json:"snowflake_config,omitempty"`
 	RedshiftConfig  *input.RedshiftConfig  `json:"redshift_config,omitempty"`
+	PostgresConfig  *input.PostgresConfig  `json:"postgres_config,omitempty"`
+	MySqlConfig     *input.MySqlConfig     `json:"mysql_config,omitempty"`
 	MongoDbConfig   *input.MongoDbConfig   `json:"mongodb_config,omitempty"`
 	WebhookConfig   *input.WebhookConfig   `json:"webhook_config,omitempty"`
 	DynamoDbConfig  *input.DynamoDbConfig  `json:"dynamodb_config,omitempty"`
@@ -73,8 +75,28 @@ func (s ApiService) CreateDestination(auth auth.Authentication, w http.ResponseW
 		if encryptionErr != nil {
 			return errors.Wrap(encryptionErr, "(api.CreateDestination)")
 		}
+		encryptedStagingCredentials, encryptionErr := s.encryptRedshiftStagingCredentials(*createDestinationRequest.RedshiftConfig)
+		if encryptionErr != nil {
+			return errors.Wrap(encryptionErr, "(api.CreateDestination)")
+		}
 		connection, err = connections.CreateRedshiftConnection(
-			s.db, auth.Organization.ID, *createDestinationRequest.RedshiftConfig, *encryptedCredentials,
+			s.db, auth.Organization.ID, *createDestinationRequest.RedshiftConfig, *encryptedCredentials, encryptedStagingCredentials,
+		)
+	case models.ConnectionTypePostgres:
+		encryptedCredentials, encryptionErr := s.cryptoService.EncryptConnectionCredentials(createDestinationRequest.PostgresConfig.Password)
+		if encryptionErr != nil {
+			return errors.Wrap(encryptionErr, "(api.CreateDestination)")
+		}
+		connection, err = connections.CreatePostgresConnection(
+			s.db, auth.Organization.ID, *createDestinationRequest.PostgresConfig, *encryptedCredentials,
+		)
+	case models.ConnectionTypeMySQL:
+		encryptedCredentials, encryptionErr := s.cryptoService.EncryptConnectionCredentials(createDestinationRequest.MySqlConfig.Password)
+		if encryptionErr != nil {
+			return errors.Wrap(encryptionErr, "(api.CreateDestination)")
+		}
+		connection, err = connections.CreateMySqlConnection(
+			s.db, auth.Organization.ID, *createDestinationRequest.MySqlConfig, *encryptedCredentials,
 		)
 	case models.ConnectionTypeMongoDb:
 		encryptedCredentials, encryptionErr := s.cryptoService.EncryptConnectionCredentials(createDestinationRequest.MongoDbConfig.Password)
@@ -99,8 +121,12 @@ func (s ApiService) CreateDestination(auth auth.Authentication, w http.ResponseW
 		if encryptionErr != nil {
 			return errors.Wrap(encryptionErr, "(api.CreateDestination)")
 		}
+		encryptedHeaders, encryptionErr := s.encryptWebhookHeaders(createDestinationRequest.WebhookConfig.Headers)
+		if encryptionErr != nil {
+			return errors.Wrap(encryptionErr, "(api.CreateDestination)")
+		}
 		connection, err = connections.CreateWebhookConnection(
-			s.db, auth.Organization.ID, *createDestinationRequest.WebhookConfig, *encryptedSigningKey,
+			s.db, auth.Organization.ID, *createDestinationRequest.WebhookConfig, *encryptedSigningKey, encryptedHeaders,
 		)
 	default:
 		return errors.Newf("(api.CreateDestination) unsupported connection type: %s", createDestinationRequest.ConnectionType)
@@ -110,12 +136,18 @@ func (s ApiService) CreateDestination(auth auth.Authentication, w http.ResponseW
 		return errors.Wrap(err, "(api.CreateDestination)")
 	}
 
+	var webhookDeliveryOptions *input.WebhookDeliveryOptions
+	if createDestinationRequest.WebhookConfig != nil {
+		webhookDeliveryOptions = createDestinationRequest.WebhookConfig.DeliveryOptions
+	}
+
 	destination, err := destinations.CreateDestination(
 		s.db,
 		auth.Organization.ID,
 		createDestinationRequest.DisplayName,
 		connection.ID,
 		createDestinationRequest.StagingBucket,
+		webhookDeliveryOptions,
 	)
 	if err != nil {
 		return errors.Wrap(err, "(api.CreateDestination)")
@@ -145,6 +177,10 @@ func validateCreateDestinationRequest(request CreateDestinationRequest) error {
 		return validateCreateSnowflakeDestination(request)
 	case models.ConnectionTypeRedshift:
 		return validateCreateRedshiftDestination(request)
+	case models.ConnectionTypePostgres:
+		return validateCreatePostgresDestination(request)
+	case models.ConnectionTypeMySQL:
+		return validateCreateMySqlDestination(request)
 	case models.ConnectionTypeMongoDb:
 		return validateCreateMongoDbDestination(request)
 	case models.ConnectionTypeWebhook:
@@ -187,6 +223,53 @@ func validateCreateRedshiftDestination(request CreateDestinationRequest) error {
 		return errors.Wrap(errors.NewBadRequest("missing Redshift configuration"), "(api.validateCreateRedshiftDestination)")
 	}
 
+	if request.StagingBucket == nil {
+		return errors.Wrap(errors.NewBadRequest("missing staging bucket"), "(api.validateCreateRedshiftDestination)")
+	}
+
+	config := request.RedshiftConfig
+	if config.StagingAccessKey == nil || config.StagingSecretKey == nil || config.StagingRegion == nil {
+		return errors.Wrap(errors.NewBadRequest("missing staging credentials"), "(api.validateCreateRedshiftDestination)")
+	}
+
+	// Redshift reads the staged data with the role, so the staging credentials are never sent to it
+	if config.IamRole == nil {
+		return errors.Wrap(errors.NewBadRequest("missing IAM role"), "(api.validateCreateRedshiftDestination)")
+	}
+
+	// TODO: validate the fields all exist in the credentials object
+
+	return nil
+}
+
+func (s ApiService) encryptRedshiftStagingCredentials(redshiftConfig input.RedshiftConfig) (*string, error) {
+	stagingCredentials, err := json.Marshal(models.AwsStagingCredentials{
+		AccessKeyID:     *redshiftConfig.StagingAccessKey,
+		SecretAccessKey: *redshiftConfig.StagingSecretKey,
+		Region:          *redshiftConfig.StagingRegion,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.encryptRedshiftStagingCredentials)")
+	}
+
+	return s.cryptoService.EncryptConnectionCredentials(string(stagingCredentials))
+}
+
+func validateCreatePostgresDestination(request CreateDestinationRequest) error {
+	if request.PostgresConfig == nil {
+		return errors.Wrap(errors.NewBadRequest("missing Postgres configuration"), "(api.validateCreatePostgresDestination)")
+	}
+
+	// TODO: validate the fields all exist in the credentials object
+
+	return nil
+}
+
+func validateCreateMySqlDestination(request CreateDestinationRequest) error {
+	if request.MySqlConfig == nil {
+		return errors.Wrap(errors.NewBadRequest("missing MySQL configuration"), "(api.validateCreateMySqlDestination)")
+	}
+
 	// TODO: validate the fields all exist in the credentials object
 
 	return nil
@@ -215,11 +298,84 @@ func validateCreateWebhookDestination(request CreateDestinationRequest) error {
 		return errors.Wrap(errors.NewBadRequest("Webhook must be HTTPS"), "(api.validateCreateWebhookDestination)")
 	}
 
+	err := validateWebhookHeaders(request.WebhookConfig.Headers)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateCreateWebhookDestination)")
+	}
+
+	if request.WebhookConfig.DeliveryOptions != nil {
+		err = validateWebhookDeliveryOptions(*request.WebhookConfig.DeliveryOptions)
+		if err != nil {
+			return errors.Wrap(err, "(api.validateCreateWebhookDestination)")
+		}
+	}
+
 	// TODO: validate the fields all exist in the credentials object
 
 	return nil
 }
 
+// these are set by Fabra on every request, so they cannot be overridden
+var reservedWebhookHeaders = map[string]bool{
+	"content-type":      true,
+	"fabra-signature":   true,
+	"x-fabra-signature": true,
+}
+
+func validateWebhookHeaders(headers []input.Header) error {
+	for _, header := range headers {
+		if header.Name == "" {
+			return errors.NewBadRequest("webhook header name cannot be empty")
+		}
+
+		if reservedWebhookHeaders[strings.ToLower(header.Name)] {
+			return errors.NewBadRequestf("webhook header %s cannot be overridden", header.Name)
+		}
+	}
+
+	return nil
+}
+
+// payloads also contain the object and end customer details, so very small limits could never be met
+const MIN_WEBHOOK_MAX_PAYLOAD_BYTES = 1024
+
+func validateWebhookDeliveryOptions(deliveryOptions input.WebhookDeliveryOptions) error {
+	if deliveryOptions.RequestsPerSecond != nil && *deliveryOptions.RequestsPerSecond <= 0 {
+		return errors.NewBadRequest("webhook requests per second must be greater than 0")
+	}
+
+	if deliveryOptions.Burst != nil && *deliveryOptions.Burst < 1 {
+		return errors.NewBadRequest("webhook burst must be at least 1")
+	}
+
+	if deliveryOptions.BatchSize != nil && *deliveryOptions.BatchSize < 1 {
+		return errors.NewBadRequest("webhook batch size must be at least 1")
+	}
+
+	if deliveryOptions.MaxPayloadBytes != nil && *deliveryOptions.MaxPayloadBytes < MIN_WEBHOOK_MAX_PAYLOAD_BYTES {
+		return errors.NewBadRequestf("webhook max payload bytes must be at least %d", MIN_WEBHOOK_MAX_PAYLOAD_BYTES)
+	}
+
+	if deliveryOptions.MaxDeadLetters != nil && *deliveryOptions.MaxDeadLetters < 1 {
+		return errors.NewBadRequest("webhook max dead letters must be at least 1")
+	}
+
+	return nil
+}
+
+func (s ApiService) encryptWebhookHeaders(headers []input.Header) (*string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	marshalled, err := json.Marshal(headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.encryptWebhookHeaders)")
+	}
+
+	return s.cryptoService.EncryptConnectionCredentials(string(marshalled))
+}
+
 func validateCreateDynamoDbDestination(request CreateDestinationRequest) error {
 	if request.DynamoDbConfig == nil {
 		return errors.Wrap(errors.NewBadRequest("missing DynamoDB configuration"), "(api.validateCreateDynamoDbDestination)")
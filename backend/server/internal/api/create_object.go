@@ -38,6 +38,10 @@ type CreateObjectRequest struct {
 	Frequency          *int64                 `json:"frequency,omitempty"`
 	FrequencyUnits     *models.FrequencyUnits `json:"frequency_units,omitempty"`
 	ObjectFields       []input.ObjectField    `json:"object_fields"`
+	// Defaults to ignoring new source fields
+	SchemaDriftPolicy *models.SchemaDriftPolicy `json:"schema_drift_policy,omitempty"`
+	// Overrides the delivery options of the destination for webhook objects
+	WebhookDeliveryOptions *input.WebhookDeliveryOptions `json:"webhook_delivery_options,omitempty"`
 }
 
 type CreateObjectResponse struct {
@@ -85,6 +89,24 @@ func (s ApiService) CreateObject(auth auth.Authentication, w http.ResponseWriter
 		return errors.Wrap(errors.NewBadRequest("must specify end_customer_id_field for non-webhook objects"), "(api.CreateObject)")
 	}
 
+	if createObjectRequest.WebhookDeliveryOptions != nil {
+		if createObjectRequest.TargetType != models.TargetTypeWebhook {
+			return errors.Wrap(errors.NewBadRequest("webhook delivery options can only be set for webhook objects"), "(api.CreateObject)")
+		}
+
+		err = validateWebhookDeliveryOptions(*createObjectRequest.WebhookDeliveryOptions)
+		if err != nil {
+			return errors.Wrap(err, "(api.CreateObject)")
+		}
+	}
+
+	if createObjectRequest.SchemaDriftPolicy != nil {
+		err = validateSchemaDriftPolicy(*createObjectRequest.SchemaDriftPolicy)
+		if err != nil {
+			return errors.Wrap(err, "(api.CreateObject)")
+		}
+	}
+
 	// TODO: create model and fields in a transaction
 	object, err := objects.CreateObject(
 		s.db,
@@ -101,6 +123,8 @@ func (s ApiService) CreateObject(auth auth.Authentication, w http.ResponseWriter
 		*createObjectRequest.Recurring,
 		createObjectRequest.Frequency,
 		createObjectRequest.FrequencyUnits,
+		createObjectRequest.WebhookDeliveryOptions,
+		createObjectRequest.SchemaDriftPolicy,
 	)
 	if err != nil {
 		return errors.Wrap(err, "(api.CreateObject) creating object")
@@ -124,3 +148,12 @@ func (s ApiService) CreateObject(auth auth.Authentication, w http.ResponseWriter
 		views.ConvertObject(object, objectFields),
 	})
 }
+
+func validateSchemaDriftPolicy(schemaDriftPolicy models.SchemaDriftPolicy) error {
+	switch schemaDriftPolicy {
+	case models.SchemaDriftPolicyFail, models.SchemaDriftPolicyIgnoreNewColumns, models.SchemaDriftPolicyAutoCast:
+		return nil
+	default:
+		return errors.NewBadRequestf("unknown schema drift policy: %s", schemaDriftPolicy)
+	}
+}
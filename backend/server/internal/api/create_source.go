@@ -15,6 +15,8 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+const MAX_READ_CONCURRENCY = 32
+
 type CreateSourceRequest struct {
 	DisplayName     string                 `json:"display_name" validate:"required"`
 	ConnectionType  models.ConnectionType  `json:"connection_type"`
@@ -25,7 +27,11 @@ type CreateSourceRequest struct {
 	SynapseConfig   *input.SynapseConfig   `json:"synapse_config,omitempty"`
 	PostgresConfig  *input.PostgresConfig  `json:"postgres_config,omitempty"`
 	MySqlConfig     *input.MySqlConfig     `json:"mysql_config,omitempty"`
+	DynamoDbConfig  *input.DynamoDbConfig  `json:"dynamodb_config,omitempty"`
 	EndCustomerID   *string                `json:"end_customer_id,omitempty"`
+
+	// Only used by syncs that read partitions concurrently
+	MaxReadConcurrency *int `json:"max_read_concurrency,omitempty"`
 }
 
 type CreateSourceResponse struct {
@@ -66,6 +72,11 @@ func (s ApiService) CreateSource(auth auth.Authentication, w http.ResponseWriter
 }
 
 func (s ApiService) createSource(auth auth.Authentication, createSourceRequest CreateSourceRequest, endCustomerID string) (*models.Source, *models.Connection, error) {
+	maxReadConcurrency := createSourceRequest.MaxReadConcurrency
+	if maxReadConcurrency != nil && (*maxReadConcurrency < 1 || *maxReadConcurrency > MAX_READ_CONCURRENCY) {
+		return nil, nil, errors.NewBadRequestf("max read concurrency must be between 1 and %d", MAX_READ_CONCURRENCY)
+	}
+
 	// TODO: Create connection + source in a transaction
 	var connection *models.Connection
 	var encryptedCredentials *string
@@ -93,7 +104,7 @@ func (s ApiService) createSource(auth auth.Authentication, createSourceRequest C
 			return nil, nil, errors.Wrap(err, "(api.createSource)")
 		}
 		connection, err = connections.CreateRedshiftConnection(
-			s.db, auth.Organization.ID, *createSourceRequest.RedshiftConfig, *encryptedCredentials,
+			s.db, auth.Organization.ID, *createSourceRequest.RedshiftConfig, *encryptedCredentials, nil,
 		)
 	case models.ConnectionTypeMongoDb:
 		encryptedCredentials, err = s.cryptoService.EncryptConnectionCredentials(createSourceRequest.MongoDbConfig.Password)
@@ -127,6 +138,15 @@ func (s ApiService) createSource(auth auth.Authentication, createSourceRequest C
 		connection, err = connections.CreateMySqlConnection(
 			s.db, auth.Organization.ID, *createSourceRequest.MySqlConfig, *encryptedCredentials,
 		)
+	case models.ConnectionTypeDynamoDb:
+		encryptedCredentials, err = s.cryptoService.EncryptConnectionCredentials(createSourceRequest.DynamoDbConfig.SecretKey)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "(api.createSource)")
+		}
+		connection, err = connections.CreateDynamoDbConnection(
+			s.db, auth.Organization.ID, createSourceRequest.DynamoDbConfig.AccessKey, *encryptedCredentials,
+			createSourceRequest.DynamoDbConfig.Region,
+		)
 	default:
 		return nil, nil, errors.Wrap(errors.Newf("unsupported connection type: %s", createSourceRequest.ConnectionType), "(api.createSource)")
 	}
@@ -135,6 +155,13 @@ func (s ApiService) createSource(auth auth.Authentication, createSourceRequest C
 		return nil, nil, errors.Wrap(err, "(api.createSource)")
 	}
 
+	if maxReadConcurrency != nil {
+		connection, err = connections.UpdateMaxReadConcurrency(s.db, connection, maxReadConcurrency)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "(api.createSource)")
+		}
+	}
+
 	source, err := sources.CreateSource(
 		s.db,
 		auth.Organization.ID,
@@ -8,10 +8,14 @@ import (
 	"time"
 
 	"go.fabra.io/server/common/auth"
+	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/input"
 	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/repositories/connections"
+	"go.fabra.io/server/common/repositories/destinations"
 	"go.fabra.io/server/common/repositories/objects"
+	"go.fabra.io/server/common/repositories/sources"
 	"go.fabra.io/server/common/repositories/syncs"
 	"go.fabra.io/server/common/timeutils"
 	"go.fabra.io/server/common/views"
@@ -24,6 +28,14 @@ import (
 const CLIENT_PEM_KEY = "projects/932264813910/secrets/temporal-client-pem/versions/latest"
 const CLIENT_KEY_KEY = "projects/932264813910/secrets/temporal-client-key/versions/latest"
 
+const MAX_CURSOR_LOOKBACK_SECONDS = 7 * 24 * 60 * 60
+
+// Detecting deletes reads every primary key in the source table, so it can't run more often than this
+const MIN_DELETE_DETECTION_INTERVAL_SECONDS = 60 * 60
+
+const DEFAULT_PARTITION_COUNT = 16
+const MAX_PARTITION_COUNT = 1024
+
 type CreateSyncRequest struct {
 	DisplayName       string                 `json:"display_name"`
 	EndCustomerID     *string                `json:"end_customer_id,omitempty"`
@@ -39,6 +51,20 @@ type CreateSyncRequest struct {
 	Frequency         *int64                 `json:"frequency,omitempty"`
 	FrequencyUnits    *models.FrequencyUnits `json:"frequency_units,omitempty"`
 	FieldMappings     []input.FieldMapping   `json:"field_mappings"`
+
+	// Only used by incremental syncs
+	CursorTieBreaker      *bool  `json:"cursor_tie_breaker,omitempty"`
+	CursorLookbackSeconds *int64 `json:"cursor_lookback_seconds,omitempty"`
+
+	// Only used by full syncs
+	PartitionField *string `json:"partition_field,omitempty"`
+	PartitionCount *int    `json:"partition_count,omitempty"`
+
+	// Limits which source rows are synced
+	Filter *data.Filter `json:"filter,omitempty"`
+
+	// Only used by incremental update syncs
+	DeleteDetectionIntervalSeconds *int64 `json:"delete_detection_interval_seconds,omitempty"`
 }
 
 type CreateSyncResponse struct {
@@ -134,6 +160,37 @@ func (s ApiService) createSync(auth auth.Authentication, createSyncRequest Creat
 		return nil, nil, errors.Wrap(err, "(api.createSync)")
 	}
 
+	err = validateTransforms(createSyncRequest.FieldMappings)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(api.createSync)")
+	}
+
+	cursorTieBreaker := createSyncRequest.CursorTieBreaker != nil && *createSyncRequest.CursorTieBreaker
+	err = validateCursorOptions(syncMode, sourceCursorField, sourcePrimaryKey, cursorTieBreaker, createSyncRequest.CursorLookbackSeconds, createSyncRequest.FieldMappings)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(api.createSync)")
+	}
+
+	partitionCount, err := validatePartitionOptions(syncMode, createSyncRequest.PartitionField, createSyncRequest.PartitionCount, createSyncRequest.FieldMappings)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(api.createSync)")
+	}
+
+	err = s.validateFilter(auth.Organization.ID, endCustomerID, createSyncRequest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(api.createSync)")
+	}
+
+	err = s.validateChangeDataCapture(auth.Organization.ID, endCustomerID, object, syncMode, sourcePrimaryKey, createSyncRequest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(api.createSync)")
+	}
+
+	err = s.validateDeleteDetection(auth.Organization.ID, endCustomerID, object, syncMode, sourcePrimaryKey, createSyncRequest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(api.createSync)")
+	}
+
 	// TODO: create via schedule in Temporal once GA
 	// TODO: create field mappings in DB using transaction
 	sync, err := syncs.CreateSync(
@@ -152,6 +209,12 @@ func (s ApiService) createSync(auth auth.Authentication, createSyncRequest Creat
 		recurring,
 		frequency,
 		frequencyUnits,
+		cursorTieBreaker,
+		createSyncRequest.CursorLookbackSeconds,
+		createSyncRequest.PartitionField,
+		partitionCount,
+		createSyncRequest.Filter,
+		createSyncRequest.DeleteDetectionIntervalSeconds,
 	)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "(api.createSync)")
@@ -286,3 +349,260 @@ func validateFieldsMapped(objectFields []models.ObjectField, fieldMappings []inp
 
 	return nil
 }
+
+func validateTransforms(fieldMappings []input.FieldMapping) error {
+	for _, fieldMapping := range fieldMappings {
+		_, err := data.NewFieldTransformer(fieldMapping.SourceFieldType, fieldMapping.Transforms)
+		if err != nil {
+			return errors.NewBadRequestf("invalid transforms for field %s: %s", fieldMapping.SourceFieldName, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func validateCursorOptions(syncMode models.SyncMode, sourceCursorField *string, sourcePrimaryKey *string, cursorTieBreaker bool, cursorLookbackSeconds *int64, fieldMappings []input.FieldMapping) error {
+	if !cursorTieBreaker && cursorLookbackSeconds == nil {
+		return nil
+	}
+
+	if !syncMode.UsesCursor() || sourceCursorField == nil {
+		return errors.NewBadRequest("cursor tie-breaker and lookback window can only be used by incremental syncs with a cursor field")
+	}
+
+	// both use the primary key, to order rows with the same cursor value or to skip rows that were already read
+	if sourcePrimaryKey == nil {
+		return errors.NewBadRequest("cursor tie-breaker and lookback window require a primary key")
+	}
+
+	if cursorLookbackSeconds != nil {
+		if *cursorLookbackSeconds <= 0 || *cursorLookbackSeconds > MAX_CURSOR_LOOKBACK_SECONDS {
+			return errors.NewBadRequestf("cursor lookback window must be between 1 and %d seconds", MAX_CURSOR_LOOKBACK_SECONDS)
+		}
+
+		for _, fieldMapping := range fieldMappings {
+			if fieldMapping.SourceFieldName == *sourceCursorField {
+				switch fieldMapping.SourceFieldType {
+				case data.FieldTypeDate, data.FieldTypeDateTimeTz, data.FieldTypeDateTimeNtz, data.FieldTypeTimestamp:
+					return nil
+				default:
+					return errors.NewBadRequest("cursor lookback window can only be used with date or time cursor fields")
+				}
+			}
+		}
+
+		return errors.NewBadRequestf("cursor field %s is not mapped", *sourceCursorField)
+	}
+
+	return nil
+}
+
+// Returns the number of partitions to split the read into, or nil if the sync is not partitioned
+func validatePartitionOptions(syncMode models.SyncMode, partitionField *string, partitionCount *int, fieldMappings []input.FieldMapping) (*int, error) {
+	if partitionField == nil {
+		if partitionCount != nil {
+			return nil, errors.NewBadRequest("partition count requires a partition field")
+		}
+		return nil, nil
+	}
+
+	// incremental syncs read in cursor order so they can checkpoint, which concurrent partitions would break
+	if syncMode.UsesCursor() {
+		return nil, errors.NewBadRequest("partitioned reads can only be used by full syncs")
+	}
+
+	if partitionCount == nil {
+		defaultPartitionCount := DEFAULT_PARTITION_COUNT
+		partitionCount = &defaultPartitionCount
+	}
+	if *partitionCount < 2 || *partitionCount > MAX_PARTITION_COUNT {
+		return nil, errors.NewBadRequestf("partition count must be between 2 and %d", MAX_PARTITION_COUNT)
+	}
+
+	for _, fieldMapping := range fieldMappings {
+		if fieldMapping.SourceFieldName == *partitionField {
+			switch fieldMapping.SourceFieldType {
+			case data.FieldTypeInteger, data.FieldTypeNumber, data.FieldTypeDate, data.FieldTypeDateTimeTz, data.FieldTypeDateTimeNtz, data.FieldTypeTimestamp:
+				return partitionCount, nil
+			default:
+				return nil, errors.NewBadRequest("partition field must be a numeric, date, or time field")
+			}
+		}
+	}
+
+	return nil, errors.NewBadRequestf("partition field %s is not mapped", *partitionField)
+}
+
+// Checks the filter against the source schema, recording the type of each filtered field so connectors can bind values
+// with the right type
+func (s ApiService) validateFilter(organizationID int64, endCustomerID string, createSyncRequest CreateSyncRequest) error {
+	if createSyncRequest.Filter == nil {
+		return nil
+	}
+
+	fieldTypes := map[string]data.FieldType{}
+	if createSyncRequest.CustomJoin != nil {
+		// schemas can't be fetched for custom joins, so only mapped fields can be filtered on
+		for _, fieldMapping := range createSyncRequest.FieldMappings {
+			fieldTypes[fieldMapping.SourceFieldName] = fieldMapping.SourceFieldType
+		}
+	} else {
+		source, err := sources.LoadSourceByID(s.db, organizationID, endCustomerID, createSyncRequest.SourceID)
+		if err != nil {
+			return errors.Wrap(err, "(api.validateFilter)")
+		}
+
+		connection, err := connections.LoadConnectionByID(s.db, organizationID, source.ConnectionID)
+		if err != nil {
+			return errors.Wrap(err, "(api.validateFilter)")
+		}
+
+		schema, err := s.queryService.GetSchema(context.TODO(), connection, *createSyncRequest.Namespace, *createSyncRequest.TableName)
+		if err != nil {
+			return errors.Wrap(err, "(api.validateFilter)")
+		}
+
+		for _, field := range schema {
+			fieldTypes[field.Name] = field.Type
+		}
+	}
+
+	return createSyncRequest.Filter.Validate(fieldTypes)
+}
+
+// Change data capture reads a single table's change log, which is only supported by some sources and destinations
+func (s ApiService) validateChangeDataCapture(organizationID int64, endCustomerID string, object *models.Object, syncMode models.SyncMode, sourcePrimaryKey *string, createSyncRequest CreateSyncRequest) error {
+	if syncMode != models.SyncModeChangeDataCapture {
+		return nil
+	}
+
+	if createSyncRequest.CustomJoin != nil {
+		return errors.NewBadRequest("change data capture can't be used with a custom join")
+	}
+
+	// changes to the same row are merged by primary key, since they have no cursor field to be ordered by
+	if sourcePrimaryKey == nil {
+		return errors.NewBadRequest("change data capture requires a primary key")
+	}
+
+	if createSyncRequest.Filter != nil || createSyncRequest.SourceCursorField != nil || createSyncRequest.PartitionField != nil {
+		return errors.NewBadRequest("change data capture can't be used with a filter, cursor field, or partition field")
+	}
+
+	source, err := sources.LoadSourceByID(s.db, organizationID, endCustomerID, createSyncRequest.SourceID)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateChangeDataCapture)")
+	}
+
+	connection, err := connections.LoadConnectionByID(s.db, organizationID, source.ConnectionID)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateChangeDataCapture)")
+	}
+
+	switch connection.ConnectionType {
+	case models.ConnectionTypePostgres, models.ConnectionTypeMySQL:
+	case models.ConnectionTypeMongoDb:
+		// the keys written are tracked to delete the documents missing from a reread of the whole collection
+		for _, fieldMapping := range createSyncRequest.FieldMappings {
+			if fieldMapping.SourceFieldName != *sourcePrimaryKey {
+				continue
+			}
+			switch fieldMapping.SourceFieldType {
+			case data.FieldTypeInteger, data.FieldTypeString:
+			default:
+				return errors.NewBadRequest("change data capture from MongoDB requires an integer or string primary key")
+			}
+		}
+	default:
+		return errors.NewBadRequestf("change data capture is not supported for %s sources", connection.ConnectionType)
+	}
+
+	// the change log includes deleted rows, which would otherwise be left in the destination
+	err = s.validateDeleteDestination(organizationID, object)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateChangeDataCapture)")
+	}
+
+	return nil
+}
+
+// Deletes are detected by reading every primary key from a SQL source, and only some destinations can apply them
+func (s ApiService) validateDeleteDetection(organizationID int64, endCustomerID string, object *models.Object, syncMode models.SyncMode, sourcePrimaryKey *string, createSyncRequest CreateSyncRequest) error {
+	intervalSeconds := createSyncRequest.DeleteDetectionIntervalSeconds
+	if intervalSeconds == nil {
+		return nil
+	}
+
+	// change data capture already reads deletes, and full syncs replace every row
+	if syncMode != models.SyncModeIncrementalUpdate {
+		return errors.NewBadRequest("delete detection can only be used by incremental update syncs")
+	}
+
+	if createSyncRequest.CustomJoin != nil {
+		return errors.NewBadRequest("delete detection can't be used with a custom join")
+	}
+
+	if *intervalSeconds < MIN_DELETE_DETECTION_INTERVAL_SECONDS {
+		return errors.NewBadRequestf("delete detection interval must be at least %d seconds", MIN_DELETE_DETECTION_INTERVAL_SECONDS)
+	}
+
+	if sourcePrimaryKey == nil {
+		return errors.NewBadRequest("delete detection requires a primary key")
+	}
+
+	primaryKeyMapped := false
+	for _, fieldMapping := range createSyncRequest.FieldMappings {
+		if fieldMapping.SourceFieldName == *sourcePrimaryKey {
+			if fieldMapping.SourceFieldType != data.FieldTypeInteger && fieldMapping.SourceFieldType != data.FieldTypeString {
+				return errors.NewBadRequest("delete detection requires an integer or string primary key")
+			}
+			primaryKeyMapped = true
+		}
+	}
+	if !primaryKeyMapped {
+		return errors.NewBadRequestf("primary key %s is not mapped", *sourcePrimaryKey)
+	}
+
+	source, err := sources.LoadSourceByID(s.db, organizationID, endCustomerID, createSyncRequest.SourceID)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateDeleteDetection)")
+	}
+
+	sourceConnection, err := connections.LoadConnectionByID(s.db, organizationID, source.ConnectionID)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateDeleteDetection)")
+	}
+
+	switch sourceConnection.ConnectionType {
+	case models.ConnectionTypeBigQuery, models.ConnectionTypeSnowflake, models.ConnectionTypeRedshift, models.ConnectionTypeSynapse, models.ConnectionTypePostgres, models.ConnectionTypeMySQL:
+	default:
+		return errors.NewBadRequestf("delete detection is not supported for %s sources", sourceConnection.ConnectionType)
+	}
+
+	err = s.validateDeleteDestination(organizationID, object)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateDeleteDetection)")
+	}
+
+	return nil
+}
+
+// Only some destinations can apply the deletes read by change data capture or delete detection
+func (s ApiService) validateDeleteDestination(organizationID int64, object *models.Object) error {
+	destination, err := destinations.LoadDestinationByID(s.db, organizationID, object.DestinationID)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateDeleteDestination)")
+	}
+
+	destinationConnection, err := connections.LoadConnectionByID(s.db, organizationID, destination.ConnectionID)
+	if err != nil {
+		return errors.Wrap(err, "(api.validateDeleteDestination)")
+	}
+
+	switch destinationConnection.ConnectionType {
+	case models.ConnectionTypeBigQuery, models.ConnectionTypeWebhook:
+		return nil
+	default:
+		return errors.NewBadRequestf("deletes can't be applied to %s destinations", destinationConnection.ConnectionType)
+	}
+}
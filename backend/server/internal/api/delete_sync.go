@@ -2,13 +2,18 @@ package api
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"go.fabra.io/server/common/auth"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/repositories/connections"
+	"go.fabra.io/server/common/repositories/sources"
 	"go.fabra.io/server/common/repositories/syncs"
+	"go.fabra.io/sync/connectors"
 	"go.fabra.io/sync/temporal"
 )
 
@@ -53,5 +58,36 @@ func (s ApiService) DeleteSync(auth auth.Authentication, w http.ResponseWriter,
 		return errors.Wrap(err, "(api.DeleteSync) deleting temporal schedule")
 	}
 
+	// the sync is already deleted, so a source that can't be reached only leaves the slot behind for its owner to drop
+	err = s.dropReplicationSlot(r.Context(), auth.Organization.ID, sync)
+	if err != nil {
+		log.Printf("Failed to drop replication slot for sync %d: %v", sync.ID, err)
+	}
+
 	return nil
 }
+
+// Change data capture syncs hold a replication slot on the source, which keeps the source from cleaning up its change
+// log until the slot is dropped
+func (s ApiService) dropReplicationSlot(ctx context.Context, organizationID int64, sync *models.Sync) error {
+	if sync.SyncMode != models.SyncModeChangeDataCapture {
+		return nil
+	}
+
+	source, err := sources.LoadSourceByID(s.db, organizationID, sync.EndCustomerID, sync.SourceID)
+	if err != nil {
+		return errors.Wrap(err, "(api.dropReplicationSlot)")
+	}
+
+	connection, err := connections.LoadConnectionByID(s.db, organizationID, source.ConnectionID)
+	if err != nil {
+		return errors.Wrap(err, "(api.dropReplicationSlot)")
+	}
+
+	client, err := s.queryService.GetClient(ctx, connection)
+	if err != nil {
+		return errors.Wrap(err, "(api.dropReplicationSlot)")
+	}
+
+	return connectors.DropReplicationSlot(ctx, client, connection.ConnectionType, sync.ID)
+}
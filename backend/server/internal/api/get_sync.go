@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/mux"
 	"go.fabra.io/server/common/auth"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/repositories/objects"
 	"go.fabra.io/server/common/repositories/sync_runs"
 	"go.fabra.io/server/common/repositories/syncs"
@@ -16,10 +17,11 @@ import (
 )
 
 type GetSyncResponse struct {
-	Sync          views.Sync           `json:"sync"`
-	FieldMappings []views.FieldMapping `json:"field_mappings"`
-	NextRunTime   string               `json:"next_run_time"`
-	SyncRuns      []views.SyncRun      `json:"sync_runs"`
+	Sync          views.Sync             `json:"sync"`
+	FieldMappings []views.FieldMapping   `json:"field_mappings"`
+	NextRunTime   string                 `json:"next_run_time"`
+	SyncRuns      []views.SyncRun        `json:"sync_runs"`
+	Progress      *views.SyncRunProgress `json:"progress,omitempty"` // progress of the current run, if the sync is running
 }
 
 func (s ApiService) GetSync(auth auth.Authentication, w http.ResponseWriter, r *http.Request) error {
@@ -71,5 +73,15 @@ func (s ApiService) GetSync(auth auth.Authentication, w http.ResponseWriter, r *
 		FieldMappings: views.ConvertFieldMappings(fieldMappings, objectFields),
 		NextRunTime:   "",
 		SyncRuns:      syncRunsView,
+		Progress:      getCurrentProgress(syncRunsView),
 	})
 }
+
+// Sync runs are ordered by most recent first, so the current run is the first one if it is still running
+func getCurrentProgress(syncRuns []views.SyncRun) *views.SyncRunProgress {
+	if len(syncRuns) > 0 && syncRuns[0].Status == models.SyncRunStatusRunning {
+		return syncRuns[0].Progress
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.fabra.io/server/common/auth"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/repositories/syncs"
+	"go.fabra.io/server/common/repositories/webhook_dead_letters"
+	"go.fabra.io/server/common/timeutils"
+	"go.fabra.io/server/common/views"
+)
+
+type GetWebhookDeadLettersResponse struct {
+	DeadLetters []views.WebhookDeadLetter `json:"dead_letters"`
+}
+
+func (s ApiService) GetWebhookDeadLetters(auth auth.Authentication, w http.ResponseWriter, r *http.Request) error {
+	if auth.Organization == nil {
+		return errors.Wrap(errors.NewBadRequest("must setup organization first"), "(api.GetWebhookDeadLetters)")
+	}
+
+	timezone := timeutils.GetTimezoneHeader(r)
+
+	vars := mux.Vars(r)
+	strSyncId, ok := vars["syncID"]
+	if !ok {
+		return errors.Newf("(api.GetWebhookDeadLetters) missing sync ID from GetWebhookDeadLetters request URL: %s", r.URL.RequestURI())
+	}
+
+	syncId, err := strconv.ParseInt(strSyncId, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "(api.GetWebhookDeadLetters)")
+	}
+
+	// check the sync belongs to the right organization
+	sync, err := syncs.LoadSyncByID(s.db, auth.Organization.ID, syncId)
+	if err != nil {
+		return errors.Wrap(err, "(api.GetWebhookDeadLetters)")
+	}
+
+	includeReplayed := r.URL.Query().Get("include_replayed") == "true"
+	deadLetters, err := webhook_dead_letters.LoadWebhookDeadLettersForSync(s.db, auth.Organization.ID, sync.ID, includeReplayed)
+	if err != nil {
+		return errors.Wrap(err, "(api.GetWebhookDeadLetters)")
+	}
+
+	return json.NewEncoder(w).Encode(GetWebhookDeadLettersResponse{
+		DeadLetters: views.ConvertWebhookDeadLetters(deadLetters, timezone),
+	})
+}
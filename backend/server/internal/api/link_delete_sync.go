@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -53,5 +54,11 @@ func (s ApiService) LinkDeleteSync(auth auth.Authentication, w http.ResponseWrit
 		return errors.Wrap(err, "(api.LinkDeleteSync) deleting temporal schedule")
 	}
 
+	// the sync is already deleted, so a source that can't be reached only leaves the slot behind for its owner to drop
+	err = s.dropReplicationSlot(r.Context(), auth.Organization.ID, sync)
+	if err != nil {
+		log.Printf("Failed to drop replication slot for sync %d: %v", sync.ID, err)
+	}
+
 	return nil
 }
@@ -68,5 +68,6 @@ func (s ApiService) LinkGetSync(auth auth.Authentication, w http.ResponseWriter,
 		FieldMappings: views.ConvertFieldMappings(fieldMappings, objectFields),
 		NextRunTime:   "",
 		SyncRuns:      syncRunsView,
+		Progress:      getCurrentProgress(syncRunsView),
 	})
 }
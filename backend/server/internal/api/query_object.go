@@ -2,30 +2,49 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"go.fabra.io/server/common/auth"
+	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/repositories/connections"
 	"go.fabra.io/server/common/repositories/objects"
 	"go.fabra.io/server/common/repositories/sources"
 	"go.fabra.io/server/common/repositories/syncs"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
 )
 
+const DEFAULT_QUERY_LIMIT = 100
+const MAX_QUERY_LIMIT = 1000
+
 type QueryFilter struct {
-	FieldName  string `json:"field_name" validate:"required"`
-	FieldValue string `json:"field_value" validate:"required"`
+	FieldName  string               `json:"field_name" validate:"required"`
+	Operator   *data.FilterOperator `json:"operator,omitempty"` // defaults to equal
+	FieldValue any                  `json:"field_value,omitempty"`
 }
 
 type QueryObjectRecordRequest struct {
-	Filters []QueryFilter `json:"filters,omitempty"`
+	Filters []QueryFilter `json:"filters,omitempty" validate:"dive"`
+	Fields  []string      `json:"fields,omitempty"` // object fields to return, defaulting to every field
+
+	// Returns only the record with this primary key instead of a page of records
+	PrimaryKey any `json:"primary_key,omitempty"`
+
+	// Only used when returning a page of records
+	Limit  *int    `json:"limit,omitempty"`
+	Cursor *string `json:"cursor,omitempty"`
+}
+
+type QueryObjectRecordResponse struct {
+	Records    []map[string]any `json:"records"`
+	NextCursor *string          `json:"next_cursor,omitempty"`
 }
 
 func (s ApiService) QueryObjectRecord(auth auth.Authentication, w http.ResponseWriter, r *http.Request) error {
@@ -63,78 +82,303 @@ func (s ApiService) QueryObjectRecord(auth auth.Authentication, w http.ResponseW
 		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(api.QueryObject)")
 	}
 
-	payload, err := s.queryObject(auth.Organization, endCustomerId, objectId, queryObjectRecordRequest.Filters)
+	querySource, err := s.loadQuerySource(auth.Organization, endCustomerId, objectId)
+	if err != nil {
+		return errors.Wrap(err, "(api.QueryObject)")
+	}
+
+	if queryObjectRecordRequest.PrimaryKey != nil {
+		record, err := s.queryObjectRecord(querySource, queryObjectRecordRequest)
+		if err != nil {
+			return errors.Wrap(err, "(api.QueryObject) running query")
+		}
+
+		return json.NewEncoder(w).Encode(record)
+	}
+
+	response, err := s.queryObjectRecords(querySource, queryObjectRecordRequest)
 	if err != nil {
 		return errors.Wrap(err, "(api.QueryObject) running query")
 	}
 
-	// TODO: should we include any additional information here?
-	return json.NewEncoder(w).Encode(payload)
+	return json.NewEncoder(w).Encode(response)
+}
+
+// The source an object's records are read from for an end customer
+type querySource struct {
+	connection    *models.Connection
+	object        *models.Object
+	sync          views.Sync
+	fieldMappings []views.FieldMapping
 }
 
-func (s ApiService) queryObject(organization *models.Organization, endCustomerID string, objectID int64, filters []QueryFilter) (map[string]any, error) {
+func (s ApiService) loadQuerySource(organization *models.Organization, endCustomerID string, objectID int64) (*querySource, error) {
 	syncList, err := syncs.LoadSyncsForCustomerAndObject(s.db, organization.ID, endCustomerID, objectID)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to load syncs")
+		return nil, errors.Wrap(err, "(api.loadQuerySource) failed to load syncs")
 	}
 
 	if len(syncList) > 1 || len(syncList) == 0 {
-		return nil, errors.Wrap(errors.NewBadRequest("must have exactly one sync per object"), "(api.queryObject)")
+		return nil, errors.Wrap(errors.NewBadRequest("must have exactly one sync per object"), "(api.loadQuerySource)")
 	}
 
 	sync := syncList[0]
 
 	source, err := sources.LoadSourceByID(s.db, organization.ID, endCustomerID, sync.SourceID)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to load source")
+		return nil, errors.Wrap(err, "(api.loadQuerySource) failed to load source")
 	}
 
 	connection, err := connections.LoadConnectionByID(s.db, organization.ID, source.ConnectionID)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to load connection")
+		return nil, errors.Wrap(err, "(api.loadQuerySource) failed to load connection")
 	}
 
 	// Validate the organization owns the object
 	object, err := objects.LoadObjectByID(s.db, organization.ID, objectID)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to load object")
+		return nil, errors.Wrap(err, "(api.loadQuerySource) failed to load object")
 	}
 
 	fieldMappings, err := syncs.LoadFieldMappingsForSync(s.db, sync.ID)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to load field mappings")
+		return nil, errors.Wrap(err, "(api.loadQuerySource) failed to load field mappings")
 	}
 
 	objectFields, err := objects.LoadObjectFieldsByID(s.db, object.ID)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to load object fields")
+		return nil, errors.Wrap(err, "(api.loadQuerySource) failed to load object fields")
+	}
+
+	return &querySource{
+		connection:    connection,
+		object:        object,
+		sync:          views.ConvertSync(&sync),
+		fieldMappings: views.ConvertFieldMappings(fieldMappings, objectFields),
+	}, nil
+}
+
+// Returns the record with the requested primary key
+func (s ApiService) queryObjectRecord(querySource *querySource, request QueryObjectRecordRequest) (map[string]any, error) {
+	primaryKey := getQueryPrimaryKey(querySource)
+	if primaryKey == nil {
+		return nil, errors.Wrap(errors.NewBadRequest("object must have a primary key to look up a record by primary key"), "(api.queryObjectRecord)")
+	}
+
+	primaryKeyFilter := QueryFilter{FieldName: primaryKey.DestinationFieldName, FieldValue: request.PrimaryKey}
+	filter, err := getQueryFilter(append(request.Filters, primaryKeyFilter), querySource.fieldMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.queryObjectRecord)")
+	}
+
+	selectedFieldMappings, err := getSelectedFieldMappings(request.Fields, querySource.fieldMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.queryObjectRecord)")
+	}
+
+	client, err := s.queryService.GetClient(context.TODO(), querySource.connection)
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.queryObjectRecord) failed to get client")
+	}
+
+	// read one more than needed to catch primary keys that aren't unique in the source
+	rows, err := connectors.Lookup(context.TODO(), client, querySource.connection, querySource.sync, selectedFieldMappings, connectors.LookupOptions{
+		Filter: filter,
+		Limit:  2,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.queryObjectRecord) failed to run query")
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.Wrap(errors.NotFound, "(api.queryObjectRecord)")
+	}
+	if len(rows) > 1 {
+		return nil, errors.Wrap(errors.NewBadRequest("more than one record has this primary key"), "(api.queryObjectRecord)")
 	}
 
-	orderedObjectFields := createOrderedObjectFields(objectFields, fieldMappings)
+	return convertQueryRow(rows[0], selectedFieldMappings), nil
+}
 
-	client, err := s.queryService.GetClient(context.TODO(), connection)
+// Returns a page of records ordered by primary key, with a cursor for the next page if there are more records
+func (s ApiService) queryObjectRecords(querySource *querySource, request QueryObjectRecordRequest) (*QueryObjectRecordResponse, error) {
+	limit := DEFAULT_QUERY_LIMIT
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	if limit < 1 || limit > MAX_QUERY_LIMIT {
+		return nil, errors.Wrap(errors.NewBadRequestf("limit must be between 1 and %d", MAX_QUERY_LIMIT), "(api.queryObjectRecords)")
+	}
+
+	filter, err := getQueryFilter(request.Filters, querySource.fieldMappings)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to get client")
+		return nil, errors.Wrap(err, "(api.queryObjectRecords)")
 	}
 
-	// TODO: apply filters
-	readQuery := getReadQuery(connection, sync, fieldMappings)
+	selectedFieldMappings, err := getSelectedFieldMappings(request.Fields, querySource.fieldMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.queryObjectRecords)")
+	}
 
-	data, err := client.RunQuery(context.TODO(), readQuery)
+	// pages are ordered by primary key, so objects without one can only return the first page
+	primaryKey := getQueryPrimaryKey(querySource)
+	lookupOptions := connectors.LookupOptions{
+		Filter: filter,
+		Limit:  limit + 1, // read one more than needed to know if there is another page
+	}
+	primaryKeyIndex := -1
+	addedPrimaryKey := false
+	if primaryKey != nil {
+		lookupOptions.OrderBy = &primaryKey.SourceFieldName
+
+		// the primary key is read even if it wasn't requested so the next cursor can be created
+		for i, fieldMapping := range selectedFieldMappings {
+			if fieldMapping.SourceFieldName == primaryKey.SourceFieldName {
+				primaryKeyIndex = i
+			}
+		}
+		if primaryKeyIndex == -1 {
+			primaryKeyIndex = len(selectedFieldMappings)
+			selectedFieldMappings = append(selectedFieldMappings, *primaryKey)
+			addedPrimaryKey = true
+		}
+	}
+
+	if request.Cursor != nil {
+		if primaryKey == nil {
+			return nil, errors.Wrap(errors.NewBadRequest("object must have a primary key to page through records"), "(api.queryObjectRecords)")
+		}
+
+		lookupOptions.After, err = decodeQueryCursor(*request.Cursor)
+		if err != nil {
+			return nil, errors.Wrap(err, "(api.queryObjectRecords)")
+		}
+	}
+
+	client, err := s.queryService.GetClient(context.TODO(), querySource.connection)
 	if err != nil {
-		return nil, errors.Wrap(err, "(api.queryObject) failed to run query")
+		return nil, errors.Wrap(err, "(api.queryObjectRecords) failed to get client")
 	}
 
-	// TODO: rethink this
-	if len(data.Data) > 1 || len(data.Data) == 0 {
-		return nil, errors.Wrap(errors.NewBadRequest("must have exactly one record"), "(api.queryObject)")
+	rows, err := connectors.Lookup(context.TODO(), client, querySource.connection, querySource.sync, selectedFieldMappings, lookupOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "(api.queryObjectRecords) failed to run query")
 	}
 
-	row := data.Data[0]
+	response := QueryObjectRecordResponse{Records: []map[string]any{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		if primaryKey != nil {
+			nextCursor, err := encodeQueryCursor(primaryKey.SourceFieldType, rows[limit-1][primaryKeyIndex])
+			if err != nil {
+				return nil, errors.Wrap(err, "(api.queryObjectRecords)")
+			}
+			response.NextCursor = &nextCursor
+		}
+	}
+
+	for _, row := range rows {
+		record := convertQueryRow(row, selectedFieldMappings)
+		if addedPrimaryKey {
+			delete(record, primaryKey.DestinationFieldName)
+		}
+		response.Records = append(response.Records, record)
+	}
+
+	return &response, nil
+}
+
+// Converts the request filters on object fields into a filter on the source fields they're mapped from
+func getQueryFilter(queryFilters []QueryFilter, fieldMappings []views.FieldMapping) (*data.Filter, error) {
+	if len(queryFilters) == 0 {
+		return nil, nil
+	}
+
+	fieldTypes := map[string]data.FieldType{}
+	for _, fieldMapping := range fieldMappings {
+		fieldTypes[fieldMapping.SourceFieldName] = fieldMapping.SourceFieldType
+	}
+
+	filter := data.Filter{}
+	for _, queryFilter := range queryFilters {
+		fieldMapping := getQueryFieldMapping(queryFilter.FieldName, fieldMappings)
+		if fieldMapping == nil {
+			return nil, errors.NewBadRequestf("cannot filter on field %s", queryFilter.FieldName)
+		}
+
+		operator := data.FilterOperatorEqual
+		if queryFilter.Operator != nil {
+			operator = *queryFilter.Operator
+		}
+
+		filter.And = append(filter.And, data.Filter{
+			Field:    fieldMapping.SourceFieldName,
+			Operator: operator,
+			Value:    queryFilter.FieldValue,
+		})
+	}
+
+	err := filter.Validate(fieldTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
+// Returns the field mappings for the requested object fields, in the order of the field mappings
+func getSelectedFieldMappings(fields []string, fieldMappings []views.FieldMapping) ([]views.FieldMapping, error) {
+	if len(fields) == 0 {
+		return fieldMappings, nil
+	}
+
+	for _, field := range fields {
+		found := false
+		for _, fieldMapping := range fieldMappings {
+			if fieldMapping.DestinationFieldName == field {
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.NewBadRequestf("unknown field: %s", field)
+		}
+	}
+
+	selected := []views.FieldMapping{}
+	for _, fieldMapping := range fieldMappings {
+		if contains(fields, fieldMapping.DestinationFieldName) {
+			selected = append(selected, fieldMapping)
+		}
+	}
+
+	return selected, nil
+}
+
+// Returns the field mapping for the object's primary key, or nil if the object doesn't have one
+func getQueryPrimaryKey(querySource *querySource) *views.FieldMapping {
+	if !querySource.object.PrimaryKey.Valid {
+		return nil
+	}
+
+	return getQueryFieldMapping(querySource.object.PrimaryKey.String, querySource.fieldMappings)
+}
+
+// Fields mapped into a JSON field can't be looked up by the object field name, so they are skipped
+func getQueryFieldMapping(fieldName string, fieldMappings []views.FieldMapping) *views.FieldMapping {
+	for _, fieldMapping := range fieldMappings {
+		if fieldMapping.DestinationFieldName == fieldName && !fieldMapping.IsJsonField {
+			return &fieldMapping
+		}
+	}
+
+	return nil
+}
+
+func convertQueryRow(row data.Row, fieldMappings []views.FieldMapping) map[string]any {
 	outputData := map[string]any{}
 	for i, value := range row {
 		fieldMapping := fieldMappings[i]
-		destFieldName := orderedObjectFields[i].Name
+		destFieldName := fieldMapping.DestinationFieldName
 		// add raw values to the json object even if they're nil
 		if fieldMapping.IsJsonField {
 			existing, ok := outputData[destFieldName]
@@ -151,35 +395,44 @@ func (s ApiService) queryObject(organization *models.Organization, endCustomerID
 		}
 	}
 
-	return outputData, nil
+	return outputData
 }
 
-func getReadQuery(sourceConnection *models.Connection, sync models.Sync, fieldMappings []models.FieldMapping) string {
-	var queryString string
-	if sync.CustomJoin.Valid {
-		queryString = sync.CustomJoin.String
-	} else {
-		fields := []string{}
-		for _, fieldMapping := range fieldMappings {
-			fields = append(fields, fieldMapping.SourceFieldName)
-		}
-		selectString := strings.Join(fields, ",")
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, sync.Namespace.String, sync.TableName.String)
+// Cursors are the last primary key on the page, encoded the same way as sync cursors
+func encodeQueryCursor(primaryKeyType data.FieldType, primaryKeyValue any) (string, error) {
+	cursorState, err := data.NewCursorState(primaryKeyType, primaryKeyValue)
+	if err != nil {
+		return "", errors.Wrap(err, "(api.encodeQueryCursor)")
 	}
 
-	return fmt.Sprintf("%s;", queryString)
+	encoded, err := cursorState.Encode()
+	if err != nil {
+		return "", errors.Wrap(err, "(api.encodeQueryCursor)")
+	}
+
+	return base64.URLEncoding.EncodeToString([]byte(encoded)), nil
 }
 
-func createOrderedObjectFields(objectFields []models.ObjectField, fieldMappings []models.FieldMapping) []models.ObjectField {
-	objectFieldIdToObjectField := make(map[int64]models.ObjectField)
-	for _, objectField := range objectFields {
-		objectFieldIdToObjectField[objectField.ID] = objectField
+func decodeQueryCursor(cursor string) (any, error) {
+	decoded, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.NewBadRequest("invalid cursor")
+	}
+
+	value, err := data.ParseCursorState(string(decoded)).TypedValue()
+	if err != nil {
+		return nil, errors.NewBadRequest("invalid cursor")
 	}
 
-	var orderedObjectFields []models.ObjectField
-	for _, fieldMapping := range fieldMappings {
-		orderedObjectFields = append(orderedObjectFields, objectFieldIdToObjectField[fieldMapping.DestinationFieldId])
+	return value, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
 
-	return orderedObjectFields
+	return false
 }
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.fabra.io/server/common/auth"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/repositories/connections"
+	"go.fabra.io/server/common/repositories/destinations"
+	"go.fabra.io/server/common/repositories/objects"
+	"go.fabra.io/server/common/repositories/syncs"
+	"go.fabra.io/server/common/repositories/webhook_dead_letters"
+	"go.fabra.io/server/common/repositories/webhooks"
+	"go.fabra.io/server/common/timeutils"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+)
+
+type ReplayWebhookDeadLetterResponse struct {
+	DeadLetter views.WebhookDeadLetter `json:"dead_letter"`
+}
+
+func (s ApiService) ReplayWebhookDeadLetter(auth auth.Authentication, w http.ResponseWriter, r *http.Request) error {
+	if auth.Organization == nil {
+		return errors.Wrap(errors.NewBadRequest("must setup organization first"), "(api.ReplayWebhookDeadLetter)")
+	}
+
+	timezone := timeutils.GetTimezoneHeader(r)
+
+	vars := mux.Vars(r)
+	strSyncId, ok := vars["syncID"]
+	if !ok {
+		return errors.Newf("(api.ReplayWebhookDeadLetter) missing sync ID from ReplayWebhookDeadLetter request URL: %s", r.URL.RequestURI())
+	}
+
+	syncId, err := strconv.ParseInt(strSyncId, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	strDeadLetterId, ok := vars["deadLetterID"]
+	if !ok {
+		return errors.Newf("(api.ReplayWebhookDeadLetter) missing dead letter ID from ReplayWebhookDeadLetter request URL: %s", r.URL.RequestURI())
+	}
+
+	deadLetterId, err := strconv.ParseInt(strDeadLetterId, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	// check the sync belongs to the right organization
+	sync, err := syncs.LoadSyncByID(s.db, auth.Organization.ID, syncId)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	deadLetter, err := webhook_dead_letters.LoadWebhookDeadLetterByID(s.db, auth.Organization.ID, sync.ID, deadLetterId)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	if deadLetter.ReplayedAt.Valid {
+		return errors.NewBadRequest("dead letter has already been replayed")
+	}
+
+	object, err := objects.LoadObjectByID(s.db, auth.Organization.ID, deadLetter.ObjectID)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	objectFields, err := objects.LoadObjectFieldsByID(s.db, object.ID)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	destination, err := destinations.LoadDestinationByID(s.db, auth.Organization.ID, object.DestinationID)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	destinationConnection, err := connections.LoadConnectionByID(s.db, auth.Organization.ID, destination.ConnectionID)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	if destinationConnection.ConnectionType != models.ConnectionTypeWebhook {
+		return errors.NewBadRequest("destination is not a webhook")
+	}
+
+	encryptedEndCustomerApiKey, err := webhooks.LoadEndCustomerApiKey(s.db, auth.Organization.ID, deadLetter.EndCustomerID)
+	// This might be missing, but that's ok-- it isn't required
+	if err != nil && !errors.IsRecordNotFound(err) {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	var data []map[string]any
+	err = json.Unmarshal([]byte(deadLetter.Data), &data)
+	if err != nil {
+		return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+	}
+
+	replayErr := connectors.ReplayWebhookBatch(
+		r.Context(),
+		s.cryptoService,
+		views.ConvertFullConnection(destinationConnection),
+		views.ConvertObject(object, objectFields),
+		deadLetter.EndCustomerID,
+		encryptedEndCustomerApiKey,
+		data,
+	)
+	if replayErr != nil {
+		var deliveryError connectors.WebhookDeliveryError
+		if !errors.As(replayErr, &deliveryError) {
+			return errors.Wrap(replayErr, "(api.ReplayWebhookDeadLetter)")
+		}
+
+		deadLetter, err = webhook_dead_letters.RecordFailedReplay(s.db, deadLetter, deliveryError.Err.Error(), deliveryError.StatusCode)
+		if err != nil {
+			return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+		}
+	} else {
+		deadLetter, err = webhook_dead_letters.MarkWebhookDeadLetterReplayed(s.db, deadLetter)
+		if err != nil {
+			return errors.Wrap(err, "(api.ReplayWebhookDeadLetter)")
+		}
+	}
+
+	return json.NewEncoder(w).Encode(ReplayWebhookDeadLetterResponse{
+		DeadLetter: views.ConvertWebhookDeadLetter(*deadLetter, timezone),
+	})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.fabra.io/server/common/auth"
+	"go.fabra.io/server/common/crypto"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/repositories/connections"
+	"go.fabra.io/server/common/repositories/destinations"
+	"go.fabra.io/server/common/timeutils"
+	"go.fabra.io/server/common/views"
+)
+
+// how long payloads keep being signed with the previous key, giving receivers time to switch over
+const WEBHOOK_SIGNING_KEY_ROTATION_PERIOD = 24 * time.Hour
+
+type RotateWebhookSigningKeyResponse struct {
+	Destination          views.Destination `json:"destination"`
+	PreviousKeyExpiresAt string            `json:"previous_key_expires_at"`
+}
+
+func (s ApiService) RotateWebhookSigningKey(auth auth.Authentication, w http.ResponseWriter, r *http.Request) error {
+	if auth.Organization == nil {
+		return errors.Wrap(errors.NewBadRequest("must setup organization first"), "(api.RotateWebhookSigningKey)")
+	}
+
+	timezone := timeutils.GetTimezoneHeader(r)
+
+	vars := mux.Vars(r)
+	strDestinationId, ok := vars["destinationID"]
+	if !ok {
+		return errors.Newf("(api.RotateWebhookSigningKey) missing destination ID from RotateWebhookSigningKey request URL: %s", r.URL.RequestURI())
+	}
+
+	destinationId, err := strconv.ParseInt(strDestinationId, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "(api.RotateWebhookSigningKey)")
+	}
+
+	destination, err := destinations.LoadDestinationByID(s.db, auth.Organization.ID, destinationId)
+	if err != nil {
+		return errors.Wrap(err, "(api.RotateWebhookSigningKey)")
+	}
+
+	webhookSigningKey := crypto.GenerateSigningKey()
+	encryptedSigningKey, err := s.cryptoService.EncryptWebhookSigningKey(webhookSigningKey)
+	if err != nil {
+		return errors.Wrap(err, "(api.RotateWebhookSigningKey)")
+	}
+
+	previousKeyExpiresAt := time.Now().Add(WEBHOOK_SIGNING_KEY_ROTATION_PERIOD)
+	connection, err := connections.RotateWebhookSigningKey(s.db, auth.Organization.ID, destination.ConnectionID, *encryptedSigningKey, previousKeyExpiresAt)
+	if err != nil {
+		return errors.Wrap(err, "(api.RotateWebhookSigningKey)")
+	}
+
+	return json.NewEncoder(w).Encode(RotateWebhookSigningKeyResponse{
+		Destination:          views.ConvertWebhook(*destination, *connection, &webhookSigningKey),
+		PreviousKeyExpiresAt: previousKeyExpiresAt.In(timezone).Format(views.CUSTOMER_VISIBLE_TIME_FORMAT),
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.fabra.io/server/common/auth"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/input"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/repositories/connections"
+	"go.fabra.io/server/common/repositories/destinations"
+	"go.fabra.io/server/common/views"
+)
+
+type UpdateDestinationRequest struct {
+	// Replaces all existing headers when set. Pass an empty list to remove them.
+	WebhookHeaders *[]input.Header `json:"webhook_headers,omitempty"`
+	// Replaces all existing delivery options when set. Unset options use the defaults.
+	WebhookDeliveryOptions *input.WebhookDeliveryOptions `json:"webhook_delivery_options,omitempty"`
+}
+
+type UpdateDestinationResponse struct {
+	Destination views.Destination `json:"destination"`
+}
+
+func (s ApiService) UpdateDestination(auth auth.Authentication, w http.ResponseWriter, r *http.Request) error {
+	if auth.Organization == nil {
+		return errors.Wrap(errors.NewBadRequest("must setup organization first"), "(api.UpdateDestination)")
+	}
+
+	vars := mux.Vars(r)
+	strDestinationId, ok := vars["destinationID"]
+	if !ok {
+		return errors.Newf("(api.UpdateDestination) missing destination ID from UpdateDestination request URL: %s", r.URL.RequestURI())
+	}
+
+	destinationId, err := strconv.ParseInt(strDestinationId, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "(api.UpdateDestination)")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var updateDestinationRequest UpdateDestinationRequest
+	err = decoder.Decode(&updateDestinationRequest)
+	if err != nil {
+		return errors.Wrap(errors.WrapCustomerVisibleError(err), "(api.UpdateDestination)")
+	}
+
+	destination, err := destinations.LoadDestinationByID(s.db, auth.Organization.ID, destinationId)
+	if err != nil {
+		return errors.Wrap(err, "(api.UpdateDestination)")
+	}
+
+	connection, err := connections.LoadConnectionByID(s.db, auth.Organization.ID, destination.ConnectionID)
+	if err != nil {
+		return errors.Wrap(err, "(api.UpdateDestination)")
+	}
+
+	isWebhook := connection.ConnectionType == models.ConnectionTypeWebhook
+	if !isWebhook && (updateDestinationRequest.WebhookHeaders != nil || updateDestinationRequest.WebhookDeliveryOptions != nil) {
+		return errors.Wrap(errors.NewBadRequest("webhook settings can only be updated for webhook destinations"), "(api.UpdateDestination)")
+	}
+
+	if updateDestinationRequest.WebhookHeaders != nil {
+		err = validateWebhookHeaders(*updateDestinationRequest.WebhookHeaders)
+		if err != nil {
+			return errors.Wrap(err, "(api.UpdateDestination)")
+		}
+
+		encryptedHeaders, err := s.encryptWebhookHeaders(*updateDestinationRequest.WebhookHeaders)
+		if err != nil {
+			return errors.Wrap(err, "(api.UpdateDestination)")
+		}
+
+		connection, err = connections.UpdateWebhookHeaders(s.db, auth.Organization.ID, connection.ID, encryptedHeaders)
+		if err != nil {
+			return errors.Wrap(err, "(api.UpdateDestination)")
+		}
+	}
+
+	if updateDestinationRequest.WebhookDeliveryOptions != nil {
+		err = validateWebhookDeliveryOptions(*updateDestinationRequest.WebhookDeliveryOptions)
+		if err != nil {
+			return errors.Wrap(err, "(api.UpdateDestination)")
+		}
+
+		destination, err = destinations.UpdateWebhookDeliveryOptions(s.db, auth.Organization.ID, destination.ID, *updateDestinationRequest.WebhookDeliveryOptions)
+		if err != nil {
+			return errors.Wrap(err, "(api.UpdateDestination)")
+		}
+	}
+
+	var destinationView views.Destination
+	if isWebhook {
+		webhookSigningKey, err := s.cryptoService.DecryptWebhookSigningKey(connection.Credentials.String)
+		if err != nil {
+			return errors.Wrap(err, "(api.UpdateDestination)")
+		}
+
+		destinationView = views.ConvertWebhook(*destination, *connection, webhookSigningKey)
+	} else {
+		destinationView = views.ConvertDestination(*destination, *connection)
+	}
+
+	return json.NewEncoder(w).Encode(UpdateDestinationResponse{
+		destinationView,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"go.fabra.io/server/common/auth"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/input"
+	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/repositories/objects"
 	"go.fabra.io/server/common/views"
 
@@ -48,6 +49,29 @@ func (s ApiService) UpdateObject(auth auth.Authentication, w http.ResponseWriter
 		return err
 	}
 
+	if updateObjectRequest.SchemaDriftPolicy != nil {
+		err = validateSchemaDriftPolicy(*updateObjectRequest.SchemaDriftPolicy)
+		if err != nil {
+			return err
+		}
+	}
+
+	if updateObjectRequest.WebhookDeliveryOptions != nil {
+		existingObject, err := objects.LoadObjectByID(s.db, auth.Organization.ID, objectID)
+		if err != nil {
+			return err
+		}
+
+		if existingObject.TargetType != models.TargetTypeWebhook {
+			return errors.NewBadRequest("webhook delivery options can only be set for webhook objects")
+		}
+
+		err = validateWebhookDeliveryOptions(*updateObjectRequest.WebhookDeliveryOptions)
+		if err != nil {
+			return err
+		}
+	}
+
 	object, err := objects.PartialUpdateObject(
 		s.db,
 		auth.Organization.ID,
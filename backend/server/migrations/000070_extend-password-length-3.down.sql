@@ -0,0 +1 @@
+ALTER TABLE connections ALTER COLUMN password TYPE VARCHAR(512);
\ No newline at end of file
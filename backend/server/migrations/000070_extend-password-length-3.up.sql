@@ -0,0 +1 @@
+ALTER TABLE connections ALTER COLUMN password TYPE TEXT;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE webhook_dead_letters;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE webhook_dead_letters (
+    id              BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations(id),
+    sync_id         BIGINT NOT NULL REFERENCES syncs(id),
+    object_id       BIGINT NOT NULL REFERENCES objects(id),
+    end_customer_id VARCHAR(256) NOT NULL,
+    data            TEXT NOT NULL,
+    error           TEXT NOT NULL,
+    status_code     INT,
+    attempts        INT NOT NULL,
+    replayed_at     TIMESTAMP WITH TIME ZONE,
+
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    deactivated_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX webhook_dead_letters_sync_id_idx ON webhook_dead_letters(sync_id);
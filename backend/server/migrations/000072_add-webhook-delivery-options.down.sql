@@ -0,0 +1,9 @@
+ALTER TABLE objects DROP COLUMN webhook_max_payload_bytes;
+ALTER TABLE objects DROP COLUMN webhook_batch_size;
+ALTER TABLE objects DROP COLUMN webhook_burst;
+ALTER TABLE objects DROP COLUMN webhook_requests_per_second;
+
+ALTER TABLE destinations DROP COLUMN webhook_max_payload_bytes;
+ALTER TABLE destinations DROP COLUMN webhook_batch_size;
+ALTER TABLE destinations DROP COLUMN webhook_burst;
+ALTER TABLE destinations DROP COLUMN webhook_requests_per_second;
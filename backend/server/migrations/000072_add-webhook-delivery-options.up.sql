@@ -0,0 +1,9 @@
+ALTER TABLE destinations ADD COLUMN webhook_requests_per_second DOUBLE PRECISION;
+ALTER TABLE destinations ADD COLUMN webhook_burst INT;
+ALTER TABLE destinations ADD COLUMN webhook_batch_size INT;
+ALTER TABLE destinations ADD COLUMN webhook_max_payload_bytes INT;
+
+ALTER TABLE objects ADD COLUMN webhook_requests_per_second DOUBLE PRECISION;
+ALTER TABLE objects ADD COLUMN webhook_burst INT;
+ALTER TABLE objects ADD COLUMN webhook_batch_size INT;
+ALTER TABLE objects ADD COLUMN webhook_max_payload_bytes INT;
@@ -0,0 +1 @@
+ALTER TABLE connections DROP COLUMN previous_signing_keys;
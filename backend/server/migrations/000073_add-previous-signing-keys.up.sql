@@ -0,0 +1 @@
+ALTER TABLE connections ADD COLUMN previous_signing_keys TEXT;
@@ -0,0 +1,6 @@
+UPDATE syncs SET cursor_position = CASE
+    WHEN cursor_position::JSON->>'field_type' IN ('INTEGER', 'NUMBER') THEN cursor_position::JSON->>'value'
+    ELSE '''' || replace(cursor_position::JSON->>'value', '''', '''''') || ''''
+  END
+WHERE cursor_position LIKE '{%';
+ALTER TABLE syncs ALTER COLUMN cursor_position TYPE VARCHAR(255);
@@ -0,0 +1,15 @@
+ALTER TABLE syncs ALTER COLUMN cursor_position TYPE TEXT;
+UPDATE syncs SET cursor_position = json_build_object(
+  'version', 1,
+  'field_type', field_mappings.source_field_type,
+  'value', CASE
+    WHEN syncs.cursor_position LIKE '''%''' THEN replace(substring(syncs.cursor_position FROM 2 FOR length(syncs.cursor_position) - 2), '''''', '''')
+    ELSE syncs.cursor_position
+  END
+)::TEXT
+FROM field_mappings
+WHERE field_mappings.sync_id = syncs.id
+  AND field_mappings.source_field_name = syncs.source_cursor_field
+  AND field_mappings.deactivated_at IS NULL
+  AND syncs.cursor_position IS NOT NULL
+  AND syncs.cursor_position NOT LIKE '{%';
@@ -0,0 +1,2 @@
+ALTER TABLE syncs DROP COLUMN cursor_lookback_seconds;
+ALTER TABLE syncs DROP COLUMN cursor_tie_breaker;
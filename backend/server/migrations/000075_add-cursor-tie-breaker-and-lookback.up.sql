@@ -0,0 +1,2 @@
+ALTER TABLE syncs ADD COLUMN cursor_tie_breaker BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE syncs ADD COLUMN cursor_lookback_seconds BIGINT;
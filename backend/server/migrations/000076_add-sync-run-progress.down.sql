@@ -0,0 +1,4 @@
+ALTER TABLE sync_runs DROP COLUMN progress_updated_at;
+ALTER TABLE sync_runs DROP COLUMN phase;
+ALTER TABLE sync_runs DROP COLUMN bytes_read;
+ALTER TABLE sync_runs DROP COLUMN rows_read;
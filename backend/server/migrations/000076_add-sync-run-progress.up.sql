@@ -0,0 +1,4 @@
+ALTER TABLE sync_runs ADD COLUMN rows_read BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE sync_runs ADD COLUMN bytes_read BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE sync_runs ADD COLUMN phase VARCHAR(64);
+ALTER TABLE sync_runs ADD COLUMN progress_updated_at TIMESTAMP WITH TIME ZONE;
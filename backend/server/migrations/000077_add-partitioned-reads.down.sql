@@ -0,0 +1,3 @@
+ALTER TABLE connections DROP COLUMN max_read_concurrency;
+ALTER TABLE syncs DROP COLUMN partition_count;
+ALTER TABLE syncs DROP COLUMN partition_field;
@@ -0,0 +1,3 @@
+ALTER TABLE syncs ADD COLUMN partition_field VARCHAR(255);
+ALTER TABLE syncs ADD COLUMN partition_count INT;
+ALTER TABLE connections ADD COLUMN max_read_concurrency INT;
@@ -0,0 +1 @@
+ALTER TABLE syncs DROP COLUMN filter;
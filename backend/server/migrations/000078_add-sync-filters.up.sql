@@ -0,0 +1 @@
+ALTER TABLE syncs ADD COLUMN filter TEXT;
@@ -0,0 +1 @@
+ALTER TABLE field_mappings ADD COLUMN transforms TEXT;
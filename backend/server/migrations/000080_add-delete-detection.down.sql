@@ -0,0 +1,2 @@
+DROP TABLE sync_primary_key_snapshots;
+ALTER TABLE syncs DROP COLUMN delete_detection_interval_seconds;
@@ -0,0 +1,15 @@
+ALTER TABLE syncs ADD COLUMN delete_detection_interval_seconds BIGINT;
+
+CREATE TABLE sync_primary_key_snapshots (
+    id              BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations(id),
+    sync_id         BIGINT NOT NULL REFERENCES syncs(id),
+    primary_keys    BYTEA NOT NULL,
+    diffed_at       TIMESTAMP WITH TIME ZONE,
+
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    deactivated_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX sync_primary_key_snapshots_sync_id_idx ON sync_primary_key_snapshots(sync_id);
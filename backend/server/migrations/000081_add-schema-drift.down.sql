@@ -0,0 +1,3 @@
+ALTER TABLE sync_runs DROP COLUMN schema_drift;
+ALTER TABLE syncs DROP COLUMN source_schema;
+ALTER TABLE objects DROP COLUMN schema_drift_policy;
@@ -0,0 +1,6 @@
+ALTER TABLE objects ADD COLUMN schema_drift_policy VARCHAR(32) NOT NULL DEFAULT 'ignore_new_columns';
+ALTER TABLE objects ALTER COLUMN schema_drift_policy DROP DEFAULT;
+
+ALTER TABLE syncs ADD COLUMN source_schema TEXT;
+
+ALTER TABLE sync_runs ADD COLUMN schema_drift TEXT;
@@ -0,0 +1,8 @@
+DROP TABLE sync_staged_primary_keys;
+DROP TABLE sync_primary_keys;
+
+DROP INDEX sync_primary_key_snapshots_sync_id_idx;
+CREATE INDEX sync_primary_key_snapshots_sync_id_idx ON sync_primary_key_snapshots(sync_id);
+
+ALTER TABLE sync_primary_key_snapshots ADD COLUMN primary_keys BYTEA NOT NULL DEFAULT '';
+ALTER TABLE sync_primary_key_snapshots ALTER COLUMN primary_keys DROP DEFAULT;
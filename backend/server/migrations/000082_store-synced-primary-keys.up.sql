@@ -0,0 +1,24 @@
+-- snapshots no longer hold the serialized keys, which can't be carried over to the new tables
+DELETE FROM sync_primary_key_snapshots;
+ALTER TABLE sync_primary_key_snapshots DROP COLUMN primary_keys;
+
+DROP INDEX sync_primary_key_snapshots_sync_id_idx;
+CREATE UNIQUE INDEX sync_primary_key_snapshots_sync_id_idx ON sync_primary_key_snapshots(sync_id);
+
+-- the primary key of every row in the destination as of the last successful run
+CREATE TABLE sync_primary_keys (
+    sync_id     BIGINT NOT NULL REFERENCES syncs(id),
+    primary_key TEXT NOT NULL,
+
+    PRIMARY KEY (sync_id, primary_key)
+);
+
+-- the keys written, deleted, or read from the source by the current run, applied once every row is written
+CREATE TABLE sync_staged_primary_keys (
+    sync_id     BIGINT NOT NULL REFERENCES syncs(id),
+    primary_key TEXT NOT NULL,
+    deleted     BOOLEAN,
+    in_source   BOOLEAN NOT NULL DEFAULT FALSE,
+
+    PRIMARY KEY (sync_id, primary_key)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE sync_runs DROP COLUMN rows_dead_lettered;
+
+ALTER TABLE objects DROP COLUMN webhook_max_dead_letters;
+ALTER TABLE destinations DROP COLUMN webhook_max_dead_letters;
@@ -0,0 +1,4 @@
+ALTER TABLE destinations ADD COLUMN webhook_max_dead_letters INT;
+ALTER TABLE objects ADD COLUMN webhook_max_dead_letters INT;
+
+ALTER TABLE sync_runs ADD COLUMN rows_dead_lettered BIGINT NOT NULL DEFAULT 0;
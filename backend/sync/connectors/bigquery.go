@@ -1,20 +1,30 @@
 package connectors
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
+// Rows merged into the destination are loaded with whether they were deleted in this column
+const BIGQUERY_DELETED_COLUMN = "_fabra_deleted"
+const BIGQUERY_BATCH_COLUMN = "_fabra_batch"
+
 type BigQueryImpl struct {
 	client query.WarehouseClient
 }
@@ -30,20 +40,32 @@ func (bq BigQueryImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
-	readQuery := bq.getReadQuery(sourceConnection, sync, fieldMappings)
-	iterator, err := bq.client.GetQueryIterator(ctx, readQuery)
+	if sync.PartitionField != nil {
+		readPartitioned(ctx, bq.client, sqlbuilder.DialectBigQuery, sourceConnection, sync, fieldMappings, bq.getSelectColumns(fieldMappings), toBigQueryQueryValue, rowsC, readOutputC, errC)
+		return
+	}
+
+	readQuery, readArgs, err := bq.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.BigQueryImpl.Read) building query")
+		return
+	}
+
+	iterator, err := bq.client.GetQueryIterator(ctx, readQuery, readArgs...)
 	if err != nil {
 		errC <- errors.Wrap(err, "(connectors.BigQueryImpl.Read) getting iterator")
 		return
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
+	cursorTracker := newCursorTracker(sync, iterator.Schema())
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -55,90 +77,126 @@ func (bq BigQueryImpl) Read(
 			}
 		}
 
+		alreadySynced, err := cursorTracker.Track(row)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, row)
-		lastRow = row
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	newCursorPosition, err := cursorTracker.CursorPosition()
+	if err != nil {
+		errC <- err
+		return
 	}
 
-	newCursorPosition := bq.getNewCursorPosition(lastRow, iterator.Schema(), sync)
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
 	close(errC)
 }
 
-func (bq BigQueryImpl) getReadQuery(sourceConnection views.FullConnection, sync views.Sync, fieldMappings []views.FieldMapping) string {
-	var queryString string
+func (bq BigQueryImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (string, []any, error) {
+	var builder *sqlbuilder.SelectBuilder
 	if sync.CustomJoin != nil {
-		queryString = *sync.CustomJoin
+		builder = sqlbuilder.Select(sqlbuilder.DialectBigQuery).FromQuery(*sync.CustomJoin)
 	} else {
-		selectString := bq.getSelectString(fieldMappings)
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, *sync.Namespace, *sync.TableName)
+		builder = sqlbuilder.Select(sqlbuilder.DialectBigQuery, bq.getSelectColumns(fieldMappings)...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	err := addFilterCondition(builder, sync, toBigQueryQueryValue)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(connectors.BigQueryImpl.getReadQuery)")
 	}
 
 	if sync.SyncMode.UsesCursor() {
-		if sync.CursorPosition != nil {
-			// TODO: allow choosing other operators (rows smaller than current cursor)
-			// order by cursor field to simplify
-			return fmt.Sprintf("%s WHERE %s > %s ORDER BY %s ASC;", queryString, *sync.SourceCursorField, *sync.CursorPosition, *sync.SourceCursorField)
-		} else {
-			return fmt.Sprintf("%s ORDER BY %s ASC;", queryString, *sync.SourceCursorField)
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "(connectors.BigQueryImpl.getReadQuery)")
 		}
-	} else {
-		return fmt.Sprintf("%s;", queryString)
-	}
-}
 
-func (bq BigQueryImpl) getSelectString(fieldMappings []views.FieldMapping) string {
-	fields := []string{}
-	for _, fieldMapping := range fieldMappings {
-		fields = append(fields, fieldMapping.SourceFieldName)
+		if condition != nil {
+			sourceCursorFieldType, err := getSourceCursorFieldType(*sync.SourceCursorField, fieldMappings)
+			if err != nil {
+				return "", nil, errors.Wrap(err, "(connectors.BigQueryImpl.getReadQuery) error getting source cursor field type")
+			}
+
+			condition.CursorValue = toBigQueryQueryValue(*sourceCursorFieldType, condition.CursorValue)
+		}
+
+		addCursorCondition(builder, sync, condition)
 	}
 
-	return strings.Join(fields, ",")
+	queryString, args := builder.Build()
+	return queryString, args, nil
 }
 
-func (bq BigQueryImpl) getNewCursorPosition(lastRow data.Row, schema data.Schema, sync views.Sync) *string {
-	if sync.SourceCursorField == nil {
-		return nil
+// BigQuery won't compare DATETIME or DATE columns to a TIMESTAMP parameter, so times are converted to the civil type
+func toBigQueryQueryValue(fieldType data.FieldType, value any) any {
+	timeValue, ok := value.(time.Time)
+	if !ok {
+		return value
 	}
 
-	if lastRow == nil {
-		return nil
+	switch fieldType {
+	case data.FieldTypeDateTimeNtz:
+		return civil.DateTimeOf(timeValue)
+	case data.FieldTypeDate:
+		return civil.DateOf(timeValue)
+	default:
+		return value
 	}
+}
 
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range schema {
-		if schema[i].Name == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = schema[i].Type
-		}
+func (bq BigQueryImpl) ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error) {
+	iterator, err := readPrimaryKeys(ctx, bq.client, sqlbuilder.DialectBigQuery, sync, toBigQueryQueryValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.BigQueryImpl.ReadPrimaryKeys)")
 	}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+	return iterator, nil
+}
+
+func (bq BigQueryImpl) getSelectColumns(fieldMappings []views.FieldMapping) []string {
+	columns := []string{}
+	for _, fieldMapping := range fieldMappings {
+		columns = append(columns, fieldMapping.SourceFieldName)
 	}
 
-	return &newCursorPos
+	return columns
 }
 
 func (bq BigQueryImpl) Write(
@@ -148,10 +206,16 @@ func (bq BigQueryImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
+	// check before anything is staged, since rows can only be merged into the destination by primary key
+	if sync.SyncMode.UpdatesByPrimaryKey() && object.PrimaryKey == nil {
+		errC <- errors.NewCustomerVisibleError("primary key must be set on the object to use incremental update")
+		return
+	}
+
 	// always clean up the data in the storage bucket
 	objectPrefix := uuid.New().String()
 	wildcardObject := fmt.Sprintf("%s-*", objectPrefix)
@@ -160,14 +224,19 @@ func (bq BigQueryImpl) Write(
 	batchNum := 0
 	rowsWritten := 0
 	for {
-		rows, more := <-rowsC
+		rowBatch, more := <-rowsC
 		if !more {
 			break
 		}
 
-		rowsWritten += len(rows)
+		// only rows merged by primary key can be deleted
+		if !sync.SyncMode.UpdatesByPrimaryKey() {
+			rowBatch = NewRowBatch(rowBatch.Upserts())
+		}
+
+		rowsWritten += len(rowBatch.Rows)
 		objectName := fmt.Sprintf("%s-%d", objectPrefix, batchNum)
-		err := bq.stageBatch(ctx, rows, fieldMappings, object, sync, destinationOptions, bq.client, objectName)
+		err := bq.stageBatch(ctx, rowBatch, batchNum, fieldMappings, object, sync, destinationOptions, bq.client, objectName)
 		if err != nil {
 			errC <- errors.Wrap(err, "(connectors.BigQueryImpl.Write) staging batch")
 			return
@@ -182,7 +251,18 @@ func (bq BigQueryImpl) Write(
 	if rowsWritten > 0 {
 		writeMode := bq.toBigQueryWriteMode(sync.SyncMode)
 		csvSchema := bq.createCsvSchema(*object.EndCustomerIDField, object.ObjectFields)
-		err := bq.client.LoadFromStaging(ctx, *object.Namespace, *object.TableName, query.LoadOptions{
+
+		// incremental updates are loaded into a temporary table first and then merged into the destination
+		loadTableName := *object.TableName
+		if sync.SyncMode.UpdatesByPrimaryKey() {
+			loadTableName = bq.getTempTableName(*object.TableName)
+			csvSchema = bq.createTempTableSchema(csvSchema)
+
+			// use a separate context for cleanup so it won't get cancelled
+			defer bq.client.RunQuery(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS `%s.%s`", *object.Namespace, loadTableName))
+		}
+
+		err := bq.client.LoadFromStaging(ctx, *object.Namespace, loadTableName, query.LoadOptions{
 			GcsReference:   gcsReference,
 			BigQuerySchema: csvSchema,
 			WriteMode:      writeMode,
@@ -191,10 +271,19 @@ func (bq BigQueryImpl) Write(
 			errC <- errors.Wrap(err, "(connectors.BigQueryImpl.Write) loading data from staging")
 			return
 		}
+
+		if sync.SyncMode.UpdatesByPrimaryKey() {
+			_, err = bq.client.RunQuery(ctx, bq.getMergeQuery(object, loadTableName))
+			if err != nil {
+				errC <- errors.Wrap(err, "(connectors.BigQueryImpl.Write) merging temp table")
+				return
+			}
+		}
 	}
 
 	writeOutputC <- WriteOutput{
 		RowsWritten: rowsWritten,
+		Done:        true,
 	}
 
 	close(errC)
@@ -202,7 +291,8 @@ func (bq BigQueryImpl) Write(
 
 func (bq BigQueryImpl) stageBatch(
 	ctx context.Context,
-	rows []data.Row,
+	rowBatch RowBatch,
+	batchNum int,
 	fieldMappings []views.FieldMapping,
 	object views.Object, sync views.Sync,
 	destinationOptions DestinationOptions,
@@ -223,67 +313,88 @@ func (bq BigQueryImpl) stageBatch(
 
 	// extra field for end customer ID
 	numFields++
+	endCustomerIDIdx := numFields - 1
+
+	// rows merged into the destination also have whether they were deleted and the batch they were sent in
+	deletedIdx := -1
+	if sync.SyncMode.UpdatesByPrimaryKey() {
+		deletedIdx = numFields
+		numFields += 2
+	}
 
-	// allocate the arrays and reuse them to save memory
-	rowStrings := make([]string, len(rows))
+	// allocate the row tokens once and reuse them to save memory
 	rowTokens := make([]string, numFields)
-	rowTokens[numFields-1] = sync.EndCustomerID // end customer ID will be the same for every row
-
-	// write to temporary table in destination
-	for i, row := range rows {
-		indexToJsonValueMap := make(map[int]map[string]any)
-		for j, value := range row {
-			fieldMapping := fieldMappings[j]
-			sourceType := fieldMapping.SourceFieldType
-			destFieldIdx := objectFieldsIdToIndex[fieldMapping.DestinationFieldId]
-
-			// just collect the raw values into a map
-			if fieldMapping.IsJsonField {
-				existing, ok := indexToJsonValueMap[destFieldIdx]
-				if !ok {
-					existing = make(map[string]any)
-					indexToJsonValueMap[destFieldIdx] = existing
-				}
+	rowTokens[endCustomerIDIdx] = sync.EndCustomerID // end customer ID will be the same for every row
+	if deletedIdx >= 0 {
+		rowTokens[deletedIdx+1] = strconv.Itoa(batchNum)
+	}
 
-				existing[fieldMapping.SourceFieldName] = value
-			} else {
-				if value == nil {
-					// empty string for null values will be interpreted as null when loading from csv
-					rowTokens[j] = ""
+	// stream each row to staging as it is serialized so the whole batch is never held as a single string
+	writeData := func(w io.Writer) error {
+		writer := bufio.NewWriter(w)
+		for rowIndex, row := range rowBatch.Rows {
+			if deletedIdx >= 0 {
+				rowTokens[deletedIdx] = strconv.FormatBool(rowBatch.Operation(rowIndex) == data.RowOperationDelete)
+			}
+
+			indexToJsonValueMap := make(map[int]map[string]any)
+			for j, value := range row {
+				fieldMapping := fieldMappings[j]
+				sourceType := fieldMapping.SourceFieldType
+				destFieldIdx := objectFieldsIdToIndex[fieldMapping.DestinationFieldId]
+
+				// just collect the raw values into a map
+				if fieldMapping.IsJsonField {
+					existing, ok := indexToJsonValueMap[destFieldIdx]
+					if !ok {
+						existing = make(map[string]any)
+						indexToJsonValueMap[destFieldIdx] = existing
+					}
+
+					existing[fieldMapping.SourceFieldName] = value
 				} else {
-					switch sourceType {
-					case data.FieldTypeJson:
-						jsonStr, err := getBigQueryJsonString(value)
-						if err != nil {
-							return errors.Wrap(err, "(connectors.BigQueryImpl.stageBatch)")
+					if value == nil {
+						// empty string for null values will be interpreted as null when loading from csv
+						rowTokens[destFieldIdx] = ""
+					} else {
+						switch sourceType {
+						case data.FieldTypeJson:
+							jsonStr, err := getBigQueryJsonString(value)
+							if err != nil {
+								return errors.Wrap(err, "(connectors.BigQueryImpl.stageBatch)")
+							}
+							rowTokens[destFieldIdx] = jsonStr
+						case data.FieldTypeString:
+							// escape the string so commas don't break the CSV schema
+							rowTokens[destFieldIdx] = fmt.Sprintf("\"%v\"", value)
+						default:
+							rowTokens[destFieldIdx] = fmt.Sprintf("%v", value)
 						}
-						rowTokens[destFieldIdx] = jsonStr
-					case data.FieldTypeString:
-						// escape the string so commas don't break the CSV schema
-						rowTokens[destFieldIdx] = fmt.Sprintf("\"%v\"", value)
-					default:
-						rowTokens[destFieldIdx] = fmt.Sprintf("%v", value)
 					}
 				}
 			}
-		}
 
-		// insert the many-to-one mappings into the row tokens slice
-		for key, value := range indexToJsonValueMap {
-			jsonStr, err := getBigQueryJsonString(value)
-			if err != nil {
-				return errors.Wrap(err, "(connectors.BigQueryImpl.stageBatch)")
+			// insert the many-to-one mappings into the row tokens slice
+			for key, value := range indexToJsonValueMap {
+				jsonStr, err := getBigQueryJsonString(value)
+				if err != nil {
+					return errors.Wrap(err, "(connectors.BigQueryImpl.stageBatch)")
+				}
+
+				rowTokens[key] = jsonStr
 			}
 
-			rowTokens[key] = jsonStr
+			_, err := writer.WriteString(strings.Join(rowTokens, ",") + "\n")
+			if err != nil {
+				return errors.Wrap(err, "(connectors.BigQueryImpl.stageBatch) writing row")
+			}
 		}
 
-		rowString := strings.Join(rowTokens, ",")
-		rowStrings[i] = rowString
+		return writer.Flush()
 	}
 
 	stagingOptions := query.StagingOptions{Bucket: destinationOptions.StagingBucket, Object: objectName}
-	err := destClient.StageData(ctx, strings.Join(rowStrings, "\n"), stagingOptions)
+	err := destClient.StageData(ctx, writeData, stagingOptions)
 	if err != nil {
 		return err
 	}
@@ -291,6 +402,65 @@ func (bq BigQueryImpl) stageBatch(
 	return nil
 }
 
+func (bq BigQueryImpl) getTempTableName(tableName string) string {
+	return fmt.Sprintf("%s_fabra_tmp_%s", tableName, strings.ReplaceAll(uuid.New().String(), "-", "_"))
+}
+
+// Merges the rows in the temp table into the destination table, matching rows on the primary key and end customer ID
+// so rows belonging to other customers and rows that were not updated are left untouched.
+func (bq BigQueryImpl) getMergeQuery(object views.Object, tempTableName string) string {
+	primaryKey := *object.PrimaryKey
+	endCustomerIDColumn := *object.EndCustomerIDField
+
+	columns := []string{}
+	updateClauses := []string{}
+	for _, objectField := range object.ObjectFields {
+		if objectField.Omit {
+			continue
+		}
+
+		columns = append(columns, fmt.Sprintf("`%s`", objectField.Name))
+		if objectField.Name != primaryKey {
+			updateClauses = append(updateClauses, fmt.Sprintf("`%s` = source.`%s`", objectField.Name, objectField.Name))
+		}
+	}
+	columns = append(columns, fmt.Sprintf("`%s`", endCustomerIDColumn))
+
+	insertValues := make([]string, len(columns))
+	for i, column := range columns {
+		insertValues[i] = fmt.Sprintf("source.%s", column)
+	}
+
+	// the same row may have been updated multiple times since the last sync, so only keep the latest version. Batches
+	// are sent in the order the changes were read, and within a batch deletes are only sent once a row is no longer
+	// in the source, so they are the latest version.
+	orderBy := fmt.Sprintf(" ORDER BY `%s` DESC, `%s` DESC", BIGQUERY_BATCH_COLUMN, BIGQUERY_DELETED_COLUMN)
+	if object.CursorField != nil {
+		orderBy += fmt.Sprintf(", `%s` DESC", *object.CursorField)
+	}
+	sourceQuery := fmt.Sprintf(
+		"SELECT * EXCEPT(_fabra_row_num) FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY `%s`, `%s`%s) AS _fabra_row_num FROM `%s.%s`) WHERE _fabra_row_num = 1",
+		primaryKey, endCustomerIDColumn, orderBy, *object.Namespace, tempTableName,
+	)
+
+	matchedClause := fmt.Sprintf(" WHEN MATCHED AND source.`%s` THEN DELETE", BIGQUERY_DELETED_COLUMN)
+	if len(updateClauses) > 0 {
+		matchedClause += fmt.Sprintf(" WHEN MATCHED THEN UPDATE SET %s", strings.Join(updateClauses, ", "))
+	}
+
+	return fmt.Sprintf(
+		"MERGE `%s.%s` AS target USING (%s) AS source ON target.`%s` = source.`%s` AND target.`%s` = source.`%s`%s WHEN NOT MATCHED AND NOT source.`%s` THEN INSERT (%s) VALUES (%s);",
+		*object.Namespace, *object.TableName,
+		sourceQuery,
+		primaryKey, primaryKey,
+		endCustomerIDColumn, endCustomerIDColumn,
+		matchedClause,
+		BIGQUERY_DELETED_COLUMN,
+		strings.Join(columns, ", "),
+		strings.Join(insertValues, ", "),
+	)
+}
+
 // JSON-like values need to be escaped according to BigQuery expectations. Even if the destination
 // type is not JSON, it is necessary to escape to avoid issues
 // https://cloud.google.com/bigquery/docs/reference/standard-sql/json-data#load_from_csv_files
@@ -310,7 +480,7 @@ func (bq BigQueryImpl) toBigQueryWriteMode(syncMode models.SyncMode) bigquery.Ta
 		return bigquery.WriteAppend
 	case models.SyncModeIncrementalAppend:
 		return bigquery.WriteAppend
-	case models.SyncModeIncrementalUpdate:
+	case models.SyncModeIncrementalUpdate, models.SyncModeChangeDataCapture:
 		// incremental update loads updated/new rows into a temp table, before merging with the destination
 		return bigquery.WriteTruncate
 	default:
@@ -341,6 +511,29 @@ func (bq BigQueryImpl) createCsvSchema(endCustomerIDColumn string, orderedObject
 	return csvSchema
 }
 
+// Deleted rows only have the primary key set, so the temp table allows nulls in every column. The destination table
+// still requires values for inserted rows.
+func (bq BigQueryImpl) createTempTableSchema(csvSchema bigquery.Schema) bigquery.Schema {
+	var tempTableSchema bigquery.Schema
+	for _, field := range csvSchema {
+		optionalField := *field
+		optionalField.Required = false
+		tempTableSchema = append(tempTableSchema, &optionalField)
+	}
+
+	deletedField := bigquery.FieldSchema{
+		Name:     BIGQUERY_DELETED_COLUMN,
+		Type:     bigquery.BooleanFieldType,
+		Required: true,
+	}
+	batchField := bigquery.FieldSchema{
+		Name:     BIGQUERY_BATCH_COLUMN,
+		Type:     bigquery.IntegerFieldType,
+		Required: true,
+	}
+	return append(tempTableSchema, &deletedField, &batchField)
+}
+
 func getBigQueryType(fieldType data.FieldType) bigquery.FieldType {
 	switch fieldType {
 	case data.FieldTypeInteger:
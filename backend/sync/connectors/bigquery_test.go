@@ -1,12 +1,15 @@
 package connectors_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
 	"github.com/golang/mock/gomock"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
@@ -67,8 +70,8 @@ var _ = Describe("BigQueryConnector", func() {
 			client := mock_query.NewMockWarehouseClient(ctrl)
 			defer ctrl.Finish()
 
-			rows := make([]data.Row, 2_000_000)
-			for i := 0; i < 2_000_000; i++ {
+			rows := make([]data.Row, 2*connectors.READ_BATCH_SIZE)
+			for i := 0; i < 2*connectors.READ_BATCH_SIZE; i++ {
 				rows[i] = data.Row{"string", 1, false, "2006-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}}
 			}
 			iterator := test.NewMockIterator(
@@ -84,11 +87,11 @@ var _ = Describe("BigQueryConnector", func() {
 			)
 			client.EXPECT().GetQueryIterator(
 				gomock.Any(),
-				"SELECT source_string,source_integer,source_boolean,source_datetime_tz,source_datetime_ntz,source_json FROM namespace.table;",
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table`",
 			).Return(iterator, nil)
 
 			connector := connectors.NewBigQueryConnector(client)
-			rowsC := make(chan []data.Row)
+			rowsC := make(chan connectors.RowBatch)
 			readOutputC := make(chan connectors.ReadOutput)
 			errC := make(chan error)
 
@@ -131,11 +134,11 @@ var _ = Describe("BigQueryConnector", func() {
 			)
 			client.EXPECT().GetQueryIterator(
 				gomock.Any(),
-				"SELECT source_string,source_integer,source_boolean,source_datetime_tz,source_datetime_ntz,source_json FROM namespace.table ORDER BY source_datetime_tz ASC;",
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table` ORDER BY `source_datetime_tz` ASC",
 			).Return(iterator, nil)
 
 			connector := connectors.NewBigQueryConnector(client)
-			rowsC := make(chan []data.Row)
+			rowsC := make(chan connectors.RowBatch)
 			readOutputC := make(chan connectors.ReadOutput)
 			errC := make(chan error)
 
@@ -147,7 +150,7 @@ var _ = Describe("BigQueryConnector", func() {
 			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
 
 			Expect(err).To(BeNil())
-			Expect(*readOutput.CursorPosition).To(Equal("'2006-01-02 15:04:05.000-07:00'"))
+			Expect(*readOutput.CursorPosition).To(Equal(data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeDateTimeTz, Value: "2006-01-02T15:04:05-07:00"}))
 			Expect(resultRows).To(Equal(rows))
 			Expect(numBatches).To(Equal(1))
 		})
@@ -158,7 +161,7 @@ var _ = Describe("BigQueryConnector", func() {
 			defer ctrl.Finish()
 
 			sync.SyncMode = models.SyncModeIncrementalAppend
-			cursorPosition := "'2007-01-02 15:04:05.000-07:00'"
+			cursorPosition := data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeDateTimeTz, Value: "2007-01-02T15:04:05-07:00"}
 			cursorField := "source_datetime_tz"
 			sync.CursorPosition = &cursorPosition
 			sync.SourceCursorField = &cursorField
@@ -180,11 +183,12 @@ var _ = Describe("BigQueryConnector", func() {
 			)
 			client.EXPECT().GetQueryIterator(
 				gomock.Any(),
-				"SELECT source_string,source_integer,source_boolean,source_datetime_tz,source_datetime_ntz,source_json FROM namespace.table WHERE source_datetime_tz > '2007-01-02 15:04:05.000-07:00' ORDER BY source_datetime_tz ASC;",
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table` WHERE `source_datetime_tz` > ? ORDER BY `source_datetime_tz` ASC",
+				time.Date(2007, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60)),
 			).Return(iterator, nil)
 
 			connector := connectors.NewBigQueryConnector(client)
-			rowsC := make(chan []data.Row)
+			rowsC := make(chan connectors.RowBatch)
 			readOutputC := make(chan connectors.ReadOutput)
 			errC := make(chan error)
 
@@ -196,19 +200,203 @@ var _ = Describe("BigQueryConnector", func() {
 			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
 
 			Expect(err).To(BeNil())
-			Expect(*readOutput.CursorPosition).To(Equal("'2008-01-02 15:04:05.000-07:00'"))
+			Expect(*readOutput.CursorPosition).To(Equal(data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeDateTimeTz, Value: "2008-01-02T15:04:05-07:00"}))
 			Expect(len(resultRows)).To(Equal(1))
 			Expect(resultRows).To(Equal(rows))
 			Expect(numBatches).To(Equal(1))
 		})
 
-		It("does not wrap integer cursor with ''", func() {
+		It("keeps the cursor read from the source when the cursor field is transformed", func() {
 			ctrl := gomock.NewController(GinkgoT())
 			client := mock_query.NewMockWarehouseClient(ctrl)
 			defer ctrl.Finish()
 
 			sync.SyncMode = models.SyncModeIncrementalAppend
-			cursorPosition := "1"
+			cursorField := "source_datetime_tz"
+			sync.SourceCursorField = &cursorField
+			fieldMappings[3].Transforms = []data.Transform{{Type: data.TransformTypeHash}}
+
+			iterator := test.NewMockIterator(
+				[]data.Row{
+					{"string", 1, false, "2008-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", nil},
+					{"string", 2, false, "2009-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", nil},
+				},
+				data.Schema{
+					{Name: "source_string", Type: data.FieldTypeString},
+					{Name: "source_integer", Type: data.FieldTypeInteger},
+					{Name: "source_boolean", Type: data.FieldTypeBoolean},
+					{Name: "source_datetime_tz", Type: data.FieldTypeDateTimeTz},
+					{Name: "source_datetime_ntz", Type: data.FieldTypeDateTimeNtz},
+					{Name: "source_json", Type: data.FieldTypeJson},
+				},
+			)
+			client.EXPECT().GetQueryIterator(gomock.Any(), gomock.Any()).Return(iterator, nil)
+
+			rowTransformer, err := connectors.NewRowTransformer(fieldMappings)
+			Expect(err).To(BeNil())
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+
+			// transform the rows as they are received, like the replicate activity does before writing them
+			var readOutput connectors.ReadOutput
+			for !readOutput.Done {
+				select {
+				case err := <-errC:
+					Expect(err).To(BeNil())
+				case rowBatch := <-rowsC:
+					Expect(rowTransformer.Transform(rowBatch.Rows)).To(Succeed())
+					Expect(rowBatch.Rows[0][3]).To(HaveLen(64))
+				case readOutput = <-readOutputC:
+				}
+			}
+
+			Expect(*readOutput.CursorPosition).To(Equal(data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeDateTimeTz, Value: "2009-01-02T15:04:05-07:00"}))
+		})
+
+		It("orders rows with the same cursor value by the primary key", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeIncrementalAppend
+			tieBreakerValue := "3"
+			cursorPosition := data.CursorState{
+				Version:             data.CURSOR_STATE_VERSION,
+				FieldType:           data.FieldTypeDateTimeTz,
+				Value:               "2007-01-02T15:04:05-07:00",
+				TieBreakerFieldType: data.FieldTypeInteger,
+				TieBreakerValue:     &tieBreakerValue,
+			}
+			cursorField := "source_datetime_tz"
+			primaryKey := "source_integer"
+			sync.CursorPosition = &cursorPosition
+			sync.SourceCursorField = &cursorField
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorTieBreaker = true
+
+			rows := []data.Row{
+				{"string", 4, false, "2007-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}},
+				{"string", 1, false, "2008-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}},
+			}
+
+			iterator := test.NewMockIterator(
+				rows,
+				data.Schema{
+					{Name: "source_string", Type: data.FieldTypeString},
+					{Name: "source_integer", Type: data.FieldTypeInteger},
+					{Name: "source_boolean", Type: data.FieldTypeBoolean},
+					{Name: "source_datetime_tz", Type: data.FieldTypeDateTimeTz},
+					{Name: "source_datetime_ntz", Type: data.FieldTypeDateTimeNtz},
+					{Name: "source_json", Type: data.FieldTypeJson},
+				},
+			)
+			cursorTime := time.Date(2007, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))
+			client.EXPECT().GetQueryIterator(
+				gomock.Any(),
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table` WHERE (`source_datetime_tz` > ? OR (`source_datetime_tz` = ? AND `source_integer` > ?)) ORDER BY `source_datetime_tz` ASC,`source_integer` ASC",
+				cursorTime, cursorTime, int64(3),
+			).Return(iterator, nil)
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, _, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(resultRows).To(Equal(rows))
+			Expect(readOutput.CursorPosition.Value).To(Equal("2008-01-02T15:04:05-07:00"))
+			Expect(readOutput.CursorPosition.TieBreakerFieldType).To(Equal(data.FieldTypeInteger))
+			Expect(*readOutput.CursorPosition.TieBreakerValue).To(Equal("1"))
+		})
+
+		It("re-reads the lookback window and skips rows that were already synced", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeIncrementalAppend
+			cursorPosition := data.CursorState{
+				Version:   data.CURSOR_STATE_VERSION,
+				FieldType: data.FieldTypeDateTimeTz,
+				Value:     "2007-01-02T15:04:05-07:00",
+				SeenKeys:  map[string]string{"4": "2007-01-02T15:04:05-07:00"},
+			}
+			cursorField := "source_datetime_tz"
+			primaryKey := "source_integer"
+			lookbackSeconds := int64(60 * 60)
+			sync.CursorPosition = &cursorPosition
+			sync.SourceCursorField = &cursorField
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorLookbackSeconds = &lookbackSeconds
+
+			rows := []data.Row{
+				{"string", 4, false, "2007-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}},
+				{"string", 5, false, "2007-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}},
+				{"string", 6, false, "2007-01-02 15:30:00.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}},
+			}
+
+			iterator := test.NewMockIterator(
+				rows,
+				data.Schema{
+					{Name: "source_string", Type: data.FieldTypeString},
+					{Name: "source_integer", Type: data.FieldTypeInteger},
+					{Name: "source_boolean", Type: data.FieldTypeBoolean},
+					{Name: "source_datetime_tz", Type: data.FieldTypeDateTimeTz},
+					{Name: "source_datetime_ntz", Type: data.FieldTypeDateTimeNtz},
+					{Name: "source_json", Type: data.FieldTypeJson},
+				},
+			)
+			client.EXPECT().GetQueryIterator(
+				gomock.Any(),
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table` WHERE `source_datetime_tz` >= ? ORDER BY `source_datetime_tz` ASC",
+				time.Date(2007, 1, 2, 14, 4, 5, 0, time.FixedZone("", -7*60*60)),
+			).Return(iterator, nil)
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, _, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(resultRows).To(Equal(rows[1:]))
+			Expect(readOutput.CursorPosition.Value).To(Equal("2007-01-02T15:30:00-07:00"))
+			Expect(readOutput.CursorPosition.SeenKeys).To(Equal(map[string]string{
+				"4": "2007-01-02T15:04:05-07:00",
+				"5": "2007-01-02T15:04:05-07:00",
+				"6": "2007-01-02T15:30:00-07:00",
+			}))
+		})
+
+		It("binds untyped legacy integer cursors as integers", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeIncrementalAppend
+			cursorPosition := data.ParseCursorState("1")
 			cursorField := "source_integer"
 			sync.CursorPosition = &cursorPosition
 			sync.SourceCursorField = &cursorField
@@ -230,11 +418,12 @@ var _ = Describe("BigQueryConnector", func() {
 			)
 			client.EXPECT().GetQueryIterator(
 				gomock.Any(),
-				"SELECT source_string,source_integer,source_boolean,source_datetime_tz,source_datetime_ntz,source_json FROM namespace.table WHERE source_integer > 1 ORDER BY source_integer ASC;",
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table` WHERE `source_integer` > ? ORDER BY `source_integer` ASC",
+				int64(1),
 			).Return(iterator, nil)
 
 			connector := connectors.NewBigQueryConnector(client)
-			rowsC := make(chan []data.Row)
+			rowsC := make(chan connectors.RowBatch)
 			readOutputC := make(chan connectors.ReadOutput)
 			errC := make(chan error)
 
@@ -246,11 +435,139 @@ var _ = Describe("BigQueryConnector", func() {
 			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
 
 			Expect(err).To(BeNil())
-			Expect(*readOutput.CursorPosition).To(Equal("2"))
+			Expect(*readOutput.CursorPosition).To(Equal(data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeInteger, Value: "2"}))
 			Expect(len(resultRows)).To(Equal(1))
 			Expect(resultRows).To(Equal(rows))
 			Expect(numBatches).To(Equal(1))
 		})
+
+		It("filters rows in the read query", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.Filter = &data.Filter{And: []data.Filter{
+				{Field: "source_string", FieldType: data.FieldTypeString, Operator: data.FilterOperatorIn, Value: []any{"active", "trialing"}},
+				{Or: []data.Filter{
+					{Field: "source_integer", FieldType: data.FieldTypeInteger, Operator: data.FilterOperatorGreaterThan, Value: float64(5)},
+					{Field: "source_datetime_ntz", FieldType: data.FieldTypeDateTimeNtz, Operator: data.FilterOperatorLessThan, Value: "2023-01-01T00:00:00"},
+				}},
+				{Field: "source_json", FieldType: data.FieldTypeJson, Operator: data.FilterOperatorIsNotNull},
+			}}
+
+			rows := []data.Row{
+				{"active", 10, false, "2008-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", nil},
+			}
+			iterator := test.NewMockIterator(
+				rows,
+				data.Schema{
+					{Name: "source_string", Type: data.FieldTypeString},
+					{Name: "source_integer", Type: data.FieldTypeInteger},
+					{Name: "source_boolean", Type: data.FieldTypeBoolean},
+					{Name: "source_datetime_tz", Type: data.FieldTypeDateTimeTz},
+					{Name: "source_datetime_ntz", Type: data.FieldTypeDateTimeNtz},
+					{Name: "source_json", Type: data.FieldTypeJson},
+				},
+			)
+			client.EXPECT().GetQueryIterator(
+				gomock.Any(),
+				"SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table` WHERE (`source_string` IN (?,?) AND (`source_integer` > ? OR `source_datetime_ntz` < ?) AND `source_json` IS NOT NULL)",
+				"active", "trialing", int64(5), civil.DateTime{Date: civil.Date{Year: 2023, Month: time.January, Day: 1}},
+			).Return(iterator, nil)
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			_, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(resultRows).To(Equal(rows))
+			Expect(numBatches).To(Equal(1))
+		})
+
+		It("reads ranges of the partition field concurrently", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			partitionField := "source_integer"
+			partitionCount := 2
+			sync.PartitionField = &partitionField
+			sync.PartitionCount = &partitionCount
+
+			schema := data.Schema{
+				{Name: "source_string", Type: data.FieldTypeString},
+				{Name: "source_integer", Type: data.FieldTypeInteger},
+				{Name: "source_boolean", Type: data.FieldTypeBoolean},
+				{Name: "source_datetime_tz", Type: data.FieldTypeDateTimeTz},
+				{Name: "source_datetime_ntz", Type: data.FieldTypeDateTimeNtz},
+				{Name: "source_json", Type: data.FieldTypeJson},
+			}
+			nullRows := []data.Row{{"null", nil, false, nil, nil, nil}}
+			lowerRows := []data.Row{{"lower", int64(1), false, nil, nil, nil}, {"lower", int64(4), false, nil, nil, nil}}
+			upperRows := []data.Row{{"upper", int64(5), false, nil, nil, nil}, {"upper", int64(10), false, nil, nil, nil}}
+
+			selectQuery := "SELECT `source_string`,`source_integer`,`source_boolean`,`source_datetime_tz`,`source_datetime_ntz`,`source_json` FROM `namespace`.`table`"
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT MIN(`source_integer`),MAX(`source_integer`) FROM `namespace`.`table`").Return(&data.QueryResults{
+				Data: []data.Row{{int64(1), int64(10)}},
+			}, nil)
+			client.EXPECT().GetQueryIterator(gomock.Any(), selectQuery+" WHERE `source_integer` IS NULL").Return(test.NewMockIterator(nullRows, schema), nil)
+			client.EXPECT().GetQueryIterator(gomock.Any(), selectQuery+" WHERE `source_integer` >= ? AND `source_integer` < ?", int64(1), int64(5)).Return(test.NewMockIterator(lowerRows, schema), nil)
+			client.EXPECT().GetQueryIterator(gomock.Any(), selectQuery+" WHERE `source_integer` >= ? AND `source_integer` <= ?", int64(5), int64(10)).Return(test.NewMockIterator(upperRows, schema), nil)
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition).To(BeNil())
+			Expect(resultRows).To(ConsistOf(append(append(nullRows, lowerRows...), upperRows...)))
+			Expect(numBatches).To(Equal(3))
+		})
+	})
+
+	Describe("Lookup", func() {
+		It("reads a filtered page of rows after the cursor", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.Filter = &data.Filter{Field: "source_boolean", FieldType: data.FieldTypeBoolean, Operator: data.FilterOperatorEqual, Value: true}
+			orderBy := "source_datetime_ntz"
+			rows := []data.Row{{"2023-01-02 00:00:00", "string"}}
+			client.EXPECT().RunQuery(
+				gomock.Any(),
+				"SELECT `source_datetime_ntz`,`source_string` FROM `namespace`.`table` WHERE (`source_boolean` = ? AND `source_string` IN (?,?)) AND `source_datetime_ntz` > ? ORDER BY `source_datetime_ntz` ASC LIMIT 10",
+				true, "a", "b", civil.DateTime{Date: civil.Date{Year: 2023, Month: time.January, Day: 1}},
+			).Return(&data.QueryResults{Data: rows}, nil)
+
+			connectionModel := views.ConvertConnectionView(sourceConnection)
+			connectionModel.ConnectionType = models.ConnectionTypeBigQuery
+			resultRows, err := connectors.Lookup(context.TODO(), client, connectionModel, sync, []views.FieldMapping{fieldMappings[4], fieldMappings[0]}, connectors.LookupOptions{
+				Filter:  &data.Filter{Field: "source_string", FieldType: data.FieldTypeString, Operator: data.FilterOperatorIn, Value: []any{"a", "b"}},
+				OrderBy: &orderBy,
+				After:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				Limit:   10,
+			})
+
+			Expect(err).To(BeNil())
+			Expect(resultRows).To(Equal(rows))
+		})
 	})
 
 	Describe("Write", func() {
@@ -280,11 +597,11 @@ var _ = Describe("BigQueryConnector", func() {
 				// Strings should be quoted, while integers, numbers, and datetimes should not. JSON should be double quoted
 				csvRows[i] = "\"string\",2,false,2006-01-02 15:04:05.000-07:00,2006-01-02 15:04:05.000,\"{\"\"hello\"\":123}\",abc123"
 			}
-			csvData := strings.Join(csvRows, "\n")
+			csvData := strings.Join(csvRows, "\n") + "\n"
 
 			client.EXPECT().StageData(
 				gomock.Any(),
-				csvData,
+				MockStagingData{csvData},
 				MockStagingOptions{Bucket: "staging"},
 			).Return(nil)
 
@@ -313,7 +630,7 @@ var _ = Describe("BigQueryConnector", func() {
 			).Return(nil)
 
 			connector := connectors.NewBigQueryConnector(client)
-			rowsC := make(chan []data.Row)
+			rowsC := make(chan connectors.RowBatch)
 			writeOutputC := make(chan connectors.WriteOutput)
 			errC := make(chan error)
 
@@ -323,7 +640,7 @@ var _ = Describe("BigQueryConnector", func() {
 				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{StagingBucket: "staging"}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
 			}()
 
-			rowsC <- rows
+			rowsC <- connectors.NewRowBatch(rows)
 			close(rowsC)
 
 			writeOutput, err := waitForWrite(writeOutputC, errC)
@@ -331,16 +648,117 @@ var _ = Describe("BigQueryConnector", func() {
 			Expect(err).To(BeNil())
 			Expect(writeOutput.RowsWritten).To(Equal(10))
 		})
+
+		It("merges incremental updates and deletes on the primary key and end customer ID", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeIncrementalUpdate
+			primaryKey := "integer"
+			cursorField := "datetime_tz"
+			object.PrimaryKey = &primaryKey
+			object.CursorField = &cursorField
+
+			var rowBatch connectors.RowBatch
+			rowBatch.Add(data.Row{"string", 2, false, "2006-01-02 15:04:05.000-07:00", "2006-01-02 15:04:05.000", map[string]int{"hello": 123}}, data.RowOperationUpsert)
+			rowBatch.Add(data.Row{nil, 3, nil, nil, nil, nil}, data.RowOperationDelete)
+
+			// deleted rows only have the primary key, so every column of the temp table is optional
+			csvData := "\"string\",2,false,2006-01-02 15:04:05.000-07:00,2006-01-02 15:04:05.000,\"{\"\"hello\"\":123}\",abc123,false,0\n" +
+				",3,,,,,abc123,true,0\n"
+			client.EXPECT().StageData(gomock.Any(), MockStagingData{csvData}, MockStagingOptions{Bucket: "staging"}).Return(nil)
+			client.EXPECT().LoadFromStaging(gomock.Any(), "namespace", MockTempTable{"table"}, MockLoadOptions{
+				"staging",
+				bigquery.Schema{
+					{Name: "string", Type: bigquery.StringFieldType},
+					{Name: "integer", Type: bigquery.IntegerFieldType},
+					{Name: "boolean", Type: bigquery.BooleanFieldType},
+					{Name: "datetime_tz", Type: bigquery.TimestampFieldType},
+					{Name: "datetime_ntz", Type: bigquery.DateTimeFieldType},
+					{Name: "json", Type: bigquery.JSONFieldType},
+					{Name: "end_customer_id", Type: bigquery.StringFieldType},
+					{Name: "_fabra_deleted", Type: bigquery.BooleanFieldType, Required: true},
+					{Name: "_fabra_batch", Type: bigquery.IntegerFieldType, Required: true},
+				},
+				bigquery.WriteTruncate,
+			}).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{
+				"MERGE `namespace.table` AS target USING (SELECT * EXCEPT(_fabra_row_num) FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY `integer`, `end_customer_id` ORDER BY `_fabra_batch` DESC, `_fabra_deleted` DESC, `datetime_tz` DESC) AS _fabra_row_num FROM `namespace.table_fabra_tmp_",
+				") WHERE _fabra_row_num = 1) AS source ON target.`integer` = source.`integer` AND target.`end_customer_id` = source.`end_customer_id` " +
+					"WHEN MATCHED AND source.`_fabra_deleted` THEN DELETE " +
+					"WHEN MATCHED THEN UPDATE SET `string` = source.`string`, `boolean` = source.`boolean`, `datetime_tz` = source.`datetime_tz`, `datetime_ntz` = source.`datetime_ntz`, `json` = source.`json` " +
+					"WHEN NOT MATCHED AND NOT source.`_fabra_deleted` THEN INSERT (`string`, `integer`, `boolean`, `datetime_tz`, `datetime_ntz`, `json`, `end_customer_id`) " +
+					"VALUES (source.`string`, source.`integer`, source.`boolean`, source.`datetime_tz`, source.`datetime_ntz`, source.`json`, source.`end_customer_id`);",
+			}).Return(nil, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS `namespace.table_fabra_tmp_", ""}).Return(nil, nil)
+			client.EXPECT().CleanUpStagingData(gomock.Any(), MockStagingOptions{Bucket: "staging"}).Return(nil)
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{StagingBucket: "staging"}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- rowBatch
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+		})
+
+		It("requires a primary key for incremental updates before staging any rows", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			// no calls are expected on the client, so nothing is staged
+			sync.SyncMode = models.SyncModeIncrementalUpdate
+
+			connector := connectors.NewBigQueryConnector(client)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{StagingBucket: "staging"}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			close(rowsC)
+
+			_, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).ToNot(BeNil())
+		})
 	})
 })
 
 func waitForRead(
-	rowsC <-chan []data.Row,
+	rowsC <-chan connectors.RowBatch,
 	readOutputC <-chan connectors.ReadOutput,
 	errC <-chan error,
 ) (*connectors.ReadOutput, []data.Row, int, error) {
+	readOutput, rowBatch, numBatches, err := waitForReadBatch(rowsC, readOutputC, errC)
+	return readOutput, rowBatch.Rows, numBatches, err
+}
+
+// Collects every batch read into a single batch, so tests can check the operation of each row
+func waitForReadBatch(
+	rowsC <-chan connectors.RowBatch,
+	readOutputC <-chan connectors.ReadOutput,
+	errC <-chan error,
+) (*connectors.ReadOutput, connectors.RowBatch, int, error) {
 	var readOutput connectors.ReadOutput
-	var rows []data.Row
+	var rows connectors.RowBatch
 	var readDone bool
 	numBatches := 0
 	for {
@@ -351,13 +769,19 @@ func waitForRead(
 		select {
 		case err := <-errC:
 			if err != nil {
-				return nil, nil, numBatches, err
+				return nil, rows, numBatches, err
 			}
 		case rowBatch := <-rowsC:
-			rows = append(rows, rowBatch...)
+			for i, row := range rowBatch.Rows {
+				rows.Add(row, rowBatch.Operation(i))
+			}
 			numBatches++
-		case readOutput = <-readOutputC:
-			readDone = true
+		case output := <-readOutputC:
+			// skip the checkpoints sent after each batch
+			if output.Done {
+				readOutput = output
+				readDone = true
+			}
 		}
 	}
 
@@ -376,7 +800,10 @@ func waitForWrite(
 				return nil, errors.Wrap(err, "bigquery test waitForWrite")
 			}
 		case writeOutput = <-writeOutputC:
-			return &writeOutput, nil
+			// skip the outputs sent after each batch is committed
+			if writeOutput.Done {
+				return &writeOutput, nil
+			}
 		}
 	}
 
@@ -399,6 +826,29 @@ func (so MockStagingOptions) String() string {
 	return fmt.Sprintf("{%s, ANY}", so.Bucket)
 }
 
+// Matches the data written by a staging data writer
+type MockStagingData struct {
+	Data string
+}
+
+func (sd MockStagingData) Matches(x interface{}) bool {
+	writeData, ok := x.(query.StagingDataWriter)
+	if !ok {
+		return false
+	}
+
+	var buffer bytes.Buffer
+	if err := writeData(&buffer); err != nil {
+		return false
+	}
+
+	return sd.Data == buffer.String()
+}
+
+func (sd MockStagingData) String() string {
+	return sd.Data
+}
+
 type MockLoadOptions struct {
 	Bucket    string
 	Schema    bigquery.Schema
@@ -452,3 +902,39 @@ func (so MockLoadOptions) String() string {
 
 	return fmt.Sprintf("{\ngs://%s/, \n%v, \n%s\n}", so.Bucket, outputSchema, so.WriteMode)
 }
+
+type MockTempTable struct {
+	TableName string
+}
+
+func (tt MockTempTable) Matches(x interface{}) bool {
+	actual, ok := x.(string)
+	if !ok {
+		return false
+	}
+
+	return strings.HasPrefix(actual, tt.TableName+"_fabra_tmp_")
+}
+
+func (tt MockTempTable) String() string {
+	return fmt.Sprintf("%s_fabra_tmp_*", tt.TableName)
+}
+
+// Matches a query containing a randomly generated temp table name between the prefix and suffix
+type MockMergeQuery struct {
+	Prefix string
+	Suffix string
+}
+
+func (mq MockMergeQuery) Matches(x interface{}) bool {
+	actual, ok := x.(string)
+	if !ok {
+		return false
+	}
+
+	return strings.HasPrefix(actual, mq.Prefix) && strings.HasSuffix(actual, mq.Suffix)
+}
+
+func (mq MockMergeQuery) String() string {
+	return fmt.Sprintf("%s*%s", mq.Prefix, mq.Suffix)
+}
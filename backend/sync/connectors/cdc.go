@@ -0,0 +1,145 @@
+package connectors
+
+import (
+	"context"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+)
+
+// Sends every row of a change data capture sync's first read, or of a read of the whole source once its change log
+// can't be read from the cursor. The snapshot isn't read in any order, so it can't be resumed partway through and its
+// batches have no checkpoint.
+func readSnapshotRows(ctx context.Context, iterator data.RowIterator, rowsC chan<- RowBatch, readOutputC chan<- ReadOutput) (int, error) {
+	batchesRead := 0
+	var batchBytes int64
+	var rowBatch []data.Row
+	for {
+		row, err := iterator.Next(ctx)
+		if err == data.ErrDone {
+			break
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.readSnapshotRows)")
+		}
+
+		rowBatch = append(rowBatch, row)
+		batchBytes += row.EstimatedSize()
+		if len(rowBatch) == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			rowsC <- RowBatch{Rows: rowBatch, Snapshot: true}
+			readOutputC <- ReadOutput{BatchesRead: batchesRead}
+
+			batchBytes = 0
+			rowBatch = []data.Row{}
+		}
+	}
+
+	if len(rowBatch) > 0 {
+		batchesRead++
+		rowsC <- RowBatch{Rows: rowBatch, Snapshot: true}
+		readOutputC <- ReadOutput{BatchesRead: batchesRead}
+	}
+
+	return batchesRead, nil
+}
+
+// Deleted rows only have the primary key set, and are nil if the key's value isn't known
+type rowChange struct {
+	deleted bool
+	row     data.Row
+}
+
+// A row with only the primary key set, to delete the row with that key
+func newDeletedRow(numFields int, primaryKeyPos int, primaryKey any) data.Row {
+	row := make(data.Row, numFields)
+	row[primaryKeyPos] = primaryKey
+	return row
+}
+
+// The latest change to each primary key, in the order the keys were first changed
+type changeSet struct {
+	positions map[string]int
+	changes   []rowChange
+	bytes     int64
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{positions: map[string]int{}}
+}
+
+func (c *changeSet) set(key string, change rowChange) {
+	c.bytes += change.row.EstimatedSize()
+	if position, ok := c.positions[key]; ok {
+		c.bytes -= c.changes[position].row.EstimatedSize()
+		c.changes[position] = change
+		return
+	}
+
+	c.positions[key] = len(c.changes)
+	c.changes = append(c.changes, change)
+}
+
+// Returns true once the changes should be sent as a batch
+func (c *changeSet) full() bool {
+	return len(c.changes) >= READ_BATCH_SIZE || c.bytes >= READ_BATCH_BYTES
+}
+
+func (c *changeSet) reset() {
+	*c = *newChangeSet()
+}
+
+// Deletes without a known key value can't be matched to a written row, so they are skipped
+func (c *changeSet) rowBatch() RowBatch {
+	var batch RowBatch
+	for _, change := range c.changes {
+		if change.row == nil {
+			continue
+		}
+
+		if change.deleted {
+			batch.Add(change.row, data.RowOperationDelete)
+		} else {
+			batch.Add(change.row, data.RowOperationUpsert)
+		}
+	}
+
+	return batch
+}
+
+// Sends the changes read since the last batch as the next batch, with the position after them as its checkpoint, and
+// returns how many batches have been read. Every row is changed at most once within a batch, and batches are written
+// in order, so the latest change to each row is the one kept.
+func sendChanges(changes *changeSet, cursorPosition *data.CursorState, batchesRead int, rowsC chan<- RowBatch, readOutputC chan<- ReadOutput) int {
+	rowBatch := changes.rowBatch()
+	changes.reset()
+	if len(rowBatch.Rows) == 0 {
+		return batchesRead
+	}
+
+	batchesRead++
+	rowsC <- rowBatch
+	readOutputC <- ReadOutput{BatchesRead: batchesRead, CursorPosition: cursorPosition}
+	return batchesRead
+}
+
+// Splits the changes into batches of at most batchSize rows
+func (c *changeSet) rowBatches(batchSize int) []RowBatch {
+	changes := c.rowBatch()
+
+	rowBatches := []RowBatch{}
+	for start := 0; start < len(changes.Rows); start += batchSize {
+		end := start + batchSize
+		if end > len(changes.Rows) {
+			end = len(changes.Rows)
+		}
+
+		rowBatch := RowBatch{Rows: changes.Rows[start:end]}
+		if changes.Operations != nil {
+			rowBatch.Operations = changes.Operations[start:end]
+		}
+		rowBatches = append(rowBatches, rowBatch)
+	}
+
+	return rowBatches
+}
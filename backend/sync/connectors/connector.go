@@ -2,24 +2,114 @@ package connectors
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"time"
 
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
-const READ_BATCH_SIZE = 1_000_000
+// Readers send a batch once it reaches either limit. rowsC is unbuffered, so a reader blocks until the writer takes the
+// previous batch, and a sync holds at most a few batches in memory no matter how large or wide the source table is.
+const READ_BATCH_SIZE = 100_000
+const READ_BATCH_BYTES = 64 * 1024 * 1024
+
+// The most primary keys kept from the lookback window. Past this, rows read again from the window may be synced twice.
+const MAX_LOOKBACK_SEEN_KEYS = 10_000
 
 type DestinationOptions struct {
-	StagingBucket string
+	StagingBucket  string
+	WebhookOptions WebhookOptions
 }
 
+// Readers send a checkpoint output after each batch of rows with the position to resume reading from after that batch,
+// and a final output with Done set once every row has been read
 type ReadOutput struct {
-	CursorPosition *string
+	CursorPosition *data.CursorState
+	BatchesRead    int
+	Done           bool
 }
 
+// Writers that commit each batch of rows separately send an output after each commit, and every writer sends a final
+// output with Done set once every row has been written
 type WriteOutput struct {
-	RowsWritten int
+	RowsWritten      int
+	RowsDeadLettered int // rows the destination could not deliver and stored to be retried later
+	BatchesCommitted int
+	Done             bool
+}
+
+// A batch of rows sent from a reader to a writer. Deleted rows only have the primary key set.
+type RowBatch struct {
+	Rows []data.Row
+
+	// nil if every row is an upsert
+	Operations []data.RowOperation
+
+	// set on the batches of a read of every row in the source, rather than just the rows changed since the cursor
+	Snapshot bool
+}
+
+func NewRowBatch(rows []data.Row) RowBatch {
+	return RowBatch{Rows: rows}
+}
+
+func (b RowBatch) Operation(i int) data.RowOperation {
+	if b.Operations == nil {
+		return data.RowOperationUpsert
+	}
+
+	return b.Operations[i]
+}
+
+func (b *RowBatch) Add(row data.Row, operation data.RowOperation) {
+	if b.Operations == nil && operation != data.RowOperationUpsert {
+		b.Operations = make([]data.RowOperation, len(b.Rows), len(b.Rows)+1)
+		for i := range b.Operations {
+			b.Operations[i] = data.RowOperationUpsert
+		}
+	}
+
+	b.Rows = append(b.Rows, row)
+	if b.Operations != nil {
+		b.Operations = append(b.Operations, operation)
+	}
+}
+
+// Returns the rows that aren't deleted
+func (b RowBatch) Upserts() []data.Row {
+	if b.Operations == nil {
+		return b.Rows
+	}
+
+	upserts := []data.Row{}
+	for i, row := range b.Rows {
+		if b.Operations[i] == data.RowOperationUpsert {
+			upserts = append(upserts, row)
+		}
+	}
+
+	return upserts
+}
+
+// Returns every row of a batch written to a destination that can't apply deletes. Syncs that read deletes can't be
+// created for these destinations, so this only fails for syncs created before that was validated.
+func (b RowBatch) UpsertsOnly() ([]data.Row, error) {
+	for i := range b.Rows {
+		if b.Operation(i) == data.RowOperationDelete {
+			return nil, errors.NewCustomerVisibleError("deletes can't be applied to this destination")
+		}
+	}
+
+	return b.Rows, nil
 }
 
 type Connector interface {
@@ -28,7 +118,7 @@ type Connector interface {
 		sourceConnection views.FullConnection,
 		sync views.Sync,
 		fieldMappings []views.FieldMapping,
-		rowsC chan<- []data.Row,
+		rowsC chan<- RowBatch,
 		readOutputC chan<- ReadOutput,
 		errC chan<- error,
 	)
@@ -39,7 +129,7 @@ type Connector interface {
 		object views.Object,
 		sync views.Sync,
 		fieldMappings []views.FieldMapping,
-		rowsC <-chan []data.Row,
+		rowsC <-chan RowBatch,
 		writeOutputC chan<- WriteOutput,
 		errC chan<- error,
 	)
@@ -54,3 +144,462 @@ func getSourceCursorFieldType(sourceCursorFieldName string, fieldMappings []view
 
 	return nil, errors.Newf("(connectors.getSourceCursorFieldType) could not find field for cursor field name: %s", sourceCursorFieldName)
 }
+
+// Converts the sync's filter into a condition on the read query. toQueryValue converts filter values into values the
+// source can compare to the filtered field, and may be nil if the values can be bound as is.
+func getFilterCondition(filter data.Filter, toQueryValue func(fieldType data.FieldType, value any) any) (sqlbuilder.Condition, error) {
+	if !filter.IsCondition() {
+		var conditions []sqlbuilder.Condition
+		for _, nested := range append(filter.And, filter.Or...) {
+			condition, err := getFilterCondition(nested, toQueryValue)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+
+		if len(filter.And) > 0 {
+			return sqlbuilder.And(conditions...), nil
+		}
+		return sqlbuilder.Or(conditions...), nil
+	}
+
+	switch filter.Operator {
+	case data.FilterOperatorIsNull:
+		return sqlbuilder.IsNull(filter.Field), nil
+	case data.FilterOperatorIsNotNull:
+		return sqlbuilder.IsNotNull(filter.Field), nil
+	}
+
+	value, err := filter.TypedValue()
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getFilterCondition)")
+	}
+
+	if toQueryValue != nil {
+		if values, ok := value.([]any); ok {
+			for i := range values {
+				values[i] = toQueryValue(filter.FieldType, values[i])
+			}
+		} else {
+			value = toQueryValue(filter.FieldType, value)
+		}
+	}
+
+	switch filter.Operator {
+	case data.FilterOperatorEqual:
+		return sqlbuilder.Compare(filter.Field, sqlbuilder.OperatorEqual, value), nil
+	case data.FilterOperatorNotEqual:
+		return sqlbuilder.Compare(filter.Field, sqlbuilder.OperatorNotEqual, value), nil
+	case data.FilterOperatorGreaterThan:
+		return sqlbuilder.Compare(filter.Field, sqlbuilder.OperatorGreaterThan, value), nil
+	case data.FilterOperatorGreaterThanOrEqual:
+		return sqlbuilder.Compare(filter.Field, sqlbuilder.OperatorGreaterThanOrEqual, value), nil
+	case data.FilterOperatorLessThan:
+		return sqlbuilder.Compare(filter.Field, sqlbuilder.OperatorLessThan, value), nil
+	case data.FilterOperatorLessThanOrEqual:
+		return sqlbuilder.Compare(filter.Field, sqlbuilder.OperatorLessThanOrEqual, value), nil
+	case data.FilterOperatorIn:
+		return sqlbuilder.In(filter.Field, value.([]any)), nil
+	case data.FilterOperatorNotIn:
+		return sqlbuilder.NotIn(filter.Field, value.([]any)), nil
+	default:
+		return nil, errors.Newf("(connectors.getFilterCondition) unknown filter operator: %s", filter.Operator)
+	}
+}
+
+// Adds the sync's filter, if any, to the read query
+func addFilterCondition(builder *sqlbuilder.SelectBuilder, sync views.Sync, toQueryValue func(fieldType data.FieldType, value any) any) error {
+	if sync.Filter == nil {
+		return nil
+	}
+
+	condition, err := getFilterCondition(*sync.Filter, toQueryValue)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.addFilterCondition)")
+	}
+
+	builder.WhereCondition(condition)
+	return nil
+}
+
+// Converts the stored cursor position into a value that can be bound as a query parameter for the cursor field
+func getCursorValue(cursorPosition data.CursorState, cursorFieldType data.FieldType) (any, error) {
+	// cursors stored before they were typed do not have a field type, so use the type of the cursor field
+	if cursorPosition.FieldType == "" {
+		cursorPosition.FieldType = cursorFieldType
+	}
+
+	cursorValue, err := cursorPosition.TypedValue()
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getCursorValue)")
+	}
+
+	return cursorValue, nil
+}
+
+type cursorCondition struct {
+	Operator        sqlbuilder.Operator
+	CursorValue     any
+	TieBreakerValue any // nil unless rows with the same cursor value are ordered by the primary key
+}
+
+// Returns the condition for rows that have not been read yet, or nil if the sync has not read any rows. With a lookback
+// window, every row within the window before the cursor is read again and duplicates are skipped by the cursorTracker.
+func getCursorCondition(sync views.Sync, fieldMappings []views.FieldMapping) (*cursorCondition, error) {
+	if sync.CursorPosition == nil {
+		return nil, nil
+	}
+
+	sourceCursorFieldType, err := getSourceCursorFieldType(*sync.SourceCursorField, fieldMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getCursorCondition) error getting source cursor field type")
+	}
+
+	cursorValue, err := getCursorValue(*sync.CursorPosition, *sourceCursorFieldType)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getCursorCondition)")
+	}
+
+	if sync.CursorLookbackSeconds != nil {
+		timeCursor, ok := cursorValue.(time.Time)
+		if !ok {
+			return nil, errors.NewCustomerVisibleError("cursor lookback window can only be used with date or time cursor fields")
+		}
+
+		return &cursorCondition{
+			Operator:    sqlbuilder.OperatorGreaterThanOrEqual,
+			CursorValue: timeCursor.Add(-time.Duration(*sync.CursorLookbackSeconds) * time.Second),
+		}, nil
+	}
+
+	condition := cursorCondition{
+		Operator:    sqlbuilder.OperatorGreaterThan,
+		CursorValue: cursorValue,
+	}
+
+	if sync.CursorTieBreaker {
+		condition.TieBreakerValue, err = sync.CursorPosition.TypedTieBreakerValue()
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.getCursorCondition)")
+		}
+	}
+
+	return &condition, nil
+}
+
+// Adds the cursor condition and ordering to a read query for a sync that uses a cursor
+func addCursorCondition(builder *sqlbuilder.SelectBuilder, sync views.Sync, condition *cursorCondition) {
+	if condition != nil {
+		if condition.TieBreakerValue != nil {
+			builder.WhereAfter(*sync.SourceCursorField, condition.CursorValue, *sync.SourcePrimaryKey, condition.TieBreakerValue)
+		} else {
+			// TODO: allow choosing other operators (rows smaller than current cursor)
+			builder.Where(*sync.SourceCursorField, condition.Operator, condition.CursorValue)
+		}
+	}
+
+	// order by cursor field to simplify
+	builder.OrderByAsc(*sync.SourceCursorField)
+	if sync.CursorTieBreaker {
+		builder.OrderByAsc(*sync.SourcePrimaryKey)
+	}
+}
+
+// Sends a batch of rows to the writer, followed by a checkpoint so a retried read can resume after the batch
+func sendBatch(rowsC chan<- RowBatch, readOutputC chan<- ReadOutput, rowBatch []data.Row, batchesRead int, cursorTracker *cursorTracker) error {
+	rowsC <- NewRowBatch(rowBatch)
+
+	checkpoint, err := cursorTracker.Checkpoint()
+	if err != nil {
+		return errors.Wrap(err, "(connectors.sendBatch)")
+	}
+
+	readOutputC <- ReadOutput{
+		CursorPosition: checkpoint,
+		BatchesRead:    batchesRead,
+	}
+
+	return nil
+}
+
+// The values of a tracked row needed for its cursor position. They are copied out of the row since rows are transformed
+// in place once they are sent to the writer.
+type trackedPosition struct {
+	cursorValue     any
+	primaryKeyValue any
+}
+
+type seenKey struct {
+	primaryKey  string
+	cursorValue string
+	cursorTime  time.Time
+}
+
+// Tracks the rows read in cursor order to compute the new cursor position, and skips rows read again from the lookback
+// window that were already synced with the same cursor value
+type cursorTracker struct {
+	sync            views.Sync
+	cursorFieldPos  int
+	cursorFieldType data.FieldType
+	primaryKeyPos   int
+	primaryKeyType  data.FieldType
+	previousKeys    map[string]string
+	windowKeys      []seenKey
+	lastPosition    *trackedPosition
+
+	// the last row with a smaller cursor value than the last row, used to checkpoint without a tie-breaker
+	previousValuePosition *trackedPosition
+}
+
+func newCursorTracker(sync views.Sync, schema data.Schema) *cursorTracker {
+	tracker := cursorTracker{
+		sync:           sync,
+		cursorFieldPos: -1,
+		primaryKeyPos:  -1,
+	}
+
+	for i := range schema {
+		if sync.SourceCursorField != nil && schema[i].Name == *sync.SourceCursorField {
+			tracker.cursorFieldPos = i
+			tracker.cursorFieldType = schema[i].Type
+		}
+		if sync.SourcePrimaryKey != nil && schema[i].Name == *sync.SourcePrimaryKey {
+			tracker.primaryKeyPos = i
+			tracker.primaryKeyType = schema[i].Type
+		}
+	}
+
+	if sync.CursorLookbackSeconds != nil && sync.CursorPosition != nil {
+		tracker.previousKeys = sync.CursorPosition.SeenKeys
+	}
+
+	return &tracker
+}
+
+func (t *cursorTracker) usesLookback() bool {
+	return t.sync.CursorLookbackSeconds != nil && t.primaryKeyPos >= 0
+}
+
+// Records a row read from the source and returns whether it was already synced by a previous run
+func (t *cursorTracker) Track(row data.Row) (bool, error) {
+	if t.cursorFieldPos < 0 || row[t.cursorFieldPos] == nil {
+		return false, nil
+	}
+
+	if t.lastPosition != nil && !reflect.DeepEqual(t.lastPosition.cursorValue, row[t.cursorFieldPos]) {
+		t.previousValuePosition = t.lastPosition
+	}
+
+	t.lastPosition = &trackedPosition{cursorValue: row[t.cursorFieldPos]}
+	if t.primaryKeyPos >= 0 {
+		t.lastPosition.primaryKeyValue = row[t.primaryKeyPos]
+	}
+	if !t.usesLookback() || row[t.primaryKeyPos] == nil {
+		return false, nil
+	}
+
+	cursorValue, err := data.EncodeCursorValue(t.cursorFieldType, row[t.cursorFieldPos])
+	if err != nil {
+		return false, errors.Wrap(err, "(connectors.cursorTracker.Track)")
+	}
+
+	cursorState := data.CursorState{FieldType: t.cursorFieldType, Value: cursorValue}
+	typedCursorValue, err := cursorState.TypedValue()
+	if err != nil {
+		return false, errors.Wrap(err, "(connectors.cursorTracker.Track)")
+	}
+
+	cursorTime, ok := typedCursorValue.(time.Time)
+	if !ok {
+		return false, errors.NewCustomerVisibleError("cursor lookback window can only be used with date or time cursor fields")
+	}
+
+	primaryKey := fmt.Sprintf("%v", row[t.primaryKeyPos])
+	t.windowKeys = append(t.windowKeys, seenKey{primaryKey: primaryKey, cursorValue: cursorValue, cursorTime: cursorTime})
+
+	// rows are read in cursor order, so keys before the window of the current row will not be needed
+	windowStart := cursorTime.Add(-time.Duration(*t.sync.CursorLookbackSeconds) * time.Second)
+	for len(t.windowKeys) > 0 && (t.windowKeys[0].cursorTime.Before(windowStart) || len(t.windowKeys) > MAX_LOOKBACK_SEEN_KEYS) {
+		t.windowKeys = t.windowKeys[1:]
+	}
+
+	previousCursorValue, ok := t.previousKeys[primaryKey]
+	return ok && previousCursorValue == cursorValue, nil
+}
+
+// Returns the position after the last row tracked, or nil if no rows were read
+func (t *cursorTracker) CursorPosition() (*data.CursorState, error) {
+	cursorPosition, err := t.getCursorPosition(t.lastPosition)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.cursorTracker.CursorPosition)")
+	}
+
+	return cursorPosition, nil
+}
+
+// Returns a position to resume reading from that will not skip any rows. Without a tie-breaker or lookback window,
+// more rows with the same cursor value as the last row may follow, so the checkpoint is before the last cursor value.
+func (t *cursorTracker) Checkpoint() (*data.CursorState, error) {
+	checkpointPosition := t.lastPosition
+	if !t.sync.CursorTieBreaker && !t.usesLookback() {
+		checkpointPosition = t.previousValuePosition
+	}
+
+	checkpoint, err := t.getCursorPosition(checkpointPosition)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.cursorTracker.Checkpoint)")
+	}
+
+	return checkpoint, nil
+}
+
+func (t *cursorTracker) getCursorPosition(position *trackedPosition) (*data.CursorState, error) {
+	if t.sync.SourceCursorField == nil || position == nil {
+		return nil, nil
+	}
+
+	// rows are sorted by the cursor field, so later rows have larger cursor values
+	newCursorPosition, err := data.NewCursorState(t.cursorFieldType, position.cursorValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.cursorTracker.getCursorPosition)")
+	}
+
+	if t.sync.CursorTieBreaker && position.primaryKeyValue != nil {
+		err = newCursorPosition.SetTieBreaker(t.primaryKeyType, position.primaryKeyValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.cursorTracker.getCursorPosition)")
+		}
+	}
+
+	if t.usesLookback() && len(t.windowKeys) > 0 {
+		newCursorPosition.SeenKeys = make(map[string]string)
+		for _, key := range t.windowKeys {
+			newCursorPosition.SeenKeys[key.primaryKey] = key.cursorValue
+		}
+	}
+
+	return newCursorPosition, nil
+}
+
+var nonIdentifierCharacters = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// Objects with a table per customer write to a separate table for each end customer, suffixed with the end customer ID
+func getDestinationTableName(object views.Object, endCustomerID string) string {
+	if object.TargetType == models.TargetTypeTablePerCustomer {
+		return fmt.Sprintf("%s_%s", *object.TableName, nonIdentifierCharacters.ReplaceAllString(endCustomerID, "_"))
+	}
+
+	return *object.TableName
+}
+
+// Returns the destination columns in the order of the non-omitted object fields, followed by the end customer ID column
+func getDestinationColumns(object views.Object) []string {
+	columns := []string{}
+	for _, objectField := range object.ObjectFields {
+		if !objectField.Omit {
+			columns = append(columns, objectField.Name)
+		}
+	}
+
+	return append(columns, *object.EndCustomerIDField)
+}
+
+// JSON values are written as text, so destinations with a JSON type need to know which columns to parse
+func getDestinationJsonColumns(object views.Object) []string {
+	columns := []string{}
+	for _, objectField := range object.ObjectFields {
+		if !objectField.Omit && objectField.Type == data.FieldTypeJson {
+			columns = append(columns, objectField.Name)
+		}
+	}
+
+	return columns
+}
+
+// Converts source rows into rows matching the order of getDestinationColumns. Multiple source fields may be mapped to a
+// single JSON object in the destination, so those values are collected into a map.
+func convertToDestinationRows(rows []data.Row, object views.Object, fieldMappings []views.FieldMapping, endCustomerID string) []data.Row {
+	convertRow := newDestinationRowConverter(object, fieldMappings, endCustomerID)
+	destinationRows := make([]data.Row, len(rows))
+	for i, row := range rows {
+		destinationRows[i] = convertRow(row)
+	}
+
+	return destinationRows
+}
+
+// Returns a function that converts a source row into the order of the object fields, so rows can be converted one at a
+// time while they are streamed to the destination
+func newDestinationRowConverter(object views.Object, fieldMappings []views.FieldMapping, endCustomerID string) func(data.Row) data.Row {
+	numFields := 0
+	objectFieldsIdToIndex := make(map[int64]int)
+	for _, objectField := range object.ObjectFields {
+		if !objectField.Omit {
+			objectFieldsIdToIndex[objectField.ID] = numFields
+			numFields++
+		}
+	}
+
+	// extra field for end customer ID
+	numFields++
+
+	return func(row data.Row) data.Row {
+		destinationRow := make(data.Row, numFields)
+		destinationRow[numFields-1] = endCustomerID
+		for j, value := range row {
+			fieldMapping := fieldMappings[j]
+			destFieldIdx := objectFieldsIdToIndex[fieldMapping.DestinationFieldId]
+			if fieldMapping.IsJsonField {
+				existing, ok := destinationRow[destFieldIdx].(map[string]any)
+				if !ok {
+					existing = make(map[string]any)
+					destinationRow[destFieldIdx] = existing
+				}
+
+				existing[fieldMapping.SourceFieldName] = value
+			} else {
+				destinationRow[destFieldIdx] = value
+			}
+		}
+
+		return destinationRow
+	}
+}
+
+// Streams rows as CSV for bulk loading, converting each row just before it is written. Nil values are written as empty
+// fields, which are loaded as NULL.
+func writeCsvData(w io.Writer, rows []data.Row, convertRow func(data.Row) data.Row) error {
+	writer := csv.NewWriter(w)
+
+	record := []string{}
+	for _, row := range rows {
+		record = record[:0]
+		for _, value := range convertRow(row) {
+			switch value.(type) {
+			case nil:
+				record = append(record, "")
+			case map[string]any, []any:
+				jsonValue, err := json.Marshal(value)
+				if err != nil {
+					return errors.Wrap(err, "(connectors.writeCsvData)")
+				}
+				record = append(record, string(jsonValue))
+			default:
+				record = append(record, fmt.Sprintf("%v", value))
+			}
+		}
+
+		err := writer.Write(record)
+		if err != nil {
+			return errors.Wrap(err, "(connectors.writeCsvData)")
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return errors.Wrap(err, "(connectors.writeCsvData)")
+	}
+
+	return nil
+}
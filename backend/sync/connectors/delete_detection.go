@@ -0,0 +1,297 @@
+package connectors
+
+import (
+	"context"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
+	"go.fabra.io/server/common/views"
+)
+
+// Reads ordered by a cursor never see rows deleted from the source. Incremental update syncs can instead read every
+// primary key in the source once in a while and delete the rows whose keys were synced before but are now gone.
+// Change data capture syncs that read the whole source again after losing their change log diff the keys of that read
+// the same way, since the deletes made while the log was lost are never read.
+
+// Sources that can read the primary key of every source row the sync reads
+type PrimaryKeyReader interface {
+	ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error)
+}
+
+// Stores the primary keys already synced by syncs that detect deletes. The keys a run tracks are staged until every
+// row of the run is written, so a failed run leaves the stored keys as they were.
+type PrimaryKeyStore interface {
+	// Returns nil if the sync has not stored any keys yet
+	LoadPrimaryKeySnapshot(sync views.Sync) (*PrimaryKeySnapshot, error)
+	ClearStagedPrimaryKeys(sync views.Sync) error
+	StagePrimaryKeys(sync views.Sync, keys []string, deleted bool) error
+	StageSourcePrimaryKeys(sync views.Sync, keys []string) error
+	// Returns up to limit keys in the destination that weren't read from the source, in order and after afterKey
+	LoadMissingPrimaryKeys(sync views.Sync, afterKey string, limit int) ([]string, error)
+	// Applies the staged keys. If diffedAt is set, the keys read from the source replace the stored keys.
+	CommitPrimaryKeys(sync views.Sync, diffedAt *time.Time) error
+}
+
+// When the stored keys were last diffed against the source
+type PrimaryKeySnapshot struct {
+	DiffedAt *time.Time
+}
+
+// Stages the primary keys written by each run, and finds the keys that are no longer in the source when a diff is
+// due. Keys are encoded the same way as cursor values.
+type DeleteDetector struct {
+	store          PrimaryKeyStore
+	sync           views.Sync
+	numFields      int
+	primaryKeyPos  int
+	primaryKeyType data.FieldType
+	diffedAt       *time.Time
+	diffed         bool
+
+	// whether the run read every row in the source, so the keys it wrote are every key in the source
+	snapshotRead bool
+}
+
+func NewDeleteDetector(sync views.Sync, fieldMappings []views.FieldMapping, store PrimaryKeyStore) (*DeleteDetector, error) {
+	detector := DeleteDetector{
+		store:         store,
+		sync:          sync,
+		numFields:     len(fieldMappings),
+		primaryKeyPos: -1,
+	}
+
+	for i, fieldMapping := range fieldMappings {
+		if sync.SourcePrimaryKey != nil && fieldMapping.SourceFieldName == *sync.SourcePrimaryKey {
+			detector.primaryKeyPos = i
+			detector.primaryKeyType = fieldMapping.SourceFieldType
+		}
+	}
+	if detector.primaryKeyPos < 0 {
+		return nil, errors.NewCustomerVisibleError("the primary key must be mapped to detect deletes")
+	}
+
+	// keys are sent back to the destination as they are decoded, which only keeps the source's value for these types
+	switch detector.primaryKeyType {
+	case data.FieldTypeInteger, data.FieldTypeString:
+	default:
+		return nil, errors.NewCustomerVisibleError("deletes can only be detected for integer or string primary keys")
+	}
+
+	snapshot, err := store.LoadPrimaryKeySnapshot(sync)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.NewDeleteDetector)")
+	}
+	if snapshot != nil {
+		detector.diffedAt = snapshot.DiffedAt
+	}
+
+	// keys staged by a run that failed were never written, or are written again by this run
+	err = store.ClearStagedPrimaryKeys(sync)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.NewDeleteDetector)")
+	}
+
+	return &detector, nil
+}
+
+// Stages the keys of the rows in a batch, before they are transformed
+func (d *DeleteDetector) Track(rowBatch RowBatch) error {
+	// only the last change to a key in the batch is kept
+	deletedKeys := map[string]bool{}
+	for i, row := range rowBatch.Rows {
+		if row[d.primaryKeyPos] == nil {
+			continue
+		}
+
+		key, err := data.EncodeCursorValue(d.primaryKeyType, row[d.primaryKeyPos])
+		if err != nil {
+			return errors.Wrap(err, "(connectors.DeleteDetector.Track)")
+		}
+		deletedKeys[key] = rowBatch.Operation(i) == data.RowOperationDelete
+	}
+
+	var upsertedKeys, removedKeys []string
+	for key, deleted := range deletedKeys {
+		if deleted {
+			removedKeys = append(removedKeys, key)
+		} else {
+			upsertedKeys = append(upsertedKeys, key)
+		}
+	}
+
+	err := d.store.StagePrimaryKeys(d.sync, upsertedKeys, false)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DeleteDetector.Track)")
+	}
+
+	err = d.store.StagePrimaryKeys(d.sync, removedKeys, true)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DeleteDetector.Track)")
+	}
+
+	if rowBatch.Snapshot {
+		err = d.store.StageSourcePrimaryKeys(d.sync, upsertedKeys)
+		if err != nil {
+			return errors.Wrap(err, "(connectors.DeleteDetector.Track)")
+		}
+		d.snapshotRead = true
+	}
+
+	return nil
+}
+
+// Returns whether the keys should be diffed against the source in this run. Syncs without a delete detection interval
+// only diff the keys of snapshots.
+func (d *DeleteDetector) Due(now time.Time) bool {
+	if d.sync.DeleteDetectionIntervalSeconds == nil {
+		return false
+	}
+
+	if d.diffedAt == nil {
+		return true
+	}
+
+	return now.Sub(*d.diffedAt) >= time.Duration(*d.sync.DeleteDetectionIntervalSeconds)*time.Second
+}
+
+// Stages every key read from the source, then passes batches deleting the rows whose keys are gone to handleBatch. The
+// keys are compared by the store, so neither set of keys is held in memory.
+func (d *DeleteDetector) DetectDeletes(ctx context.Context, iterator data.RowIterator, handleBatch func(RowBatch) error) error {
+	diffedAt := time.Now()
+	sourceKeys := []string{}
+	for {
+		row, err := iterator.Next(ctx)
+		if err == data.ErrDone {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "(connectors.DeleteDetector.DetectDeletes) reading primary keys")
+		}
+		if row[0] == nil {
+			continue
+		}
+
+		key, err := data.EncodeCursorValue(d.primaryKeyType, row[0])
+		if err != nil {
+			return errors.Wrap(err, "(connectors.DeleteDetector.DetectDeletes)")
+		}
+
+		sourceKeys = append(sourceKeys, key)
+		if len(sourceKeys) == READ_BATCH_SIZE {
+			err = d.store.StageSourcePrimaryKeys(d.sync, sourceKeys)
+			if err != nil {
+				return errors.Wrap(err, "(connectors.DeleteDetector.DetectDeletes)")
+			}
+			sourceKeys = []string{}
+		}
+	}
+
+	err := d.store.StageSourcePrimaryKeys(d.sync, sourceKeys)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DeleteDetector.DetectDeletes)")
+	}
+
+	err = d.deleteMissingKeys(handleBatch, diffedAt)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DeleteDetector.DetectDeletes)")
+	}
+
+	return nil
+}
+
+// Passes batches deleting the rows whose keys weren't read by a snapshot in this run to handleBatch. Does nothing if
+// the run didn't read a snapshot.
+func (d *DeleteDetector) DetectSnapshotDeletes(handleBatch func(RowBatch) error) error {
+	if !d.snapshotRead {
+		return nil
+	}
+
+	err := d.deleteMissingKeys(handleBatch, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DeleteDetector.DetectSnapshotDeletes)")
+	}
+
+	return nil
+}
+
+// Compares the staged source keys to the keys in the destination
+func (d *DeleteDetector) deleteMissingKeys(handleBatch func(RowBatch) error, diffedAt time.Time) error {
+	afterKey := ""
+	for {
+		missingKeys, err := d.store.LoadMissingPrimaryKeys(d.sync, afterKey, READ_BATCH_SIZE)
+		if err != nil {
+			return errors.Wrap(err, "(connectors.DeleteDetector.deleteMissingKeys)")
+		}
+		if len(missingKeys) == 0 {
+			break
+		}
+
+		var rowBatch RowBatch
+		for _, key := range missingKeys {
+			value, err := data.CursorState{FieldType: d.primaryKeyType, Value: key}.TypedValue()
+			if err != nil {
+				return errors.Wrap(err, "(connectors.DeleteDetector.deleteMissingKeys)")
+			}
+
+			rowBatch.Add(newDeletedRow(d.numFields, d.primaryKeyPos, value), data.RowOperationDelete)
+		}
+		err = handleBatch(rowBatch)
+		if err != nil {
+			return errors.Wrap(err, "(connectors.DeleteDetector.deleteMissingKeys)")
+		}
+
+		afterKey = missingKeys[len(missingKeys)-1]
+	}
+
+	d.diffedAt = &diffedAt
+	d.diffed = true
+	return nil
+}
+
+// Stores the staged keys once every row of the run has been written
+func (d *DeleteDetector) Commit() error {
+	var diffedAt *time.Time
+	if d.diffed {
+		diffedAt = d.diffedAt
+	}
+
+	err := d.store.CommitPrimaryKeys(d.sync, diffedAt)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DeleteDetector.Commit)")
+	}
+
+	return nil
+}
+
+// Selects the primary key of every source row that passes the sync's filter
+func readPrimaryKeys(
+	ctx context.Context,
+	client query.ConnectorClient,
+	dialect sqlbuilder.Dialect,
+	sync views.Sync,
+	toQueryValue func(fieldType data.FieldType, value any) any,
+) (data.RowIterator, error) {
+	if sync.CustomJoin != nil || sync.SourcePrimaryKey == nil {
+		return nil, errors.NewCustomerVisibleError("deletes can only be detected for syncs of a single table with a primary key")
+	}
+
+	var filterCondition sqlbuilder.Condition
+	if sync.Filter != nil {
+		var err error
+		filterCondition, err = getFilterCondition(*sync.Filter, toQueryValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.readPrimaryKeys) getting filter condition")
+		}
+	}
+
+	keysQuery, keysArgs := getSourceBuilder(dialect, sync, []string{*sync.SourcePrimaryKey}, filterCondition).Build()
+	iterator, err := client.GetQueryIterator(ctx, keysQuery, keysArgs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.readPrimaryKeys) getting iterator")
+	}
+
+	return iterator, nil
+}
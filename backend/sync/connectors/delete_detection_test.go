@@ -0,0 +1,190 @@
+package connectors_test
+
+import (
+	"context"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/input"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/repositories/sync_primary_key_snapshots"
+	"go.fabra.io/server/common/repositories/sync_primary_keys"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+	"gorm.io/gorm"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeleteDetector", func() {
+	var (
+		sync          views.Sync
+		fieldMappings []views.FieldMapping
+		store         connectors.PrimaryKeyStore
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, _ := test.CreateSource(db, org.ID, endCustomerID)
+		destination, _ := test.CreateDestination(db, org.ID)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeIncrementalUpdate)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+		})
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeIncrementalUpdate))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_name", SourceFieldType: data.FieldTypeString, DestinationFieldId: objectFields[1].ID},
+		}), objectFields)
+
+		primaryKey := "source_id"
+		interval := int64(3600)
+		sync.SourcePrimaryKey = &primaryKey
+		sync.DeleteDetectionIntervalSeconds = &interval
+		store = testPrimaryKeyStore{db: db}
+	})
+
+	detectDeletes := func(detector *connectors.DeleteDetector, sourceKeys ...int64) []data.Row {
+		sourceRows := []data.Row{}
+		for _, key := range sourceKeys {
+			sourceRows = append(sourceRows, data.Row{key})
+		}
+
+		deletedRows := []data.Row{}
+		err := detector.DetectDeletes(context.TODO(), test.NewMockIterator(sourceRows, data.Schema{{Name: "source_id", Type: data.FieldTypeInteger}}), func(rowBatch connectors.RowBatch) error {
+			for i, row := range rowBatch.Rows {
+				Expect(rowBatch.Operation(i)).To(Equal(data.RowOperationDelete))
+				deletedRows = append(deletedRows, row)
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+
+		return deletedRows
+	}
+
+	It("deletes the rows whose keys were synced but are no longer in the source", func() {
+		detector, err := connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detector.Due(time.Now())).To(BeTrue())
+
+		err = detector.Track(connectors.NewRowBatch([]data.Row{{int64(1), "first"}, {int64(2), "second"}, {int64(3), "third"}}))
+		Expect(err).To(BeNil())
+		Expect(detectDeletes(detector, 1, 3)).To(Equal([]data.Row{{int64(2), nil}}))
+		Expect(detector.Commit()).To(BeNil())
+
+		// runs between diffs apply the keys they wrote and deleted to the stored keys
+		detector, err = connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detector.Due(time.Now())).To(BeFalse())
+
+		var rowBatch connectors.RowBatch
+		rowBatch.Add(data.Row{int64(4), "fourth"}, data.RowOperationUpsert)
+		rowBatch.Add(data.Row{int64(3), nil}, data.RowOperationDelete)
+		Expect(detector.Track(rowBatch)).To(BeNil())
+		Expect(detector.Commit()).To(BeNil())
+
+		// keys staged by a run that fails before committing are dropped
+		detector, err = connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detector.Track(connectors.NewRowBatch([]data.Row{{int64(5), "fifth"}}))).To(BeNil())
+
+		detector, err = connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detectDeletes(detector, 4)).To(Equal([]data.Row{{int64(1), nil}}))
+		Expect(detector.Commit()).To(BeNil())
+
+		detector, err = connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detectDeletes(detector)).To(Equal([]data.Row{{int64(4), nil}}))
+	})
+
+	It("deletes the rows whose keys weren't read by a snapshot", func() {
+		sync.DeleteDetectionIntervalSeconds = nil
+		detector, err := connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detector.Due(time.Now())).To(BeFalse())
+
+		Expect(detector.Track(connectors.NewRowBatch([]data.Row{{int64(1), "first"}, {int64(2), "second"}}))).To(BeNil())
+		Expect(detector.DetectSnapshotDeletes(func(connectors.RowBatch) error {
+			Fail("runs without a snapshot shouldn't delete any rows")
+			return nil
+		})).To(BeNil())
+		Expect(detector.Commit()).To(BeNil())
+
+		detector, err = connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detector.Track(connectors.RowBatch{Rows: []data.Row{{int64(2), "second"}, {int64(3), "third"}}, Snapshot: true})).To(BeNil())
+
+		deletedRows := []data.Row{}
+		err = detector.DetectSnapshotDeletes(func(rowBatch connectors.RowBatch) error {
+			for i, row := range rowBatch.Rows {
+				Expect(rowBatch.Operation(i)).To(Equal(data.RowOperationDelete))
+				deletedRows = append(deletedRows, row)
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(deletedRows).To(Equal([]data.Row{{int64(1), nil}}))
+		Expect(detector.Commit()).To(BeNil())
+
+		detector, err = connectors.NewDeleteDetector(sync, fieldMappings, store)
+		Expect(err).To(BeNil())
+		Expect(detector.Track(connectors.RowBatch{Rows: []data.Row{{int64(3), "third"}}, Snapshot: true})).To(BeNil())
+		deletedRows = []data.Row{}
+		err = detector.DetectSnapshotDeletes(func(rowBatch connectors.RowBatch) error {
+			deletedRows = append(deletedRows, rowBatch.Rows...)
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(deletedRows).To(Equal([]data.Row{{int64(2), nil}}))
+	})
+})
+
+// Stores keys in the test database the same way the replicate activity does
+type testPrimaryKeyStore struct {
+	db *gorm.DB
+}
+
+func (s testPrimaryKeyStore) LoadPrimaryKeySnapshot(sync views.Sync) (*connectors.PrimaryKeySnapshot, error) {
+	snapshot, err := sync_primary_key_snapshots.LoadSnapshotBySyncID(s.db, sync.ID)
+	if errors.IsRecordNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	primaryKeySnapshot := connectors.PrimaryKeySnapshot{}
+	if snapshot.DiffedAt.Valid {
+		primaryKeySnapshot.DiffedAt = &snapshot.DiffedAt.Time
+	}
+
+	return &primaryKeySnapshot, nil
+}
+
+func (s testPrimaryKeyStore) ClearStagedPrimaryKeys(sync views.Sync) error {
+	return sync_primary_keys.ClearStagedKeys(s.db, sync.ID)
+}
+
+func (s testPrimaryKeyStore) StagePrimaryKeys(sync views.Sync, keys []string, deleted bool) error {
+	return sync_primary_keys.StageKeys(s.db, sync.ID, keys, deleted)
+}
+
+func (s testPrimaryKeyStore) StageSourcePrimaryKeys(sync views.Sync, keys []string) error {
+	return sync_primary_keys.StageSourceKeys(s.db, sync.ID, keys)
+}
+
+func (s testPrimaryKeyStore) LoadMissingPrimaryKeys(sync views.Sync, afterKey string, limit int) ([]string, error) {
+	return sync_primary_keys.LoadMissingKeys(s.db, sync.ID, afterKey, limit)
+}
+
+func (s testPrimaryKeyStore) CommitPrimaryKeys(sync views.Sync, diffedAt *time.Time) error {
+	return sync_primary_keys.CommitStagedKeys(s.db, sync.OrganizationID, sync.ID, diffedAt)
+}
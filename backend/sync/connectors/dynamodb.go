@@ -0,0 +1,397 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
+	"go.fabra.io/server/common/views"
+)
+
+type DynamoDbImpl struct {
+	queryService query.QueryService
+}
+
+func NewDynamoDbConnector(queryService query.QueryService) Connector {
+	return DynamoDbImpl{
+		queryService: queryService,
+	}
+}
+
+func (dd DynamoDbImpl) Read(
+	ctx context.Context,
+	sourceConnection views.FullConnection,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+	errC chan<- error,
+) {
+	connectionModel := views.ConvertConnectionView(sourceConnection)
+
+	sourceClient, err := dd.queryService.GetClient(ctx, connectionModel)
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	readQuery, err := dd.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	iterator, err := sourceClient.GetQueryIterator(ctx, query.CreateDynamoDbQueryString(*readQuery))
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	cursorFieldPos := -1
+	if sync.SyncMode.UsesCursor() {
+		for i := range fieldMappings {
+			if fieldMappings[i].SourceFieldName == *sync.SourceCursorField {
+				cursorFieldPos = i
+			}
+		}
+	}
+
+	currentIndex := 0
+	var batchBytes int64
+	var rowBatch []data.Row
+	var maxCursorValue any
+	for {
+		row, err := iterator.Next(ctx)
+		if err != nil {
+			if err == data.ErrDone {
+				break
+			} else {
+				errC <- err
+				return
+			}
+		}
+
+		// scans return items in no particular order, so track the largest cursor value instead of the last one
+		if cursorFieldPos >= 0 && isGreaterCursorValue(row[cursorFieldPos], maxCursorValue) {
+			maxCursorValue = row[cursorFieldPos]
+		}
+
+		rowBatch = append(rowBatch, row)
+		currentIndex++
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			rowsC <- NewRowBatch(rowBatch)
+			currentIndex = 0
+			batchBytes = 0
+			rowBatch = []data.Row{}
+		}
+	}
+
+	// write any remaining roows
+	if currentIndex > 0 {
+		rowsC <- NewRowBatch(rowBatch)
+	}
+
+	var newCursorPosition *data.CursorState
+	if maxCursorValue != nil {
+		newCursorPosition, err = dd.getNewCursorPosition(maxCursorValue, sync, fieldMappings)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	readOutputC <- ReadOutput{
+		CursorPosition: newCursorPosition,
+		Done:           true,
+	}
+
+	close(rowsC)
+	close(errC)
+}
+
+func (dd DynamoDbImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (*query.DynamoDbQuery, error) {
+	attributes := []string{}
+	for _, fieldMapping := range fieldMappings {
+		attributes = append(attributes, fieldMapping.SourceFieldName)
+	}
+
+	dynamoDbQuery := query.DynamoDbQuery{
+		TableName:  *sync.TableName,
+		Attributes: attributes,
+	}
+
+	// scans are unordered, so there is no order to break ties in and no window before the largest cursor value to re-read
+	if sync.CursorTieBreaker || sync.CursorLookbackSeconds != nil {
+		return nil, errors.NewCustomerVisibleError("cursor tie-breaker and lookback window are not supported for DynamoDB sources")
+	}
+
+	if sync.PartitionField != nil {
+		return nil, errors.NewCustomerVisibleError("partitioned reads are not supported for DynamoDB sources")
+	}
+
+	if sync.SyncMode.UsesCursor() && sync.CursorPosition != nil {
+		sourceCursorFieldType, err := getSourceCursorFieldType(*sync.SourceCursorField, fieldMappings)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.DynamoDbImpl.getReadQuery) error getting source cursor field type")
+		}
+
+		// DynamoDB compares numbers and strings differently, so the cursor must be passed with the right type
+		var cursorValue any
+		switch *sourceCursorFieldType {
+		case data.FieldTypeInteger, data.FieldTypeNumber:
+			numberCursor := *sync.CursorPosition
+			numberCursor.FieldType = data.FieldTypeNumber
+			cursorValue, err = numberCursor.TypedValue()
+			if err != nil {
+				return nil, errors.Wrap(err, "(connectors.DynamoDbImpl.getReadQuery) error parsing cursor position")
+			}
+		default:
+			cursorValue = sync.CursorPosition.Value
+		}
+
+		filterExpression := "#cursor > :cursor"
+		dynamoDbQuery.FilterExpression = &filterExpression
+		dynamoDbQuery.ExpressionAttributeNames = map[string]string{"#cursor": *sync.SourceCursorField}
+		dynamoDbQuery.ExpressionAttributeValues = map[string]any{":cursor": cursorValue}
+	}
+
+	if sync.Filter != nil {
+		builder := dynamoDbFilterBuilder{names: map[string]string{}, values: map[string]any{}}
+		expression, err := builder.build(*sync.Filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.DynamoDbImpl.getReadQuery) error getting filter")
+		}
+
+		if dynamoDbQuery.FilterExpression != nil {
+			expression = fmt.Sprintf("(%s) AND (%s)", *dynamoDbQuery.FilterExpression, expression)
+			for key, name := range dynamoDbQuery.ExpressionAttributeNames {
+				builder.names[key] = name
+			}
+			for key, value := range dynamoDbQuery.ExpressionAttributeValues {
+				builder.values[key] = value
+			}
+		}
+
+		dynamoDbQuery.FilterExpression = &expression
+		dynamoDbQuery.ExpressionAttributeNames = builder.names
+		dynamoDbQuery.ExpressionAttributeValues = builder.values
+	}
+
+	return &dynamoDbQuery, nil
+}
+
+// Builds a filter expression from the sync's filter, using placeholders for every attribute name and value
+type dynamoDbFilterBuilder struct {
+	names  map[string]string
+	values map[string]any
+}
+
+func (b *dynamoDbFilterBuilder) build(filter data.Filter) (string, error) {
+	if !filter.IsCondition() {
+		operator := " AND "
+		nestedFilters := filter.And
+		if len(filter.Or) > 0 {
+			operator = " OR "
+			nestedFilters = filter.Or
+		}
+
+		expressions := []string{}
+		for _, nestedFilter := range nestedFilters {
+			expression, err := b.build(nestedFilter)
+			if err != nil {
+				return "", err
+			}
+			expressions = append(expressions, expression)
+		}
+
+		return fmt.Sprintf("(%s)", strings.Join(expressions, operator)), nil
+	}
+
+	name := fmt.Sprintf("#filter%d", len(b.names))
+	b.names[name] = filter.Field
+
+	// items without the attribute are treated as null
+	switch filter.Operator {
+	case data.FilterOperatorIsNull:
+		return fmt.Sprintf("attribute_not_exists(%s)", name), nil
+	case data.FilterOperatorIsNotNull:
+		return fmt.Sprintf("attribute_exists(%s)", name), nil
+	}
+
+	value, err := filter.TypedValue()
+	if err != nil {
+		return "", errors.Wrap(err, "(connectors.dynamoDbFilterBuilder.build)")
+	}
+
+	switch filter.Operator {
+	case data.FilterOperatorIn, data.FilterOperatorNotIn:
+		placeholders := []string{}
+		rawValues := filter.Value.([]any)
+		for i, v := range value.([]any) {
+			placeholders = append(placeholders, b.bind(filter.FieldType, v, rawValues[i]))
+		}
+
+		expression := fmt.Sprintf("%s IN (%s)", name, strings.Join(placeholders, ", "))
+		if filter.Operator == data.FilterOperatorNotIn {
+			expression = fmt.Sprintf("NOT (%s)", expression)
+		}
+		return expression, nil
+	}
+
+	var operator sqlbuilder.Operator
+	switch filter.Operator {
+	case data.FilterOperatorEqual:
+		operator = sqlbuilder.OperatorEqual
+	case data.FilterOperatorNotEqual:
+		operator = sqlbuilder.OperatorNotEqual
+	case data.FilterOperatorGreaterThan:
+		operator = sqlbuilder.OperatorGreaterThan
+	case data.FilterOperatorGreaterThanOrEqual:
+		operator = sqlbuilder.OperatorGreaterThanOrEqual
+	case data.FilterOperatorLessThan:
+		operator = sqlbuilder.OperatorLessThan
+	case data.FilterOperatorLessThanOrEqual:
+		operator = sqlbuilder.OperatorLessThanOrEqual
+	default:
+		return "", errors.Newf("(connectors.dynamoDbFilterBuilder.build) unknown filter operator: %s", filter.Operator)
+	}
+
+	return fmt.Sprintf("%s %s %s", name, operator, b.bind(filter.FieldType, value, filter.Value)), nil
+}
+
+// DynamoDB stores dates and times as strings and compares them lexically, so only numbers and booleans are bound with
+// their type and every other value is bound exactly as it was given
+func (b *dynamoDbFilterBuilder) bind(fieldType data.FieldType, typedValue any, rawValue any) string {
+	placeholder := fmt.Sprintf(":filter%d", len(b.values))
+	switch fieldType {
+	case data.FieldTypeInteger, data.FieldTypeNumber, data.FieldTypeBoolean:
+		b.values[placeholder] = typedValue
+	default:
+		b.values[placeholder] = fmt.Sprintf("%v", rawValue)
+	}
+
+	return placeholder
+}
+
+// DynamoDB stores dates and times as strings and compares them lexically, so only numbers are stored with their type
+// and every other cursor is stored exactly as it was read
+func (dd DynamoDbImpl) getNewCursorPosition(maxCursorValue any, sync views.Sync, fieldMappings []views.FieldMapping) (*data.CursorState, error) {
+	sourceCursorFieldType, err := getSourceCursorFieldType(*sync.SourceCursorField, fieldMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.DynamoDbImpl.getNewCursorPosition)")
+	}
+
+	cursorFieldType := data.FieldTypeString
+	switch *sourceCursorFieldType {
+	case data.FieldTypeInteger, data.FieldTypeNumber:
+		cursorFieldType = data.FieldTypeNumber
+	}
+
+	newCursorPosition, err := data.NewCursorState(cursorFieldType, maxCursorValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.DynamoDbImpl.getNewCursorPosition)")
+	}
+
+	return newCursorPosition, nil
+}
+
+func isGreaterCursorValue(value any, current any) bool {
+	if value == nil {
+		return false
+	}
+
+	if current == nil {
+		return true
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch c := current.(type) {
+		case int64:
+			return v > c
+		case float64:
+			return float64(v) > c
+		}
+	case float64:
+		switch c := current.(type) {
+		case int64:
+			return v > float64(c)
+		case float64:
+			return v > c
+		}
+	}
+
+	return fmt.Sprintf("%v", value) > fmt.Sprintf("%v", current)
+}
+
+func (dd DynamoDbImpl) Write(
+	ctx context.Context,
+	destinationConnection views.FullConnection,
+	destinationOptions DestinationOptions,
+	object views.Object,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC <-chan RowBatch,
+	writeOutputC chan<- WriteOutput,
+	errC chan<- error,
+) {
+	connectionModel := views.ConvertConnectionView(destinationConnection)
+
+	destClient, err := dd.queryService.GetDatabaseClient(ctx, connectionModel)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.DynamoDbImpl.Write) getting client")
+		return
+	}
+
+	// items are written with PutItem semantics, which replace any existing item with the same key. Removing the items
+	// that were not part of this sync would require a full table scan, so full overwrites are not supported.
+	if sync.SyncMode == models.SyncModeFullOverwrite {
+		errC <- errors.NewCustomerVisibleError("full overwrite is not supported for DynamoDB destinations")
+		return
+	}
+
+	tableName := getDestinationTableName(object, sync.EndCustomerID)
+	columns := getDestinationColumns(object)
+
+	rowsWritten := 0
+	batchesCommitted := 0
+	for {
+		rowBatch, more := <-rowsC
+		if !more {
+			break
+		}
+
+		rows, err := rowBatch.UpsertsOnly()
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.DynamoDbImpl.Write)")
+			return
+		}
+
+		err = destClient.LoadData(ctx, "", tableName, columns, convertToDestinationRows(rows, object, fieldMappings, sync.EndCustomerID))
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.DynamoDbImpl.Write) writing rows")
+			return
+		}
+
+		rowsWritten += len(rows)
+		batchesCommitted++
+		writeOutputC <- WriteOutput{
+			RowsWritten:      rowsWritten,
+			BatchesCommitted: batchesCommitted,
+		}
+	}
+
+	writeOutputC <- WriteOutput{
+		RowsWritten:      rowsWritten,
+		BatchesCommitted: batchesCommitted,
+		Done:             true,
+	}
+
+	close(errC)
+}
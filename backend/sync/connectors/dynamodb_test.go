@@ -0,0 +1,234 @@
+package connectors_test
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	"go.fabra.io/server/common/application"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/input"
+	mock_query "go.fabra.io/server/common/mocks"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DynamoDbConnector", func() {
+	var (
+		sourceConnection      views.FullConnection
+		destinationConnection views.FullConnection
+		sync                  views.Sync
+		fieldMappings         []views.FieldMapping
+		object                views.Object
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, sourceConn := test.CreateSource(db, org.ID, endCustomerID)
+		sourceConnection = views.ConvertFullConnection(sourceConn)
+		destination, destConn := test.CreateDestination(db, org.ID)
+		destinationConnection = views.ConvertFullConnection(destConn)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeIncrementalAppend)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "json", Type: data.FieldTypeJson, Optional: true},
+		})
+		object = views.ConvertObject(objectModel, objectFields)
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeIncrementalAppend))
+		cursorField := "source_id"
+		sync.SourceCursorField = &cursorField
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_json", SourceFieldType: data.FieldTypeJson, DestinationFieldId: objectFields[1].ID},
+		}), objectFields)
+	})
+
+	Describe("Read", func() {
+		It("scans past the cursor and tracks the largest cursor value", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockConnectorClient(ctrl)
+			defer ctrl.Finish()
+
+			cursorPosition := data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeNumber, Value: "10"}
+			sync.CursorPosition = &cursorPosition
+
+			// scans are unordered so the largest cursor value is not the last row
+			rows := []data.Row{
+				{int64(12), map[string]any{"hello": "world"}},
+				{int64(15), nil},
+				{int64(11), nil},
+			}
+			iterator := test.NewMockIterator(rows, data.Schema{
+				{Name: "source_id", Type: data.FieldTypeInteger},
+				{Name: "source_json", Type: data.FieldTypeJson},
+			})
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().GetQueryIterator(
+				gomock.Any(),
+				`{"table_name":"table","attributes":["source_id","source_json"],"filter_expression":"#cursor > :cursor","expression_attribute_names":{"#cursor":"source_id"},"expression_attribute_values":{":cursor":10}}`,
+			).Return(iterator, nil)
+
+			connector := connectors.NewDynamoDbConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(*readOutput.CursorPosition).To(Equal(data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeNumber, Value: "15"}))
+			Expect(resultRows).To(Equal(rows))
+			Expect(numBatches).To(Equal(1))
+		})
+	})
+
+	Describe("Write", func() {
+		It("writes items with the end customer ID", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			rows := []data.Row{
+				{int64(1), map[string]any{"hello": "world"}},
+				{int64(2), nil},
+			}
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().LoadData(
+				gomock.Any(),
+				"",
+				"table",
+				[]string{"id", "json", "end_customer_id"},
+				[]data.Row{
+					{int64(1), map[string]any{"hello": "world"}, "abc123"},
+					{int64(2), nil, "abc123"},
+				},
+			).Return(nil)
+
+			connector := connectors.NewDynamoDbConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch(rows)
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+		})
+	})
+
+	// runs against a DynamoDB Local server at DYNAMODB_ENDPOINT, and is skipped when it isn't set
+	Describe("DynamoDB Local", func() {
+		var client query.DynamoDbClient
+
+		BeforeEach(func() {
+			endpoint := application.GetDynamoDbEndpoint()
+			if endpoint == nil {
+				Skip("DYNAMODB_ENDPOINT is not set")
+			}
+
+			// DynamoDB Local accepts any credentials
+			client = query.DynamoDbClient{KeyID: "local", AccessKey: "local", Location: "us-east-1", Endpoint: endpoint}
+			dynamoDb := dynamodb.New(dynamodb.Options{
+				Region:           client.Location,
+				Credentials:      aws.AnonymousCredentials{},
+				EndpointResolver: dynamodb.EndpointResolverFromURL(*endpoint),
+			})
+
+			// tables left by previous runs are dropped, so the table starts out empty
+			_, _ = dynamoDb.DeleteTable(context.TODO(), &dynamodb.DeleteTableInput{TableName: aws.String("table")})
+			_, err := dynamoDb.CreateTable(context.TODO(), &dynamodb.CreateTableInput{
+				TableName:            aws.String("table"),
+				AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeN}},
+				KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash}},
+				BillingMode:          types.BillingModePayPerRequest,
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("writes items that are read back past the cursor", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			defer ctrl.Finish()
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			connector := connectors.NewDynamoDbConnector(queryService)
+
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			writeErrC := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, writeErrC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{
+				{int64(1), map[string]any{"hello": "world"}},
+				{int64(2), map[string]any{"nested": []any{"a", int64(1)}}},
+				{int64(3), nil},
+			})
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, writeErrC)
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(3))
+
+			// the written items are read back from the destination table
+			cursorField := "id"
+			cursorPosition := data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeNumber, Value: "1"}
+			sync.SourceCursorField = &cursorField
+			sync.CursorPosition = &cursorPosition
+			readFieldMappings := []views.FieldMapping{
+				{SourceFieldName: "id", SourceFieldType: data.FieldTypeInteger},
+				{SourceFieldName: "json", SourceFieldType: data.FieldTypeJson},
+				{SourceFieldName: "end_customer_id", SourceFieldType: data.FieldTypeString},
+			}
+
+			readRowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			readErrC := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, readFieldMappings, readRowsC, readOutputC, readErrC)
+			}()
+			readOutput, resultRows, _, err := waitForRead(readRowsC, readOutputC, readErrC)
+
+			Expect(err).To(BeNil())
+			Expect(*readOutput.CursorPosition).To(Equal(data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeNumber, Value: "3"}))
+			Expect(resultRows).To(ConsistOf(
+				data.Row{int64(2), map[string]any{"nested": []any{"a", int64(1)}}, "abc123"},
+				data.Row{int64(3), nil, "abc123"},
+			))
+		})
+	})
+})
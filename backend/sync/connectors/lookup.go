@@ -0,0 +1,167 @@
+package connectors
+
+import (
+	"context"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
+	"go.fabra.io/server/common/views"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Options for reading a page of rows directly from a sync's source, outside of a sync run
+type LookupOptions struct {
+	Filter  *data.Filter // applied along with the sync's own filter
+	OrderBy *string      // source field to order rows by and page on
+	After   any          // only rows where the OrderBy field is greater than this value are read
+	Limit   int
+}
+
+// Reads at most Limit rows from the sync's source, with values in the same order as the field mappings
+func Lookup(
+	ctx context.Context,
+	client query.ConnectorClient,
+	sourceConnection *models.Connection,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	lookupOptions LookupOptions,
+) ([]data.Row, error) {
+	// the sync's filter limits which rows the end customer shared, so lookups can't read past it
+	var filter *data.Filter
+	if sync.Filter != nil && lookupOptions.Filter != nil {
+		filter = &data.Filter{And: []data.Filter{*sync.Filter, *lookupOptions.Filter}}
+	} else if sync.Filter != nil {
+		filter = sync.Filter
+	} else {
+		filter = lookupOptions.Filter
+	}
+
+	var orderByFieldType *data.FieldType
+	if lookupOptions.OrderBy != nil {
+		var err error
+		orderByFieldType, err = getSourceCursorFieldType(*lookupOptions.OrderBy, fieldMappings)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.Lookup) getting order by field type")
+		}
+	}
+
+	if sourceConnection.ConnectionType == models.ConnectionTypeMongoDb {
+		rows, err := lookupMongoDb(ctx, client, sync, fieldMappings, filter, lookupOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.Lookup)")
+		}
+
+		return rows, nil
+	}
+
+	dialect, err := sqlbuilder.GetDialect(sourceConnection.ConnectionType)
+	if err != nil {
+		return nil, errors.Wrap(errors.NewBadRequestf("lookups are not supported for %s sources", sourceConnection.ConnectionType), "(connectors.Lookup)")
+	}
+
+	var toQueryValue func(fieldType data.FieldType, value any) any
+	if dialect == sqlbuilder.DialectBigQuery {
+		toQueryValue = toBigQueryQueryValue
+	}
+
+	var filterCondition sqlbuilder.Condition
+	if filter != nil {
+		filterCondition, err = getFilterCondition(*filter, toQueryValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.Lookup) getting filter condition")
+		}
+	}
+
+	columns := []string{}
+	for _, fieldMapping := range fieldMappings {
+		columns = append(columns, fieldMapping.SourceFieldName)
+	}
+
+	// custom joins select the mapped columns too, so rows always match the field mappings
+	builder := sqlbuilder.Select(dialect, columns...)
+	if sync.CustomJoin != nil {
+		builder.FromQuery(*sync.CustomJoin)
+	} else {
+		builder.From(*sync.Namespace, *sync.TableName)
+	}
+	if filterCondition != nil {
+		builder.WhereCondition(filterCondition)
+	}
+	if lookupOptions.OrderBy != nil {
+		if lookupOptions.After != nil {
+			after := lookupOptions.After
+			if toQueryValue != nil {
+				after = toQueryValue(*orderByFieldType, after)
+			}
+			builder.Where(*lookupOptions.OrderBy, sqlbuilder.OperatorGreaterThan, after)
+		}
+		builder.OrderByAsc(*lookupOptions.OrderBy)
+	}
+	builder.Limit(lookupOptions.Limit)
+
+	lookupQuery, lookupArgs := builder.Build()
+	results, err := client.RunQuery(ctx, lookupQuery, lookupArgs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.Lookup) running query")
+	}
+
+	return results.Data, nil
+}
+
+func lookupMongoDb(
+	ctx context.Context,
+	client query.ConnectorClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	filter *data.Filter,
+	lookupOptions LookupOptions,
+) ([]data.Row, error) {
+	mongoQuery := query.MongoQuery{
+		Database:   *sync.Namespace,
+		Collection: *sync.TableName,
+		Filter:     bson.D{},
+		Options:    options.Find(),
+	}
+	mongoQuery.Options.SetProjection(createProjection(fieldMappings))
+	mongoQuery.Options.SetLimit(int64(lookupOptions.Limit))
+
+	conditions := bson.A{}
+	if filter != nil {
+		filterDocument, err := getMongoFilter(*filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.lookupMongoDb) getting filter")
+		}
+		conditions = append(conditions, filterDocument)
+	}
+
+	if lookupOptions.OrderBy != nil {
+		if lookupOptions.After != nil {
+			conditions = append(conditions, bson.D{bson.E{
+				Key:   *lookupOptions.OrderBy,
+				Value: bson.D{bson.E{Key: "$gt", Value: getMongoComparisonValue(lookupOptions.After)}},
+			}})
+		}
+		mongoQuery.Options.SetSort(bson.D{bson.E{Key: *lookupOptions.OrderBy, Value: 1}})
+	}
+
+	if len(conditions) > 0 {
+		mongoQuery.Filter = bson.D{bson.E{Key: "$and", Value: conditions}}
+	}
+
+	results, err := client.RunQuery(ctx, query.CreateMongoQueryString(mongoQuery))
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.lookupMongoDb) running query")
+	}
+
+	// documents don't have a fixed field order, so reorder them to match the field mappings
+	rows := []data.Row{}
+	for _, row := range results.Data {
+		rows = append(rows, reorderMongoRow(row, results.Schema, fieldMappings))
+	}
+
+	return rows, nil
+}
@@ -2,13 +2,13 @@ package connectors
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -30,7 +30,7 @@ func (md MongoDbImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -42,6 +42,11 @@ func (md MongoDbImpl) Read(
 		return
 	}
 
+	if sync.SyncMode == models.SyncModeChangeDataCapture {
+		md.readChanges(ctx, sourceClient, sync, fieldMappings, rowsC, readOutputC, errC)
+		return
+	}
+
 	readQuery, err := md.getReadQuery(connectionModel, sync, fieldMappings)
 	if err != nil {
 		errC <- err
@@ -57,9 +62,13 @@ func (md MongoDbImpl) Read(
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
 	schema := iterator.Schema()
+
+	// rows are reordered to match the field mappings, so track the cursor using the field mapping order
+	cursorTracker := newCursorTracker(sync, getFieldMappingsSchema(fieldMappings))
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -72,23 +81,43 @@ func (md MongoDbImpl) Read(
 		}
 
 		reordered := reorderMongoRow(row, schema, fieldMappings)
+		alreadySynced, err := cursorTracker.Track(reordered)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, reordered)
-		lastRow = reordered
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += reordered.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
 	}
 
-	// pass field mappings not schema since the row will be reordered
-	newCursorPosition, err := md.getNewCursorPosition(lastRow, fieldMappings, sync)
+	newCursorPosition, err := cursorTracker.CursorPosition()
 	if err != nil {
 		errC <- err
 		return
@@ -96,6 +125,8 @@ func (md MongoDbImpl) Read(
 
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
@@ -104,6 +135,10 @@ func (md MongoDbImpl) Read(
 
 // TODO: only read 10,000 rows at once or something
 func (md MongoDbImpl) getReadQuery(sourceConnection *models.Connection, sync views.Sync, fieldMappings []views.FieldMapping) (*query.MongoQuery, error) {
+	if sync.PartitionField != nil {
+		return nil, errors.NewCustomerVisibleError("partitioned reads are not supported for MongoDB sources")
+	}
+
 	projection := createProjection(fieldMappings)
 	mongoQuery := query.MongoQuery{
 		Database:   *sync.Namespace,
@@ -116,49 +151,141 @@ func (md MongoDbImpl) getReadQuery(sourceConnection *models.Connection, sync vie
 
 	if sync.SyncMode.UsesCursor() {
 		// order by cursor field to simplify
-		mongoQuery.Options.SetSort(bson.D{
+		sort := bson.D{
 			bson.E{
 				Key:   *sync.SourceCursorField,
 				Value: 1, // 1 indicates ascending order
 			},
-		})
+		}
+		if sync.CursorTieBreaker {
+			sort = append(sort, bson.E{Key: *sync.SourcePrimaryKey, Value: 1})
+		}
+		mongoQuery.Options.SetSort(sort)
 
-		if sync.CursorPosition != nil {
-			sourceCursorFieldType, err := getSourceCursorFieldType(*sync.SourceCursorField, fieldMappings)
-			if err != nil {
-				return nil, errors.Wrap(err, "(connectors.MongoDbImpl.getReadQuery) error getting source cursor field type")
-			}
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.MongoDbImpl.getReadQuery) error parsing cursor position")
+		}
 
-			var comparisonValue any
-			switch *sourceCursorFieldType {
-			case data.FieldTypeDateTimeTz:
-				timeCursor, err := time.Parse(query.FABRA_TIMESTAMP_TZ_FORMAT, *sync.CursorPosition)
-				if err != nil {
-					return nil, errors.Wrap(err, "(connectors.MongoDbImpl.getReadQuery) error parsing cursor position")
-				}
-				comparisonValue = primitive.NewDateTimeFromTime(timeCursor)
-			default:
-				comparisonValue = *sync.CursorPosition
+		if condition != nil {
+			comparisonValue := getMongoComparisonValue(condition.CursorValue)
+			operator := "$gt"
+			if condition.Operator == sqlbuilder.OperatorGreaterThanOrEqual {
+				operator = "$gte"
 			}
 
 			// TODO: allow choosing other operators (rows smaller than current cursor, etc.)
 			mongoQuery.Filter = bson.D{
 				bson.E{
-					Key: *sync.SourceCursorField,
-					Value: bson.D{
-						bson.E{
-							Key:   "$gt",
-							Value: comparisonValue,
+					Key:   *sync.SourceCursorField,
+					Value: bson.D{bson.E{Key: operator, Value: comparisonValue}},
+				},
+			}
+
+			if condition.TieBreakerValue != nil {
+				mongoQuery.Filter = bson.D{
+					bson.E{
+						Key: "$or",
+						Value: bson.A{
+							mongoQuery.Filter,
+							bson.D{
+								bson.E{Key: *sync.SourceCursorField, Value: comparisonValue},
+								bson.E{
+									Key:   *sync.SourcePrimaryKey,
+									Value: bson.D{bson.E{Key: "$gt", Value: getMongoComparisonValue(condition.TieBreakerValue)}},
+								},
+							},
 						},
 					},
-				},
+				}
 			}
 		}
 	}
 
+	if sync.Filter != nil {
+		filter, err := getMongoFilter(*sync.Filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.MongoDbImpl.getReadQuery) error getting filter")
+		}
+
+		if len(mongoQuery.Filter) == 0 {
+			mongoQuery.Filter = filter
+		} else {
+			mongoQuery.Filter = bson.D{bson.E{Key: "$and", Value: bson.A{filter, mongoQuery.Filter}}}
+		}
+	}
+
 	return &mongoQuery, nil
 }
 
+// Converts the sync's filter into a Mongo query filter document
+func getMongoFilter(filter data.Filter) (bson.D, error) {
+	if !filter.IsCondition() {
+		operator := "$and"
+		nestedFilters := filter.And
+		if len(filter.Or) > 0 {
+			operator = "$or"
+			nestedFilters = filter.Or
+		}
+
+		nested := bson.A{}
+		for _, nestedFilter := range nestedFilters {
+			nestedDocument, err := getMongoFilter(nestedFilter)
+			if err != nil {
+				return nil, err
+			}
+			nested = append(nested, nestedDocument)
+		}
+
+		return bson.D{bson.E{Key: operator, Value: nested}}, nil
+	}
+
+	// a null comparison also matches documents without the field
+	switch filter.Operator {
+	case data.FilterOperatorIsNull:
+		return bson.D{bson.E{Key: filter.Field, Value: nil}}, nil
+	case data.FilterOperatorIsNotNull:
+		return bson.D{bson.E{Key: filter.Field, Value: bson.D{bson.E{Key: "$ne", Value: nil}}}}, nil
+	}
+
+	value, err := filter.TypedValue()
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getMongoFilter)")
+	}
+
+	var operator string
+	switch filter.Operator {
+	case data.FilterOperatorEqual:
+		operator = "$eq"
+	case data.FilterOperatorNotEqual:
+		operator = "$ne"
+	case data.FilterOperatorGreaterThan:
+		operator = "$gt"
+	case data.FilterOperatorGreaterThanOrEqual:
+		operator = "$gte"
+	case data.FilterOperatorLessThan:
+		operator = "$lt"
+	case data.FilterOperatorLessThanOrEqual:
+		operator = "$lte"
+	case data.FilterOperatorIn, data.FilterOperatorNotIn:
+		operator = "$in"
+		if filter.Operator == data.FilterOperatorNotIn {
+			operator = "$nin"
+		}
+
+		values := bson.A{}
+		for _, v := range value.([]any) {
+			values = append(values, getMongoComparisonValue(v))
+		}
+
+		return bson.D{bson.E{Key: filter.Field, Value: bson.D{bson.E{Key: operator, Value: values}}}}, nil
+	default:
+		return nil, errors.Newf("(connectors.getMongoFilter) unknown filter operator: %s", filter.Operator)
+	}
+
+	return bson.D{bson.E{Key: filter.Field, Value: bson.D{bson.E{Key: operator, Value: getMongoComparisonValue(value)}}}}, nil
+}
+
 // Used to ensure every field is in the correct order, and to omit the _id field
 func createProjection(fieldMappings []views.FieldMapping) bson.D {
 	projection := bson.D{
@@ -178,35 +305,23 @@ func createProjection(fieldMappings []views.FieldMapping) bson.D {
 	return projection
 }
 
-func (md MongoDbImpl) getNewCursorPosition(lastRow data.Row, fieldMappings []views.FieldMapping, sync views.Sync) (*string, error) {
-	if sync.SourceCursorField == nil {
-		return nil, nil
-	}
-
-	if lastRow == nil {
-		return nil, nil
-	}
-
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range fieldMappings {
-		if fieldMappings[i].SourceFieldName == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = fieldMappings[i].SourceFieldType
-		}
+// Cursor values for dates and times are compared to Mongo dates
+func getMongoComparisonValue(cursorValue any) any {
+	switch v := cursorValue.(type) {
+	case time.Time:
+		return primitive.NewDateTimeFromTime(v)
+	default:
+		return v
 	}
+}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger, data.FieldTypeNumber, data.FieldTypeTimestamp, data.FieldTypeDateTimeTz:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+func getFieldMappingsSchema(fieldMappings []views.FieldMapping) data.Schema {
+	schema := data.Schema{}
+	for _, fieldMapping := range fieldMappings {
+		schema = append(schema, data.Field{Name: fieldMapping.SourceFieldName, Type: fieldMapping.SourceFieldType})
 	}
 
-	return &newCursorPos, nil
+	return schema
 }
 
 func reorderMongoRow(unorderedRow data.Row, schema data.Schema, fieldMappings []views.FieldMapping) data.Row {
@@ -230,7 +345,7 @@ func (md MongoDbImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
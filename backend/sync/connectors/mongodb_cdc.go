@@ -0,0 +1,219 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/views"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Change data capture syncs read the collection's change stream. The cursor is the resume token of the last change
+// read, so the oplog must keep changes for longer than the time between runs. Otherwise the whole collection is read
+// again.
+
+func (md MongoDbImpl) readChanges(
+	ctx context.Context,
+	sourceClient query.ConnectorClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+	errC chan<- error,
+) {
+	changeStreamClient, ok := sourceClient.(query.ChangeStreamClient)
+	if !ok {
+		errC <- errors.Newf("(connectors.MongoDbImpl.readChanges) client %T can't read change streams", sourceClient)
+		return
+	}
+
+	var cursorPosition *data.CursorState
+	var batchesRead int
+	var err error
+	if sync.CursorPosition != nil {
+		cursorPosition, batchesRead, err = md.readChangeStream(ctx, changeStreamClient, sync, fieldMappings, rowsC, readOutputC)
+	}
+	if sync.CursorPosition == nil || errors.Is(err, query.ErrChangeStreamHistoryLost) {
+		cursorPosition, batchesRead, err = md.readSnapshot(ctx, sourceClient, changeStreamClient, sync, fieldMappings, rowsC, readOutputC)
+	}
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	readOutputC <- ReadOutput{
+		CursorPosition: cursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
+	}
+
+	close(rowsC)
+	close(errC)
+}
+
+// Reads the whole collection. The change stream is opened first, so changes made while the collection is read are
+// read again by the next run, which only rewrites the latest version of those documents. The rows are sent as a
+// snapshot, so the documents deleted while the change stream couldn't be read are deleted from the destination too.
+func (md MongoDbImpl) readSnapshot(
+	ctx context.Context,
+	sourceClient query.ConnectorClient,
+	changeStreamClient query.ChangeStreamClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+) (*data.CursorState, int, error) {
+	stream, err := changeStreamClient.OpenChangeStream(ctx, query.ChangeStreamOptions{
+		Database:   *sync.Namespace,
+		Collection: *sync.TableName,
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readSnapshot) opening change stream")
+	}
+	startToken := stream.ResumeToken()
+	stream.Close(ctx)
+
+	if startToken == "" {
+		return nil, 0, errors.NewCustomerVisibleError("the source doesn't return change stream resume tokens, which requires MongoDB 4.0.7 or later")
+	}
+
+	findOptions := options.Find()
+	findOptions.SetProjection(createProjection(fieldMappings))
+	queryString := query.CreateMongoQueryString(query.MongoQuery{
+		Database:   *sync.Namespace,
+		Collection: *sync.TableName,
+		Filter:     bson.D{},
+		Options:    findOptions,
+	})
+
+	iterator, err := sourceClient.GetQueryIterator(ctx, queryString)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readSnapshot) running query")
+	}
+
+	batchesRead, err := readSnapshotRows(ctx, newMongoRowIterator(iterator, fieldMappings), rowsC, readOutputC)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readSnapshot)")
+	}
+
+	cursorPosition, err := data.NewCursorState(data.FieldTypeString, startToken)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readSnapshot)")
+	}
+
+	return cursorPosition, batchesRead, nil
+}
+
+// Reads the changes made after the cursor until the stream has no more. The changes are merged into the latest version
+// of each changed document, and sent as a batch whenever enough documents have changed.
+func (md MongoDbImpl) readChangeStream(
+	ctx context.Context,
+	changeStreamClient query.ChangeStreamClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+) (*data.CursorState, int, error) {
+	resumeToken := fmt.Sprintf("%v", sync.CursorPosition.Value)
+	stream, err := changeStreamClient.OpenChangeStream(ctx, query.ChangeStreamOptions{
+		Database:    *sync.Namespace,
+		Collection:  *sync.TableName,
+		ResumeToken: &resumeToken,
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readChangeStream) opening change stream")
+	}
+	defer stream.Close(ctx)
+
+	schema := getFieldMappingsSchema(fieldMappings)
+	primaryKeyPos := -1
+	for i, fieldMapping := range fieldMappings {
+		if sync.SourcePrimaryKey != nil && fieldMapping.SourceFieldName == *sync.SourcePrimaryKey {
+			primaryKeyPos = i
+		}
+	}
+
+	changes := newChangeSet()
+	batchesRead := 0
+	for {
+		event, err := stream.Next(ctx)
+		if err == data.ErrDone {
+			// the stream's token moves past changes to other collections, so runs without changes still move forward
+			if token := stream.ResumeToken(); token != "" {
+				resumeToken = token
+			}
+			break
+		}
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readChangeStream) reading change")
+		}
+
+		// documents are merged by their ID, since deletes don't have the rest of the document
+		key := fmt.Sprintf("%v", event.DocumentKey.Map()["_id"])
+		switch event.Type {
+		case query.ChangeEventTypeInsert, query.ChangeEventTypeUpdate, query.ChangeEventTypeReplace:
+			// updated documents that have since been deleted are followed by their delete
+			if event.FullDocument != nil {
+				changes.set(key, rowChange{row: query.ConvertMongoDbRow(event.FullDocument, schema)})
+			}
+		case query.ChangeEventTypeDelete:
+			// deletes can only be written if the primary key is part of the document key, like _id
+			var row data.Row
+			if primaryKeyPos >= 0 {
+				if primaryKey := query.ConvertMongoDbRow(event.DocumentKey, schema)[primaryKeyPos]; primaryKey != nil {
+					row = newDeletedRow(len(fieldMappings), primaryKeyPos, primaryKey)
+				}
+			}
+			changes.set(key, rowChange{deleted: true, row: row})
+		case query.ChangeEventTypeInvalidate:
+			// the collection was dropped or renamed, so it has to be read again from the start
+			return nil, 0, errors.Wrap(query.ErrChangeStreamHistoryLost, "(connectors.MongoDbImpl.readChangeStream) change stream invalidated")
+		default:
+			// changes to the collection itself don't change any documents
+		}
+
+		resumeToken = event.ResumeToken
+		if changes.full() {
+			cursorPosition, err := data.NewCursorState(data.FieldTypeString, resumeToken)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readChangeStream)")
+			}
+			batchesRead = sendChanges(changes, cursorPosition, batchesRead, rowsC, readOutputC)
+		}
+	}
+
+	cursorPosition, err := data.NewCursorState(data.FieldTypeString, resumeToken)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MongoDbImpl.readChangeStream)")
+	}
+
+	batchesRead = sendChanges(changes, cursorPosition, batchesRead, rowsC, readOutputC)
+	return cursorPosition, batchesRead, nil
+}
+
+// Reorders the rows read from Mongo to match the field mappings
+type mongoRowIterator struct {
+	iterator      data.RowIterator
+	fieldMappings []views.FieldMapping
+}
+
+func newMongoRowIterator(iterator data.RowIterator, fieldMappings []views.FieldMapping) data.RowIterator {
+	return &mongoRowIterator{iterator: iterator, fieldMappings: fieldMappings}
+}
+
+func (it *mongoRowIterator) Next(ctx context.Context) (data.Row, error) {
+	row, err := it.iterator.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return reorderMongoRow(row, it.iterator.Schema(), it.fieldMappings), nil
+}
+
+func (it *mongoRowIterator) Schema() data.Schema {
+	return getFieldMappingsSchema(it.fieldMappings)
+}
@@ -0,0 +1,210 @@
+package connectors_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/mock/gomock"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/input"
+	mock_query "go.fabra.io/server/common/mocks"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+	"go.mongodb.org/mongo-driver/bson"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MongoDbConnector", func() {
+	var (
+		sourceConnection views.FullConnection
+		sync             views.Sync
+		fieldMappings    []views.FieldMapping
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, sourceConn := test.CreateSource(db, org.ID, endCustomerID)
+		sourceConnection = views.ConvertFullConnection(sourceConn)
+		destination, _ := test.CreateDestination(db, org.ID)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeChangeDataCapture)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+		})
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeChangeDataCapture))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_name", SourceFieldType: data.FieldTypeString, DestinationFieldId: objectFields[1].ID},
+		}), objectFields)
+
+		primaryKey := "source_id"
+		sync.SourcePrimaryKey = &primaryKey
+	})
+
+	Describe("Read", func() {
+		It("reads the latest version of each document changed since the resume token", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockChangeStreamClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl)}
+			defer ctrl.Finish()
+
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "token0"}
+			client.streams = map[string]*mockChangeStream{
+				"token0": {
+					events: []query.ChangeEvent{
+						{Type: query.ChangeEventTypeInsert, DocumentKey: bson.D{{Key: "_id", Value: "a"}}, FullDocument: bson.D{{Key: "source_name", Value: "first"}, {Key: "source_id", Value: int64(1)}}, ResumeToken: "token1"},
+						{Type: query.ChangeEventTypeInsert, DocumentKey: bson.D{{Key: "_id", Value: "b"}}, FullDocument: bson.D{{Key: "source_id", Value: int64(2)}, {Key: "source_name", Value: "second"}}, ResumeToken: "token2"},
+						{Type: query.ChangeEventTypeUpdate, DocumentKey: bson.D{{Key: "_id", Value: "a"}}, FullDocument: bson.D{{Key: "source_id", Value: int64(1)}, {Key: "source_name", Value: "updated"}}, ResumeToken: "token3"},
+						{Type: query.ChangeEventTypeDelete, DocumentKey: bson.D{{Key: "_id", Value: "b"}}, ResumeToken: "token4"},
+					},
+					resumeToken: "token5",
+				},
+			}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+
+			connector := connectors.NewMongoDbConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("token5"))
+			Expect(resultRows).To(Equal([]data.Row{{int64(1), "updated"}}))
+			Expect(numBatches).To(Equal(1))
+		})
+
+		It("sends a batch whenever enough documents have changed", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockChangeStreamClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl)}
+			defer ctrl.Finish()
+
+			var events []query.ChangeEvent
+			for i := 1; i <= connectors.READ_BATCH_SIZE; i++ {
+				events = append(events, query.ChangeEvent{Type: query.ChangeEventTypeInsert, DocumentKey: bson.D{{Key: "_id", Value: i}}, FullDocument: bson.D{{Key: "source_id", Value: int64(i)}, {Key: "source_name", Value: "first"}}, ResumeToken: fmt.Sprintf("token%d", i)})
+			}
+			events = append(events, query.ChangeEvent{Type: query.ChangeEventTypeUpdate, DocumentKey: bson.D{{Key: "_id", Value: 1}}, FullDocument: bson.D{{Key: "source_id", Value: int64(1)}, {Key: "source_name", Value: "updated"}}, ResumeToken: "token_last"})
+
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "token0"}
+			client.streams = map[string]*mockChangeStream{
+				"token0": {events: events, resumeToken: "token_end"},
+			}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+
+			connector := connectors.NewMongoDbConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
+
+			// the second batch is written after the first, so the update to the first document replaces its insert
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("token_end"))
+			Expect(numBatches).To(Equal(2))
+			Expect(resultRows).To(HaveLen(connectors.READ_BATCH_SIZE + 1))
+			Expect(resultRows[connectors.READ_BATCH_SIZE]).To(Equal(data.Row{int64(1), "updated"}))
+		})
+
+		It("reads the whole collection again when the resume token has expired", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockChangeStreamClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl)}
+			defer ctrl.Finish()
+
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "expired"}
+			client.streams = map[string]*mockChangeStream{
+				"": {resumeToken: "start"},
+			}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().GetQueryIterator(gomock.Any(), gomock.Any()).Return(test.NewMockIterator(
+				[]data.Row{{"first", int64(1)}, {"second", int64(2)}},
+				data.Schema{{Name: "source_name", Type: data.FieldTypeString}, {Name: "source_id", Type: data.FieldTypeInteger}},
+			), nil)
+
+			connector := connectors.NewMongoDbConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForRead(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("start"))
+			Expect(resultRows).To(Equal([]data.Row{{int64(1), "first"}, {int64(2), "second"}}))
+			Expect(numBatches).To(Equal(1))
+		})
+	})
+})
+
+// A connector client that also replays change streams by resume token, which the generated client mocks can't read.
+// Tokens without a stream have expired.
+type mockChangeStreamClient struct {
+	*mock_query.MockConnectorClient
+	streams map[string]*mockChangeStream
+}
+
+func (c mockChangeStreamClient) OpenChangeStream(ctx context.Context, options query.ChangeStreamOptions) (query.ChangeStream, error) {
+	resumeToken := ""
+	if options.ResumeToken != nil {
+		resumeToken = *options.ResumeToken
+	}
+
+	stream, ok := c.streams[resumeToken]
+	if !ok {
+		return nil, errors.Wrap(query.ErrChangeStreamHistoryLost, "resume token not found")
+	}
+
+	return stream, nil
+}
+
+type mockChangeStream struct {
+	events      []query.ChangeEvent
+	resumeToken string
+}
+
+func (s *mockChangeStream) Next(ctx context.Context) (*query.ChangeEvent, error) {
+	if len(s.events) == 0 {
+		return nil, data.ErrDone
+	}
+
+	event := s.events[0]
+	s.events = s.events[1:]
+	return &event, nil
+}
+
+func (s *mockChangeStream) ResumeToken() string {
+	return s.resumeToken
+}
+
+func (s *mockChangeStream) Close(ctx context.Context) error {
+	return nil
+}
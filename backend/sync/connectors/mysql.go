@@ -2,13 +2,16 @@ package connectors
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/google/uuid"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
@@ -27,7 +30,7 @@ func (ms MySqlImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -39,17 +42,33 @@ func (ms MySqlImpl) Read(
 		return
 	}
 
-	readQuery := ms.getReadQuery(connectionModel, sync, fieldMappings)
+	if sync.SyncMode == models.SyncModeChangeDataCapture {
+		ms.readChanges(ctx, sourceClient, sync, fieldMappings, rowsC, readOutputC, errC)
+		return
+	}
+
+	if sync.PartitionField != nil {
+		readPartitioned(ctx, sourceClient, sqlbuilder.DialectMySql, sourceConnection, sync, fieldMappings, ms.getSelectColumns(fieldMappings), nil, rowsC, readOutputC, errC)
+		return
+	}
+
+	readQuery, readArgs, err := ms.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- err
+		return
+	}
 
-	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery)
+	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery, readArgs...)
 	if err != nil {
 		errC <- err
 		return
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
+	cursorTracker := newCursorTracker(sync, iterator.Schema())
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -61,102 +80,539 @@ func (ms MySqlImpl) Read(
 			}
 		}
 
+		alreadySynced, err := cursorTracker.Track(row)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, row)
-		lastRow = row
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	newCursorPosition, err := cursorTracker.CursorPosition()
+	if err != nil {
+		errC <- err
+		return
 	}
 
-	newCursorPosition := ms.getNewCursorPosition(lastRow, iterator.Schema(), sync)
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
 	close(errC)
 }
 
-func (ms MySqlImpl) getReadQuery(sourceConnection *models.Connection, sync views.Sync, fieldMappings []views.FieldMapping) string {
-	var queryString string
+func (ms MySqlImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (string, []any, error) {
+	var builder *sqlbuilder.SelectBuilder
 	if sync.CustomJoin != nil {
-		queryString = *sync.CustomJoin
+		builder = sqlbuilder.Select(sqlbuilder.DialectMySql).FromQuery(*sync.CustomJoin)
 	} else {
-		selectString := ms.getSelectString(fieldMappings)
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, *sync.Namespace, *sync.TableName)
+		builder = sqlbuilder.Select(sqlbuilder.DialectMySql, ms.getSelectColumns(fieldMappings)...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	err := addFilterCondition(builder, sync, nil)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(connectors.MySqlImpl.getReadQuery)")
 	}
 
 	if sync.SyncMode.UsesCursor() {
-		if sync.CursorPosition != nil {
-			// TODO: allow choosing other operators (rows smaller than current cursor)
-			// order by cursor field to simplify
-			return fmt.Sprintf("%s WHERE %s > %s ORDER BY %s ASC;", queryString, *sync.SourceCursorField, *sync.CursorPosition, *sync.SourceCursorField)
-		} else {
-			return fmt.Sprintf("%s ORDER BY %s ASC;", queryString, *sync.SourceCursorField)
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "(connectors.MySqlImpl.getReadQuery)")
 		}
-	} else {
-		return fmt.Sprintf("%s;", queryString)
+
+		addCursorCondition(builder, sync, condition)
 	}
+
+	queryString, args := builder.Build()
+	return queryString, args, nil
 }
 
-func (ms MySqlImpl) getSelectString(fieldMappings []views.FieldMapping) string {
-	fields := []string{}
+func (ms MySqlImpl) ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error) {
+	connectionModel := views.ConvertConnectionView(sourceConnection)
+
+	sourceClient, err := ms.queryService.GetClient(ctx, connectionModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.MySqlImpl.ReadPrimaryKeys)")
+	}
+
+	iterator, err := readPrimaryKeys(ctx, sourceClient, sqlbuilder.DialectMySql, sync, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.MySqlImpl.ReadPrimaryKeys)")
+	}
+
+	return iterator, nil
+}
+
+func (ms MySqlImpl) getSelectColumns(fieldMappings []views.FieldMapping) []string {
+	columns := []string{}
 	for _, fieldMapping := range fieldMappings {
-		fields = append(fields, fieldMapping.SourceFieldName)
+		columns = append(columns, fieldMapping.SourceFieldName)
 	}
 
-	return strings.Join(fields, ",")
+	return columns
 }
 
-func (ms MySqlImpl) getNewCursorPosition(lastRow data.Row, schema data.Schema, sync views.Sync) *string {
-	if sync.SourceCursorField == nil {
-		return nil
+func (ms MySqlImpl) Write(
+	ctx context.Context,
+	destinationConnection views.FullConnection,
+	destinationOptions DestinationOptions,
+	object views.Object,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC <-chan RowBatch,
+	writeOutputC chan<- WriteOutput,
+	errC chan<- error,
+) {
+	connectionModel := views.ConvertConnectionView(destinationConnection)
+
+	destClient, err := ms.queryService.GetDatabaseClient(ctx, connectionModel)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.MySqlImpl.Write) getting client")
+		return
+	}
+
+	if sync.SyncMode.UpdatesByPrimaryKey() && object.PrimaryKey == nil {
+		errC <- errors.NewCustomerVisibleError("primary key must be set on the object to use incremental update")
+		return
+	}
+
+	namespace := *object.Namespace
+	tableName := getDestinationTableName(object, sync.EndCustomerID)
+	columns := getDestinationColumns(object)
+
+	err = ms.prepareTable(ctx, destClient, namespace, tableName, columns, object, sync)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.MySqlImpl.Write) preparing table")
+		return
+	}
+
+	var rowsWritten int
+	switch sync.SyncMode {
+	case models.SyncModeFullOverwrite:
+		if object.TargetType == models.TargetTypeTablePerCustomer {
+			rowsWritten, err = ms.writeWithSwapTable(ctx, destClient, namespace, tableName, columns, object, sync, fieldMappings, rowsC)
+		} else {
+			rowsWritten, err = ms.writeWithStagingTable(ctx, destClient, namespace, tableName, columns, object, sync, fieldMappings, rowsC)
+		}
+	case models.SyncModeIncrementalUpdate, models.SyncModeChangeDataCapture:
+		rowsWritten, err = ms.writeWithUpsert(ctx, destClient, namespace, tableName, columns, object, sync, fieldMappings, rowsC, writeOutputC)
+	default:
+		rowsWritten, err = ms.writeRows(ctx, destClient, namespace, tableName, columns, object, sync, fieldMappings, rowsC, writeOutputC)
+	}
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.MySqlImpl.Write)")
+		return
+	}
+
+	writeOutputC <- WriteOutput{
+		RowsWritten: rowsWritten,
+		Done:        true,
 	}
 
-	if lastRow == nil {
+	close(errC)
+}
+
+// creates the destination table if it does not exist yet, otherwise checks that it has every column we will write to
+func (ms MySqlImpl) prepareTable(ctx context.Context, destClient query.DatabaseClient, namespace string, tableName string, columns []string, object views.Object, sync views.Sync) error {
+	schema, err := destClient.GetSchema(ctx, namespace, tableName)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.MySqlImpl.prepareTable) getting schema")
+	}
+
+	if len(schema) == 0 {
+		_, err = destClient.RunQuery(ctx, ms.getCreateTableQuery(namespace, tableName, object))
+		if err != nil {
+			return errors.Wrap(err, "(connectors.MySqlImpl.prepareTable) creating table")
+		}
+
 		return nil
 	}
 
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range schema {
-		if schema[i].Name == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = schema[i].Type
+	existingColumns := map[string]bool{}
+	for _, field := range schema {
+		existingColumns[strings.ToLower(field.Name)] = true
+	}
+
+	for _, column := range columns {
+		if !existingColumns[strings.ToLower(column)] {
+			return errors.NewCustomerVisibleError(fmt.Sprintf("destination table %s.%s is missing column %s", namespace, tableName, column))
 		}
 	}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger, data.FieldTypeNumber:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+	if sync.SyncMode.UpdatesByPrimaryKey() {
+		err = ms.checkUpsertIndex(ctx, destClient, namespace, tableName, object)
+		if err != nil {
+			return errors.Wrap(err, "(connectors.MySqlImpl.prepareTable)")
+		}
 	}
 
-	return &newCursorPos
+	return nil
 }
 
-func (ms MySqlImpl) Write(
+// ON DUPLICATE KEY UPDATE matches rows on any unique index and never updates the end customer ID column, so an index on
+// the primary key alone would let one end customer's rows overwrite another's with the same key. The table must have a
+// unique index on exactly the primary key and the end customer ID column.
+func (ms MySqlImpl) checkUpsertIndex(ctx context.Context, destClient query.DatabaseClient, namespace string, tableName string, object views.Object) error {
+	indexResults, err := destClient.RunQuery(
+		ctx,
+		"SELECT index_name, column_name FROM INFORMATION_SCHEMA.STATISTICS WHERE table_schema = ? AND table_name = ? AND non_unique = 0",
+		namespace, tableName,
+	)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.MySqlImpl.checkUpsertIndex) getting indexes")
+	}
+
+	indexColumns := map[string]map[string]bool{}
+	for _, row := range indexResults.Data {
+		indexName := fmt.Sprintf("%v", row[0])
+		if indexColumns[indexName] == nil {
+			indexColumns[indexName] = map[string]bool{}
+		}
+		indexColumns[indexName][strings.ToLower(fmt.Sprintf("%v", row[1]))] = true
+	}
+
+	primaryKey := strings.ToLower(*object.PrimaryKey)
+	endCustomerIDColumn := strings.ToLower(*object.EndCustomerIDField)
+	for _, columns := range indexColumns {
+		if len(columns) == 2 && columns[primaryKey] && columns[endCustomerIDColumn] {
+			return nil
+		}
+	}
+
+	return errors.NewCustomerVisibleError(fmt.Sprintf(
+		"%s.%s must have a unique index on exactly (%s, %s) to use incremental update",
+		namespace, tableName, *object.PrimaryKey, *object.EndCustomerIDField,
+	))
+}
+
+// Rows are loaded into a copy of the table which then replaces the original in a single atomic rename, so readers never
+// see a partially loaded table. Only used for tables that belong to a single end customer, since replacing a shared table
+// would drop rows written concurrently by other end customers.
+func (ms MySqlImpl) writeWithSwapTable(
 	ctx context.Context,
-	destinationConnection views.FullConnection,
-	destinationOptions DestinationOptions,
+	destClient query.DatabaseClient,
+	namespace string,
+	tableName string,
+	columns []string,
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
+) (int, error) {
+	suffix := strings.ReplaceAll(uuid.New().String(), "-", "")
+	swapTable := ms.qualifiedName(namespace, fmt.Sprintf("fabra_swap_%s", suffix))
+	oldTable := ms.qualifiedName(namespace, fmt.Sprintf("fabra_old_%s", suffix))
+	target := ms.qualifiedName(namespace, tableName)
+
+	_, err := destClient.RunQuery(ctx, fmt.Sprintf("CREATE TABLE %s LIKE %s", swapTable, target))
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithSwapTable) creating swap table")
+	}
+
+	// use a separate context for cleanup so it won't get cancelled
+	defer destClient.RunQuery(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s, %s", swapTable, oldTable))
+
+	// rows written to the swap table are only committed by the swap, so no output is sent per batch
+	rowsWritten, err := ms.writeRows(ctx, destClient, namespace, fmt.Sprintf("fabra_swap_%s", suffix), columns, object, sync, fieldMappings, rowsC, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithSwapTable) writing rows to swap table")
+	}
+
+	if rowsWritten == 0 {
+		return 0, nil
+	}
+
+	_, err = destClient.RunQuery(ctx, fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", target, oldTable, swapTable, target))
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithSwapTable) swapping tables")
+	}
+
+	return rowsWritten, nil
+}
+
+// Rows are loaded into a staging table, then replace the end customer's rows in a shared table in a single transaction
+// that leaves the rows of other end customers untouched
+func (ms MySqlImpl) writeWithStagingTable(
+	ctx context.Context,
+	destClient query.DatabaseClient,
+	namespace string,
+	tableName string,
+	columns []string,
+	object views.Object,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC <-chan RowBatch,
+) (int, error) {
+	stagingTableName := fmt.Sprintf("fabra_staging_%s", strings.ReplaceAll(uuid.New().String(), "-", ""))
+	stagingTable := ms.qualifiedName(namespace, stagingTableName)
+	target := ms.qualifiedName(namespace, tableName)
+
+	_, err := destClient.RunQuery(ctx, fmt.Sprintf("CREATE TABLE %s LIKE %s", stagingTable, target))
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithStagingTable) creating staging table")
+	}
+
+	// use a separate context for cleanup so it won't get cancelled
+	defer destClient.RunQuery(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", stagingTable))
+
+	// rows written to the staging table are only committed by the transaction, so no output is sent per batch
+	rowsWritten, err := ms.writeRows(ctx, destClient, namespace, stagingTableName, columns, object, sync, fieldMappings, rowsC, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithStagingTable) writing rows to staging table")
+	}
+
+	if rowsWritten == 0 {
+		return 0, nil
+	}
+
+	quotedColumns := []string{}
+	for _, column := range columns {
+		quotedColumns = append(quotedColumns, query.QuoteMySqlIdentifier(column))
+	}
+	columnList := strings.Join(quotedColumns, ", ")
+
+	err = destClient.ExecuteInTransaction(
+		ctx,
+		fmt.Sprintf("DELETE FROM %s WHERE %s = %s", target, query.QuoteMySqlIdentifier(*object.EndCustomerIDField), ms.quoteLiteral(sync.EndCustomerID)),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", target, columnList, columnList, stagingTable),
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithStagingTable) replacing rows")
+	}
+
+	return rowsWritten, nil
+}
+
+// Statements run in a transaction can't bind arguments, so values are written as hex literals, which can't be
+// misread whatever the server's SQL mode, and converted back to strings so the column's collation still applies
+func (ms MySqlImpl) quoteLiteral(value string) string {
+	return fmt.Sprintf("CONVERT(X'%x' USING utf8mb4)", value)
+}
+
+func (ms MySqlImpl) writeWithUpsert(
+	ctx context.Context,
+	destClient query.DatabaseClient,
+	namespace string,
+	tableName string,
+	columns []string,
+	object views.Object,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
-	errC chan<- error,
-) {
-	errC <- errors.New("mysql destination not implemented")
+) (int, error) {
+	updateClause := ms.getUpdateClause(columns, object)
+
+	rowsWritten := 0
+	batchesCommitted := 0
+	for {
+		rowBatch, more := <-rowsC
+		if !more {
+			break
+		}
+
+		rows, err := rowBatch.UpsertsOnly()
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithUpsert)")
+		}
+
+		destinationRows, err := ms.convertRows(rows, object, fieldMappings, sync.EndCustomerID)
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithUpsert) converting rows")
+		}
+
+		// rows are read in cursor order, so later versions of the same row overwrite earlier ones
+		for _, batch := range query.GetMySqlInsertBatches(destinationRows, len(columns)) {
+			args := []any{}
+			for _, row := range batch {
+				args = append(args, row...)
+			}
+
+			upsertQuery := fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", query.GetMySqlInsertStatement(namespace, tableName, columns, len(batch)), updateClause)
+			_, err = destClient.RunQuery(ctx, upsertQuery, args...)
+			if err != nil {
+				return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeWithUpsert) upserting rows")
+			}
+		}
+
+		rowsWritten += len(rows)
+		batchesCommitted++
+		writeOutputC <- WriteOutput{
+			RowsWritten:      rowsWritten,
+			BatchesCommitted: batchesCommitted,
+		}
+	}
+
+	return rowsWritten, nil
+}
+
+func (ms MySqlImpl) writeRows(
+	ctx context.Context,
+	destClient query.DatabaseClient,
+	namespace string,
+	tableName string,
+	columns []string,
+	object views.Object,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC <-chan RowBatch,
+	writeOutputC chan<- WriteOutput,
+) (int, error) {
+	rowsWritten := 0
+	batchesCommitted := 0
+	for {
+		rowBatch, more := <-rowsC
+		if !more {
+			break
+		}
+
+		rows, err := rowBatch.UpsertsOnly()
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeRows)")
+		}
+
+		destinationRows, err := ms.convertRows(rows, object, fieldMappings, sync.EndCustomerID)
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeRows) converting rows")
+		}
+
+		err = destClient.LoadData(ctx, namespace, tableName, columns, destinationRows)
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.MySqlImpl.writeRows) inserting rows")
+		}
+
+		rowsWritten += len(rows)
+		batchesCommitted++
+		if writeOutputC != nil {
+			writeOutputC <- WriteOutput{
+				RowsWritten:      rowsWritten,
+				BatchesCommitted: batchesCommitted,
+			}
+		}
+	}
+
+	return rowsWritten, nil
+}
+
+func (ms MySqlImpl) getUpdateClause(columns []string, object views.Object) string {
+	updates := []string{}
+	for _, column := range columns {
+		if column == *object.PrimaryKey || column == *object.EndCustomerIDField {
+			continue
+		}
+
+		quotedColumn := query.QuoteMySqlIdentifier(column)
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", quotedColumn, quotedColumn))
+	}
+
+	// MySQL requires at least one assignment, so fall back to a no-op when only key columns are written
+	if len(updates) == 0 {
+		quotedPrimaryKey := query.QuoteMySqlIdentifier(*object.PrimaryKey)
+		updates = append(updates, fmt.Sprintf("%s = %s", quotedPrimaryKey, quotedPrimaryKey))
+	}
+
+	return strings.Join(updates, ", ")
+}
+
+func (ms MySqlImpl) getCreateTableQuery(namespace string, tableName string, object views.Object) string {
+	columnDefinitions := []string{}
+	for _, objectField := range object.ObjectFields {
+		if objectField.Omit {
+			continue
+		}
+
+		// TEXT columns cannot be part of an index without a prefix length, so key columns use VARCHAR instead
+		isKey := object.PrimaryKey != nil && objectField.Name == *object.PrimaryKey
+		columnDefinition := fmt.Sprintf("%s %s", query.QuoteMySqlIdentifier(objectField.Name), getMySqlType(objectField.Type, isKey))
+		if !objectField.Optional {
+			columnDefinition += " NOT NULL"
+		}
+		columnDefinitions = append(columnDefinitions, columnDefinition)
+	}
+	endCustomerIDColumn := query.QuoteMySqlIdentifier(*object.EndCustomerIDField)
+	columnDefinitions = append(columnDefinitions, fmt.Sprintf("%s VARCHAR(255) NOT NULL", endCustomerIDColumn))
+
+	if object.PrimaryKey != nil {
+		columnDefinitions = append(columnDefinitions, fmt.Sprintf("UNIQUE KEY (%s, %s)", query.QuoteMySqlIdentifier(*object.PrimaryKey), endCustomerIDColumn))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", ms.qualifiedName(namespace, tableName), strings.Join(columnDefinitions, ", "))
+}
+
+// the MySQL driver cannot bind maps or slices, so JSON values are serialized
+func (ms MySqlImpl) convertRows(rows []data.Row, object views.Object, fieldMappings []views.FieldMapping, endCustomerID string) ([]data.Row, error) {
+	destinationRows := convertToDestinationRows(rows, object, fieldMappings, endCustomerID)
+	for _, row := range destinationRows {
+		for i, value := range row {
+			switch value.(type) {
+			case map[string]any, []any:
+				jsonValue, err := json.Marshal(value)
+				if err != nil {
+					return nil, errors.Wrap(err, "(connectors.MySqlImpl.convertRows)")
+				}
+				row[i] = string(jsonValue)
+			}
+		}
+	}
+
+	return destinationRows, nil
+}
+
+func (ms MySqlImpl) qualifiedName(namespace string, tableName string) string {
+	return fmt.Sprintf("%s.%s", query.QuoteMySqlIdentifier(namespace), query.QuoteMySqlIdentifier(tableName))
+}
+
+func getMySqlType(fieldType data.FieldType, isKey bool) string {
+	switch fieldType {
+	case data.FieldTypeInteger:
+		return "BIGINT"
+	case data.FieldTypeNumber:
+		return "DOUBLE"
+	case data.FieldTypeBoolean:
+		return "BOOLEAN"
+	case data.FieldTypeTimestamp, data.FieldTypeDateTimeTz, data.FieldTypeDateTimeNtz:
+		return "DATETIME(6)"
+	case data.FieldTypeJson:
+		return "JSON"
+	case data.FieldTypeDate:
+		return "DATE"
+	case data.FieldTypeTimeTz, data.FieldTypeTimeNtz:
+		return "TIME(6)"
+	default:
+		if isKey {
+			return "VARCHAR(255)"
+		}
+		return "TEXT"
+	}
 }
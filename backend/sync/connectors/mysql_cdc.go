@@ -0,0 +1,366 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
+	"go.fabra.io/server/common/views"
+)
+
+// Change data capture syncs read the source's row-based binlog as a replica would. The cursor is the binlog file and
+// position the next run starts reading from, or the set of transactions already read if the source has GTIDs enabled,
+// so the source only needs to keep its binlog for longer than the time between runs.
+
+const mysqlReplicaServerIDOffset = 2_000_000_000
+
+// Each replica connected to the source needs its own server ID. Real replicas use small IDs, so syncs use IDs far
+// above them.
+func getReplicaServerID(syncID int64) uint32 {
+	return uint32(mysqlReplicaServerIDOffset + syncID%mysqlReplicaServerIDOffset)
+}
+
+func (ms MySqlImpl) readChanges(
+	ctx context.Context,
+	sourceClient query.ConnectorClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+	errC chan<- error,
+) {
+	binlogClient, ok := sourceClient.(query.BinlogClient)
+	if !ok {
+		errC <- errors.Newf("(connectors.MySqlImpl.readChanges) client %T can't read the binlog", sourceClient)
+		return
+	}
+
+	err := ms.checkBinlogSettings(ctx, sourceClient)
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	var cursorPosition *data.CursorState
+	var batchesRead int
+	if sync.CursorPosition == nil {
+		cursorPosition, batchesRead, err = ms.readSnapshot(ctx, binlogClient, sync, fieldMappings, rowsC, readOutputC)
+	} else {
+		cursorPosition, batchesRead, err = ms.readBinlog(ctx, binlogClient, sync, fieldMappings, rowsC, readOutputC)
+	}
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	readOutputC <- ReadOutput{
+		CursorPosition: cursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
+	}
+
+	close(rowsC)
+	close(errC)
+}
+
+// Rows are only fully logged with row-based logging and full row images, which are the defaults since MySQL 8.0
+func (ms MySqlImpl) checkBinlogSettings(ctx context.Context, sourceClient query.ConnectorClient) error {
+	results, err := sourceClient.RunQuery(ctx, "SELECT @@global.binlog_format, @@global.binlog_row_image")
+	if err != nil {
+		return errors.Wrap(err, "(connectors.MySqlImpl.checkBinlogSettings)")
+	}
+	if len(results.Data) == 0 {
+		return errors.New("(connectors.MySqlImpl.checkBinlogSettings) no binlog settings")
+	}
+
+	binlogFormat := fmt.Sprintf("%s", results.Data[0][0])
+	binlogRowImage := fmt.Sprintf("%s", results.Data[0][1])
+	if !strings.EqualFold(binlogFormat, "ROW") || !strings.EqualFold(binlogRowImage, "FULL") {
+		return errors.NewCustomerVisibleError(fmt.Sprintf(
+			"change data capture requires binlog_format = ROW and binlog_row_image = FULL, but the source has %s and %s",
+			binlogFormat, binlogRowImage,
+		))
+	}
+
+	return nil
+}
+
+// Reads the whole table from a consistent snapshot. The binlog is read from where the snapshot ends by the next run.
+func (ms MySqlImpl) readSnapshot(
+	ctx context.Context,
+	binlogClient query.BinlogClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+) (*data.CursorState, int, error) {
+	readQuery, readArgs := sqlbuilder.Select(sqlbuilder.DialectMySql, ms.getSelectColumns(fieldMappings)...).
+		From(*sync.Namespace, *sync.TableName).
+		Build()
+	startPosition, iterator, err := binlogClient.GetSnapshotIterator(ctx, readQuery, readArgs...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readSnapshot) running query")
+	}
+
+	batchesRead, err := readSnapshotRows(ctx, iterator, rowsC, readOutputC)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readSnapshot)")
+	}
+
+	cursorPosition, err := data.NewCursorState(data.FieldTypeString, startPosition.String())
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readSnapshot)")
+	}
+
+	return cursorPosition, batchesRead, nil
+}
+
+// Reads the changes committed after the cursor, up to the end of the binlog when the run started so a busy source
+// can't keep the run going. The changes are merged into the latest version of each changed row, and sent as a batch
+// whenever enough rows have changed.
+func (ms MySqlImpl) readBinlog(
+	ctx context.Context,
+	binlogClient query.BinlogClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+) (*data.CursorState, int, error) {
+	startPosition, err := query.ParseBinlogPosition(fmt.Sprintf("%v", sync.CursorPosition.Value))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog)")
+	}
+
+	endPosition, err := binlogClient.GetBinlogPosition(ctx)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog)")
+	}
+	if startPosition.Includes(*endPosition) {
+		return sync.CursorPosition, 0, nil
+	}
+
+	decoder, err := newBinlogDecoder(sync, fieldMappings)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog)")
+	}
+
+	stream, err := binlogClient.OpenBinlog(ctx, query.BinlogOptions{
+		ServerID:  getReplicaServerID(sync.ID),
+		Position:  *startPosition,
+		Namespace: *sync.Namespace,
+		TableName: *sync.TableName,
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog) opening binlog")
+	}
+	defer stream.Close()
+
+	position := *startPosition
+	batchesRead := 0
+	for {
+		event, err := stream.Next(ctx)
+		if err == data.ErrDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog) reading event")
+		}
+
+		if event.Rows != nil {
+			err = decoder.decode(event.Rows)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog) decoding rows")
+			}
+		}
+
+		// the end position is between transactions, so reaching it also ends a transaction
+		position = event.Position
+		reachedEnd := position.Includes(*endPosition)
+		if reachedEnd || (event.Commit && decoder.changes.full()) {
+			cursorPosition, err := data.NewCursorState(data.FieldTypeString, position.String())
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog)")
+			}
+			batchesRead = sendChanges(decoder.changes, cursorPosition, batchesRead, rowsC, readOutputC)
+		}
+		if reachedEnd {
+			break
+		}
+	}
+
+	// the source only ends the stream between transactions, so its end is also a place to start from
+	cursorPosition, err := data.NewCursorState(data.FieldTypeString, position.String())
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.MySqlImpl.readBinlog)")
+	}
+
+	batchesRead = sendChanges(decoder.changes, cursorPosition, batchesRead, rowsC, readOutputC)
+	return cursorPosition, batchesRead, nil
+}
+
+// Decodes binlog row events into changes to the sync's table
+type binlogDecoder struct {
+	fieldMappings []views.FieldMapping
+	primaryKey    string
+	primaryKeyPos int
+	changes       *changeSet
+}
+
+func newBinlogDecoder(sync views.Sync, fieldMappings []views.FieldMapping) (*binlogDecoder, error) {
+	for i, fieldMapping := range fieldMappings {
+		if sync.SourcePrimaryKey != nil && fieldMapping.SourceFieldName == *sync.SourcePrimaryKey {
+			return &binlogDecoder{
+				fieldMappings: fieldMappings,
+				primaryKey:    *sync.SourcePrimaryKey,
+				primaryKeyPos: i,
+				changes:       newChangeSet(),
+			}, nil
+		}
+	}
+
+	return nil, errors.NewCustomerVisibleError("the primary key must be mapped to use change data capture")
+}
+
+func (d *binlogDecoder) decode(event *query.BinlogRowsEvent) error {
+	columnPositions := map[string]int{}
+	for i, column := range event.Columns {
+		columnPositions[column] = i
+	}
+
+	for _, fieldMapping := range d.fieldMappings {
+		if _, ok := columnPositions[fieldMapping.SourceFieldName]; !ok {
+			return errors.NewCustomerVisibleError(fmt.Sprintf("column %s is no longer in the source table", fieldMapping.SourceFieldName))
+		}
+	}
+	keyPosition := columnPositions[d.primaryKey]
+
+	for i, binlogRow := range event.Rows {
+		key := fmt.Sprintf("%v", binlogRow[keyPosition])
+		switch event.Type {
+		case query.BinlogRowsEventTypeInsert:
+			err := d.upsert(key, binlogRow, columnPositions)
+			if err != nil {
+				return err
+			}
+		case query.BinlogRowsEventTypeUpdate:
+			newRow := event.NewRows[i]
+			newKey := fmt.Sprintf("%v", newRow[keyPosition])
+			if newKey != key {
+				err := d.delete(key, binlogRow[keyPosition])
+				if err != nil {
+					return err
+				}
+			}
+
+			err := d.upsert(newKey, newRow, columnPositions)
+			if err != nil {
+				return err
+			}
+		case query.BinlogRowsEventTypeDelete:
+			err := d.delete(key, binlogRow[keyPosition])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *binlogDecoder) upsert(key string, binlogRow []any, columnPositions map[string]int) error {
+	row := make(data.Row, len(d.fieldMappings))
+	for i, fieldMapping := range d.fieldMappings {
+		value, err := convertBinlogValue(binlogRow[columnPositions[fieldMapping.SourceFieldName]], fieldMapping.SourceFieldType)
+		if err != nil {
+			return errors.NewCustomerVisibleError(fmt.Sprintf("could not read value of column %s: %s", fieldMapping.SourceFieldName, err.Error()))
+		}
+		row[i] = value
+	}
+
+	d.changes.set(key, rowChange{row: row})
+	return nil
+}
+
+func (d *binlogDecoder) delete(key string, binlogKey any) error {
+	fieldMapping := d.fieldMappings[d.primaryKeyPos]
+	value, err := convertBinlogValue(binlogKey, fieldMapping.SourceFieldType)
+	if err != nil {
+		return errors.NewCustomerVisibleError(fmt.Sprintf("could not read value of column %s: %s", fieldMapping.SourceFieldName, err.Error()))
+	}
+
+	d.changes.set(key, rowChange{deleted: true, row: newDeletedRow(len(d.fieldMappings), d.primaryKeyPos, value)})
+	return nil
+}
+
+// Converts values decoded from the binlog to the same types the MySQL client reads them as
+func convertBinlogValue(value any, fieldType data.FieldType) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case data.FieldTypeInteger:
+		if v, ok := value.(string); ok {
+			return strconv.ParseInt(v, 10, 64)
+		}
+		return value, nil
+	case data.FieldTypeNumber:
+		switch v := value.(type) {
+		case string:
+			return strconv.ParseFloat(v, 64)
+		case int64:
+			return float64(v), nil
+		case uint64:
+			return float64(v), nil
+		}
+		return value, nil
+	case data.FieldTypeBoolean:
+		switch v := value.(type) {
+		case int64:
+			return v != 0, nil
+		case uint64:
+			return v != 0, nil
+		}
+		return value, nil
+	case data.FieldTypeDateTimeTz, data.FieldTypeTimestamp, data.FieldTypeDateTimeNtz:
+		text := fmt.Sprintf("%v", value)
+		// MySQL allows zero dates, which aren't valid times
+		if strings.HasPrefix(text, "0000-00-00") {
+			return nil, nil
+		}
+
+		// timestamps are logged in UTC
+		timestamp, err := time.Parse("2006-01-02 15:04:05.999999", text)
+		if err != nil {
+			return nil, err
+		}
+		if fieldType == data.FieldTypeDateTimeNtz {
+			return timestamp.Format(data.TIMESTAMP_NTZ_FORMAT), nil
+		}
+		return timestamp.Format(data.TIMESTAMP_TZ_FORMAT), nil
+	case data.FieldTypeJson:
+		if v, ok := value.(string); ok {
+			var jsonValue any
+			err := json.Unmarshal([]byte(v), &jsonValue)
+			if err != nil {
+				return nil, err
+			}
+			return jsonValue, nil
+		}
+		return value, nil
+	case data.FieldTypeString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return fmt.Sprintf("%v", value), nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,462 @@
+package connectors_test
+
+import (
+	"context"
+
+	"github.com/golang/mock/gomock"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/input"
+	mock_query "go.fabra.io/server/common/mocks"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MySqlConnector", func() {
+	var (
+		sourceConnection      views.FullConnection
+		destinationConnection views.FullConnection
+		sync                  views.Sync
+		fieldMappings         []views.FieldMapping
+		object                views.Object
+		existingSchema        data.Schema
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, sourceConn := test.CreateSource(db, org.ID, endCustomerID)
+		sourceConnection = views.ConvertFullConnection(sourceConn)
+		destination, destConn := test.CreateDestination(db, org.ID)
+		destinationConnection = views.ConvertFullConnection(destConn)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeIncrementalUpdate)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+		})
+		object = views.ConvertObject(objectModel, objectFields)
+		primaryKey := "id"
+		object.PrimaryKey = &primaryKey
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeIncrementalUpdate))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_name", SourceFieldType: data.FieldTypeString, DestinationFieldId: objectFields[1].ID},
+		}), objectFields)
+		existingSchema = data.Schema{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+			{Name: "end_customer_id", Type: data.FieldTypeString},
+		}
+	})
+
+	Describe("Read", func() {
+		It("reads the latest version of each row changed since the cursor from the binlog", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockBinlogClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl), options: &query.BinlogOptions{}}
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "mysql-bin.000001:100"}
+
+			columns := []string{"source_id", "source_name", "unmapped"}
+			client.events = []query.BinlogEvent{
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 100}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 200}, Rows: &query.BinlogRowsEvent{
+					Type:    query.BinlogRowsEventTypeInsert,
+					Columns: columns,
+					Rows:    [][]any{{int64(1), "first", "x"}, {int64(2), "second", "x"}, {int64(3), "third", "x"}},
+				}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 300}, Rows: &query.BinlogRowsEvent{
+					Type:    query.BinlogRowsEventTypeUpdate,
+					Columns: columns,
+					Rows:    [][]any{{int64(1), "first", "x"}, {int64(3), "third", "x"}},
+					NewRows: [][]any{{int64(1), "updated", "y"}, {int64(4), "third", "x"}},
+				}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 400}, Rows: &query.BinlogRowsEvent{
+					Type:    query.BinlogRowsEventTypeDelete,
+					Columns: columns,
+					Rows:    [][]any{{int64(2), "second", "x"}},
+				}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 500}, Commit: true},
+				{Position: query.BinlogPosition{File: "mysql-bin.000002", Position: 4}},
+			}
+			client.endPosition = query.BinlogPosition{File: "mysql-bin.000002", Position: 4}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT @@global.binlog_format, @@global.binlog_row_image").
+				Return(&data.QueryResults{Data: []data.Row{{"ROW", "FULL"}}}, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("mysql-bin.000002:4"))
+			Expect(resultRows.Rows).To(Equal([]data.Row{{int64(1), "updated"}, {int64(2), nil}, {int64(3), nil}, {int64(4), "third"}}))
+			Expect(resultRows.Operations).To(Equal([]data.RowOperation{data.RowOperationUpsert, data.RowOperationDelete, data.RowOperationDelete, data.RowOperationUpsert}))
+			Expect(numBatches).To(Equal(1))
+			Expect(client.options.Position).To(Equal(query.BinlogPosition{File: "mysql-bin.000001", Position: 100}))
+		})
+
+		It("reads the table from a snapshot and starts the binlog where the snapshot ends", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockBinlogClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl), options: &query.BinlogOptions{}}
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+
+			client.snapshotPosition = query.BinlogPosition{File: "mysql-bin.000003", Position: 157, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,3e11fa47-71ca-11e1-9e33-c80aa9429563:1"}
+			client.snapshotRows = []data.Row{{int64(1), "first"}}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT @@global.binlog_format, @@global.binlog_row_image").
+				Return(&data.QueryResults{Data: []data.Row{{"ROW", "FULL"}}}, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, _, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(resultRows.Rows).To(Equal([]data.Row{{int64(1), "first"}}))
+			Expect(readOutput.CursorPosition.Value).To(Equal("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,3e11fa47-71ca-11e1-9e33-c80aa9429563:1"))
+		})
+
+		It("reads the binlog from a GTID set cursor", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockBinlogClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl), options: &query.BinlogOptions{}}
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+
+			client.events = []query.BinlogEvent{
+				{Position: query.BinlogPosition{File: "mysql-bin.000003", Position: 4, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000003", Position: 300, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}, Rows: &query.BinlogRowsEvent{
+					Type:    query.BinlogRowsEventTypeInsert,
+					Columns: []string{"source_id", "source_name"},
+					Rows:    [][]any{{int64(1), "first"}},
+				}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000003", Position: 400, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"}, Commit: true},
+			}
+			client.endPosition = query.BinlogPosition{File: "mysql-bin.000003", Position: 400, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT @@global.binlog_format, @@global.binlog_row_image").
+				Return(&data.QueryResults{Data: []data.Row{{"ROW", "FULL"}}}, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, _, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(resultRows.Rows).To(Equal([]data.Row{{int64(1), "first"}}))
+			Expect(readOutput.CursorPosition.Value).To(Equal("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"))
+			Expect(client.options.Position).To(Equal(query.BinlogPosition{GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}))
+		})
+
+		It("sends a batch whenever enough rows have changed and stops at the end of the binlog when the run started", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mockBinlogClient{MockConnectorClient: mock_query.NewMockConnectorClient(ctrl), options: &query.BinlogOptions{}}
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "mysql-bin.000001:100"}
+
+			columns := []string{"source_id", "source_name"}
+			inserted := [][]any{}
+			for i := 1; i <= connectors.READ_BATCH_SIZE; i++ {
+				inserted = append(inserted, []any{int64(i), "first"})
+			}
+			client.events = []query.BinlogEvent{
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 200}, Rows: &query.BinlogRowsEvent{Type: query.BinlogRowsEventTypeInsert, Columns: columns, Rows: inserted}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 300}, Commit: true},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 400}, Rows: &query.BinlogRowsEvent{
+					Type:    query.BinlogRowsEventTypeUpdate,
+					Columns: columns,
+					Rows:    [][]any{{int64(1), "first"}},
+					NewRows: [][]any{{int64(1), "updated"}},
+				}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 500}, Commit: true},
+				// committed after the run started
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 600}, Rows: &query.BinlogRowsEvent{Type: query.BinlogRowsEventTypeDelete, Columns: columns, Rows: [][]any{{int64(1), "updated"}}}},
+				{Position: query.BinlogPosition{File: "mysql-bin.000001", Position: 700}, Commit: true},
+			}
+			client.endPosition = query.BinlogPosition{File: "mysql-bin.000001", Position: 500}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT @@global.binlog_format, @@global.binlog_row_image").
+				Return(&data.QueryResults{Data: []data.Row{{"ROW", "FULL"}}}, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			// the second batch is written after the first, so the update to the first row replaces its insert
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("mysql-bin.000001:500"))
+			Expect(numBatches).To(Equal(2))
+			Expect(resultRows.Rows).To(HaveLen(connectors.READ_BATCH_SIZE + 1))
+			Expect(resultRows.Rows[connectors.READ_BATCH_SIZE]).To(Equal(data.Row{int64(1), "updated"}))
+			Expect(resultRows.Operation(connectors.READ_BATCH_SIZE)).To(Equal(data.RowOperationUpsert))
+		})
+	})
+
+	Describe("Write", func() {
+		It("upserts rows in multi-row batches for incremental updates", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			rows := []data.Row{
+				{1, "first"},
+				{2, "second"},
+			}
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().GetSchema(gomock.Any(), "namespace", "table").Return(existingSchema, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"SELECT index_name, column_name FROM INFORMATION_SCHEMA.STATISTICS", ""}, "namespace", "table").
+				Return(&data.QueryResults{Data: []data.Row{{"PRIMARY", "row_id"}, {"id", "id"}, {"id", "end_customer_id"}}}, nil)
+			client.EXPECT().RunQuery(
+				gomock.Any(),
+				"INSERT INTO `namespace`.`table` (`id`, `name`, `end_customer_id`) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+				1, "first", "abc123", 2, "second", "abc123",
+			).Return(nil, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch(rows)
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+		})
+
+		It("rejects incremental updates if the primary key is unique without the end customer ID", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().GetSchema(gomock.Any(), "namespace", "table").Return(existingSchema, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"SELECT index_name, column_name FROM INFORMATION_SCHEMA.STATISTICS", ""}, "namespace", "table").
+				Return(&data.QueryResults{Data: []data.Row{{"id", "id"}, {"id_name", "id"}, {"id_name", "name"}, {"id_name", "end_customer_id"}}}, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			_, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(MatchError(ContainSubstring("unique index on exactly (id, end_customer_id)")))
+		})
+
+		It("replaces the end customer's rows of a shared table in a transaction for full overwrites", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeFullOverwrite
+			rows := []data.Row{
+				{1, "first"},
+			}
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().GetSchema(gomock.Any(), "namespace", "table").Return(existingSchema, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE TABLE `namespace`.`fabra_staging_", " LIKE `namespace`.`table`"}).Return(nil, nil)
+			client.EXPECT().LoadData(
+				gomock.Any(),
+				"namespace",
+				MockMergeQuery{"fabra_staging_", ""},
+				[]string{"id", "name", "end_customer_id"},
+				[]data.Row{{1, "first", "abc123"}},
+			).Return(nil)
+			client.EXPECT().ExecuteInTransaction(
+				gomock.Any(),
+				"DELETE FROM `namespace`.`table` WHERE `end_customer_id` = CONVERT(X'616263313233' USING utf8mb4)",
+				MockMergeQuery{"INSERT INTO `namespace`.`table` (`id`, `name`, `end_customer_id`) SELECT `id`, `name`, `end_customer_id` FROM `namespace`.`fabra_staging_", "`"},
+			).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS `namespace`.`fabra_staging_", "`"}).Return(nil, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch(rows)
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(1))
+		})
+
+		It("loads a swap table and renames it into place for full overwrites of a table per customer", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeFullOverwrite
+			object.TargetType = models.TargetTypeTablePerCustomer
+			rows := []data.Row{
+				{1, "first"},
+			}
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().GetSchema(gomock.Any(), "namespace", "table_abc123").Return(existingSchema, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE TABLE `namespace`.`fabra_swap_", " LIKE `namespace`.`table_abc123`"}).Return(nil, nil)
+			client.EXPECT().LoadData(
+				gomock.Any(),
+				"namespace",
+				MockMergeQuery{"fabra_swap_", ""},
+				[]string{"id", "name", "end_customer_id"},
+				[]data.Row{{1, "first", "abc123"}},
+			).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"RENAME TABLE `namespace`.`table_abc123` TO `namespace`.`fabra_old_", " TO `namespace`.`table_abc123`"}).Return(nil, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS `namespace`.`fabra_swap_", ""}).Return(nil, nil)
+
+			connector := connectors.NewMySqlConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch(rows)
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(1))
+		})
+	})
+})
+
+// A connector client that also replays binlog events and snapshots, which the generated client mocks can't read
+type mockBinlogClient struct {
+	*mock_query.MockConnectorClient
+	events  []query.BinlogEvent
+	options *query.BinlogOptions
+
+	// the end of the binlog, and the position and rows of the snapshot
+	endPosition      query.BinlogPosition
+	snapshotPosition query.BinlogPosition
+	snapshotRows     []data.Row
+}
+
+func (c mockBinlogClient) OpenBinlog(ctx context.Context, options query.BinlogOptions) (query.BinlogStream, error) {
+	*c.options = options
+	return &mockBinlogStream{events: c.events}, nil
+}
+
+func (c mockBinlogClient) GetBinlogPosition(ctx context.Context) (*query.BinlogPosition, error) {
+	return &c.endPosition, nil
+}
+
+func (c mockBinlogClient) GetSnapshotIterator(ctx context.Context, queryString string, args ...any) (*query.BinlogPosition, data.RowIterator, error) {
+	return &c.snapshotPosition, test.NewMockIterator(c.snapshotRows, data.Schema{
+		{Name: "source_id", Type: data.FieldTypeInteger},
+		{Name: "source_name", Type: data.FieldTypeString},
+	}), nil
+}
+
+type mockBinlogStream struct {
+	events []query.BinlogEvent
+}
+
+func (s *mockBinlogStream) Next(ctx context.Context) (*query.BinlogEvent, error) {
+	if len(s.events) == 0 {
+		return nil, data.ErrDone
+	}
+
+	event := s.events[0]
+	s.events = s.events[1:]
+	return &event, nil
+}
+
+func (s *mockBinlogStream) Close() error {
+	return nil
+}
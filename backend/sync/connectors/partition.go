@@ -0,0 +1,353 @@
+package connectors
+
+import (
+	"context"
+	gosync "sync"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
+	"go.fabra.io/server/common/views"
+)
+
+// Used when the source connection does not set its own limit
+const DEFAULT_MAX_READ_CONCURRENCY = 4
+
+// A range of the partition field to read. The lower bound is inclusive, and the upper bound is exclusive except for the
+// last range so the largest value is read. Rows where the partition field is null are read as a separate partition.
+type partition struct {
+	lower          any
+	upper          any
+	inclusiveUpper bool
+	null           bool
+}
+
+// Queries against the same source are limited across every sync running in this worker, so a large backfill can't
+// overload a customer's database
+var readSemaphores = struct {
+	gosync.Mutex
+	byConnectionID map[int64]chan struct{}
+}{byConnectionID: make(map[int64]chan struct{})}
+
+func getReadSemaphore(sourceConnection views.FullConnection) chan struct{} {
+	maxReadConcurrency := DEFAULT_MAX_READ_CONCURRENCY
+	if sourceConnection.MaxReadConcurrency != nil {
+		maxReadConcurrency = *sourceConnection.MaxReadConcurrency
+	}
+
+	readSemaphores.Lock()
+	defer readSemaphores.Unlock()
+
+	// replace the semaphore if the limit was changed. Reads holding the old one release it as usual.
+	semaphore, ok := readSemaphores.byConnectionID[sourceConnection.ID]
+	if !ok || cap(semaphore) != maxReadConcurrency {
+		semaphore = make(chan struct{}, maxReadConcurrency)
+		readSemaphores.byConnectionID[sourceConnection.ID] = semaphore
+	}
+
+	return semaphore
+}
+
+// Reads a full sync by splitting the source into ranges of the partition field and reading them concurrently, merging
+// the rows from every range into rowsC. toQueryValue converts partition bounds into values the source can compare to the
+// partition field, and may be nil if the bounds can be bound as is.
+func readPartitioned(
+	ctx context.Context,
+	client query.ConnectorClient,
+	dialect sqlbuilder.Dialect,
+	sourceConnection views.FullConnection,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	columns []string,
+	toQueryValue func(fieldType data.FieldType, value any) any,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+	errC chan<- error,
+) {
+	// checkpoints require reading rows in cursor order, which concurrent partitions can't guarantee
+	if sync.SyncMode.UsesCursor() {
+		errC <- errors.NewCustomerVisibleError("partitioned reads can only be used by full syncs")
+		return
+	}
+
+	partitionFieldType, err := getSourceCursorFieldType(*sync.PartitionField, fieldMappings)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.readPartitioned) getting partition field type")
+		return
+	}
+
+	var filterCondition sqlbuilder.Condition
+	if sync.Filter != nil {
+		filterCondition, err = getFilterCondition(*sync.Filter, toQueryValue)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.readPartitioned) getting filter condition")
+			return
+		}
+	}
+
+	semaphore := getReadSemaphore(sourceConnection)
+
+	// without a partition count, read one partition for each query that can run at once
+	partitionCount := cap(semaphore)
+	if sync.PartitionCount != nil {
+		partitionCount = *sync.PartitionCount
+	}
+
+	partitions, err := getPartitions(ctx, client, dialect, sync, filterCondition, *partitionFieldType, partitionCount)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.readPartitioned) getting partitions")
+		return
+	}
+
+	// stop the other partitions as soon as one fails
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu gosync.Mutex
+	var wg gosync.WaitGroup
+	var readErr error
+	batchesRead := 0
+	for _, p := range partitions {
+		builder := getSourceBuilder(dialect, sync, columns, filterCondition)
+		if p.null {
+			builder.WhereNull(*sync.PartitionField)
+		} else {
+			lower, upper := p.lower, p.upper
+			if toQueryValue != nil {
+				lower, upper = toQueryValue(*partitionFieldType, lower), toQueryValue(*partitionFieldType, upper)
+			}
+
+			builder.Where(*sync.PartitionField, sqlbuilder.OperatorGreaterThanOrEqual, lower)
+			if p.inclusiveUpper {
+				builder.Where(*sync.PartitionField, sqlbuilder.OperatorLessThanOrEqual, upper)
+			} else {
+				builder.Where(*sync.PartitionField, sqlbuilder.OperatorLessThan, upper)
+			}
+		}
+		partitionQuery, partitionArgs := builder.Build()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := readPartition(readCtx, client, semaphore, partitionQuery, partitionArgs, func(rowBatch []data.Row) error {
+				select {
+				case rowsC <- NewRowBatch(rowBatch):
+				case <-readCtx.Done():
+					return readCtx.Err()
+				}
+
+				mu.Lock()
+				batchesRead++
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				mu.Lock()
+				if readErr == nil {
+					readErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+	if readErr != nil {
+		errC <- errors.Wrap(readErr, "(connectors.readPartitioned) reading partition")
+		return
+	}
+
+	readOutputC <- ReadOutput{
+		BatchesRead: batchesRead,
+		Done:        true,
+	}
+
+	close(rowsC)
+	close(errC)
+}
+
+func readPartition(
+	ctx context.Context,
+	client query.ConnectorClient,
+	semaphore chan struct{},
+	partitionQuery string,
+	partitionArgs []any,
+	sendBatch func(rowBatch []data.Row) error,
+) error {
+	select {
+	case semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-semaphore }()
+
+	iterator, err := client.GetQueryIterator(ctx, partitionQuery, partitionArgs...)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.readPartition) getting iterator")
+	}
+
+	currentIndex := 0
+	var batchBytes int64
+	var rowBatch []data.Row
+	for {
+		row, err := iterator.Next(ctx)
+		if err != nil {
+			if err == data.ErrDone {
+				break
+			} else {
+				return errors.Wrap(err, "(connectors.readPartition) iterating data")
+			}
+		}
+
+		rowBatch = append(rowBatch, row)
+		currentIndex++
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			err = sendBatch(rowBatch)
+			if err != nil {
+				return err
+			}
+
+			currentIndex = 0
+			batchBytes = 0
+			rowBatch = []data.Row{}
+		}
+	}
+
+	if currentIndex > 0 {
+		return sendBatch(rowBatch)
+	}
+
+	return nil
+}
+
+// Selects the source rows that pass the sync's filter. filterCondition is nil if the sync is not filtered.
+func getSourceBuilder(dialect sqlbuilder.Dialect, sync views.Sync, columns []string, filterCondition sqlbuilder.Condition) *sqlbuilder.SelectBuilder {
+	var builder *sqlbuilder.SelectBuilder
+	if sync.CustomJoin != nil {
+		builder = sqlbuilder.Select(dialect).FromQuery(*sync.CustomJoin)
+	} else {
+		builder = sqlbuilder.Select(dialect, columns...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	if filterCondition != nil {
+		builder.WhereCondition(filterCondition)
+	}
+
+	return builder
+}
+
+// Splits the range between the smallest and largest values of the partition field into evenly sized partitions
+func getPartitions(ctx context.Context, client query.ConnectorClient, dialect sqlbuilder.Dialect, sync views.Sync, filterCondition sqlbuilder.Condition, partitionFieldType data.FieldType, partitionCount int) ([]partition, error) {
+	var boundsBuilder *sqlbuilder.SelectBuilder
+	if sync.CustomJoin != nil {
+		boundsBuilder = sqlbuilder.SelectBounds(dialect, *sync.PartitionField).FromQuery(*sync.CustomJoin)
+	} else {
+		boundsBuilder = sqlbuilder.SelectBounds(dialect, *sync.PartitionField).From(*sync.Namespace, *sync.TableName)
+	}
+
+	// only the filtered rows are read, so only their range needs to be split
+	if filterCondition != nil {
+		boundsBuilder.WhereCondition(filterCondition)
+	}
+
+	boundsQuery, boundsArgs := boundsBuilder.Build()
+	results, err := client.RunQuery(ctx, boundsQuery, boundsArgs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getPartitions) getting partition field bounds")
+	}
+
+	partitions := []partition{{null: true}}
+	// the table is empty or the partition field is always null
+	if len(results.Data) == 0 || results.Data[0][0] == nil || results.Data[0][1] == nil {
+		return partitions, nil
+	}
+
+	lower, err := getPartitionBound(partitionFieldType, results.Data[0][0])
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getPartitions)")
+	}
+
+	upper, err := getPartitionBound(partitionFieldType, results.Data[0][1])
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getPartitions)")
+	}
+
+	boundaries, err := splitPartitionRange(lower, upper, partitionCount)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getPartitions)")
+	}
+
+	for i := 0; i < len(boundaries)-1; i++ {
+		partitions = append(partitions, partition{
+			lower:          boundaries[i],
+			upper:          boundaries[i+1],
+			inclusiveUpper: i == len(boundaries)-2,
+		})
+	}
+
+	return partitions, nil
+}
+
+// Drivers return the bounds in different types, so they are normalized the same way as cursor values
+func getPartitionBound(partitionFieldType data.FieldType, value any) (any, error) {
+	cursorState, err := data.NewCursorState(partitionFieldType, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getPartitionBound)")
+	}
+
+	bound, err := cursorState.TypedValue()
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.getPartitionBound)")
+	}
+
+	return bound, nil
+}
+
+// Returns the boundaries between partitions, starting with the lower bound and ending with the upper bound. Ranges too
+// narrow to split into partitionCount distinct values are split into fewer partitions.
+func splitPartitionRange(lower any, upper any, partitionCount int) ([]any, error) {
+	boundaries := []any{lower}
+	switch lowerValue := lower.(type) {
+	case int64:
+		upperValue := upper.(int64)
+		// the difference may not fit in an int64, but always fits in a uint64
+		step := uint64(upperValue-lowerValue) / uint64(partitionCount)
+		if step == 0 {
+			step = 1
+		}
+		for i := 1; i < partitionCount; i++ {
+			boundary := lowerValue + int64(step*uint64(i))
+			if boundary >= upperValue || boundary <= lowerValue {
+				break
+			}
+			boundaries = append(boundaries, boundary)
+		}
+	case float64:
+		upperValue := upper.(float64)
+		step := (upperValue - lowerValue) / float64(partitionCount)
+		for i := 1; i < partitionCount; i++ {
+			boundary := lowerValue + step*float64(i)
+			if boundary >= upperValue || boundary <= boundaries[len(boundaries)-1].(float64) {
+				break
+			}
+			boundaries = append(boundaries, boundary)
+		}
+	case time.Time:
+		upperValue := upper.(time.Time)
+		step := upperValue.Sub(lowerValue) / time.Duration(partitionCount)
+		if step > 0 {
+			for i := 1; i < partitionCount; i++ {
+				boundaries = append(boundaries, lowerValue.Add(step*time.Duration(i)))
+			}
+		}
+	default:
+		return nil, errors.Newf("(connectors.splitPartitionRange) unsupported partition field value: %T", lower)
+	}
+
+	return append(boundaries, upper), nil
+}
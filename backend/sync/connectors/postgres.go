@@ -2,13 +2,16 @@ package connectors
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/google/uuid"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
@@ -27,7 +30,7 @@ func (pg PostgresImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -39,17 +42,33 @@ func (pg PostgresImpl) Read(
 		return
 	}
 
-	readQuery := pg.getReadQuery(connectionModel, sync, fieldMappings)
+	if sync.SyncMode == models.SyncModeChangeDataCapture {
+		pg.readChanges(ctx, sourceClient, sync, fieldMappings, rowsC, readOutputC, errC)
+		return
+	}
+
+	if sync.PartitionField != nil {
+		readPartitioned(ctx, sourceClient, sqlbuilder.DialectPostgres, sourceConnection, sync, fieldMappings, pg.getSelectColumns(fieldMappings), nil, rowsC, readOutputC, errC)
+		return
+	}
+
+	readQuery, readArgs, err := pg.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- err
+		return
+	}
 
-	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery)
+	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery, readArgs...)
 	if err != nil {
 		errC <- err
 		return
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
+	cursorTracker := newCursorTracker(sync, iterator.Schema())
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -61,90 +80,107 @@ func (pg PostgresImpl) Read(
 			}
 		}
 
+		alreadySynced, err := cursorTracker.Track(row)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, row)
-		lastRow = row
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	newCursorPosition, err := cursorTracker.CursorPosition()
+	if err != nil {
+		errC <- err
+		return
 	}
 
-	newCursorPosition := pg.getNewCursorPosition(lastRow, iterator.Schema(), sync)
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
 	close(errC)
 }
 
-func (pg PostgresImpl) getReadQuery(sourceConnection *models.Connection, sync views.Sync, fieldMappings []views.FieldMapping) string {
-	var queryString string
+func (pg PostgresImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (string, []any, error) {
+	var builder *sqlbuilder.SelectBuilder
 	if sync.CustomJoin != nil {
-		queryString = *sync.CustomJoin
+		builder = sqlbuilder.Select(sqlbuilder.DialectPostgres).FromQuery(*sync.CustomJoin)
 	} else {
-		selectString := pg.getSelectString(fieldMappings)
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, *sync.Namespace, *sync.TableName)
+		builder = sqlbuilder.Select(sqlbuilder.DialectPostgres, pg.getSelectColumns(fieldMappings)...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	err := addFilterCondition(builder, sync, nil)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(connectors.PostgresImpl.getReadQuery)")
 	}
 
 	if sync.SyncMode.UsesCursor() {
-		if sync.CursorPosition != nil {
-			// TODO: allow choosing other operators (rows smaller than current cursor)
-			// order by cursor field to simplify
-			return fmt.Sprintf("%s WHERE %s > %s ORDER BY %s ASC;", queryString, *sync.SourceCursorField, *sync.CursorPosition, *sync.SourceCursorField)
-		} else {
-			return fmt.Sprintf("%s ORDER BY %s ASC;", queryString, *sync.SourceCursorField)
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "(connectors.PostgresImpl.getReadQuery)")
 		}
-	} else {
-		return fmt.Sprintf("%s;", queryString)
-	}
-}
 
-func (pg PostgresImpl) getSelectString(fieldMappings []views.FieldMapping) string {
-	fields := []string{}
-	for _, fieldMapping := range fieldMappings {
-		fields = append(fields, fieldMapping.SourceFieldName)
+		addCursorCondition(builder, sync, condition)
 	}
 
-	return strings.Join(fields, ",")
+	queryString, args := builder.Build()
+	return queryString, args, nil
 }
 
-func (pg PostgresImpl) getNewCursorPosition(lastRow data.Row, schema data.Schema, sync views.Sync) *string {
-	if sync.SourceCursorField == nil {
-		return nil
-	}
+func (pg PostgresImpl) ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error) {
+	connectionModel := views.ConvertConnectionView(sourceConnection)
 
-	if lastRow == nil {
-		return nil
+	sourceClient, err := pg.queryService.GetClient(ctx, connectionModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.PostgresImpl.ReadPrimaryKeys)")
 	}
 
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range schema {
-		if schema[i].Name == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = schema[i].Type
-		}
+	iterator, err := readPrimaryKeys(ctx, sourceClient, sqlbuilder.DialectPostgres, sync, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.PostgresImpl.ReadPrimaryKeys)")
 	}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger, data.FieldTypeNumber:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+	return iterator, nil
+}
+
+func (pg PostgresImpl) getSelectColumns(fieldMappings []views.FieldMapping) []string {
+	columns := []string{}
+	for _, fieldMapping := range fieldMappings {
+		columns = append(columns, fieldMapping.SourceFieldName)
 	}
 
-	return &newCursorPos
+	return columns
 }
 
 func (pg PostgresImpl) Write(
@@ -154,9 +190,220 @@ func (pg PostgresImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
-	errC <- errors.New("postgres destination not implemented")
+	connectionModel := views.ConvertConnectionView(destinationConnection)
+
+	destClient, err := pg.queryService.GetDatabaseClient(ctx, connectionModel)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write) getting client")
+		return
+	}
+
+	if sync.SyncMode.UpdatesByPrimaryKey() && object.PrimaryKey == nil {
+		errC <- errors.NewCustomerVisibleError("primary key must be set on the object to use incremental update")
+		return
+	}
+
+	namespace := *object.Namespace
+	tableName := getDestinationTableName(object, sync.EndCustomerID)
+	columns := getDestinationColumns(object)
+
+	if object.TargetType == models.TargetTypeTablePerCustomer {
+		_, err = destClient.RunQuery(ctx, pg.getCreateTableQuery(namespace, tableName, object))
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write) creating table")
+			return
+		}
+	}
+
+	// rows are copied into a staging table first so the destination table is only modified in a single transaction
+	stagingTableName := fmt.Sprintf("fabra_staging_%s", strings.ReplaceAll(uuid.New().String(), "-", ""))
+	_, err = destClient.RunQuery(ctx, fmt.Sprintf(
+		"CREATE UNLOGGED TABLE %s (LIKE %s INCLUDING DEFAULTS)",
+		pg.qualifiedName(namespace, stagingTableName), pg.qualifiedName(namespace, tableName),
+	))
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write) creating staging table")
+		return
+	}
+
+	// use a separate context for cleanup so it won't get cancelled
+	defer destClient.RunQuery(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", pg.qualifiedName(namespace, stagingTableName)))
+
+	rowsWritten := 0
+	for {
+		rowBatch, more := <-rowsC
+		if !more {
+			break
+		}
+
+		rows, err := rowBatch.UpsertsOnly()
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write)")
+			return
+		}
+
+		destinationRows, err := pg.convertRows(rows, object, fieldMappings, sync.EndCustomerID)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write) converting rows")
+			return
+		}
+
+		err = destClient.LoadData(ctx, namespace, stagingTableName, columns, destinationRows)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write) copying rows to staging table")
+			return
+		}
+
+		rowsWritten += len(rows)
+	}
+
+	if rowsWritten > 0 {
+		err = destClient.ExecuteInTransaction(ctx, pg.getLoadStatements(namespace, tableName, stagingTableName, columns, object, sync)...)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.PostgresImpl.Write) loading from staging table")
+			return
+		}
+	}
+
+	writeOutputC <- WriteOutput{
+		RowsWritten: rowsWritten,
+		Done:        true,
+	}
+
+	close(errC)
+}
+
+func (pg PostgresImpl) getLoadStatements(namespace string, tableName string, stagingTableName string, columns []string, object views.Object, sync views.Sync) []string {
+	target := pg.qualifiedName(namespace, tableName)
+	staging := pg.qualifiedName(namespace, stagingTableName)
+	endCustomerIDColumn := pg.quoteIdentifier(*object.EndCustomerIDField)
+
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = pg.quoteIdentifier(column)
+	}
+	columnList := strings.Join(quotedColumns, ", ")
+	insertStatement := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", target, columnList, columnList, staging)
+
+	switch sync.SyncMode {
+	case models.SyncModeFullOverwrite:
+		// only replace the rows for this end customer, since other customers may share the table
+		var deleteStatement string
+		if object.TargetType == models.TargetTypeTablePerCustomer {
+			deleteStatement = fmt.Sprintf("DELETE FROM %s", target)
+		} else {
+			deleteStatement = fmt.Sprintf("DELETE FROM %s WHERE %s = %s", target, endCustomerIDColumn, pg.quoteLiteral(sync.EndCustomerID))
+		}
+		return []string{deleteStatement, insertStatement}
+	case models.SyncModeIncrementalUpdate, models.SyncModeChangeDataCapture:
+		primaryKey := pg.quoteIdentifier(*object.PrimaryKey)
+
+		// the same row may have been updated multiple times since the last sync, so only keep the latest version
+		orderBy := primaryKey
+		if object.CursorField != nil {
+			orderBy = fmt.Sprintf("%s, %s DESC", primaryKey, pg.quoteIdentifier(*object.CursorField))
+		}
+		latestRows := fmt.Sprintf("(SELECT DISTINCT ON (%s) * FROM %s ORDER BY %s)", primaryKey, staging, orderBy)
+
+		var setClauses, insertValues []string
+		for _, column := range quotedColumns {
+			insertValues = append(insertValues, fmt.Sprintf("source.%s", column))
+			if column != primaryKey && column != endCustomerIDColumn {
+				setClauses = append(setClauses, fmt.Sprintf("%s = source.%s", column, column))
+			}
+		}
+
+		statements := []string{}
+		if len(setClauses) > 0 {
+			statements = append(statements, fmt.Sprintf(
+				"UPDATE %s AS target SET %s FROM %s AS source WHERE target.%s = source.%s AND target.%s = source.%s",
+				target, strings.Join(setClauses, ", "), latestRows, primaryKey, primaryKey, endCustomerIDColumn, endCustomerIDColumn,
+			))
+		}
+
+		return append(statements, fmt.Sprintf(
+			"INSERT INTO %s (%s) SELECT %s FROM %s AS source WHERE NOT EXISTS (SELECT 1 FROM %s AS target WHERE target.%s = source.%s AND target.%s = source.%s)",
+			target, columnList, strings.Join(insertValues, ", "), latestRows, target, primaryKey, primaryKey, endCustomerIDColumn, endCustomerIDColumn,
+		))
+	default:
+		return []string{insertStatement}
+	}
+}
+
+func (pg PostgresImpl) getCreateTableQuery(namespace string, tableName string, object views.Object) string {
+	columnDefinitions := []string{}
+	for _, objectField := range object.ObjectFields {
+		if objectField.Omit {
+			continue
+		}
+
+		columnDefinition := fmt.Sprintf("%s %s", pg.quoteIdentifier(objectField.Name), getPostgresType(objectField.Type))
+		if !objectField.Optional {
+			columnDefinition += " NOT NULL"
+		}
+		columnDefinitions = append(columnDefinitions, columnDefinition)
+	}
+	columnDefinitions = append(columnDefinitions, fmt.Sprintf("%s TEXT NOT NULL", pg.quoteIdentifier(*object.EndCustomerIDField)))
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", pg.qualifiedName(namespace, tableName), strings.Join(columnDefinitions, ", "))
+}
+
+// COPY expects JSON values to be serialized, everything else can be passed through as is
+func (pg PostgresImpl) convertRows(rows []data.Row, object views.Object, fieldMappings []views.FieldMapping, endCustomerID string) ([]data.Row, error) {
+	destinationRows := convertToDestinationRows(rows, object, fieldMappings, endCustomerID)
+	for _, row := range destinationRows {
+		for i, value := range row {
+			switch value.(type) {
+			case map[string]any, []any:
+				jsonValue, err := json.Marshal(value)
+				if err != nil {
+					return nil, errors.Wrap(err, "(connectors.PostgresImpl.convertRows)")
+				}
+				row[i] = string(jsonValue)
+			}
+		}
+	}
+
+	return destinationRows, nil
+}
+
+func (pg PostgresImpl) qualifiedName(namespace string, tableName string) string {
+	return fmt.Sprintf("%s.%s", pg.quoteIdentifier(namespace), pg.quoteIdentifier(tableName))
+}
+
+func (pg PostgresImpl) quoteIdentifier(identifier string) string {
+	return fmt.Sprintf("\"%s\"", strings.ReplaceAll(identifier, "\"", "\"\""))
+}
+
+func (pg PostgresImpl) quoteLiteral(literal string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(literal, "'", "''"))
+}
+
+func getPostgresType(fieldType data.FieldType) string {
+	switch fieldType {
+	case data.FieldTypeInteger:
+		return "BIGINT"
+	case data.FieldTypeNumber:
+		return "NUMERIC"
+	case data.FieldTypeBoolean:
+		return "BOOLEAN"
+	case data.FieldTypeTimestamp, data.FieldTypeDateTimeTz:
+		return "TIMESTAMPTZ"
+	case data.FieldTypeDateTimeNtz:
+		return "TIMESTAMP"
+	case data.FieldTypeJson:
+		return "JSONB"
+	case data.FieldTypeDate:
+		return "DATE"
+	case data.FieldTypeTimeTz:
+		return "TIMETZ"
+	case data.FieldTypeTimeNtz:
+		return "TIME"
+	default:
+		return "TEXT"
+	}
 }
@@ -0,0 +1,606 @@
+package connectors
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
+	"go.fabra.io/server/common/views"
+)
+
+// Change data capture syncs read from a logical replication slot with the built-in pgoutput plugin. The slot is read
+// through the SQL decoding functions over a normal connection, so the source only needs wal_level = logical, a user
+// with the REPLICATION attribute, and Postgres 12 or later to copy the slot.
+
+func getReplicationSlotName(syncID int64) string {
+	return fmt.Sprintf("fabra_sync_%d", syncID)
+}
+
+// Drops the replication slot and publication of a change data capture sync, so the source stops retaining WAL for it
+func DropReplicationSlot(ctx context.Context, client query.ConnectorClient, connectionType models.ConnectionType, syncID int64) error {
+	if connectionType != models.ConnectionTypePostgres {
+		return nil
+	}
+
+	// the copy read from by a run is normally dropped by the run, unless it couldn't connect to the source to drop it
+	slotName := getReplicationSlotName(syncID)
+	_, err := client.RunQuery(ctx, "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name IN ($1, $2)", slotName, getReadSlotName(slotName))
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DropReplicationSlot) dropping slot")
+	}
+
+	_, err = client.RunQuery(ctx, fmt.Sprintf("DROP PUBLICATION IF EXISTS %s", slotName))
+	if err != nil {
+		return errors.Wrap(err, "(connectors.DropReplicationSlot) dropping publication")
+	}
+
+	return nil
+}
+
+func (pg PostgresImpl) readChanges(
+	ctx context.Context,
+	sourceClient query.ConnectorClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+	errC chan<- error,
+) {
+	var cursorPosition *data.CursorState
+	var batchesRead int
+	var err error
+	if sync.CursorPosition == nil {
+		cursorPosition, batchesRead, err = pg.readSnapshot(ctx, sourceClient, sync, fieldMappings, rowsC, readOutputC)
+	} else {
+		cursorPosition, batchesRead, err = pg.readReplicationSlot(ctx, sourceClient, sync, fieldMappings, rowsC, readOutputC)
+	}
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	readOutputC <- ReadOutput{
+		CursorPosition: cursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
+	}
+
+	close(rowsC)
+	close(errC)
+}
+
+// Creates the replication slot and reads the whole table. Changes committed while the table is read are read again
+// from the slot by the next run, which only rewrites the latest version of those rows.
+func (pg PostgresImpl) readSnapshot(
+	ctx context.Context,
+	sourceClient query.ConnectorClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+) (*data.CursorState, int, error) {
+	startLSN, err := pg.createReplicationSlot(ctx, sourceClient, sync)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readSnapshot)")
+	}
+
+	readQuery, readArgs := sqlbuilder.Select(sqlbuilder.DialectPostgres, pg.getSelectColumns(fieldMappings)...).
+		From(*sync.Namespace, *sync.TableName).
+		Build()
+	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery, readArgs...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readSnapshot) running query")
+	}
+
+	batchesRead, err := readSnapshotRows(ctx, iterator, rowsC, readOutputC)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readSnapshot)")
+	}
+
+	cursorPosition, err := data.NewCursorState(data.FieldTypeString, startLSN)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readSnapshot)")
+	}
+
+	return cursorPosition, batchesRead, nil
+}
+
+// Returns the position the slot starts reading changes from
+func (pg PostgresImpl) createReplicationSlot(ctx context.Context, sourceClient query.ConnectorClient, sync views.Sync) (string, error) {
+	slotName := getReplicationSlotName(sync.ID)
+
+	// a previous attempt may have created the publication and slot before failing
+	results, err := sourceClient.RunQuery(ctx, "SELECT pubname FROM pg_publication WHERE pubname = $1", slotName)
+	if err != nil {
+		return "", errors.Wrap(err, "(connectors.PostgresImpl.createReplicationSlot) loading publication")
+	}
+	if len(results.Data) == 0 {
+		// once updates are published, Postgres rejects updates to tables that have no way to identify the updated row
+		results, err = sourceClient.RunQuery(
+			ctx,
+			`SELECT 1 FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = $1 AND c.relname = $2 AND (c.relreplident IN ('f', 'i')
+				OR (c.relreplident = 'd' AND EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary)))`,
+			*sync.Namespace, *sync.TableName,
+		)
+		if err != nil {
+			return "", errors.Wrap(err, "(connectors.PostgresImpl.createReplicationSlot) loading replica identity")
+		}
+		if len(results.Data) == 0 {
+			return "", errors.NewCustomerVisibleError(fmt.Sprintf("table %s.%s must have a primary key or a replica identity to use change data capture", *sync.Namespace, *sync.TableName))
+		}
+
+		// truncates can't be applied to a single end customer's rows, so they aren't published
+		_, err = sourceClient.RunQuery(ctx, fmt.Sprintf(
+			"CREATE PUBLICATION %s FOR TABLE %s WITH (publish = 'insert, update, delete')",
+			slotName, pg.qualifiedName(*sync.Namespace, *sync.TableName),
+		))
+		if err != nil {
+			return "", errors.Wrap(err, "(connectors.PostgresImpl.createReplicationSlot) creating publication")
+		}
+	}
+
+	results, err = sourceClient.RunQuery(ctx, "SELECT confirmed_flush_lsn::text FROM pg_replication_slots WHERE slot_name = $1", slotName)
+	if err != nil {
+		return "", errors.Wrap(err, "(connectors.PostgresImpl.createReplicationSlot) loading slot")
+	}
+	if len(results.Data) == 0 {
+		results, err = sourceClient.RunQuery(ctx, "SELECT lsn::text FROM pg_create_logical_replication_slot($1, 'pgoutput')", slotName)
+		if err != nil {
+			return "", errors.Wrap(err, "(connectors.PostgresImpl.createReplicationSlot) creating slot")
+		}
+	}
+
+	return fmt.Sprintf("%v", results.Data[0][0]), nil
+}
+
+// Reads the changes committed after the cursor. The changes are merged into the latest version of each changed row,
+// and sent as a batch whenever enough rows have changed, so memory use is bounded however far behind the slot is.
+func (pg PostgresImpl) readReplicationSlot(
+	ctx context.Context,
+	sourceClient query.ConnectorClient,
+	sync views.Sync,
+	fieldMappings []views.FieldMapping,
+	rowsC chan<- RowBatch,
+	readOutputC chan<- ReadOutput,
+) (*data.CursorState, int, error) {
+	slotName := getReplicationSlotName(sync.ID)
+
+	// the cursor is only stored once every change before it was written, so the slot can release those changes
+	results, err := sourceClient.RunQuery(ctx, "SELECT confirmed_flush_lsn < $2::pg_lsn FROM pg_replication_slots WHERE slot_name = $1", slotName, sync.CursorPosition.Value)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot) loading slot")
+	}
+	if len(results.Data) == 0 {
+		return nil, 0, errors.NewCustomerVisibleError(fmt.Sprintf("replication slot %s no longer exists, so changes since the last sync were lost. Recreate the sync to read the table again.", slotName))
+	}
+	if behind, ok := results.Data[0][0].(bool); ok && behind {
+		_, err = sourceClient.RunQuery(ctx, "SELECT pg_replication_slot_advance($1, $2::pg_lsn)", slotName, sync.CursorPosition.Value)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot) advancing slot")
+		}
+	}
+
+	// changes committed while the slot is read are left for the next run, so a busy source can't keep the run going
+	results, err = sourceClient.RunQuery(ctx, "SELECT pg_current_wal_lsn()::text")
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot) loading current position")
+	}
+	endLSN := fmt.Sprintf("%v", results.Data[0][0])
+
+	// changes are consumed from a copy of the slot, so they stay in the slot until the cursor is stored and a failed
+	// run reads them again
+	readSlotName := getReadSlotName(slotName)
+	err = pg.dropReadSlot(ctx, sourceClient, readSlotName)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot)")
+	}
+
+	_, err = sourceClient.RunQuery(ctx, "SELECT pg_copy_logical_replication_slot($1, $2)", slotName, readSlotName)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot) copying slot")
+	}
+
+	// use a separate context for cleanup so it won't get cancelled
+	defer pg.dropReadSlot(context.Background(), sourceClient, readSlotName)
+
+	decoder := newPgoutputDecoder(sync, fieldMappings)
+	cursorPosition := sync.CursorPosition
+	batchesRead := 0
+	for {
+		changesRead, err := pg.readReadSlotBatch(ctx, sourceClient, readSlotName, endLSN, slotName, decoder)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot)")
+		}
+
+		// only whole transactions are read, so the end of the last one is where the next run starts
+		if decoder.lastCommitLSN != 0 {
+			cursorPosition, err = data.NewCursorState(data.FieldTypeString, formatLSN(decoder.lastCommitLSN))
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "(connectors.PostgresImpl.readReplicationSlot)")
+			}
+		}
+
+		if changesRead == 0 || decoder.changes.full() {
+			batchesRead = sendChanges(decoder.changes, cursorPosition, batchesRead, rowsC, readOutputC)
+		}
+		if changesRead == 0 {
+			break
+		}
+	}
+
+	return cursorPosition, batchesRead, nil
+}
+
+func getReadSlotName(slotName string) string {
+	return fmt.Sprintf("%s_read", slotName)
+}
+
+// Consumes a batch of changes from the copy of the slot and returns how many were read. Consuming confirms the changes
+// on the copy, so the next batch starts after them.
+func (pg PostgresImpl) readReadSlotBatch(ctx context.Context, sourceClient query.ConnectorClient, readSlotName string, endLSN string, publicationName string, decoder *pgoutputDecoder) (int, error) {
+	iterator, err := sourceClient.GetQueryIterator(
+		ctx,
+		"SELECT data FROM pg_logical_slot_get_binary_changes($1, $2::pg_lsn, $3, 'proto_version', '1', 'publication_names', $4)",
+		readSlotName, endLSN, READ_BATCH_SIZE, publicationName,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.PostgresImpl.readReadSlotBatch) reading changes")
+	}
+
+	changesRead := 0
+	for {
+		row, err := iterator.Next(ctx)
+		if err == data.ErrDone {
+			break
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.PostgresImpl.readReadSlotBatch) reading change")
+		}
+
+		var message []byte
+		switch value := row[0].(type) {
+		case string:
+			message = []byte(value)
+		case []byte:
+			message = value
+		default:
+			return 0, errors.Newf("(connectors.PostgresImpl.readReadSlotBatch) unexpected change type %T", row[0])
+		}
+
+		err = decoder.decode(message)
+		if err != nil {
+			return 0, errors.Wrap(err, "(connectors.PostgresImpl.readReadSlotBatch) decoding change")
+		}
+		changesRead++
+	}
+
+	return changesRead, nil
+}
+
+func (pg PostgresImpl) dropReadSlot(ctx context.Context, sourceClient query.ConnectorClient, readSlotName string) error {
+	_, err := sourceClient.RunQuery(ctx, "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = $1", readSlotName)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.PostgresImpl.dropReadSlot)")
+	}
+
+	return nil
+}
+
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
+
+type pgRelation struct {
+	namespace string
+	name      string
+
+	// the position of each field mapping's column in the relation's tuples
+	positions []int
+}
+
+type pgColumnValue struct {
+	kind  byte // 'n' for null, 'u' for an unchanged TOAST value, and 't' for text
+	value string
+}
+
+// Decodes pgoutput protocol version 1 messages into changes to the sync's table
+type pgoutputDecoder struct {
+	sync          views.Sync
+	fieldMappings []views.FieldMapping
+	primaryKeyPos int
+	relations     map[uint32]pgRelation
+	changes       *changeSet
+	lastCommitLSN uint64
+}
+
+func newPgoutputDecoder(sync views.Sync, fieldMappings []views.FieldMapping) *pgoutputDecoder {
+	decoder := pgoutputDecoder{
+		sync:          sync,
+		fieldMappings: fieldMappings,
+		primaryKeyPos: -1,
+		relations:     map[uint32]pgRelation{},
+		changes:       newChangeSet(),
+	}
+
+	for i, fieldMapping := range fieldMappings {
+		if sync.SourcePrimaryKey != nil && fieldMapping.SourceFieldName == *sync.SourcePrimaryKey {
+			decoder.primaryKeyPos = i
+		}
+	}
+
+	return &decoder
+}
+
+func (d *pgoutputDecoder) decode(message []byte) error {
+	if d.primaryKeyPos < 0 {
+		return errors.NewCustomerVisibleError("the primary key must be mapped to use change data capture")
+	}
+
+	reader := pgoutputReader{data: message}
+	switch reader.byte() {
+	case 'R':
+		relationID := reader.uint32()
+		relation := pgRelation{namespace: reader.string(), name: reader.string()}
+		reader.byte() // replica identity
+
+		columnPositions := map[string]int{}
+		numColumns := int(reader.uint16())
+		for i := 0; i < numColumns; i++ {
+			reader.byte() // flags
+			columnPositions[reader.string()] = i
+			reader.uint32() // type
+			reader.uint32() // type modifier
+		}
+
+		for _, fieldMapping := range d.fieldMappings {
+			position, ok := columnPositions[fieldMapping.SourceFieldName]
+			if !ok {
+				return errors.NewCustomerVisibleError(fmt.Sprintf("column %s is no longer in table %s.%s", fieldMapping.SourceFieldName, relation.namespace, relation.name))
+			}
+			relation.positions = append(relation.positions, position)
+		}
+
+		d.relations[relationID] = relation
+	case 'I':
+		relation, err := d.relation(reader.uint32())
+		if err != nil {
+			return err
+		}
+
+		reader.byte() // new tuple
+		err = d.upsert(relation, reader.tuple(), nil)
+		if err != nil {
+			return err
+		}
+	case 'U':
+		relation, err := d.relation(reader.uint32())
+		if err != nil {
+			return err
+		}
+
+		// the old tuple is only sent if the key changed or the table has REPLICA IDENTITY FULL
+		var oldTuple []pgColumnValue
+		kind := reader.byte()
+		if kind == 'K' || kind == 'O' {
+			oldTuple = reader.tuple()
+			reader.byte() // new tuple
+		}
+		newTuple := reader.tuple()
+		if reader.err != nil {
+			return reader.err
+		}
+
+		if oldTuple != nil {
+			oldKey := tupleColumn(oldTuple, relation.positions[d.primaryKeyPos])
+			if oldKey.kind == 't' && oldKey.value != tupleColumn(newTuple, relation.positions[d.primaryKeyPos]).value {
+				err = d.delete(oldKey)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = d.upsert(relation, newTuple, oldTuple)
+		if err != nil {
+			return err
+		}
+	case 'D':
+		relation, err := d.relation(reader.uint32())
+		if err != nil {
+			return err
+		}
+
+		reader.byte() // old tuple
+		oldTuple := reader.tuple()
+		if reader.err != nil {
+			return reader.err
+		}
+
+		err = d.delete(tupleColumn(oldTuple, relation.positions[d.primaryKeyPos]))
+		if err != nil {
+			return err
+		}
+	case 'C':
+		reader.byte()   // flags
+		reader.uint64() // commit LSN
+		commitEndLSN := reader.uint64()
+		if reader.err == nil {
+			d.lastCommitLSN = commitEndLSN
+		}
+	default:
+		// begin, origin and type messages don't change any rows
+	}
+
+	return reader.err
+}
+
+func (d *pgoutputDecoder) relation(relationID uint32) (*pgRelation, error) {
+	relation, ok := d.relations[relationID]
+	if !ok {
+		return nil, errors.Newf("(connectors.pgoutputDecoder.relation) change to unknown relation %d", relationID)
+	}
+
+	return &relation, nil
+}
+
+func (d *pgoutputDecoder) upsert(relation *pgRelation, tuple []pgColumnValue, oldTuple []pgColumnValue) error {
+	row := make(data.Row, len(d.fieldMappings))
+	for i, fieldMapping := range d.fieldMappings {
+		position := relation.positions[i]
+		if position >= len(tuple) {
+			return errors.Newf("(connectors.pgoutputDecoder.upsert) tuple is missing column %s", fieldMapping.SourceFieldName)
+		}
+
+		column := tuple[position]
+		if column.kind == 'u' {
+			// large values that didn't change are only sent in the old tuple, and only with REPLICA IDENTITY FULL
+			if tupleColumn(oldTuple, position).kind != 't' {
+				return errors.NewCustomerVisibleError(fmt.Sprintf(
+					"could not read unchanged value of column %s. Set REPLICA IDENTITY FULL on table %s.%s to read it.",
+					fieldMapping.SourceFieldName, relation.namespace, relation.name,
+				))
+			}
+			column = oldTuple[position]
+		}
+		if column.kind == 'n' {
+			continue
+		}
+
+		value, err := convertPgoutputValue(column.value, fieldMapping.SourceFieldType)
+		if err != nil {
+			return errors.NewCustomerVisibleError(fmt.Sprintf("could not read value of column %s: %s", fieldMapping.SourceFieldName, err.Error()))
+		}
+		row[i] = value
+	}
+
+	d.changes.set(tuple[relation.positions[d.primaryKeyPos]].value, rowChange{row: row})
+	return nil
+}
+
+func (d *pgoutputDecoder) delete(key pgColumnValue) error {
+	fieldMapping := d.fieldMappings[d.primaryKeyPos]
+	value, err := convertPgoutputValue(key.value, fieldMapping.SourceFieldType)
+	if err != nil {
+		return errors.NewCustomerVisibleError(fmt.Sprintf("could not read value of column %s: %s", fieldMapping.SourceFieldName, err.Error()))
+	}
+
+	d.changes.set(key.value, rowChange{deleted: true, row: newDeletedRow(len(d.fieldMappings), d.primaryKeyPos, value)})
+	return nil
+}
+
+// Columns missing from a tuple are treated as null
+func tupleColumn(tuple []pgColumnValue, position int) pgColumnValue {
+	if position >= len(tuple) {
+		return pgColumnValue{kind: 'n'}
+	}
+
+	return tuple[position]
+}
+
+// Converts values from their Postgres text format to the same types the Postgres client reads them as
+func convertPgoutputValue(value string, fieldType data.FieldType) (any, error) {
+	switch fieldType {
+	case data.FieldTypeInteger:
+		return strconv.ParseInt(value, 10, 64)
+	case data.FieldTypeNumber:
+		return strconv.ParseFloat(value, 64)
+	case data.FieldTypeBoolean:
+		return value == "t", nil
+	case data.FieldTypeDate:
+		return time.Parse("2006-01-02", value)
+	case data.FieldTypeDateTimeTz, data.FieldTypeTimestamp:
+		// offsets are printed without minutes unless they have them
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999Z07", "2006-01-02 15:04:05.999999999Z07:00"} {
+			timestamp, err := time.Parse(layout, value)
+			if err == nil {
+				return timestamp.Format(data.TIMESTAMP_TZ_FORMAT), nil
+			}
+		}
+		return nil, errors.Newf("invalid timestamp %s", value)
+	case data.FieldTypeDateTimeNtz:
+		timestamp, err := time.Parse("2006-01-02 15:04:05.999999999", value)
+		if err != nil {
+			return nil, err
+		}
+		return timestamp.Format(data.TIMESTAMP_NTZ_FORMAT), nil
+	case data.FieldTypeJson:
+		var jsonValue any
+		err := json.Unmarshal([]byte(value), &jsonValue)
+		if err != nil {
+			return nil, err
+		}
+		return jsonValue, nil
+	default:
+		return value, nil
+	}
+}
+
+// Reads the big-endian fields of a pgoutput message. Reading past the end of the message sets err and returns zero
+// values, so a message can be read in full before checking err.
+type pgoutputReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *pgoutputReader) next(length int) []byte {
+	if r.err != nil || r.pos+length > len(r.data) {
+		r.err = errors.New("(connectors.pgoutputReader.next) message is too short")
+		return make([]byte, length)
+	}
+
+	value := r.data[r.pos : r.pos+length]
+	r.pos += length
+	return value
+}
+
+func (r *pgoutputReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *pgoutputReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *pgoutputReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *pgoutputReader) uint64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *pgoutputReader) string() string {
+	for end := r.pos; end < len(r.data); end++ {
+		if r.data[end] == 0 {
+			value := string(r.data[r.pos:end])
+			r.pos = end + 1
+			return value
+		}
+	}
+
+	r.err = errors.New("(connectors.pgoutputReader.string) string is not terminated")
+	return ""
+}
+
+func (r *pgoutputReader) tuple() []pgColumnValue {
+	numColumns := int(r.uint16())
+	tuple := make([]pgColumnValue, numColumns)
+	for i := 0; i < numColumns && r.err == nil; i++ {
+		tuple[i].kind = r.byte()
+		if tuple[i].kind == 't' {
+			tuple[i].value = string(r.next(int(r.uint32())))
+		}
+	}
+
+	return tuple
+}
@@ -0,0 +1,380 @@
+package connectors_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/mock/gomock"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/input"
+	mock_query "go.fabra.io/server/common/mocks"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PostgresConnector", func() {
+	var (
+		sourceConnection      views.FullConnection
+		destinationConnection views.FullConnection
+		sync                  views.Sync
+		fieldMappings         []views.FieldMapping
+		object                views.Object
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, sourceConn := test.CreateSource(db, org.ID, endCustomerID)
+		sourceConnection = views.ConvertFullConnection(sourceConn)
+		destination, destConn := test.CreateDestination(db, org.ID)
+		destinationConnection = views.ConvertFullConnection(destConn)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeFullOverwrite)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+			{Name: "json", Type: data.FieldTypeJson, Optional: true},
+		})
+		object = views.ConvertObject(objectModel, objectFields)
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeFullOverwrite))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_name", SourceFieldType: data.FieldTypeString, DestinationFieldId: objectFields[1].ID},
+			{SourceFieldName: "source_json", SourceFieldType: data.FieldTypeJson, DestinationFieldId: objectFields[2].ID},
+		}), objectFields)
+	})
+
+	Describe("Read", func() {
+		It("reads the latest version of each row changed since the cursor", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockConnectorClient(ctrl)
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "1/10"}
+			slotName := fmt.Sprintf("fabra_sync_%d", sync.ID)
+
+			changes := []data.Row{
+				{pgoutputMessage('R', uint32(1), "namespace", "table", byte('d'), uint16(3),
+					byte(1), "source_id", uint32(20), int32(-1),
+					byte(0), "source_name", uint32(25), int32(-1),
+					byte(0), "source_json", uint32(3802), int32(-1),
+				)},
+				{pgoutputMessage('B', uint64(0x100000018), uint64(0), uint32(500))},
+				{pgoutputMessage('I', uint32(1), byte('N'), pgoutputTuple("1", "first", "{\"hello\": 123}"))},
+				{pgoutputMessage('I', uint32(1), byte('N'), pgoutputTuple("2", "second", nil))},
+				{pgoutputMessage('U', uint32(1), byte('N'), pgoutputTuple("1", "updated", nil))},
+				{pgoutputMessage('D', uint32(1), byte('K'), pgoutputTuple("2", nil, nil))},
+				{pgoutputMessage('C', byte(0), uint64(0x100000018), uint64(0x100000020), uint64(0))},
+			}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT confirmed_flush_lsn < $2::pg_lsn FROM pg_replication_slots WHERE slot_name = $1", slotName, "1/10").
+				Return(&data.QueryResults{Data: []data.Row{{true}}}, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT pg_replication_slot_advance($1, $2::pg_lsn)", slotName, "1/10").Return(&data.QueryResults{}, nil)
+			expectReadSlot(client, slotName, [][]data.Row{changes})
+
+			connector := connectors.NewPostgresConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("1/20"))
+			Expect(resultRows.Rows).To(Equal([]data.Row{{int64(1), "updated", nil}, {int64(2), nil, nil}}))
+			Expect(resultRows.Operations).To(Equal([]data.RowOperation{data.RowOperationUpsert, data.RowOperationDelete}))
+			Expect(numBatches).To(Equal(1))
+		})
+
+		It("drains the slot and merges changes to the same row across batches", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockConnectorClient(ctrl)
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "1/10"}
+			slotName := fmt.Sprintf("fabra_sync_%d", sync.ID)
+
+			relation := pgoutputMessage('R', uint32(1), "namespace", "table", byte('d'), uint16(3),
+				byte(1), "source_id", uint32(20), int32(-1),
+				byte(0), "source_name", uint32(25), int32(-1),
+				byte(0), "source_json", uint32(3802), int32(-1),
+			)
+			firstBatch := []data.Row{
+				{relation},
+				{pgoutputMessage('B', uint64(0x100000018), uint64(0), uint32(500))},
+				{pgoutputMessage('I', uint32(1), byte('N'), pgoutputTuple("1", "first", nil))},
+				{pgoutputMessage('I', uint32(1), byte('N'), pgoutputTuple("2", "second", nil))},
+				{pgoutputMessage('C', byte(0), uint64(0x100000018), uint64(0x100000020), uint64(0))},
+			}
+			secondBatch := []data.Row{
+				{relation},
+				{pgoutputMessage('B', uint64(0x100000028), uint64(0), uint32(501))},
+				{pgoutputMessage('D', uint32(1), byte('K'), pgoutputTuple("1", nil, nil))},
+				{pgoutputMessage('U', uint32(1), byte('N'), pgoutputTuple("2", "updated", nil))},
+				{pgoutputMessage('C', byte(0), uint64(0x100000028), uint64(0x100000030), uint64(0))},
+			}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT confirmed_flush_lsn < $2::pg_lsn FROM pg_replication_slots WHERE slot_name = $1", slotName, "1/10").
+				Return(&data.QueryResults{Data: []data.Row{{false}}}, nil)
+			expectReadSlot(client, slotName, [][]data.Row{firstBatch, secondBatch})
+
+			connector := connectors.NewPostgresConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("1/30"))
+			Expect(resultRows.Rows).To(Equal([]data.Row{{int64(1), nil, nil}, {int64(2), "updated", nil}}))
+			Expect(resultRows.Operations).To(Equal([]data.RowOperation{data.RowOperationDelete, data.RowOperationUpsert}))
+			Expect(numBatches).To(Equal(1))
+		})
+
+		It("sends a batch whenever enough rows have changed", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockConnectorClient(ctrl)
+			defer ctrl.Finish()
+
+			primaryKey := "source_id"
+			sync.SyncMode = models.SyncModeChangeDataCapture
+			sync.SourcePrimaryKey = &primaryKey
+			sync.CursorPosition = &data.CursorState{Version: data.CURSOR_STATE_VERSION, FieldType: data.FieldTypeString, Value: "1/10"}
+			slotName := fmt.Sprintf("fabra_sync_%d", sync.ID)
+
+			relation := pgoutputMessage('R', uint32(1), "namespace", "table", byte('d'), uint16(3),
+				byte(1), "source_id", uint32(20), int32(-1),
+				byte(0), "source_name", uint32(25), int32(-1),
+				byte(0), "source_json", uint32(3802), int32(-1),
+			)
+			firstBatch := []data.Row{{relation}, {pgoutputMessage('B', uint64(0x100000018), uint64(0), uint32(500))}}
+			for i := 1; i <= connectors.READ_BATCH_SIZE; i++ {
+				firstBatch = append(firstBatch, data.Row{pgoutputMessage('I', uint32(1), byte('N'), pgoutputTuple(fmt.Sprint(i), "first", nil))})
+			}
+			firstBatch = append(firstBatch, data.Row{pgoutputMessage('C', byte(0), uint64(0x100000018), uint64(0x100000020), uint64(0))})
+			secondBatch := []data.Row{
+				{relation},
+				{pgoutputMessage('B', uint64(0x100000028), uint64(0), uint32(501))},
+				{pgoutputMessage('U', uint32(1), byte('N'), pgoutputTuple("1", "updated", nil))},
+				{pgoutputMessage('C', byte(0), uint64(0x100000028), uint64(0x100000030), uint64(0))},
+			}
+
+			queryService.EXPECT().GetClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), "SELECT confirmed_flush_lsn < $2::pg_lsn FROM pg_replication_slots WHERE slot_name = $1", slotName, "1/10").
+				Return(&data.QueryResults{Data: []data.Row{{false}}}, nil)
+			expectReadSlot(client, slotName, [][]data.Row{firstBatch, secondBatch})
+
+			connector := connectors.NewPostgresConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			readOutputC := make(chan connectors.ReadOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(readOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Read(context.TODO(), sourceConnection, sync, fieldMappings, rowsC, readOutputC, errC)
+			}()
+			readOutput, resultRows, numBatches, err := waitForReadBatch(rowsC, readOutputC, errC)
+
+			// the second batch is written after the first, so the update to the first row replaces its insert
+			Expect(err).To(BeNil())
+			Expect(readOutput.CursorPosition.Value).To(Equal("1/30"))
+			Expect(numBatches).To(Equal(2))
+			Expect(resultRows.Rows).To(HaveLen(connectors.READ_BATCH_SIZE + 1))
+			Expect(resultRows.Rows[connectors.READ_BATCH_SIZE]).To(Equal(data.Row{int64(1), "updated", nil}))
+		})
+	})
+
+	Describe("Write", func() {
+		It("copies rows through a staging table and replaces the end customer's rows", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			rows := []data.Row{
+				{1, "first", map[string]any{"hello": 123}},
+				{2, "second", nil},
+			}
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE UNLOGGED TABLE \"namespace\".\"fabra_staging_", "\" (LIKE \"namespace\".\"table\" INCLUDING DEFAULTS)"}).Return(nil, nil)
+			client.EXPECT().LoadData(
+				gomock.Any(),
+				"namespace",
+				MockMergeQuery{"fabra_staging_", ""},
+				[]string{"id", "name", "json", "end_customer_id"},
+				[]data.Row{
+					{1, "first", "{\"hello\":123}", "abc123"},
+					{2, "second", nil, "abc123"},
+				},
+			).Return(nil)
+			client.EXPECT().ExecuteInTransaction(
+				gomock.Any(),
+				"DELETE FROM \"namespace\".\"table\" WHERE \"end_customer_id\" = 'abc123'",
+				MockMergeQuery{"INSERT INTO \"namespace\".\"table\" (\"id\", \"name\", \"json\", \"end_customer_id\") SELECT \"id\", \"name\", \"json\", \"end_customer_id\" FROM \"namespace\".\"fabra_staging_", "\""},
+			).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS \"namespace\".\"fabra_staging_", "\""}).Return(nil, nil)
+
+			connector := connectors.NewPostgresConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch(rows)
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+		})
+
+		It("requires a primary key for incremental updates", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			sync.SyncMode = models.SyncModeIncrementalUpdate
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+
+			connector := connectors.NewPostgresConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			_, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("fails instead of dropping deleted rows", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockDatabaseClient(ctrl)
+			defer ctrl.Finish()
+
+			queryService.EXPECT().GetDatabaseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE UNLOGGED TABLE \"namespace\".\"fabra_staging_", "\" (LIKE \"namespace\".\"table\" INCLUDING DEFAULTS)"}).Return(nil, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS \"namespace\".\"fabra_staging_", "\""}).Return(nil, nil)
+
+			connector := connectors.NewPostgresConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			var rowBatch connectors.RowBatch
+			rowBatch.Add(data.Row{1, "first", nil}, data.RowOperationUpsert)
+			rowBatch.Add(data.Row{2, nil, nil}, data.RowOperationDelete)
+			rowsC <- rowBatch
+
+			_, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("deletes can't be applied to this destination"))
+		})
+	})
+})
+
+// Expects the changes to be read from a copy of the slot, a batch at a time until a read returns no changes
+func expectReadSlot(client *mock_query.MockConnectorClient, slotName string, batches [][]data.Row) {
+	readSlotName := slotName + "_read"
+	dropReadSlotQuery := "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = $1"
+	readQuery := "SELECT data FROM pg_logical_slot_get_binary_changes($1, $2::pg_lsn, $3, 'proto_version', '1', 'publication_names', $4)"
+	schema := data.Schema{{Name: "data", Type: data.FieldTypeString}}
+
+	client.EXPECT().RunQuery(gomock.Any(), "SELECT pg_current_wal_lsn()::text").Return(&data.QueryResults{Data: []data.Row{{"1/40"}}}, nil)
+	client.EXPECT().RunQuery(gomock.Any(), dropReadSlotQuery, readSlotName).Return(&data.QueryResults{}, nil).Times(2)
+	client.EXPECT().RunQuery(gomock.Any(), "SELECT pg_copy_logical_replication_slot($1, $2)", slotName, readSlotName).Return(&data.QueryResults{}, nil)
+
+	var calls []*gomock.Call
+	for _, batch := range append(batches, []data.Row{}) {
+		calls = append(calls, client.EXPECT().GetQueryIterator(gomock.Any(), readQuery, readSlotName, "1/40", connectors.READ_BATCH_SIZE, slotName).
+			Return(test.NewMockIterator(batch, schema), nil))
+	}
+	gomock.InOrder(calls...)
+}
+
+// Encodes the fields of a pgoutput message the way Postgres sends them: big-endian integers and null-terminated strings
+func pgoutputMessage(messageType byte, fields ...any) string {
+	message := []byte{messageType}
+	for _, field := range fields {
+		switch value := field.(type) {
+		case string:
+			message = append(append(message, value...), 0)
+		case []byte:
+			message = append(message, value...)
+		default:
+			buffer := new(bytes.Buffer)
+			binary.Write(buffer, binary.BigEndian, value)
+			message = append(message, buffer.Bytes()...)
+		}
+	}
+
+	return string(message)
+}
+
+// Encodes tuple data with each value in text format, or null
+func pgoutputTuple(values ...any) []byte {
+	tuple := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+	for _, value := range values {
+		if value == nil {
+			tuple = append(tuple, 'n')
+			continue
+		}
+
+		text := value.(string)
+		tuple = binary.BigEndian.AppendUint32(append(tuple, 't'), uint32(len(text)))
+		tuple = append(tuple, text...)
+	}
+
+	return tuple
+}
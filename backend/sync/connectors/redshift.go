@@ -3,12 +3,15 @@ package connectors
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/google/uuid"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
@@ -27,7 +30,7 @@ func (rs RedshiftImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -39,17 +42,28 @@ func (rs RedshiftImpl) Read(
 		return
 	}
 
-	readQuery := rs.getReadQuery(connectionModel, sync, fieldMappings)
+	if sync.PartitionField != nil {
+		readPartitioned(ctx, sourceClient, sqlbuilder.DialectRedshift, sourceConnection, sync, fieldMappings, rs.getSelectColumns(fieldMappings), nil, rowsC, readOutputC, errC)
+		return
+	}
+
+	readQuery, readArgs, err := rs.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- err
+		return
+	}
 
-	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery)
+	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery, readArgs...)
 	if err != nil {
 		errC <- err
 		return
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
+	cursorTracker := newCursorTracker(sync, iterator.Schema())
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -61,24 +75,52 @@ func (rs RedshiftImpl) Read(
 			}
 		}
 
+		alreadySynced, err := cursorTracker.Track(row)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, row)
-		lastRow = row
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	newCursorPosition, err := cursorTracker.CursorPosition()
+	if err != nil {
+		errC <- err
+		return
 	}
 
-	newCursorPosition := rs.getNewCursorPosition(lastRow, iterator.Schema(), sync)
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
@@ -86,66 +128,55 @@ func (rs RedshiftImpl) Read(
 }
 
 // TODO: only read 10,000 rows at once or something
-func (rs RedshiftImpl) getReadQuery(sourceConnection *models.Connection, sync views.Sync, fieldMappings []views.FieldMapping) string {
-	var queryString string
+func (rs RedshiftImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (string, []any, error) {
+	var builder *sqlbuilder.SelectBuilder
 	if sync.CustomJoin != nil {
-		queryString = *sync.CustomJoin
+		builder = sqlbuilder.Select(sqlbuilder.DialectRedshift).FromQuery(*sync.CustomJoin)
 	} else {
-		selectString := rs.getSelectString(fieldMappings)
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, *sync.Namespace, *sync.TableName)
+		builder = sqlbuilder.Select(sqlbuilder.DialectRedshift, rs.getSelectColumns(fieldMappings)...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	err := addFilterCondition(builder, sync, nil)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(connectors.RedshiftImpl.getReadQuery)")
 	}
 
 	if sync.SyncMode.UsesCursor() {
-		if sync.CursorPosition != nil {
-			// TODO: allow choosing other operators (rows smaller than current cursor)
-			// order by cursor field to simplify
-			return fmt.Sprintf("%s WHERE %s > %s ORDER BY %s ASC;", queryString, *sync.SourceCursorField, *sync.CursorPosition, *sync.SourceCursorField)
-		} else {
-			return fmt.Sprintf("%s ORDER BY %s ASC;", queryString, *sync.SourceCursorField)
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "(connectors.RedshiftImpl.getReadQuery)")
 		}
-	} else {
-		return fmt.Sprintf("%s;", queryString)
-	}
-}
 
-func (rs RedshiftImpl) getSelectString(fieldMappings []views.FieldMapping) string {
-	fields := []string{}
-	for _, fieldMapping := range fieldMappings {
-		fields = append(fields, fieldMapping.SourceFieldName)
+		addCursorCondition(builder, sync, condition)
 	}
 
-	return strings.Join(fields, ",")
+	queryString, args := builder.Build()
+	return queryString, args, nil
 }
 
-func (rs RedshiftImpl) getNewCursorPosition(lastRow data.Row, schema data.Schema, sync views.Sync) *string {
-	if sync.SourceCursorField == nil {
-		return nil
-	}
+func (rs RedshiftImpl) ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error) {
+	connectionModel := views.ConvertConnectionView(sourceConnection)
 
-	if lastRow == nil {
-		return nil
+	sourceClient, err := rs.queryService.GetClient(ctx, connectionModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.RedshiftImpl.ReadPrimaryKeys)")
 	}
 
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range schema {
-		if schema[i].Name == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = schema[i].Type
-		}
+	iterator, err := readPrimaryKeys(ctx, sourceClient, sqlbuilder.DialectRedshift, sync, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.RedshiftImpl.ReadPrimaryKeys)")
 	}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger, data.FieldTypeNumber:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+	return iterator, nil
+}
+
+func (rs RedshiftImpl) getSelectColumns(fieldMappings []views.FieldMapping) []string {
+	columns := []string{}
+	for _, fieldMapping := range fieldMappings {
+		columns = append(columns, fieldMapping.SourceFieldName)
 	}
 
-	return &newCursorPos
+	return columns
 }
 
 func (rs RedshiftImpl) Write(
@@ -155,9 +186,191 @@ func (rs RedshiftImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
-	errC <- errors.New("redshift destination not implemented")
+	connectionModel := views.ConvertConnectionView(destinationConnection)
+
+	destClient, err := rs.queryService.GetWarehouseClient(ctx, connectionModel)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write) getting client")
+		return
+	}
+
+	if destinationOptions.StagingBucket == "" {
+		errC <- errors.NewCustomerVisibleError("Redshift destination must have a staging bucket")
+		return
+	}
+
+	if sync.SyncMode.UpdatesByPrimaryKey() && object.PrimaryKey == nil {
+		errC <- errors.NewCustomerVisibleError("primary key must be set on the object to use incremental update")
+		return
+	}
+
+	namespace := *object.Namespace
+	tableName := getDestinationTableName(object, sync.EndCustomerID)
+	columns := getDestinationColumns(object)
+
+	if object.TargetType == models.TargetTypeTablePerCustomer {
+		_, err = destClient.RunQuery(ctx, rs.getCreateTableQuery(namespace, tableName, object))
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write) creating table")
+			return
+		}
+	}
+
+	// rows are loaded into a staging table first so the destination table is only modified in a single transaction
+	stagingTableName := fmt.Sprintf("fabra_staging_%s", strings.ReplaceAll(uuid.New().String(), "-", ""))
+	_, err = destClient.RunQuery(ctx, fmt.Sprintf("CREATE TABLE %s.%s (LIKE %s.%s)", namespace, stagingTableName, namespace, tableName))
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write) creating staging table")
+		return
+	}
+
+	// use a separate context for cleanup so it won't get cancelled
+	defer destClient.RunQuery(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", namespace, stagingTableName))
+
+	// all batches are staged under the same prefix so they can be loaded with a single COPY
+	objectPrefix := uuid.New().String()
+	stagingOptions := query.StagingOptions{Bucket: destinationOptions.StagingBucket, Object: objectPrefix}
+	convertRow := newDestinationRowConverter(object, fieldMappings, sync.EndCustomerID)
+	batchNum := 0
+	rowsWritten := 0
+	for {
+		rowBatch, more := <-rowsC
+		if !more {
+			break
+		}
+
+		rows, err := rowBatch.UpsertsOnly()
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write)")
+			return
+		}
+
+		batchStagingOptions := query.StagingOptions{Bucket: destinationOptions.StagingBucket, Object: fmt.Sprintf("%s/%d.csv", objectPrefix, batchNum)}
+		err = destClient.StageData(ctx, func(w io.Writer) error { return writeCsvData(w, rows, convertRow) }, batchStagingOptions)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write) staging batch")
+			return
+		}
+
+		// use a separate context for cleanup so it won't get cancelled
+		if batchNum == 0 {
+			defer destClient.CleanUpStagingData(context.Background(), stagingOptions)
+		}
+
+		rowsWritten += len(rows)
+		batchNum++
+	}
+
+	if rowsWritten > 0 {
+		err = destClient.LoadFromStaging(ctx, namespace, stagingTableName, query.LoadOptions{
+			StagingReference: query.GetS3StagingReference(destinationOptions.StagingBucket, objectPrefix+"/"),
+			Columns:          columns,
+		})
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write) loading data from staging")
+			return
+		}
+
+		err = destClient.ExecuteInTransaction(ctx, rs.getLoadStatements(namespace, tableName, stagingTableName, columns, object, sync)...)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.RedshiftImpl.Write) loading from staging table")
+			return
+		}
+	}
+
+	writeOutputC <- WriteOutput{
+		RowsWritten: rowsWritten,
+		Done:        true,
+	}
+
+	close(errC)
+}
+
+func (rs RedshiftImpl) getLoadStatements(namespace string, tableName string, stagingTableName string, columns []string, object views.Object, sync views.Sync) []string {
+	target := fmt.Sprintf("%s.%s", namespace, tableName)
+	staging := fmt.Sprintf("%s.%s", namespace, stagingTableName)
+	endCustomerIDColumn := *object.EndCustomerIDField
+	columnList := strings.Join(columns, ", ")
+	insertStatement := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", target, columnList, columnList, staging)
+
+	switch sync.SyncMode {
+	case models.SyncModeFullOverwrite:
+		// only replace the rows for this end customer, since other customers may share the table
+		var deleteStatement string
+		if object.TargetType == models.TargetTypeTablePerCustomer {
+			deleteStatement = fmt.Sprintf("DELETE FROM %s", target)
+		} else {
+			deleteStatement = fmt.Sprintf("DELETE FROM %s WHERE %s = '%s'", target, endCustomerIDColumn, strings.ReplaceAll(sync.EndCustomerID, "'", "''"))
+		}
+		return []string{deleteStatement, insertStatement}
+	case models.SyncModeIncrementalUpdate, models.SyncModeChangeDataCapture:
+		primaryKey := *object.PrimaryKey
+
+		// the same row may have been updated multiple times since the last sync, so only keep the latest version
+		orderBy := ""
+		if object.CursorField != nil {
+			orderBy = fmt.Sprintf(" ORDER BY %s DESC", *object.CursorField)
+		}
+		latestRows := fmt.Sprintf(
+			"(SELECT %s FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY %s%s) AS fabra_row_num FROM %s) WHERE fabra_row_num = 1)",
+			columnList, primaryKey, orderBy, staging,
+		)
+
+		// Redshift has no upsert, so delete the existing versions of the updated rows and then insert the latest versions
+		return []string{
+			fmt.Sprintf(
+				"DELETE FROM %s USING %s WHERE %s.%s = %s.%s AND %s.%s = %s.%s",
+				target, staging, target, primaryKey, staging, primaryKey, target, endCustomerIDColumn, staging, endCustomerIDColumn,
+			),
+			fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", target, columnList, columnList, latestRows),
+		}
+	default:
+		return []string{insertStatement}
+	}
+}
+
+func (rs RedshiftImpl) getCreateTableQuery(namespace string, tableName string, object views.Object) string {
+	columnDefinitions := []string{}
+	for _, objectField := range object.ObjectFields {
+		if objectField.Omit {
+			continue
+		}
+
+		columnDefinition := fmt.Sprintf("%s %s", objectField.Name, getRedshiftType(objectField.Type))
+		if !objectField.Optional {
+			columnDefinition += " NOT NULL"
+		}
+		columnDefinitions = append(columnDefinitions, columnDefinition)
+	}
+	columnDefinitions = append(columnDefinitions, fmt.Sprintf("%s VARCHAR(256) NOT NULL", *object.EndCustomerIDField))
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s)", namespace, tableName, strings.Join(columnDefinitions, ", "))
+}
+
+func getRedshiftType(fieldType data.FieldType) string {
+	switch fieldType {
+	case data.FieldTypeInteger:
+		return "BIGINT"
+	case data.FieldTypeNumber:
+		return "DOUBLE PRECISION"
+	case data.FieldTypeBoolean:
+		return "BOOLEAN"
+	case data.FieldTypeTimestamp, data.FieldTypeDateTimeTz:
+		return "TIMESTAMPTZ"
+	case data.FieldTypeDateTimeNtz:
+		return "TIMESTAMP"
+	case data.FieldTypeDate:
+		return "DATE"
+	case data.FieldTypeTimeTz:
+		return "TIMETZ"
+	case data.FieldTypeTimeNtz:
+		return "TIME"
+	default:
+		// JSON is stored as a string since CSV values are not parsed into SUPER columns
+		return "VARCHAR(MAX)"
+	}
 }
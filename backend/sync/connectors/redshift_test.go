@@ -0,0 +1,93 @@
+package connectors_test
+
+import (
+	"context"
+
+	"github.com/golang/mock/gomock"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/input"
+	mock_query "go.fabra.io/server/common/mocks"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RedshiftConnector", func() {
+	var (
+		destinationConnection views.FullConnection
+		sync                  views.Sync
+		fieldMappings         []views.FieldMapping
+		object                views.Object
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, _ := test.CreateSource(db, org.ID, endCustomerID)
+		destination, destConn := test.CreateDestination(db, org.ID)
+		destinationConnection = views.ConvertFullConnection(destConn)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeIncrementalUpdate)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+		})
+		object = views.ConvertObject(objectModel, objectFields)
+		primaryKey := "id"
+		object.PrimaryKey = &primaryKey
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeIncrementalUpdate))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_name", SourceFieldType: data.FieldTypeString, DestinationFieldId: objectFields[1].ID},
+		}), objectFields)
+	})
+
+	Describe("Write", func() {
+		It("stages rows to S3 and upserts them with delete and insert", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			rows := []data.Row{
+				{1, "first"},
+				{2, "second"},
+			}
+
+			queryService.EXPECT().GetWarehouseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE TABLE namespace.fabra_staging_", " (LIKE namespace.table)"}).Return(nil, nil)
+			client.EXPECT().StageData(gomock.Any(), MockStagingData{"1,first,abc123\n2,second,abc123\n"}, MockStagingOptions{"bucket"}).Return(nil)
+			client.EXPECT().LoadFromStaging(gomock.Any(), "namespace", MockMergeQuery{"fabra_staging_", ""}, gomock.Any()).Return(nil)
+			client.EXPECT().ExecuteInTransaction(
+				gomock.Any(),
+				MockMergeQuery{"DELETE FROM namespace.table USING namespace.fabra_staging_", "end_customer_id"},
+				MockMergeQuery{"INSERT INTO namespace.table (id, name, end_customer_id) SELECT id, name, end_customer_id FROM (SELECT id, name, end_customer_id FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY id) AS fabra_row_num FROM namespace.fabra_staging_", "WHERE fabra_row_num = 1)"},
+			).Return(nil)
+			client.EXPECT().CleanUpStagingData(gomock.Any(), MockStagingOptions{"bucket"}).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS namespace.fabra_staging_", ""}).Return(nil, nil)
+
+			connector := connectors.NewRedshiftConnector(queryService)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{StagingBucket: "bucket"}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch(rows)
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,110 @@
+package connectors
+
+import (
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/views"
+)
+
+// Compares the live schema of the source table with the types recorded by the field mappings when the sync was created
+// and the fields the sync saw when it last read, and resolves each change by the object's policy. Returns the field
+// mappings to read with: fields cast by the policy are read as their live type and cast back to the mapped type before
+// any other transforms, so writers see the same types as before.
+func CheckSchemaDrift(schema data.Schema, sync views.Sync, fieldMappings []views.FieldMapping, policy models.SchemaDriftPolicy) (data.SchemaDriftReport, []views.FieldMapping) {
+	sourceTypes := map[string]data.FieldType{}
+	for _, field := range schema {
+		sourceTypes[field.Name] = field.Type
+	}
+
+	var report data.SchemaDriftReport
+	readFields := map[string]bool{}
+	driftedMappings := make([]views.FieldMapping, len(fieldMappings))
+	for i, fieldMapping := range fieldMappings {
+		driftedMappings[i] = fieldMapping
+
+		// a source field can be mapped more than once, but only changes once
+		if readFields[fieldMapping.SourceFieldName] {
+			continue
+		}
+		readFields[fieldMapping.SourceFieldName] = true
+
+		sourceType, ok := sourceTypes[fieldMapping.SourceFieldName]
+		if !ok {
+			report.Changes = append(report.Changes, data.SchemaChange{
+				Type:       data.SchemaChangeTypeFieldRemoved,
+				FieldName:  fieldMapping.SourceFieldName,
+				MappedType: fieldMapping.SourceFieldType,
+				Resolution: data.SchemaChangeResolutionFailed,
+			})
+			continue
+		}
+		if sourceType == fieldMapping.SourceFieldType {
+			continue
+		}
+
+		change := data.SchemaChange{
+			Type:       data.SchemaChangeTypeTypeChanged,
+			FieldName:  fieldMapping.SourceFieldName,
+			MappedType: fieldMapping.SourceFieldType,
+			SourceType: sourceType,
+			Resolution: data.SchemaChangeResolutionFailed,
+		}
+		if policy == models.SchemaDriftPolicyAutoCast && data.IsSafeCast(sourceType, fieldMapping.SourceFieldType) {
+			change.Resolution = data.SchemaChangeResolutionCast
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	for i, fieldMapping := range driftedMappings {
+		for _, change := range report.Changes {
+			if change.FieldName != fieldMapping.SourceFieldName || change.Resolution != data.SchemaChangeResolutionCast {
+				continue
+			}
+
+			cast := data.Transform{Type: data.TransformTypeCast, ToType: change.MappedType}
+			driftedMappings[i].SourceFieldType = change.SourceType
+			driftedMappings[i].Transforms = append([]data.Transform{cast}, fieldMapping.Transforms...)
+		}
+	}
+
+	// the sync reads its cursor, primary key, and partition fields even if they aren't mapped
+	for _, fieldName := range []*string{sync.SourceCursorField, sync.SourcePrimaryKey, sync.PartitionField} {
+		if fieldName == nil || readFields[*fieldName] {
+			continue
+		}
+		readFields[*fieldName] = true
+
+		if _, ok := sourceTypes[*fieldName]; !ok {
+			report.Changes = append(report.Changes, data.SchemaChange{
+				Type:       data.SchemaChangeTypeFieldRemoved,
+				FieldName:  *fieldName,
+				Resolution: data.SchemaChangeResolutionFailed,
+			})
+		}
+	}
+
+	// fields added before the first run that checked the schema can't be told apart from fields that were always there
+	knownFields := map[string]bool{}
+	for _, field := range sync.SourceSchema {
+		knownFields[field.Name] = true
+	}
+
+	for _, field := range schema {
+		if sync.SourceSchema == nil || readFields[field.Name] || knownFields[field.Name] {
+			continue
+		}
+
+		change := data.SchemaChange{
+			Type:       data.SchemaChangeTypeFieldAdded,
+			FieldName:  field.Name,
+			SourceType: field.Type,
+			Resolution: data.SchemaChangeResolutionIgnored,
+		}
+		if policy == models.SchemaDriftPolicyFail {
+			change.Resolution = data.SchemaChangeResolutionFailed
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	return report, driftedMappings
+}
@@ -0,0 +1,95 @@
+package connectors_test
+
+import (
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CheckSchemaDrift", func() {
+	var (
+		sync          views.Sync
+		fieldMappings []views.FieldMapping
+	)
+
+	BeforeEach(func() {
+		cursorField := "updated_at"
+		sync = views.Sync{
+			SourceCursorField: &cursorField,
+			SourceSchema: data.Schema{
+				{Name: "id", Type: data.FieldTypeInteger},
+				{Name: "seats", Type: data.FieldTypeInteger},
+				{Name: "updated_at", Type: data.FieldTypeTimestamp},
+			},
+		}
+		fieldMappings = []views.FieldMapping{
+			{SourceFieldName: "id", SourceFieldType: data.FieldTypeInteger},
+			{SourceFieldName: "seats", SourceFieldType: data.FieldTypeInteger, Transforms: []data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeString}}},
+		}
+	})
+
+	It("reports nothing if the schema hasn't changed", func() {
+		report, driftedMappings := connectors.CheckSchemaDrift(sync.SourceSchema, sync, fieldMappings, models.SchemaDriftPolicyFail)
+
+		Expect(report.Changes).To(BeEmpty())
+		Expect(driftedMappings).To(Equal(fieldMappings))
+	})
+
+	It("ignores fields added since the last read unless the policy fails on them", func() {
+		schema := append(data.Schema{{Name: "notes", Type: data.FieldTypeString}}, sync.SourceSchema...)
+
+		report, _ := connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyIgnoreNewColumns)
+		Expect(report.Failed()).To(BeFalse())
+		Expect(report.Changes).To(Equal([]data.SchemaChange{
+			{Type: data.SchemaChangeTypeFieldAdded, FieldName: "notes", SourceType: data.FieldTypeString, Resolution: data.SchemaChangeResolutionIgnored},
+		}))
+
+		report, _ = connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyFail)
+		Expect(report.Failed()).To(BeTrue())
+
+		// the first run has nothing to compare unmapped fields with
+		sync.SourceSchema = nil
+		report, _ = connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyFail)
+		Expect(report.Changes).To(BeEmpty())
+	})
+
+	It("fails on removed fields, including the cursor field, under every policy", func() {
+		schema := data.Schema{{Name: "seats", Type: data.FieldTypeInteger}}
+
+		report, _ := connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyAutoCast)
+		Expect(report.Changes).To(Equal([]data.SchemaChange{
+			{Type: data.SchemaChangeTypeFieldRemoved, FieldName: "id", MappedType: data.FieldTypeInteger, Resolution: data.SchemaChangeResolutionFailed},
+			{Type: data.SchemaChangeTypeFieldRemoved, FieldName: "updated_at", Resolution: data.SchemaChangeResolutionFailed},
+		}))
+	})
+
+	It("casts changed types back to the mapped type before other transforms when the policy allows it", func() {
+		schema := data.Schema{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "seats", Type: data.FieldTypeBoolean},
+			{Name: "updated_at", Type: data.FieldTypeTimestamp},
+		}
+		sync.SourceSchema = schema
+
+		report, _ := connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyIgnoreNewColumns)
+		Expect(report.Failed()).To(BeTrue())
+
+		// booleans can't be cast to integers without losing values
+		report, _ = connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyAutoCast)
+		Expect(report.Failed()).To(BeTrue())
+
+		fieldMappings[1].SourceFieldType = data.FieldTypeString
+		fieldMappings[1].Transforms = nil
+		report, driftedMappings := connectors.CheckSchemaDrift(schema, sync, fieldMappings, models.SchemaDriftPolicyAutoCast)
+		Expect(report.Changes).To(Equal([]data.SchemaChange{
+			{Type: data.SchemaChangeTypeTypeChanged, FieldName: "seats", MappedType: data.FieldTypeString, SourceType: data.FieldTypeBoolean, Resolution: data.SchemaChangeResolutionCast},
+		}))
+		Expect(driftedMappings[1].SourceFieldType).To(Equal(data.FieldTypeBoolean))
+		Expect(driftedMappings[1].Transforms).To(Equal([]data.Transform{{Type: data.TransformTypeCast, ToType: data.FieldTypeString}}))
+		Expect(fieldMappings[1].SourceFieldType).To(Equal(data.FieldTypeString))
+	})
+})
@@ -3,12 +3,15 @@ package connectors
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/google/uuid"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
@@ -27,7 +30,7 @@ func (sf SnowflakeImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -39,17 +42,28 @@ func (sf SnowflakeImpl) Read(
 		return
 	}
 
-	readQuery := sf.getReadQuery(connectionModel, sync, fieldMappings)
+	if sync.PartitionField != nil {
+		readPartitioned(ctx, sourceClient, sqlbuilder.DialectSnowflake, sourceConnection, sync, fieldMappings, sf.getSelectColumns(fieldMappings), nil, rowsC, readOutputC, errC)
+		return
+	}
+
+	readQuery, readArgs, err := sf.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- err
+		return
+	}
 
-	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery)
+	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery, readArgs...)
 	if err != nil {
 		errC <- err
 		return
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
+	cursorTracker := newCursorTracker(sync, iterator.Schema())
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -61,24 +75,52 @@ func (sf SnowflakeImpl) Read(
 			}
 		}
 
+		alreadySynced, err := cursorTracker.Track(row)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, row)
-		lastRow = row
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	newCursorPosition, err := cursorTracker.CursorPosition()
+	if err != nil {
+		errC <- err
+		return
 	}
 
-	newCursorPosition := sf.getNewCursorPosition(lastRow, iterator.Schema(), sync)
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
@@ -86,66 +128,55 @@ func (sf SnowflakeImpl) Read(
 }
 
 // TODO: only read 10,000 rows at once or something
-func (sf SnowflakeImpl) getReadQuery(sourceConnection *models.Connection, sync views.Sync, fieldMappings []views.FieldMapping) string {
-	var queryString string
+func (sf SnowflakeImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (string, []any, error) {
+	var builder *sqlbuilder.SelectBuilder
 	if sync.CustomJoin != nil {
-		queryString = *sync.CustomJoin
+		builder = sqlbuilder.Select(sqlbuilder.DialectSnowflake).FromQuery(*sync.CustomJoin)
 	} else {
-		selectString := sf.getSelectString(fieldMappings)
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, *sync.Namespace, *sync.TableName)
+		builder = sqlbuilder.Select(sqlbuilder.DialectSnowflake, sf.getSelectColumns(fieldMappings)...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	err := addFilterCondition(builder, sync, nil)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(connectors.SnowflakeImpl.getReadQuery)")
 	}
 
 	if sync.SyncMode.UsesCursor() {
-		if sync.CursorPosition != nil {
-			// TODO: allow choosing other operators (rows smaller than current cursor)
-			// order by cursor field to simplify
-			return fmt.Sprintf("%s WHERE %s > %s ORDER BY %s ASC;", queryString, *sync.SourceCursorField, *sync.CursorPosition, *sync.SourceCursorField)
-		} else {
-			return fmt.Sprintf("%s ORDER BY %s ASC;", queryString, *sync.SourceCursorField)
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "(connectors.SnowflakeImpl.getReadQuery)")
 		}
-	} else {
-		return fmt.Sprintf("%s;", queryString)
-	}
-}
 
-func (sf SnowflakeImpl) getSelectString(fieldMappings []views.FieldMapping) string {
-	fields := []string{}
-	for _, fieldMapping := range fieldMappings {
-		fields = append(fields, fieldMapping.SourceFieldName)
+		addCursorCondition(builder, sync, condition)
 	}
 
-	return strings.Join(fields, ",")
+	queryString, args := builder.Build()
+	return queryString, args, nil
 }
 
-func (sf SnowflakeImpl) getNewCursorPosition(lastRow data.Row, schema data.Schema, sync views.Sync) *string {
-	if sync.SourceCursorField == nil {
-		return nil
-	}
+func (sf SnowflakeImpl) ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error) {
+	connectionModel := views.ConvertConnectionView(sourceConnection)
 
-	if lastRow == nil {
-		return nil
+	sourceClient, err := sf.queryService.GetClient(ctx, connectionModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.SnowflakeImpl.ReadPrimaryKeys)")
 	}
 
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range schema {
-		if schema[i].Name == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = schema[i].Type
-		}
+	iterator, err := readPrimaryKeys(ctx, sourceClient, sqlbuilder.DialectSnowflake, sync, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.SnowflakeImpl.ReadPrimaryKeys)")
 	}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger, data.FieldTypeNumber:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+	return iterator, nil
+}
+
+func (sf SnowflakeImpl) getSelectColumns(fieldMappings []views.FieldMapping) []string {
+	columns := []string{}
+	for _, fieldMapping := range fieldMappings {
+		columns = append(columns, fieldMapping.SourceFieldName)
 	}
 
-	return &newCursorPos
+	return columns
 }
 
 func (sf SnowflakeImpl) Write(
@@ -155,9 +186,206 @@ func (sf SnowflakeImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
-	errC <- errors.New("snowflake destination not implemented")
+	connectionModel := views.ConvertConnectionView(destinationConnection)
+
+	destClient, err := sf.queryService.GetWarehouseClient(ctx, connectionModel)
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write) getting client")
+		return
+	}
+
+	if sync.SyncMode.UpdatesByPrimaryKey() && object.PrimaryKey == nil {
+		errC <- errors.NewCustomerVisibleError("primary key must be set on the object to use incremental update")
+		return
+	}
+
+	namespace := *object.Namespace
+	tableName := getDestinationTableName(object, sync.EndCustomerID)
+	columns := getDestinationColumns(object)
+
+	if object.TargetType == models.TargetTypeTablePerCustomer {
+		_, err = destClient.RunQuery(ctx, sf.getCreateTableQuery(namespace, tableName, object))
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write) creating table")
+			return
+		}
+	}
+
+	// rows are loaded into a staging table first so the destination table is only modified in a single transaction
+	stagingTableName := fmt.Sprintf("fabra_staging_%s", strings.ReplaceAll(uuid.New().String(), "-", ""))
+	_, err = destClient.RunQuery(ctx, fmt.Sprintf("CREATE TRANSIENT TABLE %s LIKE %s", sf.qualifiedName(namespace, stagingTableName), sf.qualifiedName(namespace, tableName)))
+	if err != nil {
+		errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write) creating staging table")
+		return
+	}
+
+	// use a separate context for cleanup so it won't get cancelled
+	defer destClient.RunQuery(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %s", sf.qualifiedName(namespace, stagingTableName)))
+
+	// all batches are staged under the same prefix so they can be loaded with a single COPY
+	objectPrefix := uuid.New().String()
+	convertRow := newDestinationRowConverter(object, fieldMappings, sync.EndCustomerID)
+	batchNum := 0
+	rowsWritten := 0
+	for {
+		rowBatch, more := <-rowsC
+		if !more {
+			break
+		}
+
+		rows, err := rowBatch.UpsertsOnly()
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write)")
+			return
+		}
+
+		stagingOptions := query.StagingOptions{Bucket: destinationOptions.StagingBucket, Object: fmt.Sprintf("%s/%d.csv", objectPrefix, batchNum)}
+		err = destClient.StageData(ctx, func(w io.Writer) error { return writeCsvData(w, rows, convertRow) }, stagingOptions)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write) staging batch")
+			return
+		}
+
+		rowsWritten += len(rows)
+		batchNum++
+	}
+
+	if rowsWritten > 0 {
+		// use a separate context for cleanup so it won't get cancelled
+		defer destClient.CleanUpStagingData(context.Background(), query.StagingOptions{Bucket: destinationOptions.StagingBucket, Object: objectPrefix})
+
+		err = destClient.LoadFromStaging(ctx, namespace, stagingTableName, query.LoadOptions{
+			StagingReference: query.GetSnowflakeStagingReference(destinationOptions.StagingBucket, objectPrefix+"/"),
+			Columns:          columns,
+			JsonColumns:      getDestinationJsonColumns(object),
+		})
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write) loading data from staging")
+			return
+		}
+
+		err = destClient.ExecuteInTransaction(ctx, sf.getLoadStatements(namespace, tableName, stagingTableName, columns, object, sync)...)
+		if err != nil {
+			errC <- errors.Wrap(err, "(connectors.SnowflakeImpl.Write) loading from staging table")
+			return
+		}
+	}
+
+	writeOutputC <- WriteOutput{
+		RowsWritten: rowsWritten,
+		Done:        true,
+	}
+
+	close(errC)
+}
+
+func (sf SnowflakeImpl) getLoadStatements(namespace string, tableName string, stagingTableName string, columns []string, object views.Object, sync views.Sync) []string {
+	target := sf.qualifiedName(namespace, tableName)
+	staging := sf.qualifiedName(namespace, stagingTableName)
+	endCustomerIDColumn := sf.quoteIdentifier(*object.EndCustomerIDField)
+
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = sf.quoteIdentifier(column)
+	}
+	columnList := strings.Join(quotedColumns, ", ")
+	insertStatement := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", target, columnList, columnList, staging)
+
+	switch sync.SyncMode {
+	case models.SyncModeFullOverwrite:
+		// only replace the rows for this end customer, since other customers may share the table
+		var deleteStatement string
+		if object.TargetType == models.TargetTypeTablePerCustomer {
+			deleteStatement = fmt.Sprintf("DELETE FROM %s", target)
+		} else {
+			deleteStatement = fmt.Sprintf("DELETE FROM %s WHERE %s = %s", target, endCustomerIDColumn, sf.quoteLiteral(sync.EndCustomerID))
+		}
+		return []string{deleteStatement, insertStatement}
+	case models.SyncModeIncrementalUpdate, models.SyncModeChangeDataCapture:
+		primaryKey := sf.quoteIdentifier(*object.PrimaryKey)
+
+		// the same row may have been updated multiple times since the last sync, so only keep the latest version
+		orderBy := ""
+		if object.CursorField != nil {
+			orderBy = fmt.Sprintf(" ORDER BY %s DESC", sf.quoteIdentifier(*object.CursorField))
+		}
+		latestRows := fmt.Sprintf("(SELECT * FROM %s QUALIFY ROW_NUMBER() OVER (PARTITION BY %s%s) = 1)", staging, primaryKey, orderBy)
+
+		var setClauses, insertValues []string
+		for _, column := range quotedColumns {
+			insertValues = append(insertValues, fmt.Sprintf("source.%s", column))
+			if column != primaryKey && column != endCustomerIDColumn {
+				setClauses = append(setClauses, fmt.Sprintf("%s = source.%s", column, column))
+			}
+		}
+
+		matchedClause := ""
+		if len(setClauses) > 0 {
+			matchedClause = fmt.Sprintf(" WHEN MATCHED THEN UPDATE SET %s", strings.Join(setClauses, ", "))
+		}
+
+		return []string{fmt.Sprintf(
+			"MERGE INTO %s AS target USING %s AS source ON target.%s = source.%s AND target.%s = source.%s%s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+			target, latestRows, primaryKey, primaryKey, endCustomerIDColumn, endCustomerIDColumn, matchedClause, columnList, strings.Join(insertValues, ", "),
+		)}
+	default:
+		return []string{insertStatement}
+	}
+}
+
+func (sf SnowflakeImpl) getCreateTableQuery(namespace string, tableName string, object views.Object) string {
+	columnDefinitions := []string{}
+	for _, objectField := range object.ObjectFields {
+		if objectField.Omit {
+			continue
+		}
+
+		columnDefinition := fmt.Sprintf("%s %s", sf.quoteIdentifier(objectField.Name), getSnowflakeType(objectField.Type))
+		if !objectField.Optional {
+			columnDefinition += " NOT NULL"
+		}
+		columnDefinitions = append(columnDefinitions, columnDefinition)
+	}
+	columnDefinitions = append(columnDefinitions, fmt.Sprintf("%s VARCHAR NOT NULL", sf.quoteIdentifier(*object.EndCustomerIDField)))
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", sf.qualifiedName(namespace, tableName), strings.Join(columnDefinitions, ", "))
+}
+
+func (sf SnowflakeImpl) qualifiedName(namespace string, tableName string) string {
+	return sqlbuilder.DialectSnowflake.QuoteTable(namespace, tableName)
+}
+
+func (sf SnowflakeImpl) quoteIdentifier(identifier string) string {
+	return sqlbuilder.DialectSnowflake.QuoteIdentifier(identifier)
+}
+
+func (sf SnowflakeImpl) quoteLiteral(literal string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(literal, "'", "''"))
+}
+
+func getSnowflakeType(fieldType data.FieldType) string {
+	switch fieldType {
+	case data.FieldTypeInteger:
+		return "INTEGER"
+	case data.FieldTypeNumber:
+		return "NUMBER"
+	case data.FieldTypeBoolean:
+		return "BOOLEAN"
+	case data.FieldTypeTimestamp, data.FieldTypeDateTimeTz:
+		return "TIMESTAMP_TZ"
+	case data.FieldTypeDateTimeNtz:
+		return "TIMESTAMP_NTZ"
+	case data.FieldTypeJson:
+		return "VARIANT"
+	case data.FieldTypeDate:
+		return "DATE"
+	case data.FieldTypeTimeTz, data.FieldTypeTimeNtz:
+		return "TIME"
+	default:
+		return "VARCHAR"
+	}
 }
@@ -0,0 +1,154 @@
+package connectors_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/golang/mock/gomock"
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/input"
+	mock_query "go.fabra.io/server/common/mocks"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SnowflakeConnector", func() {
+	var (
+		destinationConnection views.FullConnection
+		sync                  views.Sync
+		fieldMappings         []views.FieldMapping
+		object                views.Object
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, _ := test.CreateSource(db, org.ID, endCustomerID)
+		destination, destConn := test.CreateDestination(db, org.ID)
+		destinationConnection = views.ConvertFullConnection(destConn)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeFullOverwrite)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+			{Name: "name", Type: data.FieldTypeString},
+			{Name: "json", Type: data.FieldTypeJson, Optional: true},
+		})
+		object = views.ConvertObject(objectModel, objectFields)
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeFullOverwrite))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+			{SourceFieldName: "source_name", SourceFieldType: data.FieldTypeString, DestinationFieldId: objectFields[1].ID},
+			{SourceFieldName: "source_json", SourceFieldType: data.FieldTypeJson, DestinationFieldId: objectFields[2].ID},
+		}), objectFields)
+	})
+
+	write := func(queryService query.QueryService, rows []data.Row) (*connectors.WriteOutput, error) {
+		connector := connectors.NewSnowflakeConnector(queryService)
+		rowsC := make(chan connectors.RowBatch)
+		writeOutputC := make(chan connectors.WriteOutput)
+		errC := make(chan error)
+
+		go func() {
+			defer GinkgoRecover()
+			defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+			connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{StagingBucket: "bucket"}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+		}()
+
+		rowsC <- connectors.NewRowBatch(rows)
+		close(rowsC)
+
+		return waitForWrite(writeOutputC, errC)
+	}
+
+	Describe("Write", func() {
+		It("stages rows and replaces the end customer's rows, parsing JSON columns", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			rows := []data.Row{
+				{1, "first", map[string]any{"hello": 123}},
+				{2, "it's", nil},
+			}
+
+			queryService.EXPECT().GetWarehouseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE TRANSIENT TABLE \"namespace\".\"fabra_staging_", "\" LIKE \"namespace\".\"table\""}).Return(nil, nil)
+			client.EXPECT().StageData(gomock.Any(), MockStagingData{"1,first,\"{\"\"hello\"\":123}\",abc123\n2,it's,,abc123\n"}, MockStagingOptions{"bucket"}).Return(nil)
+			client.EXPECT().LoadFromStaging(gomock.Any(), "namespace", MockMergeQuery{"fabra_staging_", ""}, MockCopyOptions{
+				Columns:     []string{"id", "name", "json", "end_customer_id"},
+				JsonColumns: []string{"json"},
+			}).Return(nil)
+			client.EXPECT().ExecuteInTransaction(
+				gomock.Any(),
+				"DELETE FROM \"namespace\".\"table\" WHERE \"end_customer_id\" = 'abc123'",
+				MockMergeQuery{"INSERT INTO \"namespace\".\"table\" (\"id\", \"name\", \"json\", \"end_customer_id\") SELECT \"id\", \"name\", \"json\", \"end_customer_id\" FROM \"namespace\".\"fabra_staging_", "\""},
+			).Return(nil)
+			client.EXPECT().CleanUpStagingData(gomock.Any(), MockStagingOptions{"bucket"}).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS \"namespace\".\"fabra_staging_", "\""}).Return(nil, nil)
+
+			writeOutput, err := write(queryService, rows)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+		})
+
+		It("merges the latest version of each row by primary key", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			queryService := mock_query.NewMockQueryService(ctrl)
+			client := mock_query.NewMockWarehouseClient(ctrl)
+			defer ctrl.Finish()
+
+			primaryKey := "id"
+			object.PrimaryKey = &primaryKey
+			sync.SyncMode = models.SyncModeIncrementalUpdate
+
+			queryService.EXPECT().GetWarehouseClient(gomock.Any(), gomock.Any()).Return(client, nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"CREATE TRANSIENT TABLE \"namespace\".\"fabra_staging_", "\" LIKE \"namespace\".\"table\""}).Return(nil, nil)
+			client.EXPECT().StageData(gomock.Any(), MockStagingData{"1,first,,abc123\n"}, MockStagingOptions{"bucket"}).Return(nil)
+			client.EXPECT().LoadFromStaging(gomock.Any(), "namespace", MockMergeQuery{"fabra_staging_", ""}, gomock.Any()).Return(nil)
+			client.EXPECT().ExecuteInTransaction(
+				gomock.Any(),
+				MockMergeQuery{
+					"MERGE INTO \"namespace\".\"table\" AS target USING (SELECT * FROM \"namespace\".\"fabra_staging_",
+					"\" QUALIFY ROW_NUMBER() OVER (PARTITION BY \"id\") = 1) AS source ON target.\"id\" = source.\"id\" AND target.\"end_customer_id\" = source.\"end_customer_id\"" +
+						" WHEN MATCHED THEN UPDATE SET \"name\" = source.\"name\", \"json\" = source.\"json\"" +
+						" WHEN NOT MATCHED THEN INSERT (\"id\", \"name\", \"json\", \"end_customer_id\") VALUES (source.\"id\", source.\"name\", source.\"json\", source.\"end_customer_id\")",
+				},
+			).Return(nil)
+			client.EXPECT().CleanUpStagingData(gomock.Any(), MockStagingOptions{"bucket"}).Return(nil)
+			client.EXPECT().RunQuery(gomock.Any(), MockMergeQuery{"DROP TABLE IF EXISTS \"namespace\".\"fabra_staging_", "\""}).Return(nil, nil)
+
+			writeOutput, err := write(queryService, []data.Row{{1, "first", nil}})
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(1))
+		})
+	})
+})
+
+// Matches the columns loaded by a COPY from staging, since the staging reference has a random prefix
+type MockCopyOptions struct {
+	Columns     []string
+	JsonColumns []string
+}
+
+func (co MockCopyOptions) Matches(x interface{}) bool {
+	actual, ok := x.(query.LoadOptions)
+	if !ok {
+		return false
+	}
+
+	return reflect.DeepEqual(co.Columns, actual.Columns) && reflect.DeepEqual(co.JsonColumns, actual.JsonColumns)
+}
+
+func (co MockCopyOptions) String() string {
+	return fmt.Sprintf("{ANY, %v, %v}", co.Columns, co.JsonColumns)
+}
@@ -2,14 +2,12 @@ package connectors
 
 import (
 	"context"
-	"fmt"
-	"strings"
 
 	_ "github.com/microsoft/go-mssqldb"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
-	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/sqlbuilder"
 	"go.fabra.io/server/common/views"
 )
 
@@ -28,7 +26,7 @@ func (as SynapseImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -40,17 +38,28 @@ func (as SynapseImpl) Read(
 		return
 	}
 
-	readQuery := as.getReadQuery(connectionModel, sync, fieldMappings)
+	if sync.PartitionField != nil {
+		readPartitioned(ctx, sourceClient, sqlbuilder.DialectSynapse, sourceConnection, sync, fieldMappings, as.getSelectColumns(fieldMappings), nil, rowsC, readOutputC, errC)
+		return
+	}
+
+	readQuery, readArgs, err := as.getReadQuery(sync, fieldMappings)
+	if err != nil {
+		errC <- err
+		return
+	}
 
-	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery)
+	iterator, err := sourceClient.GetQueryIterator(ctx, readQuery, readArgs...)
 	if err != nil {
 		errC <- err
 		return
 	}
 
 	currentIndex := 0
+	var batchBytes int64
+	batchesRead := 0
 	var rowBatch []data.Row
-	var lastRow data.Row
+	cursorTracker := newCursorTracker(sync, iterator.Schema())
 	for {
 		row, err := iterator.Next(ctx)
 		if err != nil {
@@ -62,24 +71,52 @@ func (as SynapseImpl) Read(
 			}
 		}
 
+		alreadySynced, err := cursorTracker.Track(row)
+		if err != nil {
+			errC <- err
+			return
+		}
+		if alreadySynced {
+			continue
+		}
+
 		rowBatch = append(rowBatch, row)
-		lastRow = row
 		currentIndex++
-		if currentIndex == READ_BATCH_SIZE {
-			rowsC <- rowBatch
+		batchBytes += row.EstimatedSize()
+		if currentIndex == READ_BATCH_SIZE || batchBytes >= READ_BATCH_BYTES {
+			batchesRead++
+			err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+			if err != nil {
+				errC <- err
+				return
+			}
+
 			currentIndex = 0
+			batchBytes = 0
 			rowBatch = []data.Row{}
 		}
 	}
 
 	// write any remaining roows
 	if currentIndex > 0 {
-		rowsC <- rowBatch
+		batchesRead++
+		err = sendBatch(rowsC, readOutputC, rowBatch, batchesRead, cursorTracker)
+		if err != nil {
+			errC <- err
+			return
+		}
+	}
+
+	newCursorPosition, err := cursorTracker.CursorPosition()
+	if err != nil {
+		errC <- err
+		return
 	}
 
-	newCursorPosition := as.getNewCursorPosition(lastRow, iterator.Schema(), sync)
 	readOutputC <- ReadOutput{
 		CursorPosition: newCursorPosition,
+		BatchesRead:    batchesRead,
+		Done:           true,
 	}
 
 	close(rowsC)
@@ -87,66 +124,55 @@ func (as SynapseImpl) Read(
 }
 
 // TODO: only read 10,000 rows at once or something
-func (as SynapseImpl) getReadQuery(sourceConnection *models.Connection, sync views.Sync, fieldMappings []views.FieldMapping) string {
-	var queryString string
+func (as SynapseImpl) getReadQuery(sync views.Sync, fieldMappings []views.FieldMapping) (string, []any, error) {
+	var builder *sqlbuilder.SelectBuilder
 	if sync.CustomJoin != nil {
-		queryString = *sync.CustomJoin
+		builder = sqlbuilder.Select(sqlbuilder.DialectSynapse).FromQuery(*sync.CustomJoin)
 	} else {
-		selectString := as.getSelectString(fieldMappings)
-		queryString = fmt.Sprintf("SELECT %s FROM %s.%s", selectString, *sync.Namespace, *sync.TableName)
+		builder = sqlbuilder.Select(sqlbuilder.DialectSynapse, as.getSelectColumns(fieldMappings)...).From(*sync.Namespace, *sync.TableName)
+	}
+
+	err := addFilterCondition(builder, sync, nil)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(connectors.SynapseImpl.getReadQuery)")
 	}
 
 	if sync.SyncMode.UsesCursor() {
-		if sync.CursorPosition != nil {
-			// TODO: allow choosing other operatoas (rows smaller than current cursor)
-			// order by cursor field to simplify
-			return fmt.Sprintf("%s WHERE %s > %s ORDER BY %s ASC;", queryString, *sync.SourceCursorField, *sync.CursorPosition, *sync.SourceCursorField)
-		} else {
-			return fmt.Sprintf("%s ORDER BY %s ASC;", queryString, *sync.SourceCursorField)
+		condition, err := getCursorCondition(sync, fieldMappings)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "(connectors.SynapseImpl.getReadQuery)")
 		}
-	} else {
-		return fmt.Sprintf("%s;", queryString)
-	}
-}
 
-func (as SynapseImpl) getSelectString(fieldMappings []views.FieldMapping) string {
-	fields := []string{}
-	for _, fieldMapping := range fieldMappings {
-		fields = append(fields, fieldMapping.SourceFieldName)
+		addCursorCondition(builder, sync, condition)
 	}
 
-	return strings.Join(fields, ",")
+	queryString, args := builder.Build()
+	return queryString, args, nil
 }
 
-func (as SynapseImpl) getNewCursorPosition(lastRow data.Row, schema data.Schema, sync views.Sync) *string {
-	if sync.SourceCursorField == nil {
-		return nil
-	}
+func (as SynapseImpl) ReadPrimaryKeys(ctx context.Context, sourceConnection views.FullConnection, sync views.Sync) (data.RowIterator, error) {
+	connectionModel := views.ConvertConnectionView(sourceConnection)
 
-	if lastRow == nil {
-		return nil
+	sourceClient, err := as.queryService.GetClient(ctx, connectionModel)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.SynapseImpl.ReadPrimaryKeys)")
 	}
 
-	var cursorFieldPos int
-	var cursorFieldType data.FieldType
-	for i := range schema {
-		if schema[i].Name == *sync.SourceCursorField {
-			cursorFieldPos = i
-			cursorFieldType = schema[i].Type
-		}
+	iterator, err := readPrimaryKeys(ctx, sourceClient, sqlbuilder.DialectSynapse, sync, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.SynapseImpl.ReadPrimaryKeys)")
 	}
 
-	// TODO: make sure we don't miss any rows
-	// we sort rows by cursor field so just take the last row
-	var newCursorPos string
-	switch cursorFieldType {
-	case data.FieldTypeInteger, data.FieldTypeNumber:
-		newCursorPos = fmt.Sprintf("%v", lastRow[cursorFieldPos])
-	default:
-		newCursorPos = fmt.Sprintf("'%v'", lastRow[cursorFieldPos])
+	return iterator, nil
+}
+
+func (as SynapseImpl) getSelectColumns(fieldMappings []views.FieldMapping) []string {
+	columns := []string{}
+	for _, fieldMapping := range fieldMappings {
+		columns = append(columns, fieldMapping.SourceFieldName)
 	}
 
-	return &newCursorPos
+	return columns
 }
 
 func (as SynapseImpl) Write(
@@ -156,7 +182,7 @@ func (as SynapseImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
@@ -0,0 +1,61 @@
+package connectors
+
+import (
+	"fmt"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/views"
+)
+
+// Applies the transforms of each field mapping to rows between reading and writing, so every destination writes the
+// same transformed values
+type RowTransformer struct {
+	fieldMappings []views.FieldMapping
+	transformers  []*data.FieldTransformer
+}
+
+func NewRowTransformer(fieldMappings []views.FieldMapping) (*RowTransformer, error) {
+	rowTransformer := RowTransformer{transformers: make([]*data.FieldTransformer, len(fieldMappings))}
+	for i, fieldMapping := range fieldMappings {
+		if len(fieldMapping.Transforms) > 0 {
+			transformer, err := data.NewFieldTransformer(fieldMapping.SourceFieldType, fieldMapping.Transforms)
+			if err != nil {
+				return nil, errors.NewCustomerVisibleError(fmt.Sprintf("invalid transforms for field %s: %s", fieldMapping.SourceFieldName, err.Error()))
+			}
+
+			rowTransformer.transformers[i] = transformer
+
+			// writers format values by their source type, so they see the type the transforms output
+			fieldMapping.SourceFieldType = transformer.OutputType
+		}
+
+		rowTransformer.fieldMappings = append(rowTransformer.fieldMappings, fieldMapping)
+	}
+
+	return &rowTransformer, nil
+}
+
+// The field mappings to write the transformed rows with
+func (t RowTransformer) FieldMappings() []views.FieldMapping {
+	return t.fieldMappings
+}
+
+// Transforms the rows in place
+func (t RowTransformer) Transform(rows []data.Row) error {
+	for _, row := range rows {
+		for i, transformer := range t.transformers {
+			if transformer == nil {
+				continue
+			}
+
+			value, err := transformer.Apply(row[i])
+			if err != nil {
+				return errors.NewCustomerVisibleError(fmt.Sprintf("could not transform field %s: %s", t.fieldMappings[i].SourceFieldName, err.Error()))
+			}
+			row[i] = value
+		}
+	}
+
+	return nil
+}
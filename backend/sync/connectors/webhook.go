@@ -3,24 +3,143 @@ package connectors
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
+	"go.fabra.io/server/common/application"
 	"go.fabra.io/server/common/crypto"
 	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/input"
+	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
 	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/webhooks"
 	"golang.org/x/time/rate"
 )
 
-const MAX_WEBHOOK_BATCH_SIZE = 1_000
-const REFILL_RATE = 100
-const MAX_BURST = 100
+// defaults used when the destination and object don't configure delivery options
+const DEFAULT_WEBHOOK_BATCH_SIZE = 1_000
+const DEFAULT_WEBHOOK_REQUESTS_PER_SECOND = 100
+const DEFAULT_WEBHOOK_BURST = 100
+
+// the sync fails once more than this many batches could not be delivered
+const DEFAULT_WEBHOOK_MAX_DEAD_LETTERS = 10
+
+const MAX_WEBHOOK_ATTEMPTS = 5
+const WEBHOOK_INITIAL_BACKOFF = 1 * time.Second
+const WEBHOOK_MAX_BACKOFF = 1 * time.Minute
+
+// endpoints can ask us to wait longer than our own backoff, but not indefinitely
+const WEBHOOK_MAX_RETRY_AFTER = 5 * time.Minute
+
+// rows deleted from the source are sent as tombstones with only the primary key and this field set to true
+const WEBHOOK_DELETED_FIELD = "fabra_deleted"
+
+// Zero values use the defaults. A zero MaxPayloadBytes means batches are only limited by row count.
+type WebhookOptions struct {
+	RequestsPerSecond float64
+	Burst             int
+	BatchSize         int
+	MaxPayloadBytes   int
+	MaxDeadLetters    int
+}
+
+func (o WebhookOptions) withDefaults() WebhookOptions {
+	if o.RequestsPerSecond == 0 {
+		o.RequestsPerSecond = DEFAULT_WEBHOOK_REQUESTS_PER_SECOND
+	}
+	if o.Burst == 0 {
+		o.Burst = DEFAULT_WEBHOOK_BURST
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = DEFAULT_WEBHOOK_BATCH_SIZE
+	}
+	if o.MaxDeadLetters == 0 {
+		o.MaxDeadLetters = DEFAULT_WEBHOOK_MAX_DEAD_LETTERS
+	}
+
+	return o
+}
+
+// limiters no sync has used for this long are dropped, so the worker doesn't keep one for every endpoint it ever sent to
+const WEBHOOK_LIMITER_IDLE_TIMEOUT = 10 * time.Minute
+
+type webhookLimit struct {
+	requestsPerSecond float64
+	burst             int
+}
+
+// Limiters are shared by every sync and object sending to the same URL, so concurrent syncs on this worker don't
+// multiply the request rate the endpoint receives. The limiter uses the strictest limit of the syncs using it.
+type webhookLimiterEntry struct {
+	limiter    *rate.Limiter
+	limits     []webhookLimit
+	releasedAt time.Time
+}
+
+func (e *webhookLimiterEntry) applyStrictestLimit() {
+	strictest := e.limits[0]
+	for _, limit := range e.limits[1:] {
+		if limit.requestsPerSecond < strictest.requestsPerSecond {
+			strictest.requestsPerSecond = limit.requestsPerSecond
+		}
+		if limit.burst < strictest.burst {
+			strictest.burst = limit.burst
+		}
+	}
+
+	e.limiter.SetLimit(rate.Limit(strictest.requestsPerSecond))
+	e.limiter.SetBurst(strictest.burst)
+}
+
+var webhookLimiters = map[string]*webhookLimiterEntry{}
+var webhookLimitersMu sync.Mutex
+
+// Returns the limiter for the URL, and a function to call once the sync is done sending requests
+func acquireWebhookLimiter(url string, requestsPerSecond float64, burst int) (*rate.Limiter, func()) {
+	webhookLimitersMu.Lock()
+	defer webhookLimitersMu.Unlock()
+
+	now := time.Now()
+	for key, entry := range webhookLimiters {
+		if len(entry.limits) == 0 && now.Sub(entry.releasedAt) > WEBHOOK_LIMITER_IDLE_TIMEOUT {
+			delete(webhookLimiters, key)
+		}
+	}
+
+	limit := webhookLimit{requestsPerSecond: requestsPerSecond, burst: burst}
+	entry, ok := webhookLimiters[url]
+	if !ok {
+		entry = &webhookLimiterEntry{limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), burst)}
+		webhookLimiters[url] = entry
+	}
+	entry.limits = append(entry.limits, limit)
+	entry.applyStrictestLimit()
+
+	release := func() {
+		webhookLimitersMu.Lock()
+		defer webhookLimitersMu.Unlock()
+
+		for i, entryLimit := range entry.limits {
+			if entryLimit == limit {
+				entry.limits = append(entry.limits[:i], entry.limits[i+1:]...)
+				break
+			}
+		}
+		if len(entry.limits) > 0 {
+			entry.applyStrictestLimit()
+		}
+		entry.releasedAt = time.Now()
+	}
+
+	return entry.limiter, release
+}
 
 type WebhookData struct {
 	ObjectID          int64            `json:"object_id"`
@@ -31,18 +150,83 @@ type WebhookData struct {
 	Data              []map[string]any `json:"data"`
 }
 
+// Returned once a batch has failed on every attempt
+type WebhookDeliveryError struct {
+	StatusCode *int
+	Attempts   int
+	Err        error
+}
+
+func (e WebhookDeliveryError) Error() string {
+	return fmt.Sprintf("webhook delivery failed after %d attempts: %s", e.Attempts, e.Err.Error())
+}
+
+// Stores batches that could not be delivered so they can be inspected and replayed later
+type WebhookDeadLetterStore interface {
+	StoreDeadLetter(sync views.Sync, object views.Object, data []map[string]any, deliveryError WebhookDeliveryError) error
+}
+
 type WebhookImpl struct {
 	queryService               query.QueryService
 	cryptoService              crypto.CryptoService
 	encryptedEndCustomerApiKey *string
+	deadLetterStore            WebhookDeadLetterStore
+}
+
+type webhookDeliveryConfig struct {
+	url        string
+	signingKey string
+	// rotated signing keys that are still valid, oldest first
+	previousSigningKeys []webhookSigningKey
+	headers             []input.Header
+	endCustomerApiKey   *string
+}
+
+type webhookSigningKey struct {
+	signingKey string
+	expiresAt  time.Time
 }
 
-func NewWebhookConnector(queryService query.QueryService, cryptoService crypto.CryptoService, encryptedEndCustomerApiKey *string) Connector {
+func NewWebhookConnector(queryService query.QueryService, cryptoService crypto.CryptoService, encryptedEndCustomerApiKey *string, deadLetterStore WebhookDeadLetterStore) Connector {
 	return WebhookImpl{
 		queryService:               queryService,
 		cryptoService:              cryptoService,
 		encryptedEndCustomerApiKey: encryptedEndCustomerApiKey, // TODO: does this belong here?
+		deadLetterStore:            deadLetterStore,
+	}
+}
+
+// Redelivers a batch from the dead letter store. Replays run while the request waits, so they make a single attempt
+// instead of retrying with backoff, and a failed replay can be replayed again.
+func ReplayWebhookBatch(
+	ctx context.Context,
+	cryptoService crypto.CryptoService,
+	destinationConnection views.FullConnection,
+	object views.Object,
+	endCustomerID string,
+	encryptedEndCustomerApiKey *string,
+	outputDataList []map[string]any,
+) error {
+	wh := WebhookImpl{
+		cryptoService:              cryptoService,
+		encryptedEndCustomerApiKey: encryptedEndCustomerApiKey,
+	}
+
+	deliveryConfig, err := wh.getDeliveryConfig(destinationConnection)
+	if err != nil {
+		return errors.Wrap(err, "(connectors.ReplayWebhookBatch)")
+	}
+
+	statusCode, _, err := wh.attemptDelivery(ctx, object, endCustomerID, outputDataList, *deliveryConfig)
+	if err != nil {
+		return WebhookDeliveryError{
+			StatusCode: statusCode,
+			Attempts:   1,
+			Err:        err,
+		}
 	}
+
+	return nil
 }
 
 func (wh WebhookImpl) Read(
@@ -50,7 +234,7 @@ func (wh WebhookImpl) Read(
 	sourceConnection views.FullConnection,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC chan<- []data.Row,
+	rowsC chan<- RowBatch,
 	readOutputC chan<- ReadOutput,
 	errC chan<- error,
 ) {
@@ -64,47 +248,86 @@ func (wh WebhookImpl) Write(
 	object views.Object,
 	sync views.Sync,
 	fieldMappings []views.FieldMapping,
-	rowsC <-chan []data.Row,
+	rowsC <-chan RowBatch,
 	writeOutputC chan<- WriteOutput,
 	errC chan<- error,
 ) {
-	// TODO: allow customizing the rate limit
-	limiter := rate.NewLimiter(REFILL_RATE, MAX_BURST)
+	webhookOptions := destinationOptions.WebhookOptions.withDefaults()
 
-	decryptedSigningKey, err := wh.cryptoService.DecryptWebhookSigningKey(destinationConnection.Credentials)
+	deliveryConfig, err := wh.getDeliveryConfig(destinationConnection)
 	if err != nil {
 		errC <- err
 		return
 	}
 
-	var decryptedEndCustomerApiKey *string
-	if wh.encryptedEndCustomerApiKey != nil {
-		decryptedEndCustomerApiKey, err = wh.cryptoService.DecryptEndCustomerApiKey(*wh.encryptedEndCustomerApiKey)
+	limiter, releaseLimiter := acquireWebhookLimiter(deliveryConfig.url, webhookOptions.RequestsPerSecond, webhookOptions.Burst)
+	defer releaseLimiter()
+
+	envelopeSize, err := wh.getEnvelopeSize(object, sync.EndCustomerID, *deliveryConfig)
+	if err != nil {
+		errC <- err
+		return
+	}
+
+	rowsWritten := 0
+	rowsDeadLettered := 0
+	numDeadLetters := 0
+	deliver := func(outputDataList []map[string]any) error {
+		err := limiter.Wait(ctx)
+		if err != nil {
+			return errors.Wrap(err, "(connectors.WebhookImpl.Write) waiting for rate limit")
+		}
+
+		err = wh.sendData(ctx, object, sync.EndCustomerID, outputDataList, *deliveryConfig)
+		if err == nil {
+			rowsWritten += len(outputDataList)
+			return nil
+		}
+
+		var deliveryError WebhookDeliveryError
+		if !errors.As(err, &deliveryError) || wh.deadLetterStore == nil {
+			return err
+		}
+
+		err = wh.deadLetterStore.StoreDeadLetter(sync, object, outputDataList, deliveryError)
 		if err != nil {
-			errC <- err
-			return
+			return errors.Wrap(err, "(connectors.WebhookImpl.Write) storing dead letter")
+		}
+
+		rowsDeadLettered += len(outputDataList)
+		numDeadLetters++
+		if numDeadLetters > webhookOptions.MaxDeadLetters {
+			return errors.NewCustomerVisibleError(fmt.Sprintf(
+				"webhook delivery failed for %d batches, most recently with: %s", numDeadLetters, deliveryError.Error(),
+			))
 		}
+
+		return nil
 	}
 
 	orderedObjectFields := wh.createOrderedObjectFields(object.ObjectFields, fieldMappings)
 	outputDataList := []map[string]any{}
+	payloadSize := envelopeSize
 
-	rowsWritten := 0
+	batchesCommitted := 0
 	for {
-		currentBatchSize := 0
-		rows, more := <-rowsC
+		rowBatch, more := <-rowsC
 		if !more {
 			break
 		}
 
-		rowsWritten += len(rows)
-		for _, row := range rows {
+		for rowIndex, row := range rowBatch.Rows {
+			deleted := rowBatch.Operation(rowIndex) == data.RowOperationDelete
 			outputData := map[string]any{}
 			for i, value := range row {
 				fieldMapping := fieldMappings[i]
 				destFieldName := orderedObjectFields[i].Name
-				// add raw values to the json object even if they're nil
+				// add raw values to the json object even if they're nil, except in tombstones which only have the key
 				if fieldMapping.IsJsonField {
+					if deleted {
+						continue
+					}
+
 					existing, ok := outputData[destFieldName]
 					if !ok {
 						existing = make(map[string]any)
@@ -118,72 +341,297 @@ func (wh WebhookImpl) Write(
 					}
 				}
 			}
-			outputDataList = append(outputDataList, outputData)
+			if deleted {
+				outputData[WEBHOOK_DELETED_FIELD] = true
+			}
+
+			if webhookOptions.MaxPayloadBytes > 0 {
+				marshalled, err := json.Marshal(outputData)
+				if err != nil {
+					errC <- errors.Wrap(err, "(connectors.WebhookImpl.Write) marshalling row")
+					return
+				}
+
+				// rows are separated by a comma in the payload
+				rowSize := len(marshalled) + 1
+				if envelopeSize+rowSize > webhookOptions.MaxPayloadBytes {
+					errC <- errors.NewCustomerVisibleError(fmt.Sprintf(
+						"a single row is %d bytes, which exceeds the webhook max payload size of %d bytes", envelopeSize+rowSize, webhookOptions.MaxPayloadBytes,
+					))
+					return
+				}
+
+				if payloadSize+rowSize > webhookOptions.MaxPayloadBytes {
+					err := deliver(outputDataList)
+					if err != nil {
+						errC <- err
+						return
+					}
+
+					outputDataList = []map[string]any{}
+					payloadSize = envelopeSize
+				}
 
-			currentBatchSize++
-			// TODO: allow customizing batch size
-			if currentBatchSize == MAX_WEBHOOK_BATCH_SIZE {
-				// TODO: add retry
-				limiter.Wait(ctx)
-				err := wh.sendData(object, sync.EndCustomerID, decryptedEndCustomerApiKey, outputDataList, destinationConnection.Host, *decryptedSigningKey)
+				payloadSize += rowSize
+			}
+
+			outputDataList = append(outputDataList, outputData)
+			if len(outputDataList) == webhookOptions.BatchSize {
+				err := deliver(outputDataList)
 				if err != nil {
 					errC <- err
 					return
 				}
 
-				currentBatchSize = 0
-				outputDataList = nil
+				outputDataList = []map[string]any{}
+				payloadSize = envelopeSize
 			}
 		}
 
-		if currentBatchSize > 0 {
-			err := wh.sendData(object, sync.EndCustomerID, decryptedEndCustomerApiKey, outputDataList, destinationConnection.Host, *decryptedSigningKey)
+		if len(outputDataList) > 0 {
+			err := deliver(outputDataList)
 			if err != nil {
 				errC <- err
 				return
 			}
+
+			outputDataList = []map[string]any{}
+			payloadSize = envelopeSize
+		}
+
+		// every row in the batch has been delivered or stored as a dead letter, so it must not be sent again on retry
+		batchesCommitted++
+		writeOutputC <- WriteOutput{
+			RowsWritten:      rowsWritten,
+			RowsDeadLettered: rowsDeadLettered,
+			BatchesCommitted: batchesCommitted,
 		}
 	}
 
 	writeOutputC <- WriteOutput{
-		RowsWritten: rowsWritten,
+		RowsWritten:      rowsWritten,
+		RowsDeadLettered: rowsDeadLettered,
+		BatchesCommitted: batchesCommitted,
+		Done:             true,
 	}
 
 	close(errC)
 }
 
-func (wh WebhookImpl) sendData(object views.Object, endCustomerID string, endCustomerApiKey *string, outputDataList []map[string]any, webhookUrl string, decryptedSigningKey string) error {
-	webhookData := WebhookData{
+// Size of a payload with no rows, so batches can be kept under the max payload size
+func (wh WebhookImpl) getEnvelopeSize(object views.Object, endCustomerID string, deliveryConfig webhookDeliveryConfig) (int, error) {
+	marshalled, err := json.Marshal(WebhookData{
 		ObjectID:          object.ID,
 		ObjectName:        object.DisplayName,
 		EndCustomerID:     endCustomerID,
-		EndCustomerApiKey: endCustomerApiKey,
+		EndCustomerApiKey: deliveryConfig.endCustomerApiKey,
 		FabraTimestamp:    time.Now().Unix(),
+		Data:              []map[string]any{},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "(connectors.WebhookImpl.getEnvelopeSize)")
+	}
+
+	return len(marshalled), nil
+}
+
+func (wh WebhookImpl) getDeliveryConfig(destinationConnection views.FullConnection) (*webhookDeliveryConfig, error) {
+	decryptedSigningKey, err := wh.cryptoService.DecryptWebhookSigningKey(destinationConnection.Credentials)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.WebhookImpl.getDeliveryConfig) decrypting signing key")
+	}
+
+	headers, err := wh.getHeaders(destinationConnection)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.WebhookImpl.getDeliveryConfig)")
+	}
+
+	var decryptedEndCustomerApiKey *string
+	if wh.encryptedEndCustomerApiKey != nil {
+		decryptedEndCustomerApiKey, err = wh.cryptoService.DecryptEndCustomerApiKey(*wh.encryptedEndCustomerApiKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.WebhookImpl.getDeliveryConfig) decrypting end customer API key")
+		}
+	}
+
+	deliveryConfig := webhookDeliveryConfig{
+		url:               destinationConnection.Host,
+		signingKey:        *decryptedSigningKey,
+		headers:           headers,
+		endCustomerApiKey: decryptedEndCustomerApiKey,
+	}
+
+	var previousSigningKeys []models.PreviousSigningKey
+	if destinationConnection.PreviousSigningKeys != "" {
+		err = json.Unmarshal([]byte(destinationConnection.PreviousSigningKeys), &previousSigningKeys)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.WebhookImpl.getDeliveryConfig) unmarshalling previous signing keys")
+		}
+	}
+
+	for _, previousSigningKey := range previousSigningKeys {
+		if !time.Now().Before(previousSigningKey.ExpiresAt) {
+			continue
+		}
+
+		decryptedPreviousSigningKey, err := wh.cryptoService.DecryptWebhookSigningKey(previousSigningKey.EncryptedSigningKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "(connectors.WebhookImpl.getDeliveryConfig) decrypting previous signing key")
+		}
+		deliveryConfig.previousSigningKeys = append(deliveryConfig.previousSigningKeys, webhookSigningKey{
+			signingKey: *decryptedPreviousSigningKey,
+			expiresAt:  previousSigningKey.ExpiresAt,
+		})
+	}
+
+	return &deliveryConfig, nil
+}
+
+// Keys that payloads should currently be signed with, starting with the newest
+func (c webhookDeliveryConfig) getSigningKeys(now time.Time) []string {
+	signingKeys := []string{c.signingKey}
+	for i := len(c.previousSigningKeys) - 1; i >= 0; i-- {
+		if now.Before(c.previousSigningKeys[i].expiresAt) {
+			signingKeys = append(signingKeys, c.previousSigningKeys[i].signingKey)
+		}
+	}
+
+	return signingKeys
+}
+
+func (wh WebhookImpl) getHeaders(destinationConnection views.FullConnection) ([]input.Header, error) {
+	// custom headers are stored encrypted in the password column
+	if destinationConnection.Password == "" {
+		return nil, nil
+	}
+
+	decryptedHeaders, err := wh.cryptoService.DecryptConnectionCredentials(destinationConnection.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.WebhookImpl.getHeaders) decrypting headers")
+	}
+
+	var headers []input.Header
+	err = json.Unmarshal([]byte(*decryptedHeaders), &headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "(connectors.WebhookImpl.getHeaders) unmarshalling headers")
+	}
+
+	return headers, nil
+}
+
+// Retries network errors and non-2xx responses with exponential backoff, returning a WebhookDeliveryError once all attempts fail
+func (wh WebhookImpl) sendData(ctx context.Context, object views.Object, endCustomerID string, outputDataList []map[string]any, deliveryConfig webhookDeliveryConfig) error {
+	var lastErr error
+	var lastStatusCode *int
+	for attempt := 1; attempt <= MAX_WEBHOOK_ATTEMPTS; attempt++ {
+		statusCode, retryAfter, err := wh.attemptDelivery(ctx, object, endCustomerID, outputDataList, deliveryConfig)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		lastStatusCode = statusCode
+		if attempt == MAX_WEBHOOK_ATTEMPTS {
+			break
+		}
+
+		wait := getWebhookBackoff(attempt)
+		if retryAfter != nil {
+			wait = *retryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "(connectors.WebhookImpl.sendData)")
+		case <-time.After(wait):
+		}
+	}
+
+	return WebhookDeliveryError{
+		StatusCode: lastStatusCode,
+		Attempts:   MAX_WEBHOOK_ATTEMPTS,
+		Err:        lastErr,
+	}
+}
+
+// Returns the status code and requested retry delay alongside any error, so the caller can decide how to retry
+func (wh WebhookImpl) attemptDelivery(ctx context.Context, object views.Object, endCustomerID string, outputDataList []map[string]any, deliveryConfig webhookDeliveryConfig) (*int, *time.Duration, error) {
+	now := time.Now()
+	webhookData := WebhookData{
+		ObjectID:          object.ID,
+		ObjectName:        object.DisplayName,
+		EndCustomerID:     endCustomerID,
+		EndCustomerApiKey: deliveryConfig.endCustomerApiKey,
+		FabraTimestamp:    now.Unix(),
 		Data:              outputDataList,
 	}
 	marshalled, err := json.Marshal(webhookData)
 	if err != nil {
-		return errors.Wrap(err, "(connectors.WebhookImpl.sendData)")
+		return nil, nil, errors.Wrap(err, "(connectors.WebhookImpl.attemptDelivery)")
 	}
 
-	request, _ := http.NewRequest("POST", webhookUrl, bytes.NewBuffer(marshalled))
+	request, err := http.NewRequestWithContext(ctx, "POST", deliveryConfig.url, bytes.NewBuffer(marshalled))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(connectors.WebhookImpl.attemptDelivery)")
+	}
+	for _, header := range deliveryConfig.headers {
+		request.Header.Set(header.Name, header.Value)
+	}
 	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
-	request.Header.Set("X-FABRA-SIGNATURE", wh.signPayload(decryptedSigningKey, marshalled))
+	request.Header.Set(webhooks.SIGNATURE_HEADER, webhooks.CreateSignatureHeader(now, marshalled, deliveryConfig.getSigningKeys(now)...))
+	if !application.IsLegacyWebhookSignatureDisabled() {
+		request.Header.Set(webhooks.LEGACY_SIGNATURE_HEADER, webhooks.ComputeLegacySignature(marshalled, deliveryConfig.signingKey))
+	}
 
 	client := &http.Client{}
 	response, err := client.Do(request)
 	if err != nil {
-		return errors.Wrap(err, "(connectors.WebhookImpl.sendData)")
+		return nil, nil, errors.Wrap(err, "(connectors.WebhookImpl.attemptDelivery)")
 	}
-	response.Body.Close()
+	defer response.Body.Close()
 
-	return nil
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		statusCode := response.StatusCode
+		return &statusCode, getRetryAfter(response), errors.Newf("(connectors.WebhookImpl.attemptDelivery) received status %d", statusCode)
+	}
+
+	return nil, nil, nil
+}
+
+func getWebhookBackoff(attempt int) time.Duration {
+	backoff := WEBHOOK_INITIAL_BACKOFF * time.Duration(1<<(attempt-1))
+	if backoff > WEBHOOK_MAX_BACKOFF {
+		backoff = WEBHOOK_MAX_BACKOFF
+	}
+
+	// add jitter so many failing syncs don't retry in lockstep
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
 }
 
-func (wh WebhookImpl) signPayload(secret string, data []byte) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(data)
-	return hex.EncodeToString(h.Sum(nil))
+// Retry-After can either be a number of seconds or an HTTP date
+func getRetryAfter(response *http.Response) *time.Duration {
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return nil
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if retryTime, err := http.ParseTime(value); err == nil {
+		retryAfter = time.Until(retryTime)
+	} else {
+		return nil
+	}
+
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	if retryAfter > WEBHOOK_MAX_RETRY_AFTER {
+		retryAfter = WEBHOOK_MAX_RETRY_AFTER
+	}
+
+	return &retryAfter
 }
 
 func (wh WebhookImpl) createOrderedObjectFields(objectFields []views.ObjectField, fieldMappings []views.FieldMapping) []views.ObjectField {
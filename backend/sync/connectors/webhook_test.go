@@ -0,0 +1,412 @@
+package connectors_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"go.fabra.io/server/common/data"
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/input"
+	"go.fabra.io/server/common/models"
+	"go.fabra.io/server/common/test"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+	"go.fabra.io/sync/webhooks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// returns the stored value as is, so encrypted fields can be set to plaintext in tests
+type passthroughCryptoService struct {
+	test.MockCryptoService
+}
+
+func (cs passthroughCryptoService) DecryptConnectionCredentials(encrypted string) (*string, error) {
+	return &encrypted, nil
+}
+
+func (cs passthroughCryptoService) DecryptWebhookSigningKey(encrypted string) (*string, error) {
+	return &encrypted, nil
+}
+
+type recordingDeadLetterStore struct {
+	deadLetters chan connectors.WebhookDeliveryError
+}
+
+func (s recordingDeadLetterStore) StoreDeadLetter(sync views.Sync, object views.Object, data []map[string]any, deliveryError connectors.WebhookDeliveryError) error {
+	s.deadLetters <- deliveryError
+	return nil
+}
+
+var _ = Describe("WebhookConnector", func() {
+	var (
+		destinationConnection views.FullConnection
+		sync                  views.Sync
+		fieldMappings         []views.FieldMapping
+		object                views.Object
+	)
+
+	BeforeEach(func() {
+		org := test.CreateOrganization(db)
+		endCustomerID := "abc123"
+		source, _ := test.CreateSource(db, org.ID, endCustomerID)
+		destination, destConn := test.CreateDestination(db, org.ID)
+		destinationConnection = views.ConvertFullConnection(destConn)
+
+		objectModel := test.CreateObject(db, org.ID, destination.ID, models.SyncModeFullAppend)
+		objectFields := test.CreateObjectFields(db, objectModel.ID, []input.ObjectField{
+			{Name: "id", Type: data.FieldTypeInteger},
+		})
+		object = views.ConvertObject(objectModel, objectFields)
+		sync = views.ConvertSync(test.CreateSync(db, org.ID, endCustomerID, source.ID, objectModel.ID, models.SyncModeFullAppend))
+		fieldMappings = views.ConvertFieldMappings(test.CreateFieldMappings(db, sync.ID, []input.FieldMapping{
+			{SourceFieldName: "source_id", SourceFieldType: data.FieldTypeInteger, DestinationFieldId: objectFields[0].ID},
+		}), objectFields)
+	})
+
+	Describe("Write", func() {
+		It("sends custom headers with every request", func() {
+			receivedHeaders := make(chan http.Header, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedHeaders <- r.Header
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+			destinationConnection.Password = `[{"name":"Authorization","value":"Bearer secret"}]`
+
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}})
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(1))
+
+			headers := <-receivedHeaders
+			Expect(headers.Get("Authorization")).To(Equal("Bearer secret"))
+			Expect(headers.Get(webhooks.SIGNATURE_HEADER)).ToNot(BeEmpty())
+		})
+
+		It("signs the exact payload with the current and unexpired previous signing keys", func() {
+			type receivedRequest struct {
+				body            []byte
+				signature       string
+				legacySignature string
+			}
+			receivedRequests := make(chan receivedRequest, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				receivedRequests <- receivedRequest{
+					body:            body,
+					signature:       r.Header.Get(webhooks.SIGNATURE_HEADER),
+					legacySignature: r.Header.Get(webhooks.LEGACY_SIGNATURE_HEADER),
+				}
+			}))
+			defer server.Close()
+
+			// keys rotated more than once within the grace period are all still valid
+			previousSigningKeys, err := json.Marshal([]models.PreviousSigningKey{
+				{EncryptedSigningKey: "expired-key", ExpiresAt: time.Now().Add(-time.Hour)},
+				{EncryptedSigningKey: "first-key", ExpiresAt: time.Now().Add(time.Hour)},
+				{EncryptedSigningKey: "previous-key", ExpiresAt: time.Now().Add(2 * time.Hour)},
+			})
+			Expect(err).To(BeNil())
+			destinationConnection.Host = server.URL
+			destinationConnection.Credentials = "current-key"
+			destinationConnection.PreviousSigningKeys = string(previousSigningKeys)
+
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}})
+			close(rowsC)
+
+			_, err = waitForWrite(writeOutputC, errC)
+			Expect(err).To(BeNil())
+
+			request := <-receivedRequests
+			Expect(webhooks.VerifySignature(request.body, request.signature, "current-key", webhooks.DEFAULT_TOLERANCE)).To(Succeed())
+			Expect(webhooks.VerifySignature(request.body, request.signature, "previous-key", webhooks.DEFAULT_TOLERANCE)).To(Succeed())
+			Expect(webhooks.VerifySignature(request.body, request.signature, "first-key", webhooks.DEFAULT_TOLERANCE)).To(Succeed())
+			Expect(webhooks.VerifySignature(request.body, request.signature, "expired-key", webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrNoValidSignature))
+
+			// receivers of the old header keep working during the deprecation window
+			Expect(request.legacySignature).To(Equal(webhooks.ComputeLegacySignature(request.body, "current-key")))
+		})
+
+		It("stops sending the legacy signature once it is disabled", func() {
+			GinkgoT().Setenv("DISABLE_LEGACY_WEBHOOK_SIGNATURE", "true")
+
+			receivedHeaders := make(chan http.Header, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedHeaders <- r.Header
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}})
+			close(rowsC)
+
+			_, err := waitForWrite(writeOutputC, errC)
+			Expect(err).To(BeNil())
+
+			headers := <-receivedHeaders
+			Expect(headers.Get(webhooks.SIGNATURE_HEADER)).ToNot(BeEmpty())
+			Expect(headers.Get(webhooks.LEGACY_SIGNATURE_HEADER)).To(BeEmpty())
+		})
+
+		It("sends deleted rows as tombstones with only the primary key", func() {
+			receivedBodies := make(chan []byte, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				receivedBodies <- body
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			var rowBatch connectors.RowBatch
+			rowBatch.Add(data.Row{1}, data.RowOperationUpsert)
+			rowBatch.Add(data.Row{2}, data.RowOperationDelete)
+			rowsC <- rowBatch
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(2))
+
+			var webhookData connectors.WebhookData
+			Expect(json.Unmarshal(<-receivedBodies, &webhookData)).To(Succeed())
+			Expect(webhookData.Data).To(Equal([]map[string]any{
+				{"id": float64(1)},
+				{"id": float64(2), connectors.WEBHOOK_DELETED_FIELD: true},
+			}))
+		})
+
+		It("retries failed requests", func() {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}})
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(1))
+			Expect(requests.Load()).To(Equal(int32(2)))
+		})
+
+		It("stores batches that fail every attempt as dead letters", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			deadLetterStore := recordingDeadLetterStore{deadLetters: make(chan connectors.WebhookDeliveryError, 1)}
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, deadLetterStore)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}})
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(0))
+			Expect(writeOutput.RowsDeadLettered).To(Equal(1))
+
+			deliveryError := <-deadLetterStore.deadLetters
+			Expect(deliveryError.Attempts).To(Equal(connectors.MAX_WEBHOOK_ATTEMPTS))
+			Expect(*deliveryError.StatusCode).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("fails once more batches than the configured limit are dead lettered", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			deadLetterStore := recordingDeadLetterStore{deadLetters: make(chan connectors.WebhookDeliveryError, 2)}
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, deadLetterStore)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			destinationOptions := connectors.DestinationOptions{
+				WebhookOptions: connectors.WebhookOptions{BatchSize: 1, MaxDeadLetters: 1},
+			}
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, destinationOptions, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}, {2}})
+			close(rowsC)
+
+			_, err := waitForWrite(writeOutputC, errC)
+			Expect(err).To(MatchError(ContainSubstring("webhook delivery failed for 2 batches")))
+			Expect(deadLetterStore.deadLetters).To(HaveLen(2))
+		})
+
+		It("reports each batch once it has been delivered", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				connector.Write(context.TODO(), destinationConnection, connectors.DestinationOptions{}, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}, {2}})
+			Expect(<-writeOutputC).To(Equal(connectors.WriteOutput{RowsWritten: 2, BatchesCommitted: 1}))
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{3}})
+			Expect(<-writeOutputC).To(Equal(connectors.WriteOutput{RowsWritten: 3, BatchesCommitted: 2}))
+
+			close(rowsC)
+			Expect(<-writeOutputC).To(Equal(connectors.WriteOutput{RowsWritten: 3, BatchesCommitted: 2, Done: true}))
+		})
+
+		It("splits rows into batches of the configured size", func() {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			connector := connectors.NewWebhookConnector(nil, passthroughCryptoService{}, nil, nil)
+			destinationOptions := connectors.DestinationOptions{
+				WebhookOptions: connectors.WebhookOptions{BatchSize: 2},
+			}
+			rowsC := make(chan connectors.RowBatch)
+			writeOutputC := make(chan connectors.WriteOutput)
+			errC := make(chan error)
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { close(writeOutputC) }() // close the output channel so the test completes in case of an error
+				connector.Write(context.TODO(), destinationConnection, destinationOptions, object, sync, fieldMappings, rowsC, writeOutputC, errC)
+			}()
+
+			rowsC <- connectors.NewRowBatch([]data.Row{{1}, {2}, {3}, {4}, {5}})
+			close(rowsC)
+
+			writeOutput, err := waitForWrite(writeOutputC, errC)
+			Expect(err).To(BeNil())
+			Expect(writeOutput.RowsWritten).To(Equal(5))
+			Expect(requests.Load()).To(Equal(int32(3)))
+		})
+	})
+
+	Describe("ReplayWebhookBatch", func() {
+		It("makes a single attempt so the replay request doesn't wait on retries", func() {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			destinationConnection.Host = server.URL
+
+			err := connectors.ReplayWebhookBatch(context.TODO(), passthroughCryptoService{}, destinationConnection, object, sync.EndCustomerID, nil, []map[string]any{{"id": 1}})
+
+			var deliveryError connectors.WebhookDeliveryError
+			Expect(errors.As(err, &deliveryError)).To(BeTrue())
+			Expect(deliveryError.Attempts).To(Equal(1))
+			Expect(*deliveryError.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(requests.Load()).To(Equal(int32(1)))
+		})
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/repositories/connections"
 	"go.fabra.io/server/common/repositories/destinations"
 	"go.fabra.io/server/common/repositories/objects"
@@ -92,5 +93,46 @@ func (a *Activities) FetchConfig(ctx context.Context, input FetchConfigInput) (*
 		syncConfig.DestinationOptions.StagingBucket = destination.StagingBucket.String
 	}
 
+	if destinationConnection.ConnectionType == models.ConnectionTypeWebhook {
+		syncConfig.DestinationOptions.WebhookOptions = getWebhookOptions(*destination, *object)
+	}
+
 	return &syncConfig, nil
 }
+
+// Settings on the object take precedence over the destination. Anything left unset uses the connector defaults.
+func getWebhookOptions(destination models.Destination, object models.Object) connectors.WebhookOptions {
+	webhookOptions := connectors.WebhookOptions{}
+
+	if object.WebhookRequestsPerSecond != nil {
+		webhookOptions.RequestsPerSecond = *object.WebhookRequestsPerSecond
+	} else if destination.WebhookRequestsPerSecond != nil {
+		webhookOptions.RequestsPerSecond = *destination.WebhookRequestsPerSecond
+	}
+
+	if object.WebhookBurst != nil {
+		webhookOptions.Burst = *object.WebhookBurst
+	} else if destination.WebhookBurst != nil {
+		webhookOptions.Burst = *destination.WebhookBurst
+	}
+
+	if object.WebhookBatchSize != nil {
+		webhookOptions.BatchSize = *object.WebhookBatchSize
+	} else if destination.WebhookBatchSize != nil {
+		webhookOptions.BatchSize = *destination.WebhookBatchSize
+	}
+
+	if object.WebhookMaxPayloadBytes != nil {
+		webhookOptions.MaxPayloadBytes = *object.WebhookMaxPayloadBytes
+	} else if destination.WebhookMaxPayloadBytes != nil {
+		webhookOptions.MaxPayloadBytes = *destination.WebhookMaxPayloadBytes
+	}
+
+	if object.WebhookMaxDeadLetters != nil {
+		webhookOptions.MaxDeadLetters = *object.WebhookMaxDeadLetters
+	} else if destination.WebhookMaxDeadLetters != nil {
+		webhookOptions.MaxDeadLetters = *destination.WebhookMaxDeadLetters
+	}
+
+	return webhookOptions
+}
@@ -0,0 +1,82 @@
+package temporal
+
+import (
+	"time"
+
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/repositories/sync_primary_key_snapshots"
+	"go.fabra.io/server/common/repositories/sync_primary_keys"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+	"gorm.io/gorm"
+)
+
+type primaryKeyStore struct {
+	db *gorm.DB
+}
+
+func newPrimaryKeyStore(db *gorm.DB) connectors.PrimaryKeyStore {
+	return primaryKeyStore{db: db}
+}
+
+func (s primaryKeyStore) LoadPrimaryKeySnapshot(sync views.Sync) (*connectors.PrimaryKeySnapshot, error) {
+	snapshot, err := sync_primary_key_snapshots.LoadSnapshotBySyncID(s.db, sync.ID)
+	if err != nil {
+		if errors.IsRecordNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "(temporal.primaryKeyStore.LoadPrimaryKeySnapshot)")
+	}
+
+	primaryKeySnapshot := connectors.PrimaryKeySnapshot{}
+	if snapshot.DiffedAt.Valid {
+		primaryKeySnapshot.DiffedAt = &snapshot.DiffedAt.Time
+	}
+
+	return &primaryKeySnapshot, nil
+}
+
+func (s primaryKeyStore) ClearStagedPrimaryKeys(sync views.Sync) error {
+	err := sync_primary_keys.ClearStagedKeys(s.db, sync.ID)
+	if err != nil {
+		return errors.Wrap(err, "(temporal.primaryKeyStore.ClearStagedPrimaryKeys)")
+	}
+
+	return nil
+}
+
+func (s primaryKeyStore) StagePrimaryKeys(sync views.Sync, keys []string, deleted bool) error {
+	err := sync_primary_keys.StageKeys(s.db, sync.ID, keys, deleted)
+	if err != nil {
+		return errors.Wrap(err, "(temporal.primaryKeyStore.StagePrimaryKeys)")
+	}
+
+	return nil
+}
+
+func (s primaryKeyStore) StageSourcePrimaryKeys(sync views.Sync, keys []string) error {
+	err := sync_primary_keys.StageSourceKeys(s.db, sync.ID, keys)
+	if err != nil {
+		return errors.Wrap(err, "(temporal.primaryKeyStore.StageSourcePrimaryKeys)")
+	}
+
+	return nil
+}
+
+func (s primaryKeyStore) LoadMissingPrimaryKeys(sync views.Sync, afterKey string, limit int) ([]string, error) {
+	keys, err := sync_primary_keys.LoadMissingKeys(s.db, sync.ID, afterKey, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "(temporal.primaryKeyStore.LoadMissingPrimaryKeys)")
+	}
+
+	return keys, nil
+}
+
+func (s primaryKeyStore) CommitPrimaryKeys(sync views.Sync, diffedAt *time.Time) error {
+	err := sync_primary_keys.CommitStagedKeys(s.db, sync.OrganizationID, sync.ID, diffedAt)
+	if err != nil {
+		return errors.Wrap(err, "(temporal.primaryKeyStore.CommitPrimaryKeys)")
+	}
+
+	return nil
+}
@@ -16,14 +16,15 @@ const (
 )
 
 type RecordStatusInput struct {
-	OrganizationID int64
-	SyncID         int64
-	SyncRun        models.SyncRun
-	WorkflowID     string
-	UpdateType     UpdateType
-	NewStatus      models.SyncRunStatus
-	RowsWritten    int
-	Error          *string
+	OrganizationID   int64
+	SyncID           int64
+	SyncRun          models.SyncRun
+	WorkflowID       string
+	UpdateType       UpdateType
+	NewStatus        models.SyncRunStatus
+	RowsWritten      int
+	RowsDeadLettered int
+	Error            *string
 }
 
 func (a *Activities) RecordStatus(ctx context.Context, input RecordStatusInput) (*models.SyncRun, error) {
@@ -32,7 +33,7 @@ func (a *Activities) RecordStatus(ctx context.Context, input RecordStatusInput)
 		// This is a no-op if the sync run already exists
 		return sync_runs.CreateOrStartSyncRun(a.Db, input.OrganizationID, input.SyncID, input.WorkflowID)
 	case UpdateTypeComplete:
-		return sync_runs.UpdateSyncRun(a.Db, &input.SyncRun, input.NewStatus, input.Error, &input.RowsWritten)
+		return sync_runs.UpdateSyncRun(a.Db, &input.SyncRun, input.NewStatus, input.Error, &input.RowsWritten, &input.RowsDeadLettered)
 	default:
 		return nil, errors.Newf("unexpected update type: %s", input.UpdateType)
 	}
@@ -2,6 +2,9 @@ package temporal
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	gosync "sync"
 	"time"
 
 	"go.fabra.io/server/common/crypto"
@@ -9,6 +12,8 @@ import (
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/models"
 	"go.fabra.io/server/common/query"
+	"go.fabra.io/server/common/repositories/sync_runs"
+	"go.fabra.io/server/common/repositories/syncs"
 	"go.fabra.io/server/common/views"
 	"go.fabra.io/sync/connectors"
 	"go.temporal.io/sdk/activity"
@@ -16,11 +21,30 @@ import (
 
 const FABRA_STAGING_BUCKET = "fabra-staging"
 
-type ReplicateInput = SyncConfig
+const HEARTBEAT_INTERVAL = 30 * time.Second
+
+// How long connecting and loading can take before heartbeats stop, since they can't report progress until they finish
+const MAX_PHASE_WAIT = time.Hour
+
+type ReplicateInput struct {
+	SyncConfig
+	SyncRunID int64
+}
 
 type ReplicateOutput struct {
-	RowsWritten    int
-	CursorPosition *string
+	RowsWritten      int
+	RowsDeadLettered int
+	CursorPosition   *data.CursorState
+}
+
+// Progress committed to the destination, recorded in the activity heartbeat so a retried activity can resume reading
+// after the last committed batch instead of sending every row again
+type ReplicateCheckpoint struct {
+	BatchesCommitted int
+	RowsRead         int
+	RowsWritten      int
+	RowsDeadLettered int
+	CursorPosition   *data.CursorState
 }
 
 type FormatToken struct {
@@ -29,13 +53,28 @@ type FormatToken struct {
 }
 
 func (a *Activities) Replicate(ctx context.Context, input ReplicateInput) (*ReplicateOutput, error) {
+	checkpoint, err := getReplicateCheckpoint(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "(temporal.Replicate) getReplicateCheckpoint")
+	}
+
+	// only reads ordered by a cursor can resume from where a previous attempt left off
+	resumable := input.Sync.SyncMode.UsesCursor()
+	if resumable && checkpoint.CursorPosition != nil {
+		input.Sync.CursorPosition = checkpoint.CursorPosition
+	} else {
+		checkpoint = ReplicateCheckpoint{}
+	}
+
 	cryptoService := crypto.NewCryptoService()
 	queryService := query.NewQueryService(cryptoService)
 
-	rowsC := make(chan []data.Row)
+	readRowsC := make(chan connectors.RowBatch)
+	rowsC := make(chan connectors.RowBatch)
 	readOutputC := make(chan connectors.ReadOutput)
 	writeOutputC := make(chan connectors.WriteOutput)
 	readErrC := make(chan error)
+	transformErrC := make(chan error)
 	writeErrC := make(chan error)
 	doneC := make(chan bool)
 
@@ -44,20 +83,96 @@ func (a *Activities) Replicate(ctx context.Context, input ReplicateInput) (*Repl
 		return nil, errors.Wrap(err, "(temporal.Replicate) getSourceConnector")
 	}
 
-	destConnector, err := getDestinationConnector(ctx, input.DestinationConnection, queryService, cryptoService, input.EncryptedEndCustomerApiKey)
+	destConnector, err := getDestinationConnector(ctx, input.DestinationConnection, queryService, cryptoService, input.EncryptedEndCustomerApiKey, newWebhookDeadLetterStore(a.Db))
 	if err != nil {
 		return nil, errors.Wrap(err, "(temporal.Replicate) getDestinationConnector")
 	}
 
+	// fields cast by the schema drift policy are read with their new type
+	input.FieldMappings, err = a.checkSchemaDrift(ctx, input, queryService)
+	if err != nil {
+		return nil, errors.Wrap(err, "(temporal.Replicate) checkSchemaDrift")
+	}
+
+	rowTransformer, err := connectors.NewRowTransformer(input.FieldMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "(temporal.Replicate) NewRowTransformer")
+	}
+
+	deleteDetector, primaryKeyReader, err := getDeleteDetector(input, sourceConnector, newPrimaryKeyStore(a.Db))
+	if err != nil {
+		return nil, errors.Wrap(err, "(temporal.Replicate) getDeleteDetector")
+	}
+
+	// a resumed attempt didn't see the keys written by previous attempts, so it diffs every key to recover them
+	detectDeletes := primaryKeyReader != nil && (checkpoint.CursorPosition != nil || deleteDetector.Due(time.Now()))
+
+	progress := newReplicateProgress(checkpoint, resumable)
+
 	go safeCall(func() {
-		sourceConnector.Read(ctx, input.SourceConnection, input.Sync, input.FieldMappings, rowsC, readOutputC, readErrC)
+		sourceConnector.Read(ctx, input.SourceConnection, input.Sync, input.FieldMappings, readRowsC, readOutputC, readErrC)
 	}, readErrC)
 
+	// count and transform the rows as they pass from the reader to the writer, followed by any rows deleted from the
+	// source once every row has been read
 	go safeCall(func() {
-		destConnector.Write(ctx, input.DestinationConnection, input.DestinationOptions, input.Object, input.Sync, input.FieldMappings, rowsC, writeOutputC, writeErrC)
+		// deleted rows are transformed too, so their keys match the written rows
+		sendDeletes := func(rowBatch connectors.RowBatch) error {
+			err := rowTransformer.Transform(rowBatch.Rows)
+			if err != nil {
+				return err
+			}
+			rowsC <- rowBatch
+			return nil
+		}
+
+		for rowBatch := range readRowsC {
+			progress.recordRowsRead(rowBatch.Rows)
+			if deleteDetector != nil {
+				err := deleteDetector.Track(rowBatch)
+				if err != nil {
+					transformErrC <- err
+					return
+				}
+			}
+
+			err := rowTransformer.Transform(rowBatch.Rows)
+			if err != nil {
+				transformErrC <- err
+				return
+			}
+			rowsC <- rowBatch
+		}
+
+		if detectDeletes {
+			iterator, err := primaryKeyReader.ReadPrimaryKeys(ctx, input.SourceConnection, input.Sync)
+			if err != nil {
+				transformErrC <- err
+				return
+			}
+
+			err = deleteDetector.DetectDeletes(ctx, iterator, sendDeletes)
+			if err != nil {
+				transformErrC <- err
+				return
+			}
+		}
+
+		if deleteDetector != nil {
+			err := deleteDetector.DetectSnapshotDeletes(sendDeletes)
+			if err != nil {
+				transformErrC <- err
+				return
+			}
+		}
+		close(rowsC)
+	}, transformErrC)
+
+	go safeCall(func() {
+		destConnector.Write(ctx, input.DestinationConnection, input.DestinationOptions, input.Object, input.Sync, rowTransformer.FieldMappings(), rowsC, writeOutputC, writeErrC)
 	}, writeErrC)
 
-	go heartbeat(ctx, doneC) // TODO: heartbeat from the write/read methods to ensure the worker is making progress
+	go a.heartbeat(ctx, doneC, progress, input.SyncRunID)
 
 	var readOutput connectors.ReadOutput
 	var writeOutput connectors.WriteOutput
@@ -73,26 +188,297 @@ func (a *Activities) Replicate(ctx context.Context, input ReplicateInput) (*Repl
 			if err != nil {
 				return nil, errors.Wrap(err, "(temporal.Replicate) readErrC")
 			}
+		case err = <-transformErrC:
+			return nil, errors.Wrap(err, "(temporal.Replicate) transformErrC")
 		case err = <-writeErrC:
 			if err != nil {
 				return nil, errors.Wrap(err, "(temporal.Replicate) writeErrC")
 			}
-		case readOutput = <-readOutputC:
-			readDone = true
-		case writeOutput = <-writeOutputC:
-			writeDone = true
+		case output := <-readOutputC:
+			if output.Done {
+				readOutput = output
+				readDone = true
+				progress.setPhase(models.SyncRunPhaseLoading)
+			} else if progress.recordRead(output) {
+				activity.RecordHeartbeat(ctx, progress.getCheckpoint())
+			}
+		case output := <-writeOutputC:
+			if output.Done {
+				writeOutput = output
+				writeDone = true
+			} else if progress.recordWrite(output) {
+				activity.RecordHeartbeat(ctx, progress.getCheckpoint())
+			}
 		}
 	}
 
 	// signal the heartbeat worker that the replication is finished
 	doneC <- true
 
+	// keys are only stored once every row is written, so a failed run is diffed against the keys of the last run
+	if deleteDetector != nil {
+		err = deleteDetector.Commit()
+		if err != nil {
+			return nil, errors.Wrap(err, "(temporal.Replicate) deleteDetector.Commit")
+		}
+	}
+
+	// a resumed read that found no new rows leaves the cursor where the previous attempt committed it
+	cursorPosition := readOutput.CursorPosition
+	if cursorPosition == nil {
+		cursorPosition = checkpoint.CursorPosition
+	}
+
 	return &ReplicateOutput{
-		RowsWritten:    writeOutput.RowsWritten,
-		CursorPosition: readOutput.CursorPosition,
+		RowsWritten:      checkpoint.RowsWritten + writeOutput.RowsWritten,
+		RowsDeadLettered: checkpoint.RowsDeadLettered + writeOutput.RowsDeadLettered,
+		CursorPosition:   cursorPosition,
 	}, nil
 }
 
+func getReplicateCheckpoint(ctx context.Context) (ReplicateCheckpoint, error) {
+	var checkpoint ReplicateCheckpoint
+	if activity.HasHeartbeatDetails(ctx) {
+		err := activity.GetHeartbeatDetails(ctx, &checkpoint)
+		if err != nil {
+			return ReplicateCheckpoint{}, errors.Wrap(err, "(temporal.getReplicateCheckpoint)")
+		}
+	}
+
+	return checkpoint, nil
+}
+
+// Matches the checkpoints sent by the reader after each batch with the batches committed by the writer. The reader
+// may be several batches ahead of the writer, and the writer may commit a batch before its checkpoint is received.
+type replicateProgress struct {
+	mu                  gosync.Mutex
+	resumable           bool
+	base                ReplicateCheckpoint
+	checkpoint          ReplicateCheckpoint
+	checkpointedBatches int
+	batchesCommitted    int
+	readCheckpoints     map[int]*data.CursorState
+	writeOutputs        map[int]connectors.WriteOutput
+
+	// the rows read by the end of each batch not yet checkpointed
+	batchesRead int
+	rowsRead    map[int]int
+
+	phase                   models.SyncRunPhase
+	phaseStartedAt          time.Time
+	currentRowsRead         int
+	bytesRead               int64
+	currentRowsWritten      int
+	currentRowsDeadLettered int
+}
+
+type replicateProgressSnapshot struct {
+	phase            models.SyncRunPhase
+	phaseStartedAt   time.Time
+	rowsRead         int
+	rowsWritten      int
+	rowsDeadLettered int
+	bytesRead        int64
+}
+
+func newReplicateProgress(base ReplicateCheckpoint, resumable bool) *replicateProgress {
+	return &replicateProgress{
+		resumable:       resumable,
+		base:            base,
+		checkpoint:      base,
+		readCheckpoints: make(map[int]*data.CursorState),
+		writeOutputs:    make(map[int]connectors.WriteOutput),
+		rowsRead:        make(map[int]int),
+		phase:           models.SyncRunPhaseConnecting,
+		phaseStartedAt:  time.Now(),
+	}
+}
+
+func (p *replicateProgress) recordRowsRead(rows []data.Row) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.updatePhase(models.SyncRunPhaseReplicating)
+	p.currentRowsRead += len(rows)
+	for _, row := range rows {
+		p.bytesRead += row.EstimatedSize()
+	}
+
+	p.batchesRead++
+	if p.resumable {
+		p.rowsRead[p.batchesRead] = p.currentRowsRead
+	}
+}
+
+func (p *replicateProgress) setPhase(phase models.SyncRunPhase) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.updatePhase(phase)
+}
+
+func (p *replicateProgress) updatePhase(phase models.SyncRunPhase) {
+	if phase != p.phase {
+		p.phase = phase
+		p.phaseStartedAt = time.Now()
+	}
+}
+
+// Rows committed by previous attempts are included so the counts cover the whole run
+func (p *replicateProgress) getSnapshot() replicateProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return replicateProgressSnapshot{
+		phase:            p.phase,
+		phaseStartedAt:   p.phaseStartedAt,
+		rowsRead:         p.base.RowsRead + p.currentRowsRead,
+		rowsWritten:      p.base.RowsWritten + p.currentRowsWritten,
+		rowsDeadLettered: p.base.RowsDeadLettered + p.currentRowsDeadLettered,
+		bytesRead:        p.bytesRead,
+	}
+}
+
+// Returns true if the checkpoint advanced
+func (p *replicateProgress) recordRead(readOutput connectors.ReadOutput) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.resumable || readOutput.CursorPosition == nil {
+		return false
+	}
+
+	p.readCheckpoints[readOutput.BatchesRead] = readOutput.CursorPosition
+	return p.advance()
+}
+
+// Returns true if the checkpoint advanced
+func (p *replicateProgress) recordWrite(writeOutput connectors.WriteOutput) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.currentRowsWritten = writeOutput.RowsWritten
+	p.currentRowsDeadLettered = writeOutput.RowsDeadLettered
+	if !p.resumable || writeOutput.BatchesCommitted == p.batchesCommitted {
+		return false
+	}
+
+	p.batchesCommitted = writeOutput.BatchesCommitted
+	p.writeOutputs[writeOutput.BatchesCommitted] = writeOutput
+	return p.advance()
+}
+
+// checkpoints the latest committed batch that has a read checkpoint
+func (p *replicateProgress) advance() bool {
+	for batch := p.batchesCommitted; batch > p.checkpointedBatches; batch-- {
+		cursorPosition, ok := p.readCheckpoints[batch]
+		if !ok {
+			continue
+		}
+
+		p.checkpoint = ReplicateCheckpoint{
+			BatchesCommitted: p.base.BatchesCommitted + batch,
+			RowsRead:         p.base.RowsRead + p.rowsRead[batch],
+			RowsWritten:      p.base.RowsWritten + p.writeOutputs[batch].RowsWritten,
+			RowsDeadLettered: p.base.RowsDeadLettered + p.writeOutputs[batch].RowsDeadLettered,
+			CursorPosition:   cursorPosition,
+		}
+
+		for previous := p.checkpointedBatches; previous <= batch; previous++ {
+			delete(p.readCheckpoints, previous)
+			delete(p.writeOutputs, previous)
+			delete(p.rowsRead, previous)
+		}
+		p.checkpointedBatches = batch
+		return true
+	}
+
+	return false
+}
+
+func (p *replicateProgress) getCheckpoint() ReplicateCheckpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.checkpoint
+}
+
+// Compares the live source schema with the sync's field mappings before anything is read, recording any changes on the
+// run. Returns the field mappings to read with, or a customer-visible error if the object's policy doesn't allow a change.
+func (a *Activities) checkSchemaDrift(ctx context.Context, input ReplicateInput, queryService query.QueryService) ([]views.FieldMapping, error) {
+	// custom joins don't read a single table, and the schemas of MongoDB and DynamoDB are inferred from a sample of
+	// documents that can miss fields that are still there
+	switch input.SourceConnection.ConnectionType {
+	case models.ConnectionTypeMongoDb, models.ConnectionTypeDynamoDb:
+		return input.FieldMappings, nil
+	}
+	if input.Sync.Namespace == nil || input.Sync.TableName == nil {
+		return input.FieldMappings, nil
+	}
+
+	connectionModel := views.ConvertConnectionView(input.SourceConnection)
+	schema, err := queryService.GetSchema(ctx, connectionModel, *input.Sync.Namespace, *input.Sync.TableName)
+	if err != nil {
+		return nil, errors.Wrap(err, "(temporal.checkSchemaDrift) GetSchema")
+	}
+
+	report, fieldMappings := connectors.CheckSchemaDrift(schema, input.Sync, input.FieldMappings, input.Object.SchemaDriftPolicy)
+	if len(report.Changes) > 0 {
+		err = sync_runs.UpdateSyncRunSchemaDrift(a.Db, input.SyncRunID, report)
+		if err != nil {
+			return nil, errors.Wrap(err, "(temporal.checkSchemaDrift) UpdateSyncRunSchemaDrift")
+		}
+	}
+	if report.Failed() {
+		return nil, errors.NewCustomerVisibleError(report.FailureMessage())
+	}
+
+	// the next run only reports fields added after this one
+	if !reflect.DeepEqual(data.Schema(schema), input.Sync.SourceSchema) {
+		sync, err := syncs.LoadSyncByID(a.Db, input.Sync.OrganizationID, input.Sync.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "(temporal.checkSchemaDrift) LoadSyncByID")
+		}
+
+		_, err = syncs.UpdateSourceSchema(a.Db, sync, schema)
+		if err != nil {
+			return nil, errors.Wrap(err, "(temporal.checkSchemaDrift) UpdateSourceSchema")
+		}
+	}
+
+	return fieldMappings, nil
+}
+
+// Returns nil if the sync doesn't detect deletes
+func getDeleteDetector(input ReplicateInput, sourceConnector connectors.Connector, primaryKeyStore connectors.PrimaryKeyStore) (*connectors.DeleteDetector, connectors.PrimaryKeyReader, error) {
+	// MongoDB reads the whole collection again once its change stream can't be resumed, so it tracks the keys it
+	// writes to delete the documents that snapshot doesn't read
+	if input.Sync.SyncMode == models.SyncModeChangeDataCapture && input.SourceConnection.ConnectionType == models.ConnectionTypeMongoDb {
+		deleteDetector, err := connectors.NewDeleteDetector(input.Sync, input.FieldMappings, primaryKeyStore)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "(temporal.getDeleteDetector)")
+		}
+
+		return deleteDetector, nil, nil
+	}
+
+	if input.Sync.DeleteDetectionIntervalSeconds == nil {
+		return nil, nil, nil
+	}
+
+	primaryKeyReader, ok := sourceConnector.(connectors.PrimaryKeyReader)
+	if !ok {
+		return nil, nil, errors.NewCustomerVisibleError(fmt.Sprintf("deletes can't be detected for %s sources", input.SourceConnection.ConnectionType))
+	}
+
+	deleteDetector, err := connectors.NewDeleteDetector(input.Sync, input.FieldMappings, primaryKeyStore)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "(temporal.getDeleteDetector)")
+	}
+
+	return deleteDetector, primaryKeyReader, nil
+}
+
 func getSourceConnector(ctx context.Context, connection views.FullConnection, queryService query.QueryService) (connectors.Connector, error) {
 	connectionModel := views.ConvertConnectionView(connection)
 	switch connection.ConnectionType {
@@ -114,12 +500,14 @@ func getSourceConnector(ctx context.Context, connection views.FullConnection, qu
 		return connectors.NewPostgresConnector(queryService), nil
 	case models.ConnectionTypeMySQL:
 		return connectors.NewMySqlConnector(queryService), nil
+	case models.ConnectionTypeDynamoDb:
+		return connectors.NewDynamoDbConnector(queryService), nil
 	default:
 		return nil, errors.Newf("(temporal.getSourceConnector) source not implemented for %s", connection.ConnectionType)
 	}
 }
 
-func getDestinationConnector(ctx context.Context, connection views.FullConnection, queryService query.QueryService, cryptoService crypto.CryptoService, encryptedEndCustomerApiKey *string) (connectors.Connector, error) {
+func getDestinationConnector(ctx context.Context, connection views.FullConnection, queryService query.QueryService, cryptoService crypto.CryptoService, encryptedEndCustomerApiKey *string, deadLetterStore connectors.WebhookDeadLetterStore) (connectors.Connector, error) {
 	connectionModel := views.ConvertConnectionView(connection)
 	switch connection.ConnectionType {
 	case models.ConnectionTypeBigQuery:
@@ -128,9 +516,19 @@ func getDestinationConnector(ctx context.Context, connection views.FullConnectio
 			return nil, errors.Wrap(err, "(temporal.getDestinationConnector)")
 		}
 		return connectors.NewBigQueryConnector(warehouseClient), nil
+	case models.ConnectionTypeSnowflake:
+		return connectors.NewSnowflakeConnector(queryService), nil
+	case models.ConnectionTypeRedshift:
+		return connectors.NewRedshiftConnector(queryService), nil
+	case models.ConnectionTypePostgres:
+		return connectors.NewPostgresConnector(queryService), nil
+	case models.ConnectionTypeMySQL:
+		return connectors.NewMySqlConnector(queryService), nil
+	case models.ConnectionTypeDynamoDb:
+		return connectors.NewDynamoDbConnector(queryService), nil
 	case models.ConnectionTypeWebhook:
 		// TODO: does end customer api key belong here?
-		return connectors.NewWebhookConnector(queryService, cryptoService, encryptedEndCustomerApiKey), nil
+		return connectors.NewWebhookConnector(queryService, cryptoService, encryptedEndCustomerApiKey, deadLetterStore), nil
 	default:
 		return nil, errors.Newf("(temporal.getDestinationConnector) destination not implemented for %s", connection.ConnectionType)
 	}
@@ -147,14 +545,37 @@ func safeCall(fn func(), errC chan<- error) {
 	fn()
 }
 
-func heartbeat(ctx context.Context, doneC <-chan bool) {
-	timeChan := time.NewTicker(time.Minute).C
+// Heartbeats only while the replication is making progress, so a hung read or write trips the heartbeat timeout
+// instead of running until the activity times out. Connecting and loading wait on a single query or load job, which
+// can't report progress until it finishes, so they heartbeat until they have taken MAX_PHASE_WAIT. Progress is also
+// saved to the sync run so it can be shown live.
+func (a *Activities) heartbeat(ctx context.Context, doneC <-chan bool, progress *replicateProgress, syncRunID int64) {
+	ticker := time.NewTicker(HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+
+	var lastSnapshot *replicateProgressSnapshot
 	for {
-		activity.RecordHeartbeat(ctx)
+		snapshot := progress.getSnapshot()
+		advanced := lastSnapshot == nil || snapshot != *lastSnapshot
+
+		waiting := snapshot.phase != models.SyncRunPhaseReplicating && time.Since(snapshot.phaseStartedAt) < MAX_PHASE_WAIT
+		if advanced || waiting {
+			// heartbeats without details would clear the checkpoint, so always send the latest one
+			activity.RecordHeartbeat(ctx, progress.getCheckpoint())
+		}
+
+		if advanced {
+			// progress is only informational, so failing to save it should not fail the sync
+			_ = sync_runs.UpdateSyncRunProgress(a.Db, syncRunID, snapshot.phase, snapshot.rowsRead, snapshot.rowsWritten, snapshot.rowsDeadLettered, snapshot.bytesRead)
+			lastSnapshot = &snapshot
+		}
+
 		select {
 		case <-doneC:
 			return
-		case <-timeChan:
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 			continue
 		}
 	}
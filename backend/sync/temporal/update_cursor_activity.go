@@ -3,6 +3,7 @@ package temporal
 import (
 	"context"
 
+	"go.fabra.io/server/common/data"
 	"go.fabra.io/server/common/errors"
 	"go.fabra.io/server/common/repositories/syncs"
 	"go.fabra.io/server/common/views"
@@ -10,7 +11,7 @@ import (
 
 type UpdateCursorInput struct {
 	Sync           views.Sync
-	CursorPosition string
+	CursorPosition data.CursorState
 }
 
 func (a *Activities) UpdateCursor(ctx context.Context, input UpdateCursorInput) error {
@@ -0,0 +1,43 @@
+package temporal
+
+import (
+	"encoding/json"
+
+	"go.fabra.io/server/common/errors"
+	"go.fabra.io/server/common/repositories/webhook_dead_letters"
+	"go.fabra.io/server/common/views"
+	"go.fabra.io/sync/connectors"
+	"gorm.io/gorm"
+)
+
+type webhookDeadLetterStore struct {
+	db *gorm.DB
+}
+
+func newWebhookDeadLetterStore(db *gorm.DB) connectors.WebhookDeadLetterStore {
+	return webhookDeadLetterStore{db: db}
+}
+
+func (s webhookDeadLetterStore) StoreDeadLetter(sync views.Sync, object views.Object, data []map[string]any, deliveryError connectors.WebhookDeliveryError) error {
+	marshalled, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "(temporal.webhookDeadLetterStore.StoreDeadLetter) marshalling data")
+	}
+
+	_, err = webhook_dead_letters.CreateWebhookDeadLetter(
+		s.db,
+		sync.OrganizationID,
+		sync.ID,
+		object.ID,
+		sync.EndCustomerID,
+		string(marshalled),
+		deliveryError.Err.Error(),
+		deliveryError.StatusCode,
+		deliveryError.Attempts,
+	)
+	if err != nil {
+		return errors.Wrap(err, "(temporal.webhookDeadLetterStore.StoreDeadLetter)")
+	}
+
+	return nil
+}
@@ -38,7 +38,8 @@ var RECORD_OPTIONS = workflow.ActivityOptions{
 
 var REPLICATE_OPTIONS = workflow.ActivityOptions{
 	StartToCloseTimeout: time.Hour * 24,
-	HeartbeatTimeout:    time.Minute * 5,
+	// webhook retries can wait several minutes for a single request before any more rows are written
+	HeartbeatTimeout: time.Minute * 10,
 	RetryPolicy: &temporal.RetryPolicy{
 		InitialInterval:        time.Second,
 		BackoffCoefficient:     2.0,
@@ -88,7 +89,7 @@ func SyncWorkflow(ctx workflow.Context, input SyncInput) error {
 	}
 
 	var replicateOutput ReplicateOutput
-	replicateInput := ReplicateInput(syncConfig)
+	replicateInput := ReplicateInput{SyncConfig: syncConfig, SyncRunID: syncRun.ID}
 	err = workflow.ExecuteActivity(replicateCtx, a.Replicate, replicateInput).Get(replicateCtx, &replicateOutput)
 	if err != nil {
 		// Ignore the error returned here. It is logged by Temporal as the activity task
@@ -111,7 +112,7 @@ func SyncWorkflow(ctx workflow.Context, input SyncInput) error {
 		}
 	}
 
-	return recordSuccess(recordCtx, syncRun, replicateOutput.RowsWritten)
+	return recordSuccess(recordCtx, syncRun, replicateOutput)
 }
 
 func recordFailure(ctx workflow.Context, err error, syncRun models.SyncRun) error {
@@ -133,12 +134,13 @@ func recordFailure(ctx workflow.Context, err error, syncRun models.SyncRun) erro
 	}).Get(ctx, nil)
 }
 
-func recordSuccess(ctx workflow.Context, syncRun models.SyncRun, rowsWritten int) error {
+func recordSuccess(ctx workflow.Context, syncRun models.SyncRun, replicateOutput ReplicateOutput) error {
 	var a *Activities // Temporal handles calling the registered activity object
 	return workflow.ExecuteActivity(ctx, a.RecordStatus, RecordStatusInput{
-		UpdateType:  UpdateTypeComplete,
-		SyncRun:     syncRun,
-		NewStatus:   models.SyncRunStatusCompleted,
-		RowsWritten: rowsWritten,
+		UpdateType:       UpdateTypeComplete,
+		SyncRun:          syncRun,
+		NewStatus:        models.SyncRunStatusCompleted,
+		RowsWritten:      replicateOutput.RowsWritten,
+		RowsDeadLettered: replicateOutput.RowsDeadLettered,
 	}).Get(ctx, nil)
 }
@@ -0,0 +1,116 @@
+// Package webhooks contains helpers for receivers of Fabra webhooks. It only depends on the standard library so it
+// can be imported without pulling in the rest of the sync module.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SIGNATURE_HEADER = "Fabra-Signature"
+const SIGNATURE_VERSION = "v1"
+
+// Deprecated: receivers should verify SIGNATURE_HEADER instead. Until it is turned off, payloads also carry this
+// header with a hex HMAC-SHA256 of just the payload, signed with the current key, so receivers that verify it keep
+// working while they switch over.
+const LEGACY_SIGNATURE_HEADER = "X-Fabra-Signature"
+
+// Receivers should reject payloads signed longer ago than this to prevent replays
+const DEFAULT_TOLERANCE = 5 * time.Minute
+
+var (
+	ErrInvalidHeader    = errors.New("webhook signature header has an invalid format")
+	ErrNoSignature      = errors.New("webhook signature header has no v1 signature")
+	ErrTimestampExpired = errors.New("webhook signature timestamp is outside the tolerance")
+	ErrNoValidSignature = errors.New("webhook signature does not match the payload")
+)
+
+// Signs "<timestamp>.<payload>" so a captured request cannot be replayed with a different timestamp
+func ComputeSignature(timestamp time.Time, payload []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Signs the payload the way LEGACY_SIGNATURE_HEADER has always been signed, without a timestamp
+func ComputeLegacySignature(payload []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Creates a header in the form "t=<timestamp>,v1=<signature>". While a signing key is being rotated, a signature is
+// included for every active key so receivers can verify with either.
+func CreateSignatureHeader(timestamp time.Time, payload []byte, secrets ...string) string {
+	parts := []string{fmt.Sprintf("t=%d", timestamp.Unix())}
+	for _, secret := range secrets {
+		parts = append(parts, fmt.Sprintf("%s=%s", SIGNATURE_VERSION, ComputeSignature(timestamp, payload, secret)))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Checks that the header contains a valid signature of the payload for the secret and that it was created within the
+// tolerance. The payload must be the raw request body, before any JSON decoding. A tolerance of 0 disables the
+// timestamp check.
+func VerifySignature(payload []byte, header string, secret string, tolerance time.Duration) error {
+	timestamp, signatures, err := parseSignatureHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if tolerance > 0 && time.Since(*timestamp).Abs() > tolerance {
+		return ErrTimestampExpired
+	}
+
+	expected := []byte(ComputeSignature(*timestamp, payload, secret))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+
+	return ErrNoValidSignature
+}
+
+func parseSignatureHeader(header string) (*time.Time, []string, error) {
+	var timestamp *time.Time
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, nil, ErrInvalidHeader
+		}
+
+		switch key {
+		case "t":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, nil, ErrInvalidHeader
+			}
+			parsed := time.Unix(seconds, 0)
+			timestamp = &parsed
+		case SIGNATURE_VERSION:
+			signatures = append(signatures, value)
+		}
+		// ignore unknown schemes so new signature versions can be added without breaking receivers
+	}
+
+	if timestamp == nil {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	if len(signatures) == 0 {
+		return nil, nil, ErrNoSignature
+	}
+
+	return timestamp, signatures, nil
+}
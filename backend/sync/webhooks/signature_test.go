@@ -0,0 +1,64 @@
+package webhooks_test
+
+import (
+	"fmt"
+	"time"
+
+	"go.fabra.io/sync/webhooks"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifySignature", func() {
+	payload := []byte(`{"object_id":1,"data":[{"id":1}]}`)
+	secret := "signing-key"
+
+	It("accepts a signature created with the secret", func() {
+		header := webhooks.CreateSignatureHeader(time.Now(), payload, secret)
+		Expect(webhooks.VerifySignature(payload, header, secret, webhooks.DEFAULT_TOLERANCE)).To(Succeed())
+	})
+
+	It("accepts a header signed with the previous and current keys during rotation", func() {
+		header := webhooks.CreateSignatureHeader(time.Now(), payload, "new-key", secret)
+		Expect(webhooks.VerifySignature(payload, header, secret, webhooks.DEFAULT_TOLERANCE)).To(Succeed())
+		Expect(webhooks.VerifySignature(payload, header, "new-key", webhooks.DEFAULT_TOLERANCE)).To(Succeed())
+	})
+
+	It("rejects a modified payload", func() {
+		header := webhooks.CreateSignatureHeader(time.Now(), payload, secret)
+		modified := []byte(`{"object_id":1,"data":[{"id":2}]}`)
+		Expect(webhooks.VerifySignature(modified, header, secret, webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrNoValidSignature))
+	})
+
+	It("rejects the wrong secret", func() {
+		header := webhooks.CreateSignatureHeader(time.Now(), payload, secret)
+		Expect(webhooks.VerifySignature(payload, header, "other-key", webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrNoValidSignature))
+	})
+
+	It("rejects a replayed timestamp", func() {
+		header := webhooks.CreateSignatureHeader(time.Now().Add(-10*time.Minute), payload, secret)
+		Expect(webhooks.VerifySignature(payload, header, secret, webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrTimestampExpired))
+	})
+
+	It("rejects a signature moved to a different timestamp", func() {
+		signedAt := time.Now()
+		signature := webhooks.ComputeSignature(signedAt, payload, secret)
+		header := fmt.Sprintf("t=%d,v1=%s", signedAt.Add(time.Minute).Unix(), signature)
+		Expect(webhooks.VerifySignature(payload, header, secret, webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrNoValidSignature))
+	})
+
+	It("rejects malformed headers", func() {
+		signature := webhooks.ComputeSignature(time.Now(), payload, secret)
+		Expect(webhooks.VerifySignature(payload, signature, secret, webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrInvalidHeader))
+		Expect(webhooks.VerifySignature(payload, "v1="+signature, secret, webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrInvalidHeader))
+		Expect(webhooks.VerifySignature(payload, fmt.Sprintf("t=%d", time.Now().Unix()), secret, webhooks.DEFAULT_TOLERANCE)).To(MatchError(webhooks.ErrNoSignature))
+	})
+})
+
+var _ = Describe("ComputeLegacySignature", func() {
+	It("signs just the payload", func() {
+		signature := webhooks.ComputeLegacySignature([]byte("The quick brown fox jumps over the lazy dog"), "key")
+		Expect(signature).To(Equal("f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"))
+	})
+})
@@ -12,6 +12,7 @@ export enum SyncMode {
   FullOverwrite = "full_overwrite",
   IncrementalAppend = "incremental_append",
   IncrementalUpdate = "incremental_update",
+  ChangeDataCapture = "change_data_capture",
 }
 
 export enum TargetType {
@@ -827,6 +828,8 @@ export const needsCursorField = (syncMode: SyncMode): boolean => {
       return true;
     case SyncMode.IncrementalUpdate:
       return true;
+    case SyncMode.ChangeDataCapture:
+      return false;
   }
 };
 
@@ -838,6 +841,8 @@ export const needsPrimaryKey = (syncMode: SyncMode): boolean => {
       return false;
     case SyncMode.IncrementalUpdate:
       return true;
+    case SyncMode.ChangeDataCapture:
+      return true;
   }
 };
 
@@ -885,6 +890,8 @@ export function syncModeToString(syncMode: SyncMode) {
       return "Incremental Append";
     case SyncMode.IncrementalUpdate:
       return "Incremental Update";
+    case SyncMode.ChangeDataCapture:
+      return "Change Data Capture";
   }
 }
 